@@ -0,0 +1,5 @@
+// Package taxiv1 holds the protobuf messages and gRPC stubs of the location
+// and matching services, generated from the .proto files in this directory.
+package taxiv1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative taxi/v1/location.proto taxi/v1/matching.proto
@@ -0,0 +1,243 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: taxi/v1/location.proto
+
+package taxiv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	LocationService_UpdateLocation_FullMethodName  = "/taxi.v1.LocationService/UpdateLocation"
+	LocationService_StreamLocations_FullMethodName = "/taxi.v1.LocationService/StreamLocations"
+	LocationService_FindNearby_FullMethodName      = "/taxi.v1.LocationService/FindNearby"
+)
+
+// LocationServiceClient is the client API for LocationService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// LocationService records driver locations and finds drivers near a point.
+// Calls authenticate with a bearer JWT in the "authorization" metadata or an
+// API key in "x-api-key".
+type LocationServiceClient interface {
+	// UpdateLocation saves the location of a driver. API keys need the
+	// locations:write scope.
+	UpdateLocation(ctx context.Context, in *UpdateLocationRequest, opts ...grpc.CallOption) (*UpdateLocationResponse, error)
+	// StreamLocations saves the locations sent by the client in batches and
+	// answers with the number saved once the client closes the stream. API
+	// keys need the locations:write scope.
+	StreamLocations(ctx context.Context, opts ...grpc.CallOption) (LocationService_StreamLocationsClient, error)
+	// FindNearby finds the drivers within a radius of a point. API keys need
+	// the locations:read scope.
+	FindNearby(ctx context.Context, in *FindNearbyRequest, opts ...grpc.CallOption) (*FindNearbyResponse, error)
+}
+
+type locationServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLocationServiceClient(cc grpc.ClientConnInterface) LocationServiceClient {
+	return &locationServiceClient{cc}
+}
+
+func (c *locationServiceClient) UpdateLocation(ctx context.Context, in *UpdateLocationRequest, opts ...grpc.CallOption) (*UpdateLocationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateLocationResponse)
+	err := c.cc.Invoke(ctx, LocationService_UpdateLocation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *locationServiceClient) StreamLocations(ctx context.Context, opts ...grpc.CallOption) (LocationService_StreamLocationsClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LocationService_ServiceDesc.Streams[0], LocationService_StreamLocations_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &locationServiceStreamLocationsClient{ClientStream: stream}
+	return x, nil
+}
+
+type LocationService_StreamLocationsClient interface {
+	Send(*UpdateLocationRequest) error
+	CloseAndRecv() (*StreamLocationsResponse, error)
+	grpc.ClientStream
+}
+
+type locationServiceStreamLocationsClient struct {
+	grpc.ClientStream
+}
+
+func (x *locationServiceStreamLocationsClient) Send(m *UpdateLocationRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *locationServiceStreamLocationsClient) CloseAndRecv() (*StreamLocationsResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(StreamLocationsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *locationServiceClient) FindNearby(ctx context.Context, in *FindNearbyRequest, opts ...grpc.CallOption) (*FindNearbyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FindNearbyResponse)
+	err := c.cc.Invoke(ctx, LocationService_FindNearby_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LocationServiceServer is the server API for LocationService service.
+// All implementations must embed UnimplementedLocationServiceServer
+// for forward compatibility
+//
+// LocationService records driver locations and finds drivers near a point.
+// Calls authenticate with a bearer JWT in the "authorization" metadata or an
+// API key in "x-api-key".
+type LocationServiceServer interface {
+	// UpdateLocation saves the location of a driver. API keys need the
+	// locations:write scope.
+	UpdateLocation(context.Context, *UpdateLocationRequest) (*UpdateLocationResponse, error)
+	// StreamLocations saves the locations sent by the client in batches and
+	// answers with the number saved once the client closes the stream. API
+	// keys need the locations:write scope.
+	StreamLocations(LocationService_StreamLocationsServer) error
+	// FindNearby finds the drivers within a radius of a point. API keys need
+	// the locations:read scope.
+	FindNearby(context.Context, *FindNearbyRequest) (*FindNearbyResponse, error)
+	mustEmbedUnimplementedLocationServiceServer()
+}
+
+// UnimplementedLocationServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedLocationServiceServer struct {
+}
+
+func (UnimplementedLocationServiceServer) UpdateLocation(context.Context, *UpdateLocationRequest) (*UpdateLocationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateLocation not implemented")
+}
+func (UnimplementedLocationServiceServer) StreamLocations(LocationService_StreamLocationsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamLocations not implemented")
+}
+func (UnimplementedLocationServiceServer) FindNearby(context.Context, *FindNearbyRequest) (*FindNearbyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindNearby not implemented")
+}
+func (UnimplementedLocationServiceServer) mustEmbedUnimplementedLocationServiceServer() {}
+
+// UnsafeLocationServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LocationServiceServer will
+// result in compilation errors.
+type UnsafeLocationServiceServer interface {
+	mustEmbedUnimplementedLocationServiceServer()
+}
+
+func RegisterLocationServiceServer(s grpc.ServiceRegistrar, srv LocationServiceServer) {
+	s.RegisterService(&LocationService_ServiceDesc, srv)
+}
+
+func _LocationService_UpdateLocation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateLocationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LocationServiceServer).UpdateLocation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LocationService_UpdateLocation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LocationServiceServer).UpdateLocation(ctx, req.(*UpdateLocationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LocationService_StreamLocations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LocationServiceServer).StreamLocations(&locationServiceStreamLocationsServer{ServerStream: stream})
+}
+
+type LocationService_StreamLocationsServer interface {
+	SendAndClose(*StreamLocationsResponse) error
+	Recv() (*UpdateLocationRequest, error)
+	grpc.ServerStream
+}
+
+type locationServiceStreamLocationsServer struct {
+	grpc.ServerStream
+}
+
+func (x *locationServiceStreamLocationsServer) SendAndClose(m *StreamLocationsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *locationServiceStreamLocationsServer) Recv() (*UpdateLocationRequest, error) {
+	m := new(UpdateLocationRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _LocationService_FindNearby_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindNearbyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LocationServiceServer).FindNearby(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LocationService_FindNearby_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LocationServiceServer).FindNearby(ctx, req.(*FindNearbyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// LocationService_ServiceDesc is the grpc.ServiceDesc for LocationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var LocationService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "taxi.v1.LocationService",
+	HandlerType: (*LocationServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "UpdateLocation",
+			Handler:    _LocationService_UpdateLocation_Handler,
+		},
+		{
+			MethodName: "FindNearby",
+			Handler:    _LocationService_FindNearby_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamLocations",
+			Handler:       _LocationService_StreamLocations_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "taxi/v1/location.proto",
+}
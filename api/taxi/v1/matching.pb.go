@@ -0,0 +1,254 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: taxi/v1/matching.proto
+
+package taxiv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type FindNearestRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Latitude  float64 `protobuf:"fixed64,1,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude float64 `protobuf:"fixed64,2,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	// Search radius in meters
+	Radius float64 `protobuf:"fixed64,3,opt,name=radius,proto3" json:"radius,omitempty"`
+}
+
+func (x *FindNearestRequest) Reset() {
+	*x = FindNearestRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_taxi_v1_matching_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FindNearestRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FindNearestRequest) ProtoMessage() {}
+
+func (x *FindNearestRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_taxi_v1_matching_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FindNearestRequest.ProtoReflect.Descriptor instead.
+func (*FindNearestRequest) Descriptor() ([]byte, []int) {
+	return file_taxi_v1_matching_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *FindNearestRequest) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *FindNearestRequest) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+func (x *FindNearestRequest) GetRadius() float64 {
+	if x != nil {
+		return x.Radius
+	}
+	return 0
+}
+
+type FindNearestResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Driver *DriverLocation `protobuf:"bytes,1,opt,name=driver,proto3" json:"driver,omitempty"`
+	// Distance to the driver in kilometers
+	Distance float64 `protobuf:"fixed64,2,opt,name=distance,proto3" json:"distance,omitempty"`
+}
+
+func (x *FindNearestResponse) Reset() {
+	*x = FindNearestResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_taxi_v1_matching_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FindNearestResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FindNearestResponse) ProtoMessage() {}
+
+func (x *FindNearestResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_taxi_v1_matching_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FindNearestResponse.ProtoReflect.Descriptor instead.
+func (*FindNearestResponse) Descriptor() ([]byte, []int) {
+	return file_taxi_v1_matching_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *FindNearestResponse) GetDriver() *DriverLocation {
+	if x != nil {
+		return x.Driver
+	}
+	return nil
+}
+
+func (x *FindNearestResponse) GetDistance() float64 {
+	if x != nil {
+		return x.Distance
+	}
+	return 0
+}
+
+var File_taxi_v1_matching_proto protoreflect.FileDescriptor
+
+var file_taxi_v1_matching_proto_rawDesc = []byte{
+	0x0a, 0x16, 0x74, 0x61, 0x78, 0x69, 0x2f, 0x76, 0x31, 0x2f, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x69,
+	0x6e, 0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x07, 0x74, 0x61, 0x78, 0x69, 0x2e, 0x76,
+	0x31, 0x1a, 0x16, 0x74, 0x61, 0x78, 0x69, 0x2f, 0x76, 0x31, 0x2f, 0x6c, 0x6f, 0x63, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x66, 0x0a, 0x12, 0x46, 0x69, 0x6e,
+	0x64, 0x4e, 0x65, 0x61, 0x72, 0x65, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x1a, 0x0a, 0x08, 0x6c, 0x61, 0x74, 0x69, 0x74, 0x75, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x01, 0x52, 0x08, 0x6c, 0x61, 0x74, 0x69, 0x74, 0x75, 0x64, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6c,
+	0x6f, 0x6e, 0x67, 0x69, 0x74, 0x75, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x09,
+	0x6c, 0x6f, 0x6e, 0x67, 0x69, 0x74, 0x75, 0x64, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x61, 0x64,
+	0x69, 0x75, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x06, 0x72, 0x61, 0x64, 0x69, 0x75,
+	0x73, 0x22, 0x62, 0x0a, 0x13, 0x46, 0x69, 0x6e, 0x64, 0x4e, 0x65, 0x61, 0x72, 0x65, 0x73, 0x74,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2f, 0x0a, 0x06, 0x64, 0x72, 0x69, 0x76,
+	0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x74, 0x61, 0x78, 0x69, 0x2e,
+	0x76, 0x31, 0x2e, 0x44, 0x72, 0x69, 0x76, 0x65, 0x72, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x52, 0x06, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x69, 0x73,
+	0x74, 0x61, 0x6e, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x08, 0x64, 0x69, 0x73,
+	0x74, 0x61, 0x6e, 0x63, 0x65, 0x32, 0x5b, 0x0a, 0x0f, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x69, 0x6e,
+	0x67, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x48, 0x0a, 0x0b, 0x46, 0x69, 0x6e, 0x64,
+	0x4e, 0x65, 0x61, 0x72, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x2e, 0x74, 0x61, 0x78, 0x69, 0x2e, 0x76,
+	0x31, 0x2e, 0x46, 0x69, 0x6e, 0x64, 0x4e, 0x65, 0x61, 0x72, 0x65, 0x73, 0x74, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c, 0x2e, 0x74, 0x61, 0x78, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x46,
+	0x69, 0x6e, 0x64, 0x4e, 0x65, 0x61, 0x72, 0x65, 0x73, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x42, 0x3c, 0x5a, 0x3a, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d,
+	0x2f, 0x79, 0x75, 0x73, 0x75, 0x66, 0x61, 0x74, 0x61, 0x63, 0x2f, 0x62, 0x69, 0x74, 0x61, 0x6b,
+	0x73, 0x69, 0x2d, 0x63, 0x61, 0x73, 0x65, 0x2d, 0x73, 0x74, 0x75, 0x64, 0x79, 0x2f, 0x61, 0x70,
+	0x69, 0x2f, 0x74, 0x61, 0x78, 0x69, 0x2f, 0x76, 0x31, 0x3b, 0x74, 0x61, 0x78, 0x69, 0x76, 0x31,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_taxi_v1_matching_proto_rawDescOnce sync.Once
+	file_taxi_v1_matching_proto_rawDescData = file_taxi_v1_matching_proto_rawDesc
+)
+
+func file_taxi_v1_matching_proto_rawDescGZIP() []byte {
+	file_taxi_v1_matching_proto_rawDescOnce.Do(func() {
+		file_taxi_v1_matching_proto_rawDescData = protoimpl.X.CompressGZIP(file_taxi_v1_matching_proto_rawDescData)
+	})
+	return file_taxi_v1_matching_proto_rawDescData
+}
+
+var file_taxi_v1_matching_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_taxi_v1_matching_proto_goTypes = []any{
+	(*FindNearestRequest)(nil),  // 0: taxi.v1.FindNearestRequest
+	(*FindNearestResponse)(nil), // 1: taxi.v1.FindNearestResponse
+	(*DriverLocation)(nil),      // 2: taxi.v1.DriverLocation
+}
+var file_taxi_v1_matching_proto_depIdxs = []int32{
+	2, // 0: taxi.v1.FindNearestResponse.driver:type_name -> taxi.v1.DriverLocation
+	0, // 1: taxi.v1.MatchingService.FindNearest:input_type -> taxi.v1.FindNearestRequest
+	1, // 2: taxi.v1.MatchingService.FindNearest:output_type -> taxi.v1.FindNearestResponse
+	2, // [2:3] is the sub-list for method output_type
+	1, // [1:2] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_taxi_v1_matching_proto_init() }
+func file_taxi_v1_matching_proto_init() {
+	if File_taxi_v1_matching_proto != nil {
+		return
+	}
+	file_taxi_v1_location_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_taxi_v1_matching_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*FindNearestRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_taxi_v1_matching_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*FindNearestResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_taxi_v1_matching_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_taxi_v1_matching_proto_goTypes,
+		DependencyIndexes: file_taxi_v1_matching_proto_depIdxs,
+		MessageInfos:      file_taxi_v1_matching_proto_msgTypes,
+	}.Build()
+	File_taxi_v1_matching_proto = out.File
+	file_taxi_v1_matching_proto_rawDesc = nil
+	file_taxi_v1_matching_proto_goTypes = nil
+	file_taxi_v1_matching_proto_depIdxs = nil
+}
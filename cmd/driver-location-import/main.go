@@ -21,6 +21,12 @@ type LoginResponse struct {
 	Token string `json:"token"`
 }
 
+// credentials holds the header used to authenticate batch requests
+type credentials struct {
+	header string
+	value  string
+}
+
 func login(username, password string) (string, error) {
 	url := "http://localhost:8080/api/v1/auth/login"
 	reqBody, _ := json.Marshal(map[string]string{
@@ -85,7 +91,7 @@ func readCSV(filePath string, locationsChan chan<- domain.DriverLocation, wg *sy
 	close(locationsChan)
 }
 
-func updateDriverLocations(token string, locationsChan <-chan domain.DriverLocation, wg *sync.WaitGroup) {
+func updateDriverLocations(creds credentials, locationsChan <-chan domain.DriverLocation, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	client := &http.Client{}
@@ -96,7 +102,7 @@ func updateDriverLocations(token string, locationsChan <-chan domain.DriverLocat
 	for location := range locationsChan {
 		batch = append(batch, location)
 		if len(batch) >= batchSize {
-			if err := sendBatch(client, url, token, batch); err != nil {
+			if err := sendBatch(client, url, creds, batch); err != nil {
 				log.Fatalf("Failed to update locations: %v", err)
 			}
 			batch = batch[:0]
@@ -104,13 +110,13 @@ func updateDriverLocations(token string, locationsChan <-chan domain.DriverLocat
 	}
 
 	if len(batch) > 0 {
-		if err := sendBatch(client, url, token, batch); err != nil {
+		if err := sendBatch(client, url, creds, batch); err != nil {
 			log.Fatalf("Failed to update locations: %v", err)
 		}
 	}
 }
 
-func sendBatch(client *http.Client, url, token string, batch []domain.DriverLocation) error {
+func sendBatch(client *http.Client, url string, creds credentials, batch []domain.DriverLocation) error {
 	reqBody, _ := json.Marshal(batch)
 
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
@@ -118,7 +124,7 @@ func sendBatch(client *http.Client, url, token string, batch []domain.DriverLoca
 		return fmt.Errorf("failed to create request: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(creds.header, creds.value)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -134,10 +140,14 @@ func sendBatch(client *http.Client, url, token string, batch []domain.DriverLoca
 }
 
 func main() {
-	// Login to get JWT token
-	token, err := login("yusuf", "secret")
-	if err != nil {
-		log.Fatalf("Failed to login: %v", err)
+	// Prefer an API key, fall back to logging in to get a JWT token
+	creds := credentials{header: "X-API-Key", value: os.Getenv("DRIVER_LOCATION_API_KEY")}
+	if creds.value == "" {
+		token, err := login("yusuf", "secret")
+		if err != nil {
+			log.Fatalf("Failed to login: %v", err)
+		}
+		creds = credentials{header: "Authorization", value: "Bearer " + token}
 	}
 
 	locationsChan := make(chan domain.DriverLocation, 100)
@@ -149,7 +159,7 @@ func main() {
 
 	// Update driver locations
 	wg.Add(1)
-	go updateDriverLocations(token, locationsChan, &wg)
+	go updateDriverLocations(creds, locationsChan, &wg)
 
 	wg.Wait()
 	log.Println("Driver locations updated successfully")
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,71 +14,299 @@ import (
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc"
 
+	taxiv1 "github.com/yusufatac/bitaksi-case-study/api/taxi/v1"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/config"
+	"github.com/yusufatac/bitaksi-case-study/internal/grpcserver"
 	"github.com/yusufatac/bitaksi-case-study/internal/handler"
+	handlerv2 "github.com/yusufatac/bitaksi-case-study/internal/handler/v2"
+	"github.com/yusufatac/bitaksi-case-study/internal/logging"
+	"github.com/yusufatac/bitaksi-case-study/internal/metrics"
 	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+	"github.com/yusufatac/bitaksi-case-study/internal/mqttingest"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository/memory"
 	"github.com/yusufatac/bitaksi-case-study/internal/repository/mongodb"
 	"github.com/yusufatac/bitaksi-case-study/internal/router"
 	"github.com/yusufatac/bitaksi-case-study/internal/service"
+	"github.com/yusufatac/bitaksi-case-study/internal/stream"
+	"github.com/yusufatac/bitaksi-case-study/internal/tracing"
+	"github.com/yusufatac/bitaksi-case-study/internal/tracking"
+	"github.com/yusufatac/bitaksi-case-study/internal/udpingest"
+	"github.com/yusufatac/bitaksi-case-study/internal/webhook"
 )
 
 func main() {
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: .env file not found")
+	envErr := godotenv.Load()
+
+	// Structured logging, also used by the standard logger
+	logger := logging.New(logging.Config{
+		Level:  config.String("LOG_LEVEL", "info"),
+		Format: config.String("LOG_FORMAT", logging.FormatJSON),
+	}, os.Stdout).With("service", "driver-location")
+	slog.SetDefault(logger)
+	if envErr != nil {
+		logger.Warn(".env file not found")
+	}
+
+	// Tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: "driver-location",
+		Exporter:    config.String("TRACING_EXPORTER", tracing.ExporterNone),
+		FilePath:    config.String("TRACING_FILE", "traces.json"),
+		SampleRatio: config.Float("TRACING_SAMPLE_RATIO", 1),
+	})
+	if err != nil {
+		fatal("Failed to set up tracing", err)
 	}
 
+	// Metrics
+	appMetrics := metrics.New()
+
 	// MongoDB connection
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	mongoURI := getEnv("MONGODB_URI", "mongodb://localhost:27017")
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
+	mongoURI := config.String("MONGODB_URI", "mongodb://localhost:27017")
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI).SetMonitor(appMetrics.CommandMonitor()))
 	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
+		fatal("Failed to connect to MongoDB", err)
 	}
 	defer client.Disconnect(ctx)
 
 	// Ping MongoDB
 	if err := client.Ping(ctx, nil); err != nil {
-		log.Fatalf("Failed to ping MongoDB: %v", err)
+		fatal("Failed to ping MongoDB", err)
 	}
 
-	db := client.Database(getEnv("MONGODB_DATABASE", "bitaksi"))
+	db := client.Database(config.String("MONGODB_DATABASE", "bitaksi"))
 
 	// Initialize repositories
 	locationRepo := mongodb.NewLocationRepository(db)
 	userRepo := mongodb.NewUserRepository(db)
+	apiKeyRepo := mongodb.NewAPIKeyRepository(db)
+	loginAttemptRepo := memory.NewLoginAttemptRepository()
+	if config.String("LOGIN_ATTEMPT_STORE", "mongo") == "mongo" {
+		loginAttemptRepo = mongodb.NewLoginAttemptRepository(db)
+	}
+	userTokenRepo := mongodb.NewUserTokenRepository(db)
+	rideRepo := mongodb.NewRideRepository(db)
+	webhookRepo := mongodb.NewWebhookRepository(db)
+	idempotencyRepo := memory.NewIdempotencyRepository()
+	if config.String("IDEMPOTENCY_STORE", "mongo") == "mongo" {
+		idempotencyRepo = mongodb.NewIdempotencyRepository(db)
+	}
+
+	// Saved locations are pushed to the riders tracking their driver
+	trackingHub := tracking.NewHub()
+
+	// Ride events are sent to the webhooks subscribed to them
+	webhookConfig := newWebhookConfig()
+	webhookDispatcher := webhook.NewDispatcher(
+		webhookRepo,
+		webhookConfig,
+		webhook.WithMetrics(appMetrics),
+		webhook.WithLogger(logger.With("component", "webhook_dispatcher")),
+	)
+	webhookDispatcher.Start()
 
 	// Initialize services
-	locationService := service.NewLocationService(locationRepo)
-	matchingService := service.NewMatchingService(locationService)
-	authService := service.NewAuthService(userRepo, getEnv("JWT_SECRET", "your-secret-key"))
+	locationService := service.NewLocationService(
+		locationRepo,
+		service.WithLocationPublisher(trackingHub),
+		service.WithLocationLogger(logger.With("component", "location_service")),
+		service.WithLocationMetrics(appMetrics),
+		service.WithActiveDriverWindow(config.Duration("ACTIVE_DRIVER_WINDOW", 5*time.Minute)),
+	)
+	lockoutPolicy := service.DefaultLockoutPolicy()
+	lockoutPolicy.MaxFailures = config.Int("LOGIN_MAX_FAILURES", lockoutPolicy.MaxFailures)
+	lockoutPolicy.MaxIPFailures = config.Int("LOGIN_MAX_IP_FAILURES", lockoutPolicy.MaxIPFailures)
+	lockoutPolicy.Cooldown = config.Duration("LOGIN_LOCKOUT_COOLDOWN", lockoutPolicy.Cooldown)
+	appMailer, err := config.NewMailer()
+	if err != nil {
+		fatal("Failed to open mail log file", err)
+	}
+	authService := service.NewAuthService(
+		userRepo,
+		config.String("JWT_SECRET", "your-secret-key"),
+		service.WithLoginAttemptRepository(loginAttemptRepo),
+		service.WithLockoutPolicy(lockoutPolicy),
+		service.WithUserTokenRepository(userTokenRepo),
+		service.WithMailer(appMailer),
+		service.WithAppBaseURL(config.String("APP_BASE_URL", "http://localhost:8080")),
+		service.WithAuthLogger(logger.With("component", "auth_service")),
+	)
+	matchTokens := service.NewMatchTokens(
+		config.String("JWT_SECRET", "your-secret-key"),
+		config.Duration("MATCH_TOKEN_TTL", service.DefaultMatchTokenTTL),
+	)
+	apiKeyService := service.NewAPIKeyService(
+		apiKeyRepo,
+		service.WithAPIKeyLogger(logger.With("component", "api_key_service")),
+	)
+	userService := service.NewUserService(
+		userRepo,
+		authService,
+		service.WithUserLogger(logger.With("component", "user_service")),
+	)
+	rideService := service.NewRideService(
+		rideRepo,
+		matchTokens,
+		service.WithUsedMatchTokens(userTokenRepo),
+		service.WithMaxRideDuration(config.Duration("RIDE_MAX_DURATION", service.DefaultMaxRideDuration)),
+		service.WithRideNotifier(trackingHub),
+		service.WithRideEvents(webhookDispatcher),
+		service.WithRideLogger(logger.With("component", "ride_service")),
+	)
+	webhookService := service.NewWebhookService(
+		webhookRepo,
+		service.WithWebhookLogger(logger.With("component", "webhook_service")),
+		service.WithPrivateWebhookURLs(webhookConfig.AllowPrivateAddresses),
+	)
 
 	// Initialize handlers
 	locationHandler := handler.NewLocationHandler(locationService)
-	matchingHandler := handler.NewMatchingHandler(matchingService)
 	authHandler := handler.NewAuthHandler(authService)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+	userHandler := handler.NewUserHandler(userService)
+	driverStreamHandler := handler.NewDriverStreamHandler(
+		locationService,
+		newStreamConfig(),
+		logger.With("component", "driver_stream"),
+	)
+	rideHandler := handler.NewRideHandler(rideService, trackingHub, config.Duration("RIDE_TRACK_INTERVAL", time.Second))
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+	v2Handlers := router.V2Handlers{
+		Location: handlerv2.NewLocationHandler(locationService),
+		Ride:     handlerv2.NewRideHandler(rideService),
+	}
 
 	// Initialize middleware
-	authMiddleware := middleware.NewAuthMiddleware(getEnv("JWT_SECRET", "your-secret-key"))
+	authMiddleware := middleware.NewAuthMiddleware(config.String("JWT_SECRET", "your-secret-key"), apiKeyService, middleware.WithUserLookup(userService))
+	rateLimiter := config.NewRateLimiter(map[string]middleware.RateLimit{
+		router.RateLimitGroupAuth:      {Rate: 1, Burst: 10},
+		router.RateLimitGroupLocations: {Rate: 10, Burst: 20},
+		router.RateLimitGroupMatch:     {Rate: 5, Burst: 10},
+		router.RateLimitGroupUsers:     {Rate: 5, Burst: 10},
+		router.RateLimitGroupAdmin:     {Rate: 10, Burst: 20},
+		router.RateLimitGroupRides:     {Rate: 5, Burst: 10},
+	})
+	cors, err := config.NewCORS()
+	if err != nil {
+		fatal("Invalid CORS configuration", err)
+	}
+	idempotency := middleware.NewIdempotency(
+		idempotencyRepo,
+		middleware.WithIdempotencyTTL(config.Duration("IDEMPOTENCY_TTL", 24*time.Hour)),
+	)
+
+	// Metrics read on every scrape
+	appMetrics.RegisterActiveDrivers(locationService.CountActiveDrivers)
 
 	// Initialize router
-	r := router.NewRouter(
-		authMiddleware,
-		locationHandler,
-		matchingHandler,
-		authHandler,
-	)
+	r, err := router.NewRouter(router.Options{
+		Logger:  logger,
+		Metrics: appMetrics,
+		Limits: router.Limits{
+			MaxBodyBytes:      int64(config.Int("HTTP_MAX_BODY_BYTES", 64<<10)),
+			MaxBatchBodyBytes: int64(config.Int("HTTP_MAX_BATCH_BODY_BYTES", 4<<20)),
+			RequestTimeout:    config.Duration("HTTP_REQUEST_TIMEOUT", 10*time.Second),
+		},
+		TrustedProxies: config.List("TRUSTED_PROXIES", nil),
+		CORS:           cors,
+		Auth:           authMiddleware,
+		RateLimiter:    rateLimiter,
+		Idempotency:    idempotency,
+		Handlers: router.Handlers{
+			Location:     locationHandler,
+			Auth:         authHandler,
+			APIKey:       apiKeyHandler,
+			User:         userHandler,
+			DriverStream: driverStreamHandler,
+			Ride:         rideHandler,
+			Webhook:      webhookHandler,
+		},
+		V2: v2Handlers,
+	})
+	if err != nil {
+		fatal("Invalid TRUSTED_PROXIES", err)
+	}
 
 	// Setup routes
 	r.SetupDriverLocationRoutes()
 
 	// Start server with graceful shutdown
-	port := getEnv("PORT", "8080")
+	port := config.String("PORT", "8080")
 	srv := &http.Server{
-		Addr:    ":" + port,
-		Handler: r.Engine,
+		Addr:              ":" + port,
+		Handler:           middleware.ResponseControllers(r.Engine),
+		ReadHeaderTimeout: config.Duration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		ReadTimeout:       config.Duration("HTTP_READ_TIMEOUT", 15*time.Second),
+		WriteTimeout:      config.Duration("HTTP_WRITE_TIMEOUT", 30*time.Second),
+		IdleTimeout:       config.Duration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+		MaxHeaderBytes:    config.Int("HTTP_MAX_HEADER_BYTES", 1<<20),
+	}
+
+	// The gRPC API shares the services and credentials of the REST API
+	var grpcServer *grpc.Server
+	if grpcPort := config.String("GRPC_PORT", "9090"); grpcPort != "" {
+		grpcServer = grpcserver.New(
+			logger.With("component", "grpc"),
+			grpcserver.NewAuth(authService, apiKeyService, grpcserver.LocationScopes),
+		)
+		taxiv1.RegisterLocationServiceServer(grpcServer, grpcserver.NewLocationServer(
+			locationService,
+			grpcserver.WithStreamBatchSize(config.Int("GRPC_STREAM_BATCH_SIZE", 100)),
+		))
+		serveGRPC(grpcServer, grpcPort)
+		logger.Info("Driver Location gRPC server starting", "port", grpcPort)
+	}
+
+	// Trackers that can only send UDP report through an optional listener
+	udpCtx, stopUDP := context.WithCancel(context.Background())
+	defer stopUDP()
+	udpDone := make(chan struct{})
+	if udpAddr := config.String("UDP_ADDR", ""); udpAddr != "" {
+		udpServer := udpingest.NewServer(
+			locationService,
+			loadUDPDevices(),
+			newUDPConfig(),
+			udpingest.WithMetrics(appMetrics),
+			udpingest.WithLogger(logger.With("component", "udp_ingest")),
+		)
+		conn, err := net.ListenPacket("udp", udpAddr)
+		if err != nil {
+			fatal("Failed to listen for UDP", err)
+		}
+		go func() {
+			defer close(udpDone)
+			if err := udpServer.Serve(udpCtx, conn); err != nil {
+				logger.Error("UDP listener stopped", "error", err)
+			}
+		}()
+		logger.Info("Driver Location UDP listener starting", "addr", udpAddr)
+	} else {
+		close(udpDone)
+	}
+
+	// Telematics boxes publishing to MQTT report through an optional bridge
+	var mqttBridge *mqttingest.Bridge
+	if brokerURL := config.String("MQTT_BROKER_URL", ""); brokerURL != "" {
+		mqttBridge, err = mqttingest.NewBridge(
+			locationService,
+			newMQTTConfig(brokerURL),
+			mqttingest.WithMetrics(appMetrics),
+			mqttingest.WithLogger(logger.With("component", "mqtt_ingest")),
+		)
+		if err != nil {
+			fatal("Invalid MQTT configuration", err)
+		}
+		if err := mqttBridge.Start(); err != nil {
+			fatal("Failed to connect to MQTT broker", err)
+		}
+		logger.Info("Driver Location MQTT bridge started", "broker", brokerURL)
 	}
 
 	// Channel to listen for OS signals
@@ -85,27 +315,132 @@ func main() {
 
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			fatal("Failed to start server", err)
 		}
 	}()
-	log.Printf("Driver Location Service starting on port %s", port)
+	logger.Info("Driver Location Service starting", "port", port)
 
 	<-quit
-	log.Println("Shutting down server...")
+	logger.Info("Shutting down server")
+
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+	if mqttBridge != nil {
+		mqttBridge.Stop()
+	}
+	// Save the locations the UDP listener still buffers
+	stopUDP()
+	<-udpDone
 
 	// Create a deadline to wait for.
 	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		fatal("Server forced to shutdown", err)
+	}
+	// Stopped after the server, so events of the last requests are queued
+	webhookDispatcher.Stop()
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("Failed to flush traces", "error", err)
+	}
+
+	logger.Info("Server exiting")
+}
+
+// serveGRPC starts serving the gRPC server on port in the background
+func serveGRPC(server *grpc.Server, port string) {
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		fatal("Failed to listen for gRPC", err)
 	}
 
-	log.Println("Server exiting")
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			fatal("Failed to start gRPC server", err)
+		}
+	}()
+}
+
+// newStreamConfig reads the driver location streaming settings from
+// environment variables
+func newStreamConfig() stream.Config {
+	cfg := stream.DefaultConfig()
+	cfg.FlushInterval = config.Duration("STREAM_FLUSH_INTERVAL", cfg.FlushInterval)
+	cfg.PingInterval = config.Duration("STREAM_PING_INTERVAL", cfg.PingInterval)
+	cfg.PongWait = config.Duration("STREAM_PONG_WAIT", cfg.PongWait)
+	cfg.SendBuffer = config.Int("STREAM_SEND_BUFFER", cfg.SendBuffer)
+
+	return cfg
 }
 
-func getEnv(key, fallback string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+// newMQTTConfig reads the MQTT bridge settings from environment variables
+func newMQTTConfig(brokerURL string) mqttingest.Config {
+	cfg := mqttingest.DefaultConfig()
+	cfg.BrokerURL = brokerURL
+	// Instances need their own client ID, or the broker disconnects one
+	// when the next connects
+	if hostname, err := os.Hostname(); err == nil {
+		cfg.ClientID += "-" + hostname
 	}
-	return fallback
+	cfg.ClientID = config.String("MQTT_CLIENT_ID", cfg.ClientID)
+	cfg.Username = config.String("MQTT_USERNAME", "")
+	cfg.Password = config.String("MQTT_PASSWORD", "")
+	cfg.TopicPattern = config.String("MQTT_TOPIC_PATTERN", cfg.TopicPattern)
+	cfg.QoS = byte(config.Int("MQTT_QOS", int(cfg.QoS)))
+
+	return cfg
+}
+
+// newWebhookConfig reads the webhook dispatcher settings from environment
+// variables
+func newWebhookConfig() webhook.Config {
+	cfg := webhook.DefaultConfig()
+	cfg.Workers = config.Int("WEBHOOK_WORKERS", cfg.Workers)
+	cfg.QueueSize = config.Int("WEBHOOK_QUEUE_SIZE", cfg.QueueSize)
+	cfg.MaxAttempts = config.Int("WEBHOOK_MAX_ATTEMPTS", cfg.MaxAttempts)
+	cfg.BaseBackoff = config.Duration("WEBHOOK_BACKOFF", cfg.BaseBackoff)
+	cfg.MaxBackoff = config.Duration("WEBHOOK_MAX_BACKOFF", cfg.MaxBackoff)
+	cfg.Timeout = config.Duration("WEBHOOK_TIMEOUT", cfg.Timeout)
+	cfg.AllowPrivateAddresses = config.String("WEBHOOK_ALLOW_PRIVATE_ADDRESSES", "false") == "true"
+
+	return cfg
+}
+
+// newUDPConfig reads the UDP listener settings from environment variables
+func newUDPConfig() udpingest.Config {
+	cfg := udpingest.DefaultConfig()
+	cfg.FlushInterval = config.Duration("UDP_FLUSH_INTERVAL", cfg.FlushInterval)
+	cfg.BatchSize = config.Int("UDP_BATCH_SIZE", cfg.BatchSize)
+	cfg.MaxPending = config.Int("UDP_MAX_PENDING", cfg.MaxPending)
+	cfg.MaxClockSkew = config.Duration("UDP_MAX_CLOCK_SKEW", cfg.MaxClockSkew)
+	cfg.RateLimit.Rate = config.Float("UDP_RPS", cfg.RateLimit.Rate)
+	cfg.RateLimit.Burst = config.Int("UDP_BURST", cfg.RateLimit.Burst)
+
+	return cfg
+}
+
+// loadUDPDevices reads the devices allowed to send UDP datagrams from
+// UDP_DEVICES_FILE
+func loadUDPDevices() udpingest.DeviceStore {
+	path := config.String("UDP_DEVICES_FILE", "")
+	if path == "" {
+		fatal("Failed to load UDP devices", errors.New("UDP_DEVICES_FILE is required when UDP_ADDR is set"))
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		fatal("Failed to open UDP devices file", err)
+	}
+	defer file.Close()
+
+	devices, err := udpingest.LoadDevices(file)
+	if err != nil {
+		fatal("Failed to load UDP devices", err)
+	}
+	return udpingest.NewDeviceStore(devices)
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -2,7 +2,8 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,76 +13,224 @@ import (
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc"
 
+	taxiv1 "github.com/yusufatac/bitaksi-case-study/api/taxi/v1"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/config"
+	"github.com/yusufatac/bitaksi-case-study/internal/grpcserver"
 	"github.com/yusufatac/bitaksi-case-study/internal/handler"
+	handlerv2 "github.com/yusufatac/bitaksi-case-study/internal/handler/v2"
+	"github.com/yusufatac/bitaksi-case-study/internal/httpclient"
+	"github.com/yusufatac/bitaksi-case-study/internal/logging"
+	"github.com/yusufatac/bitaksi-case-study/internal/metrics"
 	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository/memory"
 	"github.com/yusufatac/bitaksi-case-study/internal/repository/mongodb"
 	"github.com/yusufatac/bitaksi-case-study/internal/router"
 	"github.com/yusufatac/bitaksi-case-study/internal/service"
+	"github.com/yusufatac/bitaksi-case-study/internal/tracing"
 )
 
 func main() {
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: .env file not found")
+	envErr := godotenv.Load()
+
+	// Structured logging, also used by the standard logger
+	logger := logging.New(logging.Config{
+		Level:  config.String("LOG_LEVEL", "info"),
+		Format: config.String("LOG_FORMAT", logging.FormatJSON),
+	}, os.Stdout).With("service", "matching-api")
+	slog.SetDefault(logger)
+	if envErr != nil {
+		logger.Warn(".env file not found")
+	}
+
+	// Tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: "matching-api",
+		Exporter:    config.String("TRACING_EXPORTER", tracing.ExporterNone),
+		FilePath:    config.String("TRACING_FILE", "traces.json"),
+		SampleRatio: config.Float("TRACING_SAMPLE_RATIO", 1),
+	})
+	if err != nil {
+		fatal("Failed to set up tracing", err)
 	}
 
+	// Metrics
+	appMetrics := metrics.New()
+
 	// MongoDB connection
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	mongoURI := getEnv("MONGODB_URI", "mongodb://localhost:27017")
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
+	mongoURI := config.String("MONGODB_URI", "mongodb://localhost:27017")
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI).SetMonitor(appMetrics.CommandMonitor()))
 	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
+		fatal("Failed to connect to MongoDB", err)
 	}
 	defer client.Disconnect(ctx)
 
 	// Ping MongoDB
 	if err := client.Ping(ctx, nil); err != nil {
-		log.Fatalf("Failed to ping MongoDB: %v", err)
+		fatal("Failed to ping MongoDB", err)
 	}
 
-	db := client.Database(getEnv("MONGODB_DATABASE", "bitaksi"))
+	db := client.Database(config.String("MONGODB_DATABASE", "bitaksi"))
 
 	// Initialize repositories
 	locationRepo := mongodb.NewLocationRepository(db)
 	userRepo := mongodb.NewUserRepository(db)
+	apiKeyRepo := mongodb.NewAPIKeyRepository(db)
+	loginAttemptRepo := memory.NewLoginAttemptRepository()
+	if config.String("LOGIN_ATTEMPT_STORE", "mongo") == "mongo" {
+		loginAttemptRepo = mongodb.NewLoginAttemptRepository(db)
+	}
+	userTokenRepo := mongodb.NewUserTokenRepository(db)
+	idempotencyRepo := memory.NewIdempotencyRepository()
+	if config.String("IDEMPOTENCY_STORE", "mongo") == "mongo" {
+		idempotencyRepo = mongodb.NewIdempotencyRepository(db)
+	}
 
 	// Initialize services
-	locationService := service.NewLocationService(locationRepo)
-	matchingService := service.NewMatchingService(locationService)
-	authService := service.NewAuthService(userRepo, getEnv("JWT_SECRET", "your-secret-key"))
+	locationService := service.NewLocationService(
+		locationRepo,
+		service.WithLocationLogger(logger.With("component", "location_service")),
+		service.WithLocationMetrics(appMetrics),
+	)
+	httpClient := httpclient.New(
+		httpclient.WithAttemptTimeout(config.Duration("OUTBOUND_ATTEMPT_TIMEOUT", 2*time.Second)),
+		httpclient.WithMaxRetries(config.Int("OUTBOUND_MAX_RETRIES", 2)),
+		httpclient.WithBreakerOptions(config.BreakerOptions("OUTBOUND_BREAKER")...),
+	)
+	matchingService := service.NewMatchingService(
+		locationService,
+		service.WithHTTPClient(httpClient),
+		service.WithLocationAPIURL(config.String("DRIVER_LOCATION_API_URL", "http://driver-location-api:8080")),
+		service.WithAPIKey(config.String("DRIVER_LOCATION_API_KEY", "")),
+		service.WithMatchingLogger(logger.With("component", "matching_service")),
+		service.WithMatchingMetrics(appMetrics),
+	)
+	lockoutPolicy := service.DefaultLockoutPolicy()
+	lockoutPolicy.MaxFailures = config.Int("LOGIN_MAX_FAILURES", lockoutPolicy.MaxFailures)
+	lockoutPolicy.MaxIPFailures = config.Int("LOGIN_MAX_IP_FAILURES", lockoutPolicy.MaxIPFailures)
+	lockoutPolicy.Cooldown = config.Duration("LOGIN_LOCKOUT_COOLDOWN", lockoutPolicy.Cooldown)
+	appMailer, err := config.NewMailer()
+	if err != nil {
+		fatal("Failed to open mail log file", err)
+	}
+	authService := service.NewAuthService(
+		userRepo,
+		config.String("JWT_SECRET", "your-secret-key"),
+		service.WithLoginAttemptRepository(loginAttemptRepo),
+		service.WithLockoutPolicy(lockoutPolicy),
+		service.WithUserTokenRepository(userTokenRepo),
+		service.WithMailer(appMailer),
+		service.WithAppBaseURL(config.String("APP_BASE_URL", "http://localhost:8080")),
+		service.WithAuthLogger(logger.With("component", "auth_service")),
+	)
+	matchTokens := service.NewMatchTokens(
+		config.String("JWT_SECRET", "your-secret-key"),
+		config.Duration("MATCH_TOKEN_TTL", service.DefaultMatchTokenTTL),
+	)
+	apiKeyService := service.NewAPIKeyService(
+		apiKeyRepo,
+		service.WithAPIKeyLogger(logger.With("component", "api_key_service")),
+	)
+	userService := service.NewUserService(
+		userRepo,
+		authService,
+		service.WithUserLogger(logger.With("component", "user_service")),
+	)
+
+	// Initialize middleware
+	authMiddleware := middleware.NewAuthMiddleware(config.String("JWT_SECRET", "your-secret-key"), apiKeyService, middleware.WithUserLookup(userService))
+	rateLimiter := config.NewRateLimiter(map[string]middleware.RateLimit{
+		router.RateLimitGroupAuth:      {Rate: 1, Burst: 10},
+		router.RateLimitGroupLocations: {Rate: 10, Burst: 20},
+		router.RateLimitGroupMatch:     {Rate: 5, Burst: 10},
+		router.RateLimitGroupUsers:     {Rate: 5, Burst: 10},
+		router.RateLimitGroupAdmin:     {Rate: 10, Burst: 20},
+	})
+	cors, err := config.NewCORS()
+	if err != nil {
+		fatal("Invalid CORS configuration", err)
+	}
+	idempotency := middleware.NewIdempotency(
+		idempotencyRepo,
+		middleware.WithIdempotencyTTL(config.Duration("IDEMPOTENCY_TTL", 24*time.Hour)),
+	)
+	routeBreakers := middleware.NewRouteCircuitBreakers(config.BreakerOptions("BREAKER")...)
 
 	// Initialize handlers
 	locationHandler := handler.NewLocationHandler(locationService)
-	matchingHandler := handler.NewMatchingHandler(matchingService)
+	matchingHandler := handler.NewMatchingHandler(matchingService, matchTokens)
 	authHandler := handler.NewAuthHandler(authService)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+	userHandler := handler.NewUserHandler(userService)
+	breakerHandler := handler.NewBreakerHandler(httpClient, routeBreakers)
+	v2Handlers := router.V2Handlers{
+		Matching: handlerv2.NewMatchingHandler(matchingService, matchTokens),
+	}
 
-	// Initialize middleware
-	authMiddleware := middleware.NewAuthMiddleware(getEnv("JWT_SECRET", "your-secret-key"))
-	circuitBreaker := middleware.NewCircuitBreaker(
-		middleware.WithFailureThreshold(5),
-		middleware.WithResetTimeout(10*time.Second),
-	)
+	// Metrics read on every scrape
+	appMetrics.RegisterBreakers("outbound", httpClient.Breakers)
+	appMetrics.RegisterBreakers("route", routeBreakers.Breakers)
 
 	// Initialize router
-	r := router.NewRouter(
-		authMiddleware,
-		locationHandler,
-		matchingHandler,
-		authHandler,
-	)
+	r, err := router.NewRouter(router.Options{
+		Logger:  logger,
+		Metrics: appMetrics,
+		Limits: router.Limits{
+			MaxBodyBytes:      int64(config.Int("HTTP_MAX_BODY_BYTES", 64<<10)),
+			MaxBatchBodyBytes: int64(config.Int("HTTP_MAX_BATCH_BODY_BYTES", 4<<20)),
+			RequestTimeout:    config.Duration("HTTP_REQUEST_TIMEOUT", 10*time.Second),
+		},
+		TrustedProxies: config.List("TRUSTED_PROXIES", nil),
+		CORS:           cors,
+		Auth:           authMiddleware,
+		RateLimiter:    rateLimiter,
+		Idempotency:    idempotency,
+		Handlers: router.Handlers{
+			Location: locationHandler,
+			Matching: matchingHandler,
+			Auth:     authHandler,
+			APIKey:   apiKeyHandler,
+			User:     userHandler,
+			Breaker:  breakerHandler,
+		},
+		V2: v2Handlers,
+	})
+	if err != nil {
+		fatal("Invalid TRUSTED_PROXIES", err)
+	}
 
-	// Setup routes with circuit breaker
-	r.Engine.Use(circuitBreaker.Middleware())
+	// Setup routes with a circuit breaker per route
+	r.Engine.Use(routeBreakers.Middleware())
 	r.SetupMatchingApiRoutes()
 
 	// Start server with graceful shutdown
-	port := getEnv("PORT", "8081")
+	port := config.String("PORT", "8081")
 	srv := &http.Server{
-		Addr:    ":" + port,
-		Handler: r.Engine,
+		Addr:              ":" + port,
+		Handler:           middleware.ResponseControllers(r.Engine),
+		ReadHeaderTimeout: config.Duration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		ReadTimeout:       config.Duration("HTTP_READ_TIMEOUT", 15*time.Second),
+		WriteTimeout:      config.Duration("HTTP_WRITE_TIMEOUT", 30*time.Second),
+		IdleTimeout:       config.Duration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+		MaxHeaderBytes:    config.Int("HTTP_MAX_HEADER_BYTES", 1<<20),
+	}
+
+	// The gRPC API shares the services and credentials of the REST API
+	var grpcServer *grpc.Server
+	if grpcPort := config.String("GRPC_PORT", "9091"); grpcPort != "" {
+		grpcServer = grpcserver.New(
+			logger.With("component", "grpc"),
+			grpcserver.NewAuth(authService, apiKeyService, grpcserver.MatchingScopes),
+		)
+		taxiv1.RegisterMatchingServiceServer(grpcServer, grpcserver.NewMatchingServer(matchingService))
+		serveGRPC(grpcServer, grpcPort)
+		logger.Info("Matching API gRPC server starting", "port", grpcPort)
 	}
 
 	// Channel to listen for OS signals
@@ -90,27 +239,47 @@ func main() {
 
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			fatal("Failed to start server", err)
 		}
 	}()
-	log.Printf("Matching API Service starting on port %s", port)
+	logger.Info("Matching API Service starting", "port", port)
 
 	<-quit
-	log.Println("Shutting down server...")
+	logger.Info("Shutting down server")
+
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
 
 	// Create a deadline to wait for.
 	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		fatal("Server forced to shutdown", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("Failed to flush traces", "error", err)
 	}
 
-	log.Println("Server exiting")
+	logger.Info("Server exiting")
 }
 
-func getEnv(key, fallback string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+// serveGRPC starts serving the gRPC server on port in the background
+func serveGRPC(server *grpc.Server, port string) {
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		fatal("Failed to listen for gRPC", err)
 	}
-	return fallback
+
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			fatal("Failed to start gRPC server", err)
+		}
+	}()
+}
+
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,205 @@
+// Package batchcodec implements a compact binary encoding of driver location
+// batches, an alternative to JSON for high-volume clients.
+//
+// A batch is
+//
+//	batch             = magic version count record*
+//	magic             = "DLB"
+//	version           = 0x01
+//	count             = uvarint
+//	record            = driver_id status lat lon
+//	driver_id, status = uvarint length, then that many UTF-8 bytes
+//	lat, lon          = varint
+//
+// Varints are the unsigned and zig-zag signed varints of encoding/binary.
+// Coordinates are fixed-point integers in units of 1e-7 degrees (about 1 cm),
+// each stored as the difference from the same coordinate of the previous
+// record; the first record is relative to 0. Drivers reported together are
+// usually close to each other, so the deltas fit in two or three bytes.
+//
+// Only the fields the batch write path uses are encoded: IDs are assigned
+// and timestamps are set when locations are saved.
+package batchcodec
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+// MediaType selects the encoding in Content-Type headers
+const MediaType = "application/vnd.taxi.location-batch"
+
+// Version is the format version written by Encode
+const Version = 1
+
+// MaxFieldLength is the longest driver ID or status accepted, in bytes
+const MaxFieldLength = 256
+
+// scale converts degrees to fixed-point units
+const scale = 1e7
+
+// minRecordSize is the smallest encoded record: two empty strings and two
+// zero deltas
+const minRecordSize = 4
+
+var magic = []byte("DLB")
+
+// Custom errors
+var (
+	ErrInvalidMagic       = errors.New("batchcodec: not a location batch")
+	ErrUnsupportedVersion = errors.New("batchcodec: unsupported version")
+	ErrTruncated          = errors.New("batchcodec: truncated batch")
+	ErrTrailingData       = errors.New("batchcodec: trailing data after batch")
+	ErrFieldTooLong       = errors.New("batchcodec: field too long")
+	ErrInvalidLatitude    = errors.New("batchcodec: latitude out of range")
+	ErrInvalidLongitude   = errors.New("batchcodec: longitude out of range")
+)
+
+// Encode encodes locations as a batch
+func Encode(locations []domain.DriverLocation) ([]byte, error) {
+	return Append(make([]byte, 0, len(magic)+1+binary.MaxVarintLen64+len(locations)*24), locations)
+}
+
+// Append appends the encoding of locations to dst
+func Append(dst []byte, locations []domain.DriverLocation) ([]byte, error) {
+	dst = append(dst, magic...)
+	dst = append(dst, Version)
+	dst = binary.AppendUvarint(dst, uint64(len(locations)))
+
+	var prevLat, prevLon int64
+	for i := range locations {
+		location := &locations[i]
+		if len(location.DriverID) > MaxFieldLength || len(location.Status) > MaxFieldLength {
+			return nil, ErrFieldTooLong
+		}
+		lat, lon := location.Location.GetCoordinates()
+		if lat < -90 || lat > 90 {
+			return nil, ErrInvalidLatitude
+		}
+		if lon < -180 || lon > 180 {
+			return nil, ErrInvalidLongitude
+		}
+
+		dst = appendString(dst, location.DriverID)
+		dst = appendString(dst, location.Status)
+
+		fixedLat, fixedLon := toFixed(lat), toFixed(lon)
+		dst = binary.AppendVarint(dst, fixedLat-prevLat)
+		dst = binary.AppendVarint(dst, fixedLon-prevLon)
+		prevLat, prevLon = fixedLat, fixedLon
+	}
+
+	return dst, nil
+}
+
+// Decode decodes a batch
+func Decode(data []byte) ([]domain.DriverLocation, error) {
+	if len(data) < len(magic)+1 || string(data[:len(magic)]) != string(magic) {
+		return nil, ErrInvalidMagic
+	}
+	if data[len(magic)] != Version {
+		return nil, ErrUnsupportedVersion
+	}
+	d := decoder{data: data[len(magic)+1:]}
+
+	count, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	// Reject counts the data cannot hold before allocating for them
+	if count > uint64(len(d.data)/minRecordSize) {
+		return nil, ErrTruncated
+	}
+
+	locations := make([]domain.DriverLocation, count)
+	var lat, lon int64
+	for i := range locations {
+		driverID, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		status, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		dLat, err := d.varint()
+		if err != nil {
+			return nil, err
+		}
+		dLon, err := d.varint()
+		if err != nil {
+			return nil, err
+		}
+
+		lat += dLat
+		lon += dLon
+		if lat < -90*scale || lat > 90*scale {
+			return nil, ErrInvalidLatitude
+		}
+		if lon < -180*scale || lon > 180*scale {
+			return nil, ErrInvalidLongitude
+		}
+
+		locations[i] = domain.DriverLocation{
+			DriverID: driverID,
+			Location: domain.NewPoint(float64(lat)/scale, float64(lon)/scale),
+			Status:   status,
+		}
+	}
+
+	if len(d.data) > 0 {
+		return nil, ErrTrailingData
+	}
+	return locations, nil
+}
+
+func toFixed(degrees float64) int64 {
+	return int64(math.Round(degrees * scale))
+}
+
+func appendString(dst []byte, s string) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(s)))
+	return append(dst, s...)
+}
+
+// decoder reads values from the front of data
+type decoder struct {
+	data []byte
+}
+
+func (d *decoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		return 0, ErrTruncated
+	}
+	d.data = d.data[n:]
+	return v, nil
+}
+
+func (d *decoder) varint() (int64, error) {
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		return 0, ErrTruncated
+	}
+	d.data = d.data[n:]
+	return v, nil
+}
+
+func (d *decoder) string() (string, error) {
+	length, err := d.uvarint()
+	if err != nil {
+		return "", err
+	}
+	if length > MaxFieldLength {
+		return "", ErrFieldTooLong
+	}
+	if length > uint64(len(d.data)) {
+		return "", ErrTruncated
+	}
+	s := string(d.data[:length])
+	d.data = d.data[length:]
+	return s, nil
+}
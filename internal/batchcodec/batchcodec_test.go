@@ -0,0 +1,185 @@
+package batchcodec
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+// testBatch returns size drivers spread over about 20 km around Istanbul
+func testBatch(size int) []domain.DriverLocation {
+	rng := rand.New(rand.NewSource(1))
+	locations := make([]domain.DriverLocation, size)
+	for i := range locations {
+		locations[i] = domain.DriverLocation{
+			DriverID: fmt.Sprintf("driver-%d", i),
+			Location: domain.NewPoint(41.0+rng.Float64()*0.2, 28.9+rng.Float64()*0.2),
+			Status:   "active",
+		}
+	}
+	return locations
+}
+
+func TestRoundTrip(t *testing.T) {
+	locations := append(testBatch(50),
+		domain.DriverLocation{DriverID: "north-east", Location: domain.NewPoint(90, 180)},
+		domain.DriverLocation{DriverID: "south-west", Location: domain.NewPoint(-90, -180), Status: "busy"},
+	)
+
+	data, err := Encode(locations)
+	require.NoError(t, err)
+	decoded, err := Decode(data)
+	require.NoError(t, err)
+
+	require.Len(t, decoded, len(locations))
+	for i := range locations {
+		assert.Equal(t, locations[i].DriverID, decoded[i].DriverID)
+		assert.Equal(t, locations[i].Status, decoded[i].Status)
+
+		lat, lon := locations[i].Location.GetCoordinates()
+		decodedLat, decodedLon := decoded[i].Location.GetCoordinates()
+		assert.InDelta(t, lat, decodedLat, 0.5/scale)
+		assert.InDelta(t, lon, decodedLon, 0.5/scale)
+		assert.Equal(t, "Point", decoded[i].Location.Type)
+	}
+}
+
+func TestEmptyBatch(t *testing.T) {
+	data, err := Encode(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{'D', 'L', 'B', Version, 0}, data)
+
+	decoded, err := Decode(data)
+	require.NoError(t, err)
+	assert.Empty(t, decoded)
+}
+
+func TestEncoding(t *testing.T) {
+	data, err := Encode([]domain.DriverLocation{
+		{DriverID: "a", Location: domain.NewPoint(1, 2)},
+		{DriverID: "b", Status: "x", Location: domain.NewPoint(1.0000001, 2)},
+	})
+	require.NoError(t, err)
+
+	expected := []byte{'D', 'L', 'B', Version, 2}
+	// "a", "", lat 1e7 and lon 2e7 as zig-zag varints
+	expected = append(expected, 1, 'a', 0, 0x80, 0xDA, 0xC4, 0x09, 0x80, 0xB4, 0x89, 0x13)
+	// "b", "x", lat +1 and lon +0
+	expected = append(expected, 1, 'b', 1, 'x', 0x02, 0x00)
+	assert.Equal(t, expected, data)
+}
+
+func TestSize(t *testing.T) {
+	locations := testBatch(1000)
+
+	binaryData, err := Encode(locations)
+	require.NoError(t, err)
+	jsonData, err := json.Marshal(locations)
+	require.NoError(t, err)
+
+	assert.Less(t, len(binaryData)*4, len(jsonData), "binary %d bytes, JSON %d bytes", len(binaryData), len(jsonData))
+}
+
+func TestEncodeErrors(t *testing.T) {
+	long := string(make([]byte, MaxFieldLength+1))
+
+	tests := []struct {
+		name     string
+		location domain.DriverLocation
+		err      error
+	}{
+		{name: "long driver id", location: domain.DriverLocation{DriverID: long, Location: domain.NewPoint(0, 0)}, err: ErrFieldTooLong},
+		{name: "long status", location: domain.DriverLocation{Status: long, Location: domain.NewPoint(0, 0)}, err: ErrFieldTooLong},
+		{name: "latitude", location: domain.DriverLocation{Location: domain.NewPoint(90.1, 0)}, err: ErrInvalidLatitude},
+		{name: "longitude", location: domain.DriverLocation{Location: domain.NewPoint(0, -180.1)}, err: ErrInvalidLongitude},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Encode([]domain.DriverLocation{tt.location})
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	valid, err := Encode(testBatch(3))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{name: "empty", data: nil, err: ErrInvalidMagic},
+		{name: "json", data: []byte(`[{"driver_id":"a"}]`), err: ErrInvalidMagic},
+		{name: "version", data: []byte{'D', 'L', 'B', 2, 0}, err: ErrUnsupportedVersion},
+		{name: "missing count", data: []byte{'D', 'L', 'B', Version}, err: ErrTruncated},
+		{name: "count beyond data", data: []byte{'D', 'L', 'B', Version, 0xFF, 0xFF, 0xFF, 0xFF, 0x0F}, err: ErrTruncated},
+		{name: "truncated record", data: valid[:len(valid)-1], err: ErrTruncated},
+		{name: "trailing data", data: append(append([]byte{}, valid...), 0), err: ErrTrailingData},
+		{name: "long field", data: []byte{'D', 'L', 'B', Version, 1, 0x81, 0x02, 0, 0, 0}, err: ErrFieldTooLong},
+		// Latitude 90.0000001
+		{name: "latitude", data: []byte{'D', 'L', 'B', Version, 1, 0, 0, 0x82, 0xA4, 0xA7, 0xDA, 0x06, 0}, err: ErrInvalidLatitude},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.data)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	for _, size := range []int{10, 1000} {
+		locations := testBatch(size)
+
+		b.Run(fmt.Sprintf("binary/%d", size), func(b *testing.B) {
+			var data []byte
+			for i := 0; i < b.N; i++ {
+				data, _ = Encode(locations)
+			}
+			b.ReportMetric(float64(len(data)), "bytes/batch")
+		})
+		b.Run(fmt.Sprintf("json/%d", size), func(b *testing.B) {
+			var data []byte
+			for i := 0; i < b.N; i++ {
+				data, _ = json.Marshal(locations)
+			}
+			b.ReportMetric(float64(len(data)), "bytes/batch")
+		})
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	for _, size := range []int{10, 1000} {
+		locations := testBatch(size)
+		binaryData, _ := Encode(locations)
+		jsonData, _ := json.Marshal(locations)
+
+		b.Run(fmt.Sprintf("binary/%d", size), func(b *testing.B) {
+			b.SetBytes(int64(len(binaryData)))
+			for i := 0; i < b.N; i++ {
+				if _, err := Decode(binaryData); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("json/%d", size), func(b *testing.B) {
+			b.SetBytes(int64(len(jsonData)))
+			for i := 0; i < b.N; i++ {
+				var decoded []domain.DriverLocation
+				if err := json.Unmarshal(jsonData, &decoded); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
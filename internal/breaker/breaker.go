@@ -0,0 +1,372 @@
+// Package breaker implements the circuit breaker state machine shared by the
+// inbound gin middleware and the outbound HTTP client.
+//
+// A breaker trips either after a number of consecutive failures (the
+// default) or, when a window is configured with WithFailureRate, once the
+// failure rate over a rolling time window crosses a threshold. After the
+// reset timeout it lets a limited number of probes through; the first
+// successful probe closes it and a failed probe opens it again.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Outcome is how a call counts toward the breaker
+type Outcome int
+
+const (
+	OutcomeSuccess Outcome = iota
+	OutcomeFailure
+	// OutcomeIgnore does not count at all, e.g. a call canceled by its caller
+	OutcomeIgnore
+)
+
+// Classifier decides the outcome of a call from its status code and error.
+// The status is 0 when there is no response.
+type Classifier func(status int, err error) Outcome
+
+// DefaultClassifier counts errors and 5xx responses as failures, and ignores
+// calls canceled by the caller
+func DefaultClassifier(status int, err error) Outcome {
+	return classify(status, err, func(status int) bool {
+		return status >= 500
+	})
+}
+
+// FailureStatuses returns a classifier that counts errors and the given
+// status codes as failures, and ignores calls canceled by the caller
+func FailureStatuses(codes ...int) Classifier {
+	failures := make(map[int]bool, len(codes))
+	for _, code := range codes {
+		failures[code] = true
+	}
+
+	return func(status int, err error) Outcome {
+		return classify(status, err, func(status int) bool {
+			return failures[status]
+		})
+	}
+}
+
+func classify(status int, err error, isFailure func(int) bool) Outcome {
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return OutcomeIgnore
+		}
+		return OutcomeFailure
+	}
+	if isFailure(status) {
+		return OutcomeFailure
+	}
+	return OutcomeSuccess
+}
+
+// Status is a snapshot of a breaker
+type Status struct {
+	Name     string `json:"name"`
+	State    string `json:"state"`
+	Failures uint   `json:"failures"`
+}
+
+type Breaker struct {
+	mu sync.Mutex
+
+	name             string
+	failureThreshold uint
+	resetTimeout     time.Duration
+	maxProbes        uint
+	classifier       Classifier
+	onStateChange    func(name string, from, to State)
+	now              func() time.Time
+
+	// Failure rate mode, enabled when window is set
+	failureRate float64
+	minRequests uint
+	window      *window
+
+	failures uint
+	openedAt time.Time
+	probes   uint
+	state    State
+}
+
+type Option func(*Breaker)
+
+// WithName names the breaker in its status and state change callbacks
+func WithName(name string) Option {
+	return func(b *Breaker) {
+		b.name = name
+	}
+}
+
+// WithFailureThreshold trips the breaker after threshold consecutive failures
+func WithFailureThreshold(threshold uint) Option {
+	return func(b *Breaker) {
+		b.failureThreshold = threshold
+	}
+}
+
+// WithFailureRate trips the breaker when at least rate (0-1] of the calls in
+// the rolling window failed, once the window holds minRequests calls. It
+// replaces the consecutive failure threshold.
+func WithFailureRate(rate float64, window time.Duration, minRequests uint) Option {
+	return func(b *Breaker) {
+		b.failureRate = rate
+		b.minRequests = minRequests
+		b.window = newWindow(window, 10)
+	}
+}
+
+// WithResetTimeout sets how long the breaker stays open before probing
+func WithResetTimeout(timeout time.Duration) Option {
+	return func(b *Breaker) {
+		b.resetTimeout = timeout
+	}
+}
+
+// WithHalfOpenMaxProbes caps the calls let through at once while half-open.
+// Zero means no cap.
+func WithHalfOpenMaxProbes(probes uint) Option {
+	return func(b *Breaker) {
+		b.maxProbes = probes
+	}
+}
+
+// WithClassifier sets how calls recorded with Record are classified
+func WithClassifier(classifier Classifier) Option {
+	return func(b *Breaker) {
+		b.classifier = classifier
+	}
+}
+
+// WithOnStateChange sets a callback run after every state change. It runs
+// outside the breaker lock, so it may call back into the breaker.
+func WithOnStateChange(fn func(name string, from, to State)) Option {
+	return func(b *Breaker) {
+		b.onStateChange = fn
+	}
+}
+
+func New(options ...Option) *Breaker {
+	b := &Breaker{
+		failureThreshold: 5,
+		resetTimeout:     10 * time.Second,
+		maxProbes:        1,
+		classifier:       DefaultClassifier,
+		now:              time.Now,
+		state:            StateClosed,
+	}
+
+	for _, option := range options {
+		option(b)
+	}
+
+	return b
+}
+
+// AllowRequest reports whether a call may proceed. Every allowed call must
+// be followed by Record, RecordSuccess or RecordFailure.
+func (b *Breaker) AllowRequest() bool {
+	b.mu.Lock()
+	from := b.state
+	allowed := b.allow()
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return allowed
+}
+
+func (b *Breaker) allow() bool {
+	switch b.state {
+	case StateClosed:
+		return true
+
+	case StateOpen:
+		if b.now().Sub(b.openedAt) <= b.resetTimeout {
+			return false
+		}
+		b.state = StateHalfOpen
+		b.probes = 0
+		return b.allowProbe()
+
+	case StateHalfOpen:
+		return b.allowProbe()
+
+	default:
+		return false
+	}
+}
+
+func (b *Breaker) allowProbe() bool {
+	if b.maxProbes > 0 && b.probes >= b.maxProbes {
+		return false
+	}
+	b.probes++
+	return true
+}
+
+// Record classifies a call and records its outcome
+func (b *Breaker) Record(status int, err error) {
+	switch b.classifier(status, err) {
+	case OutcomeSuccess:
+		b.RecordSuccess()
+	case OutcomeFailure:
+		b.RecordFailure()
+	default:
+		b.mu.Lock()
+		b.releaseProbe()
+		b.mu.Unlock()
+	}
+}
+
+func (b *Breaker) RecordFailure() {
+	b.mu.Lock()
+	from := b.state
+
+	switch b.state {
+	case StateClosed:
+		b.failures++
+		if b.window != nil {
+			b.window.record(b.now(), true)
+		}
+		if b.shouldTrip() {
+			b.open()
+		}
+
+	case StateHalfOpen:
+		b.open()
+
+	default:
+		// Do nothing in other states
+	}
+
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+func (b *Breaker) RecordSuccess() {
+	b.mu.Lock()
+	from := b.state
+
+	switch b.state {
+	case StateHalfOpen:
+		b.state = StateClosed
+		b.reset()
+
+	case StateClosed:
+		b.failures = 0
+		if b.window != nil {
+			b.window.record(b.now(), false)
+		}
+
+	default:
+		// Do nothing in other states
+	}
+
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+func (b *Breaker) shouldTrip() bool {
+	if b.window == nil {
+		return b.failures >= b.failureThreshold
+	}
+
+	successes, failures := b.window.counts(b.now())
+	total := successes + failures
+	if total == 0 || total < b.minRequests {
+		return false
+	}
+	return float64(failures)/float64(total) >= b.failureRate
+}
+
+func (b *Breaker) open() {
+	b.state = StateOpen
+	b.openedAt = b.now()
+	b.probes = 0
+}
+
+func (b *Breaker) reset() {
+	b.failures = 0
+	b.openedAt = time.Time{}
+	b.probes = 0
+	if b.window != nil {
+		b.window.reset()
+	}
+}
+
+func (b *Breaker) releaseProbe() {
+	if b.state == StateHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+func (b *Breaker) notify(from, to State) {
+	if from != to && b.onStateChange != nil {
+		b.onStateChange(b.name, from, to)
+	}
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Failures returns the failures counting toward tripping: consecutive
+// failures, or failures in the window in failure rate mode
+func (b *Breaker) Failures() uint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failureCount()
+}
+
+func (b *Breaker) failureCount() uint {
+	if b.window != nil {
+		_, failures := b.window.counts(b.now())
+		return failures
+	}
+	return b.failures
+}
+
+// Status returns a snapshot of the breaker
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return Status{
+		Name:     b.name,
+		State:    b.state.String(),
+		Failures: b.failureCount(),
+	}
+}
@@ -0,0 +1,284 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a manually advanced clock
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestBreaker(clock *fakeClock, options ...Option) *Breaker {
+	b := New(options...)
+	b.now = clock.Now
+	return b
+}
+
+func TestConsecutiveFailures(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	b := newTestBreaker(clock, WithFailureThreshold(3), WithResetTimeout(time.Second))
+
+	b.RecordFailure()
+	b.RecordFailure()
+	b.RecordSuccess()
+	b.RecordFailure()
+	b.RecordFailure()
+	assert.Equal(t, StateClosed, b.State(), "a success resets the consecutive failures")
+
+	b.RecordFailure()
+	assert.Equal(t, StateOpen, b.State())
+	assert.False(t, b.AllowRequest())
+
+	clock.Advance(2 * time.Second)
+	assert.True(t, b.AllowRequest())
+	assert.Equal(t, StateHalfOpen, b.State())
+
+	b.RecordSuccess()
+	assert.Equal(t, StateClosed, b.State())
+	assert.Equal(t, uint(0), b.Failures())
+}
+
+func TestHalfOpenFailureReopens(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	b := newTestBreaker(clock, WithFailureThreshold(1), WithResetTimeout(time.Second))
+
+	b.RecordFailure()
+	clock.Advance(2 * time.Second)
+	assert.True(t, b.AllowRequest())
+
+	b.RecordFailure()
+	assert.Equal(t, StateOpen, b.State())
+	assert.False(t, b.AllowRequest())
+}
+
+func TestHalfOpenMaxProbes(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	b := newTestBreaker(clock,
+		WithFailureThreshold(1),
+		WithResetTimeout(time.Second),
+		WithHalfOpenMaxProbes(2),
+	)
+
+	b.RecordFailure()
+	clock.Advance(2 * time.Second)
+
+	assert.True(t, b.AllowRequest())
+	assert.True(t, b.AllowRequest())
+	assert.False(t, b.AllowRequest(), "only two probes may be in flight")
+
+	// An ignored probe frees its slot without closing the breaker
+	b.Record(0, context.Canceled)
+	assert.Equal(t, StateHalfOpen, b.State())
+	assert.True(t, b.AllowRequest())
+}
+
+func TestFailureRate(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	b := newTestBreaker(clock,
+		WithFailureRate(0.5, 10*time.Second, 4),
+		WithResetTimeout(time.Second),
+	)
+
+	// Not enough requests in the window yet
+	b.RecordFailure()
+	b.RecordFailure()
+	b.RecordFailure()
+	assert.Equal(t, StateClosed, b.State())
+	assert.Equal(t, uint(3), b.Failures())
+
+	// Old failures leave the window
+	clock.Advance(11 * time.Second)
+	assert.Equal(t, uint(0), b.Failures())
+
+	b.RecordSuccess()
+	b.RecordSuccess()
+	b.RecordFailure()
+	assert.Equal(t, StateClosed, b.State())
+
+	// 2 of 4 failed
+	b.RecordFailure()
+	assert.Equal(t, StateOpen, b.State())
+
+	// Closing clears the window
+	clock.Advance(2 * time.Second)
+	assert.True(t, b.AllowRequest())
+	b.RecordSuccess()
+	assert.Equal(t, StateClosed, b.State())
+	assert.Equal(t, uint(0), b.Failures())
+}
+
+func TestWindowExpiresBuckets(t *testing.T) {
+	start := time.Unix(1000, 0)
+	w := newWindow(10*time.Second, 10)
+
+	w.record(start, true)
+	w.record(start.Add(5*time.Second), false)
+
+	successes, failures := w.counts(start.Add(9 * time.Second))
+	assert.Equal(t, uint(1), successes)
+	assert.Equal(t, uint(1), failures)
+
+	successes, failures = w.counts(start.Add(10 * time.Second))
+	assert.Equal(t, uint(1), successes)
+	assert.Equal(t, uint(0), failures)
+
+	// A slot reused by a newer bucket forgets the old counts
+	w.record(start.Add(20*time.Second), false)
+	successes, failures = w.counts(start.Add(20 * time.Second))
+	assert.Equal(t, uint(1), successes)
+	assert.Equal(t, uint(0), failures)
+}
+
+func TestClassifier(t *testing.T) {
+	tests := []struct {
+		name       string
+		classifier Classifier
+		status     int
+		err        error
+		expected   Outcome
+	}{
+		{"default success", DefaultClassifier, 200, nil, OutcomeSuccess},
+		{"default client error", DefaultClassifier, 404, nil, OutcomeSuccess},
+		{"default server error", DefaultClassifier, 500, nil, OutcomeFailure},
+		{"default error", DefaultClassifier, 0, errors.New("connection refused"), OutcomeFailure},
+		{"default timeout", DefaultClassifier, 0, context.DeadlineExceeded, OutcomeFailure},
+		{"default canceled", DefaultClassifier, 0, fmt.Errorf("request: %w", context.Canceled), OutcomeIgnore},
+		{"statuses listed", FailureStatuses(429, 503), 429, nil, OutcomeFailure},
+		{"statuses unlisted", FailureStatuses(429, 503), 500, nil, OutcomeSuccess},
+		{"statuses error", FailureStatuses(503), 0, errors.New("reset"), OutcomeFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.classifier(tt.status, tt.err))
+		})
+	}
+}
+
+func TestRecordUsesClassifier(t *testing.T) {
+	b := New(WithFailureThreshold(1), WithClassifier(FailureStatuses(429)))
+
+	b.Record(500, nil)
+	assert.Equal(t, StateClosed, b.State())
+
+	b.Record(429, nil)
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func TestOnStateChange(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+
+	type transition struct {
+		name     string
+		from, to State
+	}
+	var transitions []transition
+
+	var b *Breaker
+	b = newTestBreaker(clock,
+		WithName("locations"),
+		WithFailureThreshold(1),
+		WithResetTimeout(time.Second),
+		WithOnStateChange(func(name string, from, to State) {
+			// The callback runs outside the lock, so it can read the breaker
+			assert.Equal(t, to, b.State())
+			transitions = append(transitions, transition{name, from, to})
+		}),
+	)
+
+	b.RecordFailure()
+	clock.Advance(2 * time.Second)
+	b.AllowRequest()
+	b.RecordSuccess()
+	b.RecordSuccess()
+
+	assert.Equal(t, []transition{
+		{"locations", StateClosed, StateOpen},
+		{"locations", StateOpen, StateHalfOpen},
+		{"locations", StateHalfOpen, StateClosed},
+	}, transitions)
+}
+
+func TestConcurrentUse(t *testing.T) {
+	var changes int32
+	b := New(
+		WithFailureRate(0.5, time.Second, 10),
+		WithResetTimeout(time.Millisecond),
+		WithHalfOpenMaxProbes(3),
+		WithOnStateChange(func(string, State, State) {
+			atomic.AddInt32(&changes, 1)
+		}),
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				if b.AllowRequest() {
+					if (i+j)%3 == 0 {
+						b.Record(500, nil)
+					} else {
+						b.Record(200, nil)
+					}
+				}
+				b.State()
+				b.Status()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Contains(t, []State{StateClosed, StateOpen, StateHalfOpen}, b.State())
+}
+
+func TestConcurrentHalfOpenProbes(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	b := newTestBreaker(clock,
+		WithFailureThreshold(1),
+		WithResetTimeout(time.Second),
+		WithHalfOpenMaxProbes(3),
+	)
+
+	b.RecordFailure()
+	clock.Advance(2 * time.Second)
+
+	var allowed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.AllowRequest() {
+				atomic.AddInt32(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(3), allowed)
+	assert.Equal(t, StateHalfOpen, b.State())
+}
@@ -0,0 +1,63 @@
+package breaker
+
+import "time"
+
+// window counts calls over a rolling time window, split into buckets so old
+// calls expire a bucket at a time
+type window struct {
+	bucketWidth time.Duration
+	buckets     []windowBucket
+}
+
+type windowBucket struct {
+	start     int64
+	successes uint
+	failures  uint
+}
+
+func newWindow(size time.Duration, buckets int) *window {
+	width := size / time.Duration(buckets)
+	if width <= 0 {
+		width = 1
+	}
+
+	return &window{
+		bucketWidth: width,
+		buckets:     make([]windowBucket, buckets),
+	}
+}
+
+func (w *window) record(now time.Time, failed bool) {
+	start := now.UnixNano() / int64(w.bucketWidth)
+	bucket := &w.buckets[start%int64(len(w.buckets))]
+
+	// The slot still holds an older bucket that has left the window
+	if bucket.start != start {
+		*bucket = windowBucket{start: start}
+	}
+
+	if failed {
+		bucket.failures++
+	} else {
+		bucket.successes++
+	}
+}
+
+func (w *window) counts(now time.Time) (successes, failures uint) {
+	current := now.UnixNano() / int64(w.bucketWidth)
+	oldest := current - int64(len(w.buckets)) + 1
+
+	for _, bucket := range w.buckets {
+		if bucket.start >= oldest && bucket.start <= current {
+			successes += bucket.successes
+			failures += bucket.failures
+		}
+	}
+	return successes, failures
+}
+
+func (w *window) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = windowBucket{}
+	}
+}
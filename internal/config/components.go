@@ -0,0 +1,90 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/breaker"
+	"github.com/yusufatac/bitaksi-case-study/internal/mailer"
+	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+)
+
+// NewMailer creates the mailer selected by MAIL_DRIVER. The log driver
+// writes emails to MAIL_LOG_FILE, or stdout when it is not set.
+func NewMailer() (mailer.Mailer, error) {
+	if String("MAIL_DRIVER", "log") == "smtp" {
+		return mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     String("SMTP_HOST", "localhost"),
+			Port:     String("SMTP_PORT", "587"),
+			Username: String("SMTP_USERNAME", ""),
+			Password: String("SMTP_PASSWORD", ""),
+			From:     String("MAIL_FROM", "no-reply@bitaksi.local"),
+		}), nil
+	}
+
+	if path := String("MAIL_LOG_FILE", ""); path != "" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		return mailer.NewLogMailer(file), nil
+	}
+	return mailer.NewLogMailer(os.Stdout), nil
+}
+
+// NewRateLimiter creates the per-client rate limiter of the route groups in
+// defaults. Each group's budget is read from RATE_LIMIT_<GROUP>_RPS and
+// RATE_LIMIT_<GROUP>_BURST, and RATE_LIMIT_ENABLED=false turns limiting off.
+func NewRateLimiter(defaults map[string]middleware.RateLimit) *middleware.RateLimiter {
+	limits := make(map[string]middleware.RateLimit, len(defaults))
+	if String("RATE_LIMIT_ENABLED", "true") != "false" {
+		for group, limit := range defaults {
+			prefix := "RATE_LIMIT_" + strings.ToUpper(group)
+			limits[group] = middleware.RateLimit{
+				Rate:  Float(prefix+"_RPS", limit.Rate),
+				Burst: Int(prefix+"_BURST", limit.Burst),
+			}
+		}
+	}
+
+	return middleware.NewRateLimiter(middleware.NewMemoryRateLimitStore(64), limits)
+}
+
+// NewCORS creates the CORS policy. Cross-origin requests are only allowed
+// from the origins listed in CORS_ALLOWED_ORIGINS.
+func NewCORS() (*middleware.CORS, error) {
+	cfg := middleware.DefaultCORSConfig()
+	cfg.AllowedOrigins = List("CORS_ALLOWED_ORIGINS", nil)
+	cfg.AllowedMethods = List("CORS_ALLOWED_METHODS", cfg.AllowedMethods)
+	cfg.AllowedHeaders = List("CORS_ALLOWED_HEADERS", cfg.AllowedHeaders)
+	cfg.AllowCredentials = String("CORS_ALLOW_CREDENTIALS", "false") == "true"
+	cfg.MaxAge = Duration("CORS_MAX_AGE", cfg.MaxAge)
+
+	return middleware.NewCORS(cfg)
+}
+
+// BreakerOptions reads circuit breaker settings from environment variables
+// starting with prefix. Setting <prefix>_FAILURE_RATE switches the breaker
+// from consecutive failures to the failure rate over <prefix>_WINDOW.
+func BreakerOptions(prefix string) []breaker.Option {
+	options := []breaker.Option{
+		breaker.WithFailureThreshold(uint(Int(prefix+"_FAILURES", 5))),
+		breaker.WithResetTimeout(Duration(prefix+"_RESET", 10*time.Second)),
+		breaker.WithHalfOpenMaxProbes(uint(Int(prefix+"_HALF_OPEN_PROBES", 1))),
+		breaker.WithOnStateChange(func(name string, from, to breaker.State) {
+			slog.Warn("Circuit breaker state changed", "breaker", name, "from", from.String(), "to", to.String())
+		}),
+	}
+
+	if rate := Float(prefix+"_FAILURE_RATE", 0); rate > 0 {
+		options = append(options, breaker.WithFailureRate(
+			rate,
+			Duration(prefix+"_WINDOW", 30*time.Second),
+			uint(Int(prefix+"_MIN_REQUESTS", 20)),
+		))
+	}
+
+	return options
+}
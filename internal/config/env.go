@@ -0,0 +1,69 @@
+// Package config reads the settings shared by the services from environment
+// variables. A variable that is not set, or that cannot be parsed, falls
+// back to the given default; parse errors are logged.
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// String reads a string
+func String(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
+
+// List reads a comma separated list
+func List(key string, fallback []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Int reads an integer
+func Int(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+		slog.Warn("Invalid environment variable, using default", "key", key, "default", fallback)
+	}
+	return fallback
+}
+
+// Float reads a floating point number
+func Float(key string, fallback float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+		slog.Warn("Invalid environment variable, using default", "key", key, "default", fallback)
+	}
+	return fallback
+}
+
+// Duration reads a duration such as 1m30s
+func Duration(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		slog.Warn("Invalid environment variable, using default", "key", key, "default", fallback.String())
+	}
+	return fallback
+}
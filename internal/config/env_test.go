@@ -0,0 +1,43 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "fallback", String("CONFIG_TEST_STRING", "fallback"))
+
+	// Set but empty is not the fallback
+	t.Setenv("CONFIG_TEST_STRING", "")
+	assert.Equal(t, "", String("CONFIG_TEST_STRING", "fallback"))
+}
+
+func TestList(t *testing.T) {
+	assert.Equal(t, []string{"a"}, List("CONFIG_TEST_LIST", []string{"a"}))
+
+	t.Setenv("CONFIG_TEST_LIST", " https://a.example.com, ,https://b.example.com ")
+	assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, List("CONFIG_TEST_LIST", nil))
+
+	t.Setenv("CONFIG_TEST_LIST", "")
+	assert.Empty(t, List("CONFIG_TEST_LIST", []string{"a"}))
+}
+
+func TestNumbersAndDurations(t *testing.T) {
+	t.Setenv("CONFIG_TEST_INT", "42")
+	t.Setenv("CONFIG_TEST_FLOAT", "0.5")
+	t.Setenv("CONFIG_TEST_DURATION", "1m30s")
+	assert.Equal(t, 42, Int("CONFIG_TEST_INT", 1))
+	assert.Equal(t, 0.5, Float("CONFIG_TEST_FLOAT", 1))
+	assert.Equal(t, 90*time.Second, Duration("CONFIG_TEST_DURATION", time.Second))
+
+	// Values that do not parse fall back to the default
+	t.Setenv("CONFIG_TEST_INT", "forty-two")
+	t.Setenv("CONFIG_TEST_FLOAT", "half")
+	t.Setenv("CONFIG_TEST_DURATION", "90")
+	assert.Equal(t, 1, Int("CONFIG_TEST_INT", 1))
+	assert.Equal(t, 1.0, Float("CONFIG_TEST_FLOAT", 1))
+	assert.Equal(t, time.Second, Duration("CONFIG_TEST_DURATION", time.Second))
+}
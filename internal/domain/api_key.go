@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"time"
+)
+
+// API key scopes
+const (
+	ScopeLocationsRead  = "locations:read"
+	ScopeLocationsWrite = "locations:write"
+)
+
+// Scopes lists every scope an API key can be granted
+var Scopes = []string{
+	ScopeLocationsRead,
+	ScopeLocationsWrite,
+}
+
+// APIKey represents a credential issued to a machine client.
+// Only the SHA-256 hash of the secret is stored; the prefix is used for lookup.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// HasScope reports whether the key was granted the given scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRevoked reports whether the key has been revoked
+func (k *APIKey) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
+
+// IsValidScope reports whether scope is a known API key scope
+func IsValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
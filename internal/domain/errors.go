@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"errors"
+)
+
+// Error kinds. Errors returned by services wrap one of them, so transports
+// can answer an error without knowing every service error. Errors that wrap
+// no kind are internal and are not shown to clients.
+var (
+	ErrValidation      = errors.New("validation failed")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrUnavailable     = errors.New("unavailable")
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrLocked          = errors.New("locked")
+)
+
+// Kinds lists every error kind
+var Kinds = []error{
+	ErrValidation,
+	ErrNotFound,
+	ErrConflict,
+	ErrUnauthorized,
+	ErrForbidden,
+	ErrUnavailable,
+	ErrTooManyRequests,
+	ErrLocked,
+}
+
+// Error is a service error of a kind. Its message is safe to show to
+// clients; context added by wrapping it is not.
+type Error struct {
+	kind    error
+	code    string
+	message string
+}
+
+// NewError creates an error of kind. code identifies the error for
+// programs, e.g. "ride_not_found".
+func NewError(kind error, code, message string) *Error {
+	return &Error{
+		kind:    kind,
+		code:    code,
+		message: message,
+	}
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+func (e *Error) Unwrap() error {
+	return e.kind
+}
+
+// Code returns the machine-readable code of the error
+func (e *Error) Code() string {
+	return e.code
+}
+
+// KindOf returns the kind err wraps, or nil for internal errors
+func KindOf(err error) error {
+	for _, kind := range Kinds {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"net/http"
+	"time"
+)
+
+// IdempotencyRecord is the stored outcome of a request sent with an
+// Idempotency-Key. A record without a status is still being processed.
+type IdempotencyRecord struct {
+	// Key identifies the client and the key it sent
+	Key string `json:"key"`
+	// RequestHash fingerprints the method, path and body of the request
+	RequestHash string      `json:"request_hash"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+	CreatedAt   time.Time   `json:"created_at"`
+	ExpiresAt   time.Time   `json:"expires_at"`
+}
+
+// IsCompleted reports whether the response of the request has been stored
+func (r *IdempotencyRecord) IsCompleted() bool {
+	return r.Status != 0
+}
+
+// IsExpired reports whether the record has run out at the given time
+func (r *IdempotencyRecord) IsExpired(now time.Time) bool {
+	return !r.ExpiresAt.After(now)
+}
@@ -6,17 +6,17 @@ import (
 
 // Point represents a GeoJSON Point type
 type Point struct {
-	Type        string    `json:"type" bson:"type"`
-	Coordinates []float64 `json:"coordinates" bson:"coordinates"`
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
 }
 
 // DriverLocation represents a driver's location at a specific time
 type DriverLocation struct {
-	ID        string    `json:"id" bson:"_id,omitempty"`
-	DriverID  string    `json:"driver_id" bson:"driver_id"`
-	Location  Point     `json:"location" bson:"location"`
-	Status    string    `json:"status" bson:"status"`
-	Timestamp time.Time `json:"timestamp" bson:"timestamp"`
+	ID        string    `json:"id"`
+	DriverID  string    `json:"driver_id"`
+	Location  Point     `json:"location"`
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
 // LocationRequest represents a request to find drivers within a radius
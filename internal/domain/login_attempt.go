@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"time"
+)
+
+// LoginAttempt tracks failed logins for a single key, such as a username or a client IP
+type LoginAttempt struct {
+	Key      string `json:"key"`
+	Failures int    `json:"failures"`
+	// Attempts counts the failures and the logins still in progress, so
+	// that concurrent logins cannot get past the failure limit
+	Attempts      int       `json:"attempts"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+	LockedUntil   time.Time `json:"locked_until"`
+}
+
+// IsLocked reports whether the key is locked at the given time
+func (a *LoginAttempt) IsLocked(now time.Time) bool {
+	return a.LockedUntil.After(now)
+}
+
+// LockExpired reports whether the key was locked and the lock has run out
+func (a *LoginAttempt) LockExpired(now time.Time) bool {
+	return !a.LockedUntil.IsZero() && !a.LockedUntil.After(now)
+}
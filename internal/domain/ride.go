@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"time"
+)
+
+// Ride statuses
+const (
+	RideStatusActive    = "active"
+	RideStatusCompleted = "completed"
+	// RideStatusExpired is a ride that was not completed within the
+	// longest ride duration
+	RideStatusExpired = "expired"
+)
+
+// Ride is a trip of a rider with the driver they were matched with
+type Ride struct {
+	ID       string `json:"id"`
+	RiderID  string `json:"rider_id"`
+	DriverID string `json:"driver_id"`
+	Pickup   Point  `json:"pickup"`
+	Status   string `json:"status"`
+	// ExpiresAt is when an active ride ends by itself, which also ends the
+	// tracking of the driver
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+// Match is a driver the matching API offered to a rider searching around
+// the pickup point. Rides can only be created from a match.
+type Match struct {
+	// TokenID identifies the match token, which can only be used once
+	TokenID   string
+	RiderID   string
+	DriverID  string
+	Pickup    Point
+	ExpiresAt time.Time
+}
+
+// IsActive reports whether the ride has not ended yet
+func (r *Ride) IsActive() bool {
+	return r.Status == RideStatusActive
+}
+
+// HasParticipant reports whether userID is the rider or the driver of the ride
+func (r *Ride) HasParticipant(userID string) bool {
+	return userID != "" && (userID == r.RiderID || userID == r.DriverID)
+}
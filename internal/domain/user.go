@@ -8,15 +8,29 @@ import (
 
 // User represents a system user (driver or rider)
 type User struct {
-	ID          string    `json:"id" bson:"_id,omitempty"`
-	Username    string    `json:"username" bson:"username"`
-	Password    string    `json:"-" bson:"password"`
-	Email       string    `json:"email" bson:"email"`
-	Status      string    `json:"status" bson:"status"`
-	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
-	LastLoginAt time.Time `json:"last_login_at" bson:"last_login_at"`
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	Password    string    `json:"-"`
+	Email       string    `json:"email"`
+	Status      string    `json:"status"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
 }
 
+// User statuses
+const (
+	UserStatusPending = "pending"
+	UserStatusActive  = "active"
+	UserStatusDeleted = "deleted"
+)
+
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // UserCredentials represents login/register credentials
 type UserCredentials struct {
 	Username string `json:"username" validate:"required,min=3,max=50"`
@@ -24,6 +38,20 @@ type UserCredentials struct {
 	Email    string `json:"email" validate:"required,email"`
 }
 
+// UserUpdate holds the profile fields a user can change. Nil fields are left as is.
+type UserUpdate struct {
+	Email *string
+}
+
+// UserFilter selects users when listing them
+type UserFilter struct {
+	// Query matches the start of the username or email, case insensitively
+	Query  string
+	Status string
+	Page   int
+	Limit  int
+}
+
 // HashPassword creates a bcrypt hash of the password
 func (u *User) HashPassword() error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
@@ -39,14 +67,16 @@ func (u *User) ComparePassword(password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 }
 
-// NewUser creates a new user with default values
+// NewUser creates a new user with default values.
+// New users stay pending until their email is verified.
 func NewUser(creds UserCredentials) *User {
 	now := time.Now()
 	return &User{
 		Username:    creds.Username,
 		Password:    creds.Password,
 		Email:       creds.Email,
-		Status:      "active",
+		Status:      UserStatusPending,
+		Role:        RoleUser,
 		CreatedAt:   now,
 		LastLoginAt: now,
 	}
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook events
+const (
+	// EventRideMatched happens when a rider starts a ride with the driver
+	// they were matched with
+	EventRideMatched   = "ride.matched"
+	EventRideCompleted = "ride.completed"
+)
+
+// WebhookEvents lists every event a webhook can subscribe to
+var WebhookEvents = []string{
+	EventRideMatched,
+	EventRideCompleted,
+}
+
+// IsValidWebhookEvent reports whether event is a known webhook event
+func IsValidWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookSubscription is a partner endpoint that is called when one of its
+// events happens. Payloads are signed with the secret, which is only shown
+// when the subscription is created.
+type WebhookSubscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Wants reports whether the subscription is active and subscribed to event
+func (s *WebhookSubscription) Wants(event string) bool {
+	if !s.Active {
+		return false
+	}
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookUpdate holds the subscription fields to change. Nil fields are left as is.
+type WebhookUpdate struct {
+	URL    *string
+	Events []string
+	Active *bool
+}
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	// WebhookDeliveryDeadLetter is a delivery that was given up on, kept so
+	// it can be inspected
+	WebhookDeliveryDeadLetter = "dead_letter"
+)
+
+// WebhookAttempt is one try to deliver an event
+type WebhookAttempt struct {
+	At time.Time `json:"at"`
+	// StatusCode is the status the endpoint answered with, if it answered
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// WebhookDelivery is the log of sending one event to one subscription
+type WebhookDelivery struct {
+	ID             string           `json:"id"`
+	SubscriptionID string           `json:"subscription_id"`
+	Event          string           `json:"event"`
+	Payload        json.RawMessage  `json:"payload" swaggertype:"object"`
+	Status         string           `json:"status"`
+	Attempts       []WebhookAttempt `json:"attempts"`
+	NextAttemptAt  *time.Time       `json:"next_attempt_at,omitempty"`
+	// Error tells why a dead letter was given up on
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// WebhookDeliveryFilter selects the deliveries of a subscription when
+// listing them
+type WebhookDeliveryFilter struct {
+	SubscriptionID string
+	// Status only lists deliveries with this status when set
+	Status string
+	Limit  int
+}
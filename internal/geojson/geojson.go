@@ -0,0 +1,150 @@
+// Package geojson encodes driver locations as GeoJSON (RFC 7946) feature
+// collections, the format map libraries such as Leaflet and Mapbox load
+// directly.
+//
+// Every driver becomes a Feature with a Point geometry and its driver ID,
+// status, timestamp and, when known, distance as properties. Collections
+// decode back into driver locations, so the format can also be consumed by
+// our own clients.
+package geojson
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/umahmood/haversine"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+// MediaType is the media type of GeoJSON documents
+const MediaType = "application/geo+json"
+
+// GeoJSON object types
+const (
+	TypeFeatureCollection = "FeatureCollection"
+	TypeFeature           = "Feature"
+	TypePoint             = "Point"
+)
+
+// Custom errors
+var (
+	ErrNotFeatureCollection = errors.New("geojson: not a FeatureCollection")
+	ErrNotFeature           = errors.New("geojson: not a Feature")
+	ErrInvalidGeometry      = errors.New("geojson: geometry must be a Point with longitude and latitude")
+)
+
+// FeatureCollection is a list of driver features
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a driver at its location
+type Feature struct {
+	Type       string       `json:"type"`
+	ID         string       `json:"id,omitempty"`
+	Geometry   domain.Point `json:"geometry"`
+	Properties Properties   `json:"properties"`
+}
+
+// Properties describe the driver of a feature
+type Properties struct {
+	DriverID  string    `json:"driver_id"`
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+	// Distance is the distance in kilometers from the point the drivers were
+	// searched around. It is omitted when there was no such point.
+	Distance *float64 `json:"distance,omitempty"`
+}
+
+// DistanceFunc returns the distance of a driver in kilometers
+type DistanceFunc func(location *domain.DriverLocation) float64
+
+// DistanceFrom measures the great-circle distance of drivers from a point
+func DistanceFrom(lat, lon float64) DistanceFunc {
+	origin := haversine.Coord{Lat: lat, Lon: lon}
+	return func(location *domain.DriverLocation) float64 {
+		lat, lon := location.Location.GetCoordinates()
+		_, km := haversine.Distance(origin, haversine.Coord{Lat: lat, Lon: lon})
+		return km
+	}
+}
+
+// NewFeatureCollection converts driver locations to a feature collection.
+// A nil distance leaves the distance property out.
+func NewFeatureCollection(locations []*domain.DriverLocation, distance DistanceFunc) FeatureCollection {
+	fc := FeatureCollection{
+		Type:     TypeFeatureCollection,
+		Features: make([]Feature, len(locations)),
+	}
+	for i, location := range locations {
+		fc.Features[i] = NewFeature(location, distance)
+	}
+	return fc
+}
+
+// NewFeature converts a driver location to a feature. A nil distance leaves
+// the distance property out.
+func NewFeature(location *domain.DriverLocation, distance DistanceFunc) Feature {
+	f := Feature{
+		Type:     TypeFeature,
+		ID:       location.ID,
+		Geometry: location.Location,
+		Properties: Properties{
+			DriverID:  location.DriverID,
+			Status:    location.Status,
+			Timestamp: location.Timestamp,
+		},
+	}
+	if distance != nil {
+		d := distance(location)
+		f.Properties.Distance = &d
+	}
+	return f
+}
+
+// DriverLocations converts the features back to driver locations
+func (fc FeatureCollection) DriverLocations() ([]*domain.DriverLocation, error) {
+	if fc.Type != TypeFeatureCollection {
+		return nil, ErrNotFeatureCollection
+	}
+
+	locations := make([]*domain.DriverLocation, len(fc.Features))
+	for i, f := range fc.Features {
+		location, err := f.DriverLocation()
+		if err != nil {
+			return nil, err
+		}
+		locations[i] = location
+	}
+	return locations, nil
+}
+
+// DriverLocation converts the feature back to a driver location
+func (f Feature) DriverLocation() (*domain.DriverLocation, error) {
+	if f.Type != TypeFeature {
+		return nil, ErrNotFeature
+	}
+	if f.Geometry.Type != TypePoint || len(f.Geometry.Coordinates) < 2 {
+		return nil, ErrInvalidGeometry
+	}
+
+	return &domain.DriverLocation{
+		ID:        f.ID,
+		DriverID:  f.Properties.DriverID,
+		Location:  f.Geometry,
+		Status:    f.Properties.Status,
+		Timestamp: f.Properties.Timestamp,
+	}, nil
+}
+
+// Decode parses a GeoJSON feature collection into driver locations
+func Decode(data []byte) ([]*domain.DriverLocation, error) {
+	var fc FeatureCollection
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return nil, err
+	}
+	return fc.DriverLocations()
+}
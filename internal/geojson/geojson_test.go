@@ -0,0 +1,136 @@
+package geojson
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+func testLocations() []*domain.DriverLocation {
+	timestamp := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return []*domain.DriverLocation{
+		{ID: "loc-1", DriverID: "driver-1", Location: domain.NewPoint(41.0431, 29.0099), Status: "active", Timestamp: timestamp},
+		{ID: "loc-2", DriverID: "driver-2", Location: domain.NewPoint(41.05, 29.02), Status: "busy", Timestamp: timestamp.Add(time.Minute)},
+	}
+}
+
+func TestNewFeatureCollectionEncoding(t *testing.T) {
+	distance := func(*domain.DriverLocation) float64 { return 1.25 }
+	data, err := json.Marshal(NewFeatureCollection(testLocations()[:1], distance))
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"type": "FeatureCollection",
+		"features": [{
+			"type": "Feature",
+			"id": "loc-1",
+			"geometry": {"type": "Point", "coordinates": [29.0099, 41.0431]},
+			"properties": {
+				"driver_id": "driver-1",
+				"status": "active",
+				"timestamp": "2024-01-01T12:00:00Z",
+				"distance": 1.25
+			}
+		}]
+	}`, string(data))
+}
+
+func TestNewFeatureCollectionWithoutDistance(t *testing.T) {
+	fc := NewFeatureCollection(testLocations(), nil)
+
+	for _, f := range fc.Features {
+		assert.Nil(t, f.Properties.Distance)
+	}
+	data, err := json.Marshal(fc)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "distance")
+}
+
+func TestEmptyFeatureCollection(t *testing.T) {
+	data, err := json.Marshal(NewFeatureCollection(nil, nil))
+	require.NoError(t, err)
+
+	// Map libraries reject a null features member
+	assert.JSONEq(t, `{"type": "FeatureCollection", "features": []}`, string(data))
+}
+
+func TestRoundTrip(t *testing.T) {
+	locations := testLocations()
+
+	data, err := json.Marshal(NewFeatureCollection(locations, DistanceFrom(41, 29)))
+	require.NoError(t, err)
+	decoded, err := Decode(data)
+	require.NoError(t, err)
+
+	require.Len(t, decoded, len(locations))
+	for i := range locations {
+		assert.Equal(t, locations[i].ID, decoded[i].ID)
+		assert.Equal(t, locations[i].DriverID, decoded[i].DriverID)
+		assert.Equal(t, locations[i].Location, decoded[i].Location)
+		assert.Equal(t, locations[i].Status, decoded[i].Status)
+		assert.True(t, locations[i].Timestamp.Equal(decoded[i].Timestamp))
+	}
+}
+
+func TestRoundTripFeatureCollection(t *testing.T) {
+	fc := NewFeatureCollection(testLocations(), DistanceFrom(41, 29))
+
+	data, err := json.Marshal(fc)
+	require.NoError(t, err)
+	var decoded FeatureCollection
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	assert.Equal(t, fc, decoded)
+}
+
+func TestDistanceFrom(t *testing.T) {
+	distance := DistanceFrom(41, 29)
+
+	assert.Zero(t, distance(&domain.DriverLocation{Location: domain.NewPoint(41, 29)}))
+	// One degree of latitude is about 111 km
+	assert.InDelta(t, 111.2, distance(&domain.DriverLocation{Location: domain.NewPoint(42, 29)}), 0.5)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  error
+	}{
+		{
+			name: "not a collection",
+			data: `{"type": "Feature", "geometry": {"type": "Point", "coordinates": [29, 41]}}`,
+			err:  ErrNotFeatureCollection,
+		},
+		{
+			name: "not a feature",
+			data: `{"type": "FeatureCollection", "features": [{"type": "Point"}]}`,
+			err:  ErrNotFeature,
+		},
+		{
+			name: "not a point",
+			data: `{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "LineString", "coordinates": [29, 41]}}]}`,
+			err:  ErrInvalidGeometry,
+		},
+		{
+			name: "missing coordinates",
+			data: `{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "Point", "coordinates": [29]}}]}`,
+			err:  ErrInvalidGeometry,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode([]byte(tt.data))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	_, err := Decode([]byte(`{`))
+	assert.Error(t, err)
+}
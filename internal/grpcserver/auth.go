@@ -0,0 +1,136 @@
+package grpcserver
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/requestctx"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+// Metadata keys carrying credentials
+const (
+	authorizationKey = "authorization"
+	apiKeyKey        = "x-api-key"
+)
+
+// publicMethods are served without credentials
+var publicMethods = map[string]bool{
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo":      true,
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo": true,
+}
+
+// Auth authenticates calls with a bearer JWT or an API key, the same
+// credentials the REST API accepts, and checks the scope API keys need for
+// each method
+type Auth struct {
+	authService   service.AuthService
+	apiKeyService service.APIKeyService
+	scopes        map[string]string
+}
+
+// NewAuth creates the call authenticator. scopes maps full method names,
+// e.g. "/taxi.v1.LocationService/UpdateLocation", to the scope an API key
+// needs to call them; users authenticated with a JWT are not scoped. A nil
+// apiKeyService rejects API keys.
+func NewAuth(authService service.AuthService, apiKeyService service.APIKeyService, scopes map[string]string) *Auth {
+	return &Auth{
+		authService:   authService,
+		apiKeyService: apiKeyService,
+		scopes:        scopes,
+	}
+}
+
+// UnaryInterceptor authenticates unary calls
+func (a *Auth) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor authenticates streaming calls
+func (a *Auth) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (a *Auth) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if publicMethods[method] {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if rawKey := first(md, apiKeyKey); rawKey != "" {
+		if a.apiKeyService == nil {
+			return nil, status.Error(codes.Unauthenticated, "api keys are not accepted")
+		}
+		key, err := a.apiKeyService.Authenticate(ctx, rawKey)
+		if err != nil {
+			if err == service.ErrInvalidAPIKey {
+				return nil, status.Error(codes.Unauthenticated, "invalid api key")
+			}
+			return nil, status.Error(codes.Internal, "failed to verify api key")
+		}
+		if scope, ok := a.scopes[method]; ok && !key.HasScope(scope) {
+			return nil, status.Error(codes.PermissionDenied, "api key is missing scope "+scope)
+		}
+		return withAPIKey(ctx, key), nil
+	}
+
+	authHeader := first(md, authorizationKey)
+	if authHeader == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata required")
+	}
+
+	claims, err := a.authService.ValidateToken(strings.TrimPrefix(authHeader, "Bearer "))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid or unauthorized token")
+	}
+
+	return requestctx.WithUserID(ctx, claims.UserID), nil
+}
+
+type apiKeyContextKey struct{}
+
+func withAPIKey(ctx context.Context, key *domain.APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
+
+// APIKeyFrom returns the API key a call was authenticated with, if any
+func APIKeyFrom(ctx context.Context) (*domain.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey{}).(*domain.APIKey)
+	return key, ok
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// contextStream replaces the context of a server stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
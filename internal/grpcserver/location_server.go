@@ -0,0 +1,156 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	taxiv1 "github.com/yusufatac/bitaksi-case-study/api/taxi/v1"
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+// defaultStreamBatchSize is how many streamed locations are saved at once
+const defaultStreamBatchSize = 100
+
+type locationServer struct {
+	taxiv1.UnimplementedLocationServiceServer
+
+	locationService service.LocationService
+	batchSize       int
+}
+
+// LocationServerOption configures the location server
+type LocationServerOption func(*locationServer)
+
+// WithStreamBatchSize sets how many locations received by StreamLocations
+// are saved at once
+func WithStreamBatchSize(size int) LocationServerOption {
+	return func(s *locationServer) {
+		s.batchSize = size
+	}
+}
+
+// NewLocationServer serves the location service over gRPC
+func NewLocationServer(locationService service.LocationService, options ...LocationServerOption) taxiv1.LocationServiceServer {
+	s := &locationServer{
+		locationService: locationService,
+		batchSize:       defaultStreamBatchSize,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+func (s *locationServer) UpdateLocation(ctx context.Context, req *taxiv1.UpdateLocationRequest) (*taxiv1.UpdateLocationResponse, error) {
+	if err := validateLocation(req); err != nil {
+		return nil, toStatus(err)
+	}
+
+	if err := s.locationService.UpdateDriverLocation(ctx, req.GetDriverId(), req.GetLatitude(), req.GetLongitude()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &taxiv1.UpdateLocationResponse{}, nil
+}
+
+// StreamLocations saves the received locations in batches. A batch that
+// fails to save ends the stream; the locations of earlier batches stay saved.
+func (s *locationServer) StreamLocations(stream taxiv1.LocationService_StreamLocationsServer) error {
+	ctx := stream.Context()
+
+	var saved int64
+	batch := make([]domain.DriverLocation, 0, s.batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.locationService.UpdateDriverLocations(ctx, batch); err != nil {
+			return err
+		}
+		saved += int64(len(batch))
+		batch = make([]domain.DriverLocation, 0, s.batchSize)
+		return nil
+	}
+
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := validateLocation(req); err != nil {
+			return toStatus(err)
+		}
+		batch = append(batch, domain.DriverLocation{
+			DriverID: req.GetDriverId(),
+			Location: domain.NewPoint(req.GetLatitude(), req.GetLongitude()),
+			Status:   "active",
+		})
+
+		if len(batch) >= s.batchSize {
+			if err := flush(); err != nil {
+				return toStatus(err)
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return toStatus(err)
+	}
+
+	return stream.SendAndClose(&taxiv1.StreamLocationsResponse{Saved: saved})
+}
+
+func (s *locationServer) FindNearby(ctx context.Context, req *taxiv1.FindNearbyRequest) (*taxiv1.FindNearbyResponse, error) {
+	drivers, err := s.locationService.FindNearbyDrivers(ctx, req.GetLatitude(), req.GetLongitude(), req.GetRadius())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &taxiv1.FindNearbyResponse{
+		Drivers: make([]*taxiv1.DriverLocation, len(drivers)),
+	}
+	for i, driver := range drivers {
+		resp.Drivers[i] = toProtoLocation(driver)
+	}
+
+	return resp, nil
+}
+
+func validateLocation(req *taxiv1.UpdateLocationRequest) error {
+	if req.GetDriverId() == "" {
+		return service.ErrMissingDriverID
+	}
+	return validateCoordinates(req.GetLatitude(), req.GetLongitude())
+}
+
+func validateCoordinates(lat, lon float64) error {
+	if lat < -90 || lat > 90 {
+		return service.ErrInvalidLatitude
+	}
+	if lon < -180 || lon > 180 {
+		return service.ErrInvalidLongitude
+	}
+	return nil
+}
+
+func toProtoLocation(location *domain.DriverLocation) *taxiv1.DriverLocation {
+	lat, lon := location.Location.GetCoordinates()
+	return &taxiv1.DriverLocation{
+		Id:        location.ID,
+		DriverId:  location.DriverID,
+		Latitude:  lat,
+		Longitude: lon,
+		Status:    location.Status,
+		Timestamp: timestamppb.New(location.Timestamp),
+	}
+}
@@ -0,0 +1,41 @@
+package grpcserver
+
+import (
+	"context"
+
+	taxiv1 "github.com/yusufatac/bitaksi-case-study/api/taxi/v1"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+type matchingServer struct {
+	taxiv1.UnimplementedMatchingServiceServer
+
+	matchingService service.MatchingService
+}
+
+// NewMatchingServer serves the matching service over gRPC
+func NewMatchingServer(matchingService service.MatchingService) taxiv1.MatchingServiceServer {
+	return &matchingServer{
+		matchingService: matchingService,
+	}
+}
+
+func (s *matchingServer) FindNearest(ctx context.Context, req *taxiv1.FindNearestRequest) (*taxiv1.FindNearestResponse, error) {
+	if err := validateCoordinates(req.GetLatitude(), req.GetLongitude()); err != nil {
+		return nil, toStatus(err)
+	}
+	if req.GetRadius() <= 0 {
+		return nil, toStatus(service.ErrInvalidRadius)
+	}
+
+	driver, err := s.matchingService.FindNearestDriver(ctx, req.GetLatitude(), req.GetLongitude(), req.GetRadius())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	lat, lon := driver.Location.GetCoordinates()
+	return &taxiv1.FindNearestResponse{
+		Driver:   toProtoLocation(driver),
+		Distance: s.matchingService.CalculateDistance(req.GetLatitude(), req.GetLongitude(), lat, lon),
+	}, nil
+}
@@ -0,0 +1,133 @@
+// Package grpcserver serves the location and matching services over gRPC.
+//
+// The servers are thin adapters over service.LocationService and
+// service.MatchingService, so both APIs share validation, storage and
+// matching. Calls are authenticated by interceptors with the credentials the
+// REST API accepts, and server reflection is enabled for tools like grpcurl.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	taxiv1 "github.com/yusufatac/bitaksi-case-study/api/taxi/v1"
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+// LocationScopes are the scopes API keys need for the location service methods
+var LocationScopes = map[string]string{
+	taxiv1.LocationService_UpdateLocation_FullMethodName:  domain.ScopeLocationsWrite,
+	taxiv1.LocationService_StreamLocations_FullMethodName: domain.ScopeLocationsWrite,
+	taxiv1.LocationService_FindNearby_FullMethodName:      domain.ScopeLocationsRead,
+}
+
+// MatchingScopes are the scopes API keys need for the matching service methods
+var MatchingScopes = map[string]string{
+	taxiv1.MatchingService_FindNearest_FullMethodName: domain.ScopeLocationsRead,
+}
+
+// New creates a gRPC server that logs calls, turns panics into Internal
+// errors and authenticates calls with auth. Services are registered on the
+// returned server by the caller.
+func New(logger *slog.Logger, auth *Auth, options ...grpc.ServerOption) *grpc.Server {
+	options = append(options,
+		grpc.ChainUnaryInterceptor(unaryLogging(logger), auth.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(streamLogging(logger), auth.StreamInterceptor()),
+	)
+
+	server := grpc.NewServer(options...)
+	reflection.Register(server)
+
+	return server
+}
+
+func unaryLogging(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		start := time.Now()
+		defer func() {
+			if r := recover(); r != nil {
+				logger.ErrorContext(ctx, "panic recovered", "method", info.FullMethod, "panic", r, "stack", string(debug.Stack()))
+				err = status.Error(codes.Internal, "internal error")
+			}
+			logCall(ctx, logger, info.FullMethod, start, err)
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+func streamLogging(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx := ss.Context()
+		start := time.Now()
+		defer func() {
+			if r := recover(); r != nil {
+				logger.ErrorContext(ctx, "panic recovered", "method", info.FullMethod, "panic", r, "stack", string(debug.Stack()))
+				err = status.Error(codes.Internal, "internal error")
+			}
+			logCall(ctx, logger, info.FullMethod, start, err)
+		}()
+
+		return handler(srv, ss)
+	}
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, method string, start time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	}
+
+	logger.Log(ctx, level, "grpc call",
+		"method", method,
+		"code", code.String(),
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+}
+
+// toStatus maps service errors to gRPC status errors by their domain kind.
+// Only the message of a domain.Error is passed on to clients; wrapping
+// context and unexpected errors are not.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "canceled")
+	}
+
+	kind := domain.KindOf(err)
+	code, ok := kindCodes[kind]
+	if !ok {
+		return status.Error(codes.Internal, "internal error")
+	}
+
+	message := kind.Error()
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		message = domainErr.Error()
+	}
+	return status.Error(code, message)
+}
+
+// kindCodes are the gRPC codes of the domain error kinds
+var kindCodes = map[error]codes.Code{
+	domain.ErrValidation:      codes.InvalidArgument,
+	domain.ErrNotFound:        codes.NotFound,
+	domain.ErrConflict:        codes.FailedPrecondition,
+	domain.ErrUnauthorized:    codes.Unauthenticated,
+	domain.ErrForbidden:       codes.PermissionDenied,
+	domain.ErrUnavailable:     codes.Unavailable,
+	domain.ErrTooManyRequests: codes.ResourceExhausted,
+	domain.ErrLocked:          codes.PermissionDenied,
+}
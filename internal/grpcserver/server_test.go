@@ -0,0 +1,321 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	taxiv1 "github.com/yusufatac/bitaksi-case-study/api/taxi/v1"
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+const testSecret = "test-secret"
+
+type fakeLocationService struct {
+	mu      sync.Mutex
+	updates []domain.DriverLocation
+	batches [][]domain.DriverLocation
+	nearby  []*domain.DriverLocation
+	err     error
+}
+
+func (f *fakeLocationService) UpdateDriverLocation(ctx context.Context, driverID string, lat, lon float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.updates = append(f.updates, domain.DriverLocation{DriverID: driverID, Location: domain.NewPoint(lat, lon)})
+	return nil
+}
+
+func (f *fakeLocationService) UpdateDriverLocations(ctx context.Context, locations []domain.DriverLocation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.batches = append(f.batches, locations)
+	return nil
+}
+
+func (f *fakeLocationService) FindNearbyDrivers(ctx context.Context, lat, lon, radius float64) ([]*domain.DriverLocation, error) {
+	if radius <= 0 {
+		return nil, service.ErrInvalidRadius
+	}
+	return f.nearby, f.err
+}
+
+func (f *fakeLocationService) CountActiveDrivers(ctx context.Context) (int64, error) {
+	return int64(len(f.nearby)), nil
+}
+
+type fakeMatchingService struct {
+	driver *domain.DriverLocation
+	err    error
+}
+
+func (f *fakeMatchingService) FindNearestDriver(ctx context.Context, lat, lon, radius float64) (*domain.DriverLocation, error) {
+	return f.driver, f.err
+}
+
+func (f *fakeMatchingService) CalculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	return 1.5
+}
+
+type fakeAPIKeyService struct {
+	keys map[string]*domain.APIKey
+}
+
+func (f *fakeAPIKeyService) CreateAPIKey(ctx context.Context, name string, scopes []string) (*domain.APIKey, string, error) {
+	return nil, "", nil
+}
+
+func (f *fakeAPIKeyService) ListAPIKeys(ctx context.Context) ([]*domain.APIKey, error) {
+	return nil, nil
+}
+
+func (f *fakeAPIKeyService) RevokeAPIKey(ctx context.Context, id string) error {
+	return nil
+}
+
+func (f *fakeAPIKeyService) Authenticate(ctx context.Context, rawKey string) (*domain.APIKey, error) {
+	if key, ok := f.keys[rawKey]; ok {
+		return key, nil
+	}
+	return nil, service.ErrInvalidAPIKey
+}
+
+// dial serves the location and matching services on an in-memory listener
+// and returns a connection to them
+func dial(t *testing.T, locations service.LocationService, matching service.MatchingService, options ...LocationServerOption) *grpc.ClientConn {
+	t.Helper()
+
+	apiKeys := &fakeAPIKeyService{keys: map[string]*domain.APIKey{
+		"read-key":  {ID: "1", Scopes: []string{domain.ScopeLocationsRead}},
+		"write-key": {ID: "2", Scopes: []string{domain.ScopeLocationsWrite}},
+	}}
+	scopes := maps.Clone(LocationScopes)
+	maps.Copy(scopes, MatchingScopes)
+	auth := NewAuth(service.NewAuthService(nil, testSecret), apiKeys, scopes)
+	server := New(slog.New(slog.NewTextHandler(io.Discard, nil)), auth)
+	taxiv1.RegisterLocationServiceServer(server, NewLocationServer(locations, options...))
+	taxiv1.RegisterMatchingServiceServer(server, NewMatchingServer(matching))
+
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func bearer(t *testing.T) context.Context {
+	t.Helper()
+
+	claims := service.Claims{
+		UserID:        "user-1",
+		Username:      "alice",
+		Authenticated: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	require.NoError(t, err)
+
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func withAPIKeyMetadata(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+}
+
+func TestAuth(t *testing.T) {
+	conn := dial(t, &fakeLocationService{}, &fakeMatchingService{})
+	client := taxiv1.NewLocationServiceClient(conn)
+	req := &taxiv1.UpdateLocationRequest{DriverId: "driver-1", Latitude: 41, Longitude: 29}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		code codes.Code
+	}{
+		{name: "no credentials", ctx: context.Background(), code: codes.Unauthenticated},
+		{name: "invalid token", ctx: metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer nope"), code: codes.Unauthenticated},
+		{name: "invalid api key", ctx: withAPIKeyMetadata("nope"), code: codes.Unauthenticated},
+		{name: "api key missing scope", ctx: withAPIKeyMetadata("read-key"), code: codes.PermissionDenied},
+		{name: "api key with scope", ctx: withAPIKeyMetadata("write-key"), code: codes.OK},
+		{name: "bearer token", ctx: bearer(t), code: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.UpdateLocation(tt.ctx, req)
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
+
+func TestUpdateLocation(t *testing.T) {
+	locations := &fakeLocationService{}
+	client := taxiv1.NewLocationServiceClient(dial(t, locations, &fakeMatchingService{}))
+
+	_, err := client.UpdateLocation(bearer(t), &taxiv1.UpdateLocationRequest{DriverId: "driver-1", Latitude: 41, Longitude: 29})
+	require.NoError(t, err)
+	require.Len(t, locations.updates, 1)
+	assert.Equal(t, "driver-1", locations.updates[0].DriverID)
+	assert.Equal(t, domain.NewPoint(41, 29), locations.updates[0].Location)
+
+	_, err = client.UpdateLocation(bearer(t), &taxiv1.UpdateLocationRequest{DriverId: "driver-1", Latitude: 91})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.UpdateLocation(bearer(t), &taxiv1.UpdateLocationRequest{Latitude: 41, Longitude: 29})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestStreamLocations(t *testing.T) {
+	locations := &fakeLocationService{}
+	client := taxiv1.NewLocationServiceClient(dial(t, locations, &fakeMatchingService{}, WithStreamBatchSize(2)))
+
+	stream, err := client.StreamLocations(bearer(t))
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, stream.Send(&taxiv1.UpdateLocationRequest{DriverId: "driver-1", Latitude: 41 + float64(i)/100, Longitude: 29}))
+	}
+	resp, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(5), resp.GetSaved())
+	require.Len(t, locations.batches, 3)
+	assert.Len(t, locations.batches[0], 2)
+	assert.Len(t, locations.batches[2], 1)
+	assert.Equal(t, domain.NewPoint(41.04, 29), locations.batches[2][0].Location)
+	assert.Equal(t, "active", locations.batches[2][0].Status)
+}
+
+func TestStreamLocationsInvalidLocation(t *testing.T) {
+	locations := &fakeLocationService{}
+	client := taxiv1.NewLocationServiceClient(dial(t, locations, &fakeMatchingService{}))
+
+	stream, err := client.StreamLocations(withAPIKeyMetadata("write-key"))
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&taxiv1.UpdateLocationRequest{DriverId: "driver-1", Latitude: 41, Longitude: 181}))
+	_, err = stream.CloseAndRecv()
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Empty(t, locations.batches)
+}
+
+func TestFindNearby(t *testing.T) {
+	timestamp := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	locations := &fakeLocationService{nearby: []*domain.DriverLocation{
+		{ID: "loc-1", DriverID: "driver-1", Location: domain.NewPoint(41.01, 29.02), Status: "active", Timestamp: timestamp},
+	}}
+	client := taxiv1.NewLocationServiceClient(dial(t, locations, &fakeMatchingService{}))
+
+	resp, err := client.FindNearby(withAPIKeyMetadata("read-key"), &taxiv1.FindNearbyRequest{Latitude: 41, Longitude: 29, Radius: 1000})
+	require.NoError(t, err)
+	require.Len(t, resp.GetDrivers(), 1)
+
+	driver := resp.GetDrivers()[0]
+	assert.Equal(t, "driver-1", driver.GetDriverId())
+	assert.Equal(t, 41.01, driver.GetLatitude())
+	assert.Equal(t, 29.02, driver.GetLongitude())
+	assert.Equal(t, timestamp, driver.GetTimestamp().AsTime())
+
+	_, err = client.FindNearby(withAPIKeyMetadata("read-key"), &taxiv1.FindNearbyRequest{Latitude: 41, Longitude: 29})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestFindNearest(t *testing.T) {
+	driver := &domain.DriverLocation{DriverID: "driver-1", Location: domain.NewPoint(41.01, 29.02)}
+	client := taxiv1.NewMatchingServiceClient(dial(t, &fakeLocationService{}, &fakeMatchingService{driver: driver}))
+
+	resp, err := client.FindNearest(bearer(t), &taxiv1.FindNearestRequest{Latitude: 41, Longitude: 29, Radius: 1000})
+	require.NoError(t, err)
+	assert.Equal(t, "driver-1", resp.GetDriver().GetDriverId())
+	assert.Equal(t, 1.5, resp.GetDistance())
+}
+
+func TestFindNearestScopes(t *testing.T) {
+	driver := &domain.DriverLocation{DriverID: "driver-1", Location: domain.NewPoint(41.01, 29.02)}
+	client := taxiv1.NewMatchingServiceClient(dial(t, &fakeLocationService{}, &fakeMatchingService{driver: driver}))
+	req := &taxiv1.FindNearestRequest{Latitude: 41, Longitude: 29, Radius: 1000}
+
+	_, err := client.FindNearest(withAPIKeyMetadata("write-key"), req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.FindNearest(withAPIKeyMetadata("read-key"), req)
+	assert.NoError(t, err)
+}
+
+func TestFindNearestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    codes.Code
+		message string
+	}{
+		{name: "no driver", err: service.ErrNoDriversFound, code: codes.NotFound, message: service.ErrNoDriversFound.Error()},
+		{name: "upstream down", err: service.ErrLocationServiceUnavailable, code: codes.Unavailable, message: service.ErrLocationServiceUnavailable.Error()},
+		{name: "wrapped upstream error", err: fmt.Errorf("%w: %w", service.ErrLocationServiceUnavailable, assert.AnError), code: codes.Unavailable, message: service.ErrLocationServiceUnavailable.Error()},
+		{name: "deadline", err: context.DeadlineExceeded, code: codes.DeadlineExceeded, message: "deadline exceeded"},
+		{name: "unexpected", err: assert.AnError, code: codes.Internal, message: "internal error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := taxiv1.NewMatchingServiceClient(dial(t, &fakeLocationService{}, &fakeMatchingService{err: tt.err}))
+
+			_, err := client.FindNearest(bearer(t), &taxiv1.FindNearestRequest{Latitude: 41, Longitude: 29, Radius: 1000})
+
+			assert.Equal(t, tt.code, status.Code(err))
+			assert.Equal(t, tt.message, status.Convert(err).Message())
+		})
+	}
+}
+
+func TestReflection(t *testing.T) {
+	conn := dial(t, &fakeLocationService{}, &fakeMatchingService{})
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+
+	var services []string
+	for _, svc := range resp.GetListServicesResponse().GetService() {
+		services = append(services, svc.GetName())
+	}
+	assert.Contains(t, services, "taxi.v1.LocationService")
+	assert.Contains(t, services, "taxi.v1.MatchingService")
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	apiKeyService := new(MockAPIKeyService)
+	h := NewAPIKeyHandler(apiKeyService)
+	engine := newTestEngine(func(routes *gin.RouterGroup) {
+		routes.POST("/admin/api-keys", h.CreateAPIKey)
+	})
+	scopes := []string{domain.ScopeLocationsRead}
+	key := &domain.APIKey{ID: "key-1", Name: "partner", Prefix: "tk_abc", Scopes: scopes}
+	apiKeyService.On("CreateAPIKey", mock.Anything, "partner", scopes).Return(key, "tk_abc.secret", nil)
+
+	w := request{
+		method: http.MethodPost,
+		path:   "/api/v1/admin/api-keys",
+		user:   "admin-1",
+		role:   domain.RoleAdmin,
+		body:   `{"name": "partner", "scopes": ["` + domain.ScopeLocationsRead + `"]}`,
+	}.do(engine)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var resp CreateAPIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "tk_abc.secret", resp.Key)
+	assert.Equal(t, "key-1", resp.APIKey.ID)
+}
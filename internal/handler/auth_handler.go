@@ -27,6 +27,9 @@ func NewAuthHandler(authService service.AuthService) *AuthHandler {
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
 
 // Register godoc
 // @Summary Register new user
@@ -37,7 +40,7 @@ func NewAuthHandler(authService service.AuthService) *AuthHandler {
 // @Param request body RegisterRequest true "Register request"
 // @Success 201 {object} RegisterResponse "User successfully registered"
 // @Failure 400 {object} ErrorResponse "Invalid request parameters"
-// @Failure 409 {object} ErrorResponse "User already exists"
+// @Failure 409 {object} ErrorResponse "User or email already exists"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /auth/register [post]
 func (h *AuthHandler) Register(c *gin.Context) {
@@ -55,11 +58,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	user, err := h.authService.Register(c, creds)
 	if err != nil {
-		if err == service.ErrUserAlreadyExists {
-			c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
@@ -79,6 +78,9 @@ func (h *AuthHandler) Register(c *gin.Context) {
 // @Success 200 {object} LoginResponse "Successfully authenticated"
 // @Failure 400 {object} ErrorResponse "Invalid request parameters"
 // @Failure 401 {object} ErrorResponse "Invalid credentials"
+// @Failure 403 {object} ErrorResponse "Email not verified"
+// @Failure 423 {object} ErrorResponse "Account locked"
+// @Failure 429 {object} ErrorResponse "Too many failed attempts"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -88,13 +90,9 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := h.authService.Login(c, req.Username, req.Password)
+	token, err := h.authService.Login(c, req.Username, req.Password, c.ClientIP())
 	if err != nil {
-		if err == service.ErrInvalidCredentials {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
@@ -103,6 +101,132 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	})
 }
 
+// VerifyEmail godoc
+// @Summary Verify email
+// @Description Activate an account with the token sent by email after registration
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body TokenRequest true "Verification token"
+// @Success 200 {object} Response "Email verified"
+// @Failure 400 {object} ErrorResponse "Invalid or expired token"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /auth/verify-email [post]
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request body"})
+		return
+	}
+
+	if err := h.authService.VerifyEmail(c, req.Token); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Message: "email verified successfully"})
+}
+
+// ResendVerification godoc
+// @Summary Resend verification email
+// @Description Send a new verification email to a pending account
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body EmailRequest true "Account email"
+// @Success 202 {object} Response "Verification email sent if the account exists"
+// @Failure 400 {object} ErrorResponse "Invalid request parameters"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /auth/verify-email/resend [post]
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request body"})
+		return
+	}
+
+	if err := h.authService.ResendVerification(c, req.Email); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, Response{Message: "if the account exists and is not verified yet, a verification email has been sent"})
+}
+
+// ForgotPassword godoc
+// @Summary Request password reset
+// @Description Email a password reset link to the owner of the address
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body EmailRequest true "Account email"
+// @Success 202 {object} Response "Reset email sent if the account exists"
+// @Failure 400 {object} ErrorResponse "Invalid request parameters"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /auth/password/forgot [post]
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request body"})
+		return
+	}
+
+	if err := h.authService.RequestPasswordReset(c, req.Email); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, Response{Message: "if the account exists, a password reset email has been sent"})
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password with the token sent by email
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} Response "Password reset"
+// @Failure 400 {object} ErrorResponse "Invalid or expired token"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /auth/password/reset [post]
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request body"})
+		return
+	}
+
+	if err := h.authService.ResetPassword(c, req.Token, req.Password); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Message: "password reset successfully"})
+}
+
+// UnlockUser godoc
+// @Summary Unlock user
+// @Description Clear the failed login lockout of a user before the cooldown expires
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param username path string true "Username"
+// @Success 200 {object} Response "User unlocked"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "User not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /admin/users/{username}/unlock [post]
+func (h *AuthHandler) UnlockUser(c *gin.Context) {
+	if err := h.authService.UnlockUser(c, c.Param("username")); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Message: "user unlocked successfully"})
+}
+
 // Request/Response types
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=50"`
@@ -123,3 +247,16 @@ type LoginRequest struct {
 type LoginResponse struct {
 	Token string `json:"token"`
 }
+
+type TokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type EmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6,max=100"`
+}
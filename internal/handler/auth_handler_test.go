@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		err        error
+		status     int
+		body       string
+		retryAfter string
+	}{
+		{name: "success", token: "jwt", status: http.StatusOK, body: `{"token": "jwt"}`},
+		{name: "invalid credentials", err: service.ErrInvalidCredentials, status: http.StatusUnauthorized, body: `{"error": "invalid credentials"}`},
+		{
+			name:       "too many attempts",
+			err:        &service.LoginBlockedError{Reason: service.ErrTooManyAttempts, RetryAfter: 1500 * time.Millisecond},
+			status:     http.StatusTooManyRequests,
+			body:       `{"error": "` + service.ErrTooManyAttempts.Error() + `"}`,
+			retryAfter: "2",
+		},
+		{
+			name:       "account locked",
+			err:        &service.LoginBlockedError{Reason: service.ErrAccountLocked, RetryAfter: time.Minute},
+			status:     http.StatusLocked,
+			body:       `{"error": "` + service.ErrAccountLocked.Error() + `"}`,
+			retryAfter: "60",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authService := new(MockAuthService)
+			h := NewAuthHandler(authService)
+			engine := newTestEngine(func(routes *gin.RouterGroup) {
+				routes.POST("/auth/login", h.Login)
+			})
+			authService.On("Login", mock.Anything, "rider", "secret", mock.Anything).Return(tt.token, tt.err)
+
+			w := request{method: http.MethodPost, path: "/api/v1/auth/login", body: `{"username": "rider", "password": "secret"}`}.do(engine)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.JSONEq(t, tt.body, w.Body.String())
+			assert.Equal(t, tt.retryAfter, w.Header().Get("Retry-After"))
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusufatac/bitaksi-case-study/internal/breaker"
+	"github.com/yusufatac/bitaksi-case-study/internal/httpclient"
+	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+)
+
+type BreakerHandler struct {
+	httpClient    *httpclient.Client
+	routeBreakers *middleware.RouteCircuitBreakers
+}
+
+// NewBreakerHandler exposes the outbound breakers of httpClient and, when
+// set, the inbound routeBreakers
+func NewBreakerHandler(httpClient *httpclient.Client, routeBreakers *middleware.RouteCircuitBreakers) *BreakerHandler {
+	return &BreakerHandler{
+		httpClient:    httpClient,
+		routeBreakers: routeBreakers,
+	}
+}
+
+// ListBreakers godoc
+// @Summary List circuit breakers
+// @Description Show the state of the circuit breaker of every host called by this service and of every guarded route
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} ListBreakersResponse "Circuit breakers"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Router /admin/breakers [get]
+func (h *BreakerHandler) ListBreakers(c *gin.Context) {
+	resp := ListBreakersResponse{
+		Breakers: h.httpClient.Breakers(),
+		Routes:   []breaker.Status{},
+	}
+	if h.routeBreakers != nil {
+		resp.Routes = h.routeBreakers.Breakers()
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Request/Response types
+type ListBreakersResponse struct {
+	Breakers []breaker.Status `json:"breakers"`
+	Routes   []breaker.Status `json:"routes"`
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/httpclient"
+)
+
+func TestListBreakers(t *testing.T) {
+	h := NewBreakerHandler(httpclient.New(), nil)
+	engine := newTestEngine(func(routes *gin.RouterGroup) {
+		routes.GET("/admin/breakers", h.ListBreakers)
+	})
+
+	w := request{method: http.MethodGet, path: "/api/v1/admin/breakers", user: "admin-1", role: domain.RoleAdmin}.do(engine)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"breakers": [], "routes": []}`, w.Body.String())
+}
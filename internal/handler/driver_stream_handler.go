@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+	"github.com/yusufatac/bitaksi-case-study/internal/stream"
+)
+
+type DriverStreamHandler struct {
+	locationService service.LocationService
+	config          stream.Config
+	logger          *slog.Logger
+	upgrader        websocket.Upgrader
+}
+
+func NewDriverStreamHandler(locationService service.LocationService, config stream.Config, logger *slog.Logger) *DriverStreamHandler {
+	return &DriverStreamHandler{
+		locationService: locationService,
+		config:          config,
+		logger:          logger,
+		upgrader: websocket.Upgrader{
+			Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(status)
+				_, _ = w.Write([]byte(`{"error":"websocket upgrade failed"}`))
+			},
+		},
+	}
+}
+
+// Stream godoc
+// @Summary Stream driver locations
+// @Description Upgrade to a WebSocket over which a driver pushes [seq, lat, lon] location frames.
+// @Description Frames are coalesced and saved at most once per flush interval; the server answers
+// @Description with {"type":"ack","seq":N} for the latest saved frame, which covers all earlier frames,
+// @Description and {"type":"error","seq":N,"error":"..."} for rejected frames. The server pings the
+// @Description client periodically and disconnects clients that stop answering or reading acks.
+// @Tags locations
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param driver_id query string false "Driver to stream for, defaults to the authenticated user"
+// @Success 101 "Switching to the WebSocket protocol"
+// @Failure 400 {object} ErrorResponse "Missing driver ID or not a WebSocket handshake"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "API key is missing the required scope"
+// @Router /drivers/stream [get]
+func (h *DriverStreamHandler) Stream(c *gin.Context) {
+	driverID := c.Query("driver_id")
+	if driverID == "" {
+		driverID = c.GetString(middleware.ContextUserID)
+	}
+	if driverID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "driver_id is required"})
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already replied
+		return
+	}
+
+	// The session outlives the request deadline but keeps its values, such
+	// as the request ID used in logs
+	ctx := context.WithoutCancel(c.Request.Context())
+
+	h.logger.InfoContext(ctx, "driver stream opened", "driver_id", driverID)
+	stream.Serve(ctx, conn, driverID, h.locationService, h.config, h.logger)
+	h.logger.InfoContext(ctx, "driver stream closed", "driver_id", driverID)
+}
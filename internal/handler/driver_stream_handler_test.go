@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/stream"
+)
+
+func TestStreamRequiresHandshake(t *testing.T) {
+	h := NewDriverStreamHandler(new(MockLocationService), stream.Config{FlushInterval: time.Second, PingInterval: time.Second, PongWait: 2 * time.Second}, slog.Default())
+	engine := newTestEngine(func(routes *gin.RouterGroup) {
+		routes.GET("/drivers/stream", h.Stream)
+	})
+
+	// API keys have to name the driver
+	w := request{method: http.MethodGet, path: "/api/v1/drivers/stream"}.do(engine)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "driver_id is required"}`, w.Body.String())
+
+	w = request{method: http.MethodGet, path: "/api/v1/drivers/stream", user: "driver-1"}.do(engine)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "websocket upgrade failed"}`, w.Body.String())
+}
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/geojson"
+)
+
+// wantsGeoJSON reports whether the client prefers GeoJSON over plain JSON.
+// Responses that depend on it vary by Accept.
+func wantsGeoJSON(c *gin.Context) bool {
+	// Added, not set, so the Vary: Origin of the CORS middleware stays
+	c.Writer.Header().Add("Vary", "Accept")
+	return c.NegotiateFormat(gin.MIMEJSON, geojson.MediaType) == geojson.MediaType
+}
+
+// respondGeoJSON writes fc with the GeoJSON media type
+func respondGeoJSON(c *gin.Context, fc geojson.FeatureCollection) {
+	body, err := json.Marshal(fc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "failed to encode response"})
+		return
+	}
+	c.Data(http.StatusOK, geojson.MediaType, body)
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/geojson"
+	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+)
+
+func TestWantsGeoJSONKeepsCORSVary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cors, err := middleware.NewCORS(middleware.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}})
+	require.NoError(t, err)
+
+	engine := gin.New()
+	engine.Use(cors.Middleware())
+	engine.GET("/drivers", func(c *gin.Context) {
+		if wantsGeoJSON(c) {
+			respondGeoJSON(c, geojson.FeatureCollection{Type: "FeatureCollection"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/drivers", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Accept", geojson.MediaType)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	assert.Equal(t, geojson.MediaType, w.Header().Get("Content-Type"))
+	assert.ElementsMatch(t, []string{"Origin", "Accept"}, w.Header().Values("Vary"))
+}
@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+// MockAuthService is a mock implementation of the AuthService interface
+type MockAuthService struct {
+	mock.Mock
+}
+
+func (m *MockAuthService) Register(ctx context.Context, creds domain.UserCredentials) (*domain.User, error) {
+	args := m.Called(ctx, creds)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *MockAuthService) Login(ctx context.Context, username, password, clientIP string) (string, error) {
+	args := m.Called(ctx, username, password, clientIP)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockAuthService) ValidateToken(token string) (*service.Claims, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.Claims), args.Error(1)
+}
+
+func (m *MockAuthService) UnlockUser(ctx context.Context, username string) error {
+	return m.Called(ctx, username).Error(0)
+}
+
+func (m *MockAuthService) VerifyEmail(ctx context.Context, token string) error {
+	return m.Called(ctx, token).Error(0)
+}
+
+func (m *MockAuthService) ResendVerification(ctx context.Context, email string) error {
+	return m.Called(ctx, email).Error(0)
+}
+
+func (m *MockAuthService) SendVerification(ctx context.Context, user *domain.User) error {
+	return m.Called(ctx, user).Error(0)
+}
+
+func (m *MockAuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	return m.Called(ctx, email).Error(0)
+}
+
+func (m *MockAuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	return m.Called(ctx, token, newPassword).Error(0)
+}
+
+func (m *MockAuthService) CheckPassword(ctx context.Context, user *domain.User, password string) error {
+	return m.Called(ctx, user, password).Error(0)
+}
+
+// MockUserService is a mock implementation of the UserService interface
+type MockUserService struct {
+	mock.Mock
+}
+
+func (m *MockUserService) GetUser(ctx context.Context, userID string) (*domain.User, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *MockUserService) UpdateUser(ctx context.Context, userID string, update domain.UserUpdate) (*domain.User, error) {
+	args := m.Called(ctx, userID, update)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *MockUserService) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) error {
+	return m.Called(ctx, userID, currentPassword, newPassword).Error(0)
+}
+
+func (m *MockUserService) DeleteUser(ctx context.Context, userID string) error {
+	return m.Called(ctx, userID).Error(0)
+}
+
+func (m *MockUserService) ListUsers(ctx context.Context, filter domain.UserFilter) (*service.UserPage, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.UserPage), args.Error(1)
+}
+
+// MockAPIKeyService is a mock implementation of the APIKeyService interface
+type MockAPIKeyService struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyService) CreateAPIKey(ctx context.Context, name string, scopes []string) (*domain.APIKey, string, error) {
+	args := m.Called(ctx, name, scopes)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).(*domain.APIKey), args.String(1), args.Error(2)
+}
+
+func (m *MockAPIKeyService) ListAPIKeys(ctx context.Context) ([]*domain.APIKey, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) RevokeAPIKey(ctx context.Context, id string) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func (m *MockAPIKeyService) Authenticate(ctx context.Context, rawKey string) (*domain.APIKey, error) {
+	args := m.Called(ctx, rawKey)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+// MockLocationService is a mock implementation of the LocationService interface
+type MockLocationService struct {
+	mock.Mock
+}
+
+func (m *MockLocationService) UpdateDriverLocation(ctx context.Context, driverID string, lat, lon float64) error {
+	return m.Called(ctx, driverID, lat, lon).Error(0)
+}
+
+func (m *MockLocationService) UpdateDriverLocations(ctx context.Context, locations []domain.DriverLocation) error {
+	return m.Called(ctx, locations).Error(0)
+}
+
+func (m *MockLocationService) FindNearbyDrivers(ctx context.Context, lat, lon, radius float64) ([]*domain.DriverLocation, error) {
+	args := m.Called(ctx, lat, lon, radius)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.DriverLocation), args.Error(1)
+}
+
+func (m *MockLocationService) CountActiveDrivers(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockMatchingService is a mock implementation of the MatchingService interface
+type MockMatchingService struct {
+	mock.Mock
+}
+
+func (m *MockMatchingService) FindNearestDriver(ctx context.Context, lat, lon, radius float64) (*domain.DriverLocation, error) {
+	args := m.Called(ctx, lat, lon, radius)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.DriverLocation), args.Error(1)
+}
+
+func (m *MockMatchingService) CalculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	return m.Called(lat1, lon1, lat2, lon2).Get(0).(float64)
+}
+
+// MockRideService is a mock implementation of the RideService interface
+type MockRideService struct {
+	mock.Mock
+}
+
+func (m *MockRideService) CreateRide(ctx context.Context, riderID, matchToken string) (*domain.Ride, error) {
+	args := m.Called(ctx, riderID, matchToken)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Ride), args.Error(1)
+}
+
+func (m *MockRideService) GetRide(ctx context.Context, rideID string) (*domain.Ride, error) {
+	args := m.Called(ctx, rideID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Ride), args.Error(1)
+}
+
+func (m *MockRideService) CompleteRide(ctx context.Context, rideID string) (*domain.Ride, error) {
+	args := m.Called(ctx, rideID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Ride), args.Error(1)
+}
+
+// newTestEngine serves the routes registered by register under /api/v1
+// with the error handling of the router. Instead of authenticating,
+// requests name their user in X-User and its role in X-Role; requests
+// without X-User stand for API keys.
+func newTestEngine(register func(routes *gin.RouterGroup)) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.Use(middleware.Errors(), func(c *gin.Context) {
+		if userID := c.GetHeader("X-User"); userID != "" {
+			c.Set(middleware.ContextUserID, userID)
+			c.Set(middleware.ContextRole, c.GetHeader("X-Role"))
+		}
+	})
+	register(engine.Group("/api/v1"))
+	return engine
+}
+
+// request is a request to a test engine
+type request struct {
+	method string
+	path   string
+	user   string
+	role   string
+	accept string
+	body   string
+}
+
+func (r request) do(engine *gin.Engine) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(r.method, r.path, strings.NewReader(r.body))
+	req.Header.Set("Content-Type", "application/json")
+	if r.accept != "" {
+		req.Header.Set("Accept", r.accept)
+	}
+	if r.user != "" {
+		req.Header.Set("X-User", r.user)
+		req.Header.Set("X-Role", r.role)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
@@ -4,7 +4,9 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/yusufatac/bitaksi-case-study/internal/batchcodec"
 	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/geojson"
 	"github.com/yusufatac/bitaksi-case-study/internal/service"
 )
 
@@ -25,10 +27,12 @@ func NewLocationHandler(locationService service.LocationService) *LocationHandle
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Security APIKeyAuth
 // @Param request body UpdateLocationRequest true "Location update request"
 // @Success 200 {object} Response "Location successfully updated"
 // @Failure 400 {object} ErrorResponse "Invalid request parameters"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "API key is missing the required scope"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /locations [post]
 func (h *LocationHandler) UpdateLocation(c *gin.Context) {
@@ -39,7 +43,7 @@ func (h *LocationHandler) UpdateLocation(c *gin.Context) {
 	}
 
 	if err := h.locationService.UpdateDriverLocation(c, req.DriverID, req.Latitude, req.Longitude); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
@@ -48,26 +52,28 @@ func (h *LocationHandler) UpdateLocation(c *gin.Context) {
 
 // UpdateLocations godoc
 // @Summary Update multiple driver locations
-// @Description Update locations for multiple drivers in batch
+// @Description Update locations for multiple drivers in batch. Besides JSON, the body can use the compact binary format described in internal/batchcodec, sent with `Content-Type: application/vnd.taxi.location-batch`.
 // @Tags locations
-// @Accept json
+// @Accept json,application/vnd.taxi.location-batch
 // @Produce json
 // @Security BearerAuth
+// @Security APIKeyAuth
 // @Param request body []domain.DriverLocation true "Batch location update request"
 // @Success 200 {object} Response "Locations successfully updated"
 // @Failure 400 {object} ErrorResponse "Invalid request parameters"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "API key is missing the required scope"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /locations/batch [post]
 func (h *LocationHandler) UpdateLocations(c *gin.Context) {
-	var locations []domain.DriverLocation
-	if err := c.ShouldBindJSON(&locations); err != nil {
+	locations, err := bindLocations(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request body"})
 		return
 	}
 
 	if err := h.locationService.UpdateDriverLocations(c, locations); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
@@ -76,15 +82,17 @@ func (h *LocationHandler) UpdateLocations(c *gin.Context) {
 
 // FindNearbyDrivers godoc
 // @Summary Find nearby drivers
-// @Description Find drivers within a specified radius of a given location
+// @Description Find drivers within a specified radius of a given location. With `Accept: application/geo+json` the drivers are returned as a GeoJSON FeatureCollection whose features carry the distance in kilometers.
 // @Tags locations
 // @Accept json
-// @Produce json
+// @Produce json,application/geo+json
 // @Security BearerAuth
+// @Security APIKeyAuth
 // @Param request body FindDriversRequest true "Find drivers request"
 // @Success 200 {array} domain.DriverLocation "List of nearby drivers"
 // @Failure 400 {object} ErrorResponse "Invalid request parameters"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "API key is missing the required scope"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /locations/nearby [post]
 func (h *LocationHandler) FindNearbyDrivers(c *gin.Context) {
@@ -96,13 +104,36 @@ func (h *LocationHandler) FindNearbyDrivers(c *gin.Context) {
 
 	drivers, err := h.locationService.FindNearbyDrivers(c, req.Latitude, req.Longitude, req.Radius)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		_ = c.Error(err)
+		return
+	}
+
+	if wantsGeoJSON(c) {
+		respondGeoJSON(c, geojson.NewFeatureCollection(drivers, geojson.DistanceFrom(req.Latitude, req.Longitude)))
 		return
 	}
 
 	c.JSON(http.StatusOK, drivers)
 }
 
+// bindLocations decodes a location batch in the format selected by the
+// Content-Type header, JSON unless it is the binary batch format
+func bindLocations(c *gin.Context) ([]domain.DriverLocation, error) {
+	if c.ContentType() == batchcodec.MediaType {
+		body, err := c.GetRawData()
+		if err != nil {
+			return nil, err
+		}
+		return batchcodec.Decode(body)
+	}
+
+	var locations []domain.DriverLocation
+	if err := c.ShouldBindJSON(&locations); err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
 // Request/Response types
 type UpdateLocationRequest struct {
 	DriverID  string  `json:"driver_id" binding:"required"`
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUpdateLocation(t *testing.T) {
+	locationService := new(MockLocationService)
+	h := NewLocationHandler(locationService)
+	engine := newTestEngine(func(routes *gin.RouterGroup) {
+		routes.POST("/locations", h.UpdateLocation)
+	})
+	locationService.On("UpdateDriverLocation", mock.Anything, "driver-1", 41.0431, 29.0099).Return(nil)
+
+	w := request{method: http.MethodPost, path: "/api/v1/locations", body: `{"driver_id": "driver-1", "latitude": 41.0431, "longitude": 29.0099}`}.do(engine)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message": "location updated successfully"}`, w.Body.String())
+
+	w = request{method: http.MethodPost, path: "/api/v1/locations", body: `{"driver_id": "driver-1", "latitude": 91, "longitude": 29.0099}`}.do(engine)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "invalid request body"}`, w.Body.String())
+	locationService.AssertNumberOfCalls(t, "UpdateDriverLocation", 1)
+}
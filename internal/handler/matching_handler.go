@@ -4,32 +4,43 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/geojson"
+	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
 	"github.com/yusufatac/bitaksi-case-study/internal/service"
 )
 
 type MatchingHandler struct {
 	matchingService service.MatchingService
+	matches         *service.MatchTokens
 }
 
-func NewMatchingHandler(matchingService service.MatchingService) *MatchingHandler {
+// NewMatchingHandler creates a matching handler. Users get a token signed
+// with matches to book the driver they were matched with.
+func NewMatchingHandler(matchingService service.MatchingService, matches *service.MatchTokens) *MatchingHandler {
 	return &MatchingHandler{
 		matchingService: matchingService,
+		matches:         matches,
 	}
 }
 
 // FindNearestDriver godoc
 // @Summary Find nearest driver
-// @Description Find the nearest available driver within a specified radius
+// @Description Find the nearest available driver within a specified radius. Users also get a match_token to book the driver with POST /rides. With `Accept: application/geo+json` the driver is returned as a GeoJSON FeatureCollection with one feature and the token in the Match-Token header.
 // @Tags matching
 // @Accept json
-// @Produce json
+// @Produce json,application/geo+json
 // @Security BearerAuth
 // @Param request body FindDriversRequest true "Find nearest driver request"
-// @Success 200 {object} domain.DriverLocation "Nearest driver found"
+// @Success 200 {object} MatchResponse "Nearest driver found"
+// @Header 200 {string} Match-Token "Token to book the driver, for users"
 // @Failure 400 {object} ErrorResponse "Invalid request parameters"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "API key is missing the required scope"
 // @Failure 404 {object} ErrorResponse "No drivers found"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Driver location service unavailable"
+// @Failure 504 {object} ErrorResponse "Request timed out"
 // @Router /match [post]
 func (h *MatchingHandler) FindNearestDriver(c *gin.Context) {
 	var req FindDriversRequest
@@ -40,15 +51,44 @@ func (h *MatchingHandler) FindNearestDriver(c *gin.Context) {
 
 	driver, err := h.matchingService.FindNearestDriver(c, req.Latitude, req.Longitude, req.Radius)
 	if err != nil {
-		if err == service.ErrNoDriversFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		_ = c.Error(err)
+		return
+	}
+
+	// Only users can book rides. API keys search on behalf of services.
+	var token string
+	if riderID := c.GetString(middleware.ContextUserID); riderID != "" {
+		token, err = h.matches.Issue(riderID, driver, req.Latitude, req.Longitude)
+		if err != nil {
+			_ = c.Error(err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	if wantsGeoJSON(c) {
+		if token != "" {
+			c.Header(MatchTokenHeader, token)
+		}
+		drivers := []*domain.DriverLocation{driver}
+		respondGeoJSON(c, geojson.NewFeatureCollection(drivers, geojson.DistanceFrom(req.Latitude, req.Longitude)))
 		return
 	}
 
-	c.JSON(http.StatusOK, driver)
+	c.JSON(http.StatusOK, MatchResponse{
+		DriverLocation: driver,
+		MatchToken:     token,
+	})
+}
+
+// MatchTokenHeader carries the match token of GeoJSON responses
+const MatchTokenHeader = "Match-Token"
+
+// MatchResponse is the nearest driver together with the token to book it
+type MatchResponse struct {
+	*domain.DriverLocation
+	// MatchToken books the driver with POST /rides for a few minutes. It is
+	// only returned to users.
+	MatchToken string `json:"match_token,omitempty"`
 }
 
 type EstimateTimeRequest struct {
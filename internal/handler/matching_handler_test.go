@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/geojson"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+func TestFindNearestDriver(t *testing.T) {
+	matches := service.NewMatchTokens("test-secret", time.Minute)
+	matchingService := new(MockMatchingService)
+	h := NewMatchingHandler(matchingService, matches)
+	engine := newTestEngine(func(routes *gin.RouterGroup) {
+		routes.POST("/match", h.FindNearestDriver)
+	})
+	driver := &domain.DriverLocation{ID: "loc-1", DriverID: "driver-1", Location: domain.NewPoint(41.05, 29.01), Status: "active"}
+	matchingService.On("FindNearestDriver", mock.Anything, 41.0431, 29.0099, 5.0).Return(driver, nil)
+	body := `{"latitude": 41.0431, "longitude": 29.0099, "radius": 5}`
+
+	w := request{method: http.MethodPost, path: "/api/v1/match", user: "rider-1", body: body}.do(engine)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var match MatchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &match))
+	assert.Equal(t, "driver-1", match.DriverID)
+	booked, err := matches.Verify(match.MatchToken, "rider-1")
+	require.NoError(t, err)
+	assert.Equal(t, "driver-1", booked.DriverID)
+
+	// GeoJSON responses carry the token in a header
+	w = request{method: http.MethodPost, path: "/api/v1/match", user: "rider-1", accept: geojson.MediaType, body: body}.do(engine)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, geojson.MediaType, w.Header().Get("Content-Type"))
+	_, err = matches.Verify(w.Header().Get(MatchTokenHeader), "rider-1")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,282 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+	"github.com/yusufatac/bitaksi-case-study/internal/tracking"
+)
+
+// trackHeartbeatInterval is how often an idle tracking stream sends a
+// comment, so that proxies do not close it
+const trackHeartbeatInterval = 15 * time.Second
+
+// Tracking stream events
+const (
+	trackEventLocation = "location"
+	trackEventEnd      = "end"
+)
+
+type RideHandler struct {
+	rideService   service.RideService
+	hub           *tracking.Hub
+	trackInterval time.Duration
+}
+
+// NewRideHandler creates a ride handler. Tracking streams send a rider at
+// most one driver location per trackInterval.
+func NewRideHandler(rideService service.RideService, hub *tracking.Hub, trackInterval time.Duration) *RideHandler {
+	return &RideHandler{
+		rideService:   rideService,
+		hub:           hub,
+		trackInterval: trackInterval,
+	}
+}
+
+// CreateRide godoc
+// @Summary Create ride
+// @Description Start a ride of the authenticated rider with the driver returned by /match, using the match_token of the match. The ride expires if it is not completed in time.
+// @Tags rides
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateRideRequest true "Ride request"
+// @Success 201 {object} domain.Ride "Ride created"
+// @Failure 400 {object} ErrorResponse "Invalid request parameters or match token"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Not authenticated as a user"
+// @Failure 409 {object} ErrorResponse "Driver is on another ride or match token already used"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /rides [post]
+func (h *RideHandler) CreateRide(c *gin.Context) {
+	riderID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req CreateRideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request body"})
+		return
+	}
+
+	ride, err := h.rideService.CreateRide(c, riderID, req.MatchToken)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Header("Location", "/api/v1/rides/"+ride.ID)
+	c.JSON(http.StatusCreated, ride)
+}
+
+// GetRide godoc
+// @Summary Get ride
+// @Description Get a ride of which the authenticated user is the rider or the driver
+// @Tags rides
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Ride ID"
+// @Success 200 {object} domain.Ride "Ride"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Not a participant of the ride"
+// @Failure 404 {object} ErrorResponse "Ride not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /rides/{id} [get]
+func (h *RideHandler) GetRide(c *gin.Context) {
+	ride, ok := h.participantRide(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, ride)
+}
+
+// CompleteRide godoc
+// @Summary Complete ride
+// @Description End a ride. Streams tracking the ride receive an end event and are closed.
+// @Tags rides
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Ride ID"
+// @Success 200 {object} domain.Ride "Completed ride"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Not a participant of the ride"
+// @Failure 404 {object} ErrorResponse "Ride not found"
+// @Failure 409 {object} ErrorResponse "Ride has already ended"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /rides/{id}/complete [post]
+func (h *RideHandler) CompleteRide(c *gin.Context) {
+	ride, ok := h.participantRide(c)
+	if !ok {
+		return
+	}
+
+	ride, err := h.rideService.CompleteRide(c, ride.ID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ride)
+}
+
+// TrackRide godoc
+// @Summary Track ride
+// @Description Server-Sent Events stream of the driver's position while the ride is active.
+// @Description Sends a "location" event with a TrackLocationEvent whenever the driver reports a location,
+// @Description at most once per tracking interval, and an "end" event with a TrackEndEvent before closing
+// @Description the stream when the ride ends. Rides that are not completed expire after the longest ride
+// @Description duration, which also ends the stream.
+// @Tags rides
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param id path string true "Ride ID"
+// @Success 200 {object} TrackLocationEvent "Stream of location events"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Not a participant of the ride"
+// @Failure 404 {object} ErrorResponse "Ride not found"
+// @Failure 409 {object} ErrorResponse "Ride has already ended"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /rides/{id}/track [get]
+func (h *RideHandler) TrackRide(c *gin.Context) {
+	ride, ok := h.participantRide(c)
+	if !ok {
+		return
+	}
+
+	sub := h.hub.Subscribe(ride.ID, ride.DriverID)
+	defer sub.Close()
+
+	// The ride may have ended before the subscription was in place, in
+	// which case the subscription never hears about it
+	ride, err := h.rideService.GetRide(c, ride.ID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if !ride.IsActive() {
+		_ = c.Error(service.ErrRideEnded)
+		return
+	}
+
+	// Without a response controller the stream is cut off by the server
+	// timeouts and clients reconnect
+	_ = middleware.DisableDeadlines(c)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(trackHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	// The driver is never tracked past the end of the ride, even when
+	// nobody completes it
+	expiry := time.NewTimer(time.Until(ride.ExpiresAt))
+	defer expiry.Stop()
+
+	for {
+		select {
+		case <-c.Done():
+			return
+		case <-sub.Ended():
+			h.sendEnd(c, ride)
+			return
+		case <-expiry.C:
+			h.sendEnd(c, ride)
+			return
+		case <-heartbeat.C:
+			_, _ = c.Writer.WriteString(": heartbeat\n\n")
+			c.Writer.Flush()
+		case <-sub.Ready():
+			location, ok := sub.Next()
+			if !ok {
+				continue
+			}
+			lat, lon := location.Location.GetCoordinates()
+			c.SSEvent(trackEventLocation, TrackLocationEvent{
+				DriverID:  location.DriverID,
+				Latitude:  lat,
+				Longitude: lon,
+				Timestamp: location.Timestamp,
+			})
+			c.Writer.Flush()
+
+			// Throttle this subscriber: locations published meanwhile
+			// are coalesced into the next event
+			select {
+			case <-time.After(h.trackInterval):
+			case <-c.Done():
+				return
+			case <-sub.Ended():
+				h.sendEnd(c, ride)
+				return
+			case <-expiry.C:
+				h.sendEnd(c, ride)
+				return
+			}
+		}
+	}
+}
+
+func (h *RideHandler) sendEnd(c *gin.Context, ride *domain.Ride) {
+	// Reading the ride expires it when it is overdue
+	status := domain.RideStatusCompleted
+	if ended, err := h.rideService.GetRide(c, ride.ID); err == nil {
+		status = ended.Status
+	}
+
+	c.SSEvent(trackEventEnd, TrackEndEvent{
+		RideID: ride.ID,
+		Status: status,
+	})
+	c.Writer.Flush()
+}
+
+// participantRide looks up the ride of the request and checks that the
+// authenticated user is its rider or driver, or an admin
+func (h *RideHandler) participantRide(c *gin.Context) (*domain.Ride, bool) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return nil, false
+	}
+
+	ride, err := h.rideService.GetRide(c, c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return nil, false
+	}
+
+	if !ride.HasParticipant(userID) && c.GetString(middleware.ContextRole) != domain.RoleAdmin {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "not a participant of this ride"})
+		return nil, false
+	}
+
+	return ride, true
+}
+
+// Request/Response types
+type CreateRideRequest struct {
+	// MatchToken is the match_token returned by /match
+	MatchToken string `json:"match_token" binding:"required"`
+}
+
+type TrackLocationEvent struct {
+	DriverID  string    `json:"driver_id"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type TrackEndEvent struct {
+	RideID string `json:"ride_id"`
+	Status string `json:"status"`
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+	"github.com/yusufatac/bitaksi-case-study/internal/tracking"
+)
+
+func newRideEngine(rideService *MockRideService) *gin.Engine {
+	h := NewRideHandler(rideService, tracking.NewHub(), time.Second)
+	return newTestEngine(func(routes *gin.RouterGroup) {
+		routes.POST("/rides", h.CreateRide)
+		routes.GET("/rides/:id/track", h.TrackRide)
+	})
+}
+
+func testRide(status string, expiresAt time.Time) *domain.Ride {
+	return &domain.Ride{
+		ID:        "ride-1",
+		RiderID:   "rider-1",
+		DriverID:  "driver-1",
+		Pickup:    domain.NewPoint(41.0431, 29.0099),
+		Status:    status,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+}
+
+func TestCreateRide(t *testing.T) {
+	rideService := new(MockRideService)
+	engine := newRideEngine(rideService)
+	rideService.On("CreateRide", mock.Anything, "rider-1", "token").Return(testRide(domain.RideStatusActive, time.Now().Add(time.Hour)), nil).Once()
+	rideService.On("CreateRide", mock.Anything, "rider-1", "token").Return(nil, service.ErrDriverBusy)
+
+	w := request{method: http.MethodPost, path: "/api/v1/rides", user: "rider-1", body: `{"match_token": "token"}`}.do(engine)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/api/v1/rides/ride-1", w.Header().Get("Location"))
+
+	w = request{method: http.MethodPost, path: "/api/v1/rides", user: "rider-1", body: `{"match_token": "token"}`}.do(engine)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"error": "driver is on another ride"}`, w.Body.String())
+}
+
+func TestTrackRideEndsWhenRideExpires(t *testing.T) {
+	rideService := new(MockRideService)
+	engine := newRideEngine(rideService)
+	expiresAt := time.Now().Add(50 * time.Millisecond)
+	rideService.On("GetRide", mock.Anything, "ride-1").Return(testRide(domain.RideStatusActive, expiresAt), nil).Twice()
+	rideService.On("GetRide", mock.Anything, "ride-1").Return(testRide(domain.RideStatusExpired, expiresAt), nil)
+
+	w := request{method: http.MethodGet, path: "/api/v1/rides/ride-1/track", user: "rider-1"}.do(engine)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.Contains(t, body, "event:"+trackEventEnd)
+	assert.Contains(t, body, `"status":"expired"`)
+}
@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+// Custom errors
+var (
+	errInvalidUserRequest = domain.NewError(domain.ErrValidation, "invalid_request", "invalid request body")
+	errInvalidUserQuery   = domain.NewError(domain.ErrValidation, "invalid_request", "invalid query parameters")
+	errUserRequired       = domain.NewError(domain.ErrForbidden, "user_required", "this endpoint requires a user account")
+)
+
+type UserHandler struct {
+	userService service.UserService
+}
+
+func NewUserHandler(userService service.UserService) *UserHandler {
+	return &UserHandler{
+		userService: userService,
+	}
+}
+
+// GetMe godoc
+// @Summary Get current user
+// @Description Get the profile of the authenticated user
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} domain.User "User profile"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Not authenticated as a user"
+// @Failure 404 {object} ErrorResponse "User not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /users/me [get]
+func (h *UserHandler) GetMe(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.userService.GetUser(c, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateMe godoc
+// @Summary Update current user
+// @Description Update the profile of the authenticated user. Changing the email requires verifying it again.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UpdateUserRequest true "Profile update"
+// @Success 200 {object} domain.User "Updated user profile"
+// @Failure 400 {object} ErrorResponse "Invalid request parameters"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Not authenticated as a user"
+// @Failure 404 {object} ErrorResponse "User not found"
+// @Failure 409 {object} ErrorResponse "Email already in use"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /users/me [patch]
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(errInvalidUserRequest)
+		return
+	}
+
+	user, err := h.userService.UpdateUser(c, userID, domain.UserUpdate{Email: req.Email})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Change the password of the authenticated user after verifying the current one
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} Response "Password changed"
+// @Failure 400 {object} ErrorResponse "Invalid request parameters"
+// @Failure 401 {object} ErrorResponse "Unauthorized or wrong current password"
+// @Failure 403 {object} ErrorResponse "Not authenticated as a user"
+// @Failure 404 {object} ErrorResponse "User not found"
+// @Failure 423 {object} ErrorResponse "Account locked"
+// @Failure 429 {object} ErrorResponse "Too many failed attempts"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /users/me/password [post]
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(errInvalidUserRequest)
+		return
+	}
+
+	if err := h.userService.ChangePassword(c, userID, req.CurrentPassword, req.NewPassword); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Message: "password changed successfully"})
+}
+
+// DeleteMe godoc
+// @Summary Delete current user
+// @Description Soft delete the authenticated user
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} Response "User deleted"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Not authenticated as a user"
+// @Failure 404 {object} ErrorResponse "User not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /users/me [delete]
+func (h *UserHandler) DeleteMe(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.DeleteUser(c, userID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Message: "user deleted successfully"})
+}
+
+// ListUsers godoc
+// @Summary List users
+// @Description List and search users with pagination
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param q query string false "Username or email prefix"
+// @Param status query string false "User status"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Page size" default(20)
+// @Success 200 {object} ListUsersResponse "Page of users"
+// @Failure 400 {object} ErrorResponse "Invalid request parameters"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /admin/users [get]
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	var req ListUsersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(errInvalidUserQuery)
+		return
+	}
+
+	page, err := h.userService.ListUsers(c, domain.UserFilter{
+		Query:  req.Query,
+		Status: req.Status,
+		Page:   req.Page,
+		Limit:  req.Limit,
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ListUsersResponse{
+		Users: page.Users,
+		Page:  page.Page,
+		Limit: page.Limit,
+		Total: page.Total,
+	})
+}
+
+// currentUserID returns the ID of the authenticated user. Requests made with
+// an API key have no user and are rejected.
+func currentUserID(c *gin.Context) (string, bool) {
+	userID := c.GetString(middleware.ContextUserID)
+	if userID == "" {
+		_ = c.Error(errUserRequired)
+		return "", false
+	}
+	return userID, true
+}
+
+// Request/Response types
+type UpdateUserRequest struct {
+	Email *string `json:"email" binding:"omitempty,email"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6,max=100"`
+}
+
+type ListUsersRequest struct {
+	Query  string `form:"q"`
+	Status string `form:"status" binding:"omitempty,oneof=pending active locked deleted"`
+	Page   int    `form:"page" binding:"omitempty,min=1"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type ListUsersResponse struct {
+	Users []*domain.User `json:"users"`
+	Page  int            `json:"page"`
+	Limit int            `json:"limit"`
+	Total int64          `json:"total"`
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+func TestGetMe(t *testing.T) {
+	userService := new(MockUserService)
+	h := NewUserHandler(userService)
+	engine := newTestEngine(func(routes *gin.RouterGroup) {
+		routes.GET("/users/me", h.GetMe)
+	})
+	userService.On("GetUser", mock.Anything, "user-1").Return(&domain.User{ID: "user-1", Username: "rider", Role: domain.RoleUser}, nil)
+
+	w := request{method: http.MethodGet, path: "/api/v1/users/me", user: "user-1", role: domain.RoleUser}.do(engine)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"username":"rider"`)
+
+	w = request{method: http.MethodGet, path: "/api/v1/users/me"}.do(engine)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"error": "this endpoint requires a user account"}`, w.Body.String())
+}
@@ -0,0 +1,157 @@
+// Package v2 serves API v2.
+//
+// Every point is a {"latitude", "longitude"} object, in requests and
+// responses alike, and errors are RFC 7807 problem details with a
+// machine-readable code.
+package v2
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+	"github.com/yusufatac/bitaksi-case-study/internal/problem"
+)
+
+// @title Taxi Location Service API
+// @version 2.0
+// @description API v2 of the taxi service. Every point is a latitude/longitude object and errors are RFC 7807 problem details (application/problem+json) with a machine-readable code.
+// @host localhost:8080
+// @BasePath /api/v2
+// @schemes http https
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
+
+// Problem codes of the checks made by API v2 handlers. Service errors carry
+// their own codes.
+const (
+	CodeInvalidCoordinates = "invalid_coordinates"
+	CodeNotRideParticipant = "not_ride_participant"
+	CodeUserRequired       = "user_required"
+)
+
+// Coordinates is a point on the map
+type Coordinates struct {
+	Latitude  *float64 `json:"latitude" binding:"required" example:"41.0431"`
+	Longitude *float64 `json:"longitude" binding:"required" example:"29.0099"`
+}
+
+// NewCoordinates creates the coordinates of a point
+func NewCoordinates(point domain.Point) Coordinates {
+	lat, lon := point.GetCoordinates()
+	return Coordinates{Latitude: &lat, Longitude: &lon}
+}
+
+// Point returns the domain point of the coordinates
+func (p Coordinates) Point() domain.Point {
+	return domain.NewPoint(*p.Latitude, *p.Longitude)
+}
+
+// validate checks the coordinates of the named request field are in range
+func (p Coordinates) validate(field string) error {
+	if *p.Latitude < -90 || *p.Latitude > 90 {
+		return fmt.Errorf("%s.latitude must be between -90 and 90", field)
+	}
+	if *p.Longitude < -180 || *p.Longitude > 180 {
+		return fmt.Errorf("%s.longitude must be between -180 and 180", field)
+	}
+	return nil
+}
+
+// DriverLocation is the last reported location of a driver
+type DriverLocation struct {
+	ID        string      `json:"id"`
+	DriverID  string      `json:"driver_id"`
+	Location  Coordinates `json:"location"`
+	Status    string      `json:"status"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+func newDriverLocation(location *domain.DriverLocation) DriverLocation {
+	return DriverLocation{
+		ID:        location.ID,
+		DriverID:  location.DriverID,
+		Location:  NewCoordinates(location.Location),
+		Status:    location.Status,
+		Timestamp: location.Timestamp,
+	}
+}
+
+// Match is the nearest driver together with the token to book it
+type Match struct {
+	DriverLocation
+	// MatchToken books the driver with POST /rides for a few minutes. It is
+	// only returned to users.
+	MatchToken string `json:"match_token,omitempty"`
+}
+
+// Ride is a trip of a rider with the driver they were matched with
+type Ride struct {
+	ID        string      `json:"id"`
+	RiderID   string      `json:"rider_id"`
+	DriverID  string      `json:"driver_id"`
+	Pickup    Coordinates `json:"pickup"`
+	Status    string      `json:"status"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	CreatedAt time.Time   `json:"created_at"`
+	EndedAt   *time.Time  `json:"ended_at,omitempty"`
+}
+
+func newRide(ride *domain.Ride) Ride {
+	return Ride{
+		ID:        ride.ID,
+		RiderID:   ride.RiderID,
+		DriverID:  ride.DriverID,
+		Pickup:    NewCoordinates(ride.Pickup),
+		Status:    ride.Status,
+		ExpiresAt: ride.ExpiresAt,
+		CreatedAt: ride.CreatedAt,
+		EndedAt:   ride.EndedAt,
+	}
+}
+
+// Response is the body of successful requests that return no resource
+type Response struct {
+	Message string `json:"message"`
+}
+
+// SearchRequest looks for drivers within Radius kilometers of Location
+type SearchRequest struct {
+	Location *Coordinates `json:"location" binding:"required"`
+	Radius   float64      `json:"radius" binding:"required,gt=0" example:"5"`
+}
+
+// abort answers the request with a problem
+func abort(c *gin.Context, status int, code, detail string) {
+	problem.Write(c, problem.New(status, code, detail))
+	c.Abort()
+}
+
+// invalidRequest rejects a request whose body could not be bound
+func invalidRequest(c *gin.Context) {
+	abort(c, http.StatusBadRequest, problem.CodeInvalidRequest, "invalid request body")
+}
+
+// invalidCoordinates rejects a request with out of range coordinates
+func invalidCoordinates(c *gin.Context, err error) {
+	abort(c, http.StatusBadRequest, CodeInvalidCoordinates, err.Error())
+}
+
+// currentUserID returns the ID of the authenticated user, rejecting
+// requests authenticated with an API key
+func currentUserID(c *gin.Context) (string, bool) {
+	userID := c.GetString(middleware.ContextUserID)
+	if userID == "" {
+		abort(c, http.StatusForbidden, CodeUserRequired, "this endpoint requires a user account")
+		return "", false
+	}
+	return userID, true
+}
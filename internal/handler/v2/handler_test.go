@@ -0,0 +1,157 @@
+package v2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+	"github.com/yusufatac/bitaksi-case-study/internal/problem"
+)
+
+// MockLocationService is a mock implementation of the LocationService interface
+type MockLocationService struct {
+	mock.Mock
+}
+
+func (m *MockLocationService) UpdateDriverLocation(ctx context.Context, driverID string, lat, lon float64) error {
+	args := m.Called(ctx, driverID, lat, lon)
+	return args.Error(0)
+}
+
+func (m *MockLocationService) UpdateDriverLocations(ctx context.Context, locations []domain.DriverLocation) error {
+	args := m.Called(ctx, locations)
+	return args.Error(0)
+}
+
+func (m *MockLocationService) FindNearbyDrivers(ctx context.Context, lat, lon, radius float64) ([]*domain.DriverLocation, error) {
+	args := m.Called(ctx, lat, lon, radius)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.DriverLocation), args.Error(1)
+}
+
+func (m *MockLocationService) CountActiveDrivers(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockMatchingService is a mock implementation of the MatchingService interface
+type MockMatchingService struct {
+	mock.Mock
+}
+
+func (m *MockMatchingService) FindNearestDriver(ctx context.Context, lat, lon, radius float64) (*domain.DriverLocation, error) {
+	args := m.Called(ctx, lat, lon, radius)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.DriverLocation), args.Error(1)
+}
+
+func (m *MockMatchingService) CalculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	args := m.Called(lat1, lon1, lat2, lon2)
+	return args.Get(0).(float64)
+}
+
+// MockRideService is a mock implementation of the RideService interface
+type MockRideService struct {
+	mock.Mock
+}
+
+func (m *MockRideService) CreateRide(ctx context.Context, riderID, matchToken string) (*domain.Ride, error) {
+	args := m.Called(ctx, riderID, matchToken)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Ride), args.Error(1)
+}
+
+func (m *MockRideService) GetRide(ctx context.Context, rideID string) (*domain.Ride, error) {
+	args := m.Called(ctx, rideID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Ride), args.Error(1)
+}
+
+func (m *MockRideService) CompleteRide(ctx context.Context, rideID string) (*domain.Ride, error) {
+	args := m.Called(ctx, rideID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Ride), args.Error(1)
+}
+
+// newTestEngine serves the routes registered by register under /api/v2
+// with the error handling of the router. Instead of authenticating,
+// requests name their user in X-User and its role in X-Role; requests
+// without X-User stand for API keys.
+func newTestEngine(register func(routes *gin.RouterGroup)) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.Use(problem.Routes("/api/v2"), middleware.Errors(), func(c *gin.Context) {
+		if userID := c.GetHeader("X-User"); userID != "" {
+			c.Set(middleware.ContextUserID, userID)
+			c.Set(middleware.ContextRole, c.GetHeader("X-Role"))
+		}
+	})
+	register(engine.Group("/api/v2"))
+	return engine
+}
+
+// request is a request to a test engine
+type request struct {
+	method      string
+	path        string
+	user        string
+	role        string
+	contentType string
+	body        string
+}
+
+func (r request) do(engine *gin.Engine) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(r.method, r.path, strings.NewReader(r.body))
+	req.Header.Set("Content-Type", "application/json")
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	if r.user != "" {
+		req.Header.Set("X-User", r.user)
+		req.Header.Set("X-Role", r.role)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+// decodeProblem decodes the problem details answering a request
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) problem.Details {
+	t.Helper()
+
+	require.Equal(t, problem.MediaType, w.Header().Get("Content-Type"))
+	var details problem.Details
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &details))
+	require.Equal(t, w.Code, details.Status)
+	return details
+}
+
+// assertProblem checks that a request was answered with the given status
+// and problem code
+func assertProblem(t *testing.T, w *httptest.ResponseRecorder, status int, code string) problem.Details {
+	t.Helper()
+
+	require.Equal(t, status, w.Code, w.Body.String())
+	details := decodeProblem(t, w)
+	require.Equal(t, code, details.Code)
+	return details
+}
@@ -0,0 +1,179 @@
+package v2
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/batchcodec"
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+type LocationHandler struct {
+	locationService service.LocationService
+}
+
+func NewLocationHandler(locationService service.LocationService) *LocationHandler {
+	return &LocationHandler{
+		locationService: locationService,
+	}
+}
+
+// UpdateLocation godoc
+// @Summary Update driver location
+// @Description Update a single driver's location
+// @Tags locations
+// @Accept json
+// @Produce json,application/problem+json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param request body UpdateLocationRequest true "Location update request"
+// @Success 200 {object} Response "Location successfully updated"
+// @Failure 400 {object} problem.Details "invalid_request or invalid_coordinates"
+// @Failure 401 {object} problem.Details "unauthorized"
+// @Failure 403 {object} problem.Details "forbidden: API key is missing the required scope"
+// @Failure 500 {object} problem.Details "internal_error"
+// @Router /locations [post]
+func (h *LocationHandler) UpdateLocation(c *gin.Context) {
+	var req UpdateLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		invalidRequest(c)
+		return
+	}
+	if err := req.Location.validate("location"); err != nil {
+		invalidCoordinates(c, err)
+		return
+	}
+
+	if err := h.locationService.UpdateDriverLocation(c, req.DriverID, *req.Location.Latitude, *req.Location.Longitude); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Message: "location updated successfully"})
+}
+
+// UpdateLocations godoc
+// @Summary Update multiple driver locations
+// @Description Update locations for multiple drivers in batch. Updates without a status are "active". Besides JSON, the body can use the compact binary format described in internal/batchcodec, sent with `Content-Type: application/vnd.taxi.location-batch`.
+// @Tags locations
+// @Accept json,application/vnd.taxi.location-batch
+// @Produce json,application/problem+json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param request body []LocationUpdate true "Batch location update request"
+// @Success 200 {object} Response "Locations successfully updated"
+// @Failure 400 {object} problem.Details "invalid_request or invalid_coordinates"
+// @Failure 401 {object} problem.Details "unauthorized"
+// @Failure 403 {object} problem.Details "forbidden: API key is missing the required scope"
+// @Failure 413 {object} problem.Details "body_too_large"
+// @Failure 500 {object} problem.Details "internal_error"
+// @Router /locations/batch [post]
+func (h *LocationHandler) UpdateLocations(c *gin.Context) {
+	var locations []domain.DriverLocation
+	if c.ContentType() == batchcodec.MediaType {
+		body, err := c.GetRawData()
+		if err == nil {
+			locations, err = batchcodec.Decode(body)
+		}
+		if err != nil {
+			invalidRequest(c)
+			return
+		}
+	} else {
+		var ok bool
+		if locations, ok = bindLocationUpdates(c); !ok {
+			return
+		}
+	}
+
+	if err := h.locationService.UpdateDriverLocations(c, locations); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Message: "locations updated successfully"})
+}
+
+// FindNearbyDrivers godoc
+// @Summary Find nearby drivers
+// @Description Find drivers within radius kilometers of a location
+// @Tags locations
+// @Accept json
+// @Produce json,application/problem+json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param request body SearchRequest true "Find drivers request"
+// @Success 200 {array} DriverLocation "List of nearby drivers"
+// @Failure 400 {object} problem.Details "invalid_request, invalid_coordinates or invalid_radius"
+// @Failure 401 {object} problem.Details "unauthorized"
+// @Failure 403 {object} problem.Details "forbidden: API key is missing the required scope"
+// @Failure 500 {object} problem.Details "internal_error"
+// @Router /locations/nearby [post]
+func (h *LocationHandler) FindNearbyDrivers(c *gin.Context) {
+	var req SearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		invalidRequest(c)
+		return
+	}
+	if err := req.Location.validate("location"); err != nil {
+		invalidCoordinates(c, err)
+		return
+	}
+
+	drivers, err := h.locationService.FindNearbyDrivers(c, *req.Location.Latitude, *req.Location.Longitude, req.Radius)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response := make([]DriverLocation, len(drivers))
+	for i, driver := range drivers {
+		response[i] = newDriverLocation(driver)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// bindLocationUpdates binds and validates a JSON batch of location updates.
+// Problems name the offending update by its index.
+func bindLocationUpdates(c *gin.Context) ([]domain.DriverLocation, bool) {
+	var updates []LocationUpdate
+	if err := c.ShouldBindJSON(&updates); err != nil {
+		invalidRequest(c)
+		return nil, false
+	}
+
+	locations := make([]domain.DriverLocation, len(updates))
+	for i, update := range updates {
+		if err := binding.Validator.ValidateStruct(&update); err != nil {
+			invalidRequest(c)
+			return nil, false
+		}
+		if err := update.Location.validate(fmt.Sprintf("[%d].location", i)); err != nil {
+			invalidCoordinates(c, err)
+			return nil, false
+		}
+
+		locations[i] = domain.DriverLocation{
+			DriverID: update.DriverID,
+			Location: update.Location.Point(),
+			Status:   update.Status,
+		}
+	}
+	return locations, true
+}
+
+// Request types
+type UpdateLocationRequest struct {
+	DriverID string       `json:"driver_id" binding:"required" example:"driver-1"`
+	Location *Coordinates `json:"location" binding:"required"`
+}
+
+type LocationUpdate struct {
+	DriverID string       `json:"driver_id" binding:"required" example:"driver-1"`
+	Location *Coordinates `json:"location" binding:"required"`
+	Status   string       `json:"status,omitempty" example:"active"`
+}
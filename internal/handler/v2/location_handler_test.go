@@ -0,0 +1,194 @@
+package v2
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/batchcodec"
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+func newLocationEngine(locationService *MockLocationService) *gin.Engine {
+	h := NewLocationHandler(locationService)
+	return newTestEngine(func(routes *gin.RouterGroup) {
+		routes.POST("/locations", h.UpdateLocation)
+		routes.POST("/locations/batch", h.UpdateLocations)
+		routes.POST("/locations/nearby", h.FindNearbyDrivers)
+	})
+}
+
+func TestUpdateLocation(t *testing.T) {
+	locationService := new(MockLocationService)
+	engine := newLocationEngine(locationService)
+	locationService.On("UpdateDriverLocation", mock.Anything, "driver-1", 41.0431, 29.0099).Return(nil)
+
+	w := request{
+		method: http.MethodPost,
+		path:   "/api/v2/locations",
+		body:   `{"driver_id": "driver-1", "location": {"latitude": 41.0431, "longitude": 29.0099}}`,
+	}.do(engine)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message": "location updated successfully"}`, w.Body.String())
+	locationService.AssertExpectations(t)
+}
+
+func TestUpdateLocationInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		code   string
+		detail string
+	}{
+		{name: "malformed", body: `{"driver_id":`, code: "invalid_request"},
+		{name: "missing location", body: `{"driver_id": "driver-1"}`, code: "invalid_request"},
+		{name: "missing longitude", body: `{"driver_id": "driver-1", "location": {"latitude": 41}}`, code: "invalid_request"},
+		{
+			name:   "latitude out of range",
+			body:   `{"driver_id": "driver-1", "location": {"latitude": 91, "longitude": 29}}`,
+			code:   CodeInvalidCoordinates,
+			detail: "location.latitude must be between -90 and 90",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locationService := new(MockLocationService)
+			engine := newLocationEngine(locationService)
+
+			w := request{method: http.MethodPost, path: "/api/v2/locations", body: tt.body}.do(engine)
+
+			details := assertProblem(t, w, http.StatusBadRequest, tt.code)
+			assert.Equal(t, "/api/v2/locations", details.Instance)
+			if tt.detail != "" {
+				assert.Equal(t, tt.detail, details.Detail)
+			}
+			locationService.AssertNotCalled(t, "UpdateDriverLocation", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUpdateLocations(t *testing.T) {
+	locationService := new(MockLocationService)
+	engine := newLocationEngine(locationService)
+	locationService.On("UpdateDriverLocations", mock.Anything, []domain.DriverLocation{
+		{DriverID: "driver-1", Location: domain.NewPoint(41.0431, 29.0099)},
+		{DriverID: "driver-2", Location: domain.NewPoint(41.05, 29.01), Status: "busy"},
+	}).Return(nil)
+
+	w := request{
+		method: http.MethodPost,
+		path:   "/api/v2/locations/batch",
+		body: `[
+			{"driver_id": "driver-1", "location": {"latitude": 41.0431, "longitude": 29.0099}},
+			{"driver_id": "driver-2", "location": {"latitude": 41.05, "longitude": 29.01}, "status": "busy"}
+		]`,
+	}.do(engine)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	locationService.AssertExpectations(t)
+}
+
+func TestUpdateLocationsInvalidLocation(t *testing.T) {
+	locationService := new(MockLocationService)
+	engine := newLocationEngine(locationService)
+
+	w := request{
+		method: http.MethodPost,
+		path:   "/api/v2/locations/batch",
+		body: `[
+			{"driver_id": "driver-1", "location": {"latitude": 41.0431, "longitude": 29.0099}},
+			{"driver_id": "driver-2", "location": {"latitude": 41.05, "longitude": 181}}
+		]`,
+	}.do(engine)
+
+	details := assertProblem(t, w, http.StatusBadRequest, CodeInvalidCoordinates)
+	assert.Equal(t, "[1].location.longitude must be between -180 and 180", details.Detail)
+	locationService.AssertNotCalled(t, "UpdateDriverLocations", mock.Anything, mock.Anything)
+}
+
+func TestUpdateLocationsBinary(t *testing.T) {
+	locations := []domain.DriverLocation{
+		{DriverID: "driver-1", Location: domain.NewPoint(41.0431, 29.0099), Status: "active"},
+	}
+	body, err := batchcodec.Encode(locations)
+	require.NoError(t, err)
+	decoded, err := batchcodec.Decode(body)
+	require.NoError(t, err)
+
+	locationService := new(MockLocationService)
+	engine := newLocationEngine(locationService)
+	locationService.On("UpdateDriverLocations", mock.Anything, decoded).Return(nil)
+
+	w := request{
+		method:      http.MethodPost,
+		path:        "/api/v2/locations/batch",
+		contentType: batchcodec.MediaType,
+		body:        string(body),
+	}.do(engine)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	locationService.AssertExpectations(t)
+
+	w = request{
+		method:      http.MethodPost,
+		path:        "/api/v2/locations/batch",
+		contentType: batchcodec.MediaType,
+		body:        "not a batch",
+	}.do(engine)
+	assertProblem(t, w, http.StatusBadRequest, "invalid_request")
+}
+
+func TestFindNearbyDrivers(t *testing.T) {
+	timestamp := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	locationService := new(MockLocationService)
+	engine := newLocationEngine(locationService)
+	locationService.On("FindNearbyDrivers", mock.Anything, 41.0431, 29.0099, 5.0).Return([]*domain.DriverLocation{
+		{ID: "loc-1", DriverID: "driver-1", Location: domain.NewPoint(41.05, 29.01), Status: "active", Timestamp: timestamp},
+	}, nil)
+
+	w := request{
+		method: http.MethodPost,
+		path:   "/api/v2/locations/nearby",
+		body:   `{"location": {"latitude": 41.0431, "longitude": 29.0099}, "radius": 5}`,
+	}.do(engine)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var drivers []DriverLocation
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &drivers))
+	require.Len(t, drivers, 1)
+	assert.Equal(t, "driver-1", drivers[0].DriverID)
+	assert.Equal(t, 41.05, *drivers[0].Location.Latitude)
+	assert.Equal(t, 29.01, *drivers[0].Location.Longitude)
+	assert.Equal(t, timestamp, drivers[0].Timestamp)
+}
+
+func TestFindNearbyDriversErrors(t *testing.T) {
+	locationService := new(MockLocationService)
+	engine := newLocationEngine(locationService)
+	locationService.On("FindNearbyDrivers", mock.Anything, 41.0431, 29.0099, 500.0).Return(nil, service.ErrInvalidRadius)
+
+	w := request{
+		method: http.MethodPost,
+		path:   "/api/v2/locations/nearby",
+		body:   `{"location": {"latitude": 41.0431, "longitude": 29.0099}, "radius": 0}`,
+	}.do(engine)
+	assertProblem(t, w, http.StatusBadRequest, "invalid_request")
+
+	// Errors of the service carry their own code
+	w = request{
+		method: http.MethodPost,
+		path:   "/api/v2/locations/nearby",
+		body:   `{"location": {"latitude": 41.0431, "longitude": 29.0099}, "radius": 500}`,
+	}.do(engine)
+	details := assertProblem(t, w, http.StatusBadRequest, "invalid_radius")
+	assert.Equal(t, service.ErrInvalidRadius.Error(), details.Detail)
+}
@@ -0,0 +1,71 @@
+package v2
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+type MatchingHandler struct {
+	matchingService service.MatchingService
+	matches         *service.MatchTokens
+}
+
+// NewMatchingHandler creates a matching handler. Users get a token signed
+// with matches to book the driver they were matched with.
+func NewMatchingHandler(matchingService service.MatchingService, matches *service.MatchTokens) *MatchingHandler {
+	return &MatchingHandler{
+		matchingService: matchingService,
+		matches:         matches,
+	}
+}
+
+// FindNearestDriver godoc
+// @Summary Find nearest driver
+// @Description Find the nearest available driver within radius kilometers of a location. Users also get a match_token to book the driver with POST /rides.
+// @Tags matching
+// @Accept json
+// @Produce json,application/problem+json
+// @Security BearerAuth
+// @Param request body SearchRequest true "Find nearest driver request"
+// @Success 200 {object} Match "Nearest driver found"
+// @Failure 400 {object} problem.Details "invalid_request, invalid_coordinates or invalid_radius"
+// @Failure 401 {object} problem.Details "unauthorized"
+// @Failure 403 {object} problem.Details "forbidden: API key is missing the required scope"
+// @Failure 404 {object} problem.Details "no_drivers_found"
+// @Failure 500 {object} problem.Details "internal_error"
+// @Failure 503 {object} problem.Details "location_service_unavailable"
+// @Failure 504 {object} problem.Details "timeout"
+// @Router /match [post]
+func (h *MatchingHandler) FindNearestDriver(c *gin.Context) {
+	var req SearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		invalidRequest(c)
+		return
+	}
+	if err := req.Location.validate("location"); err != nil {
+		invalidCoordinates(c, err)
+		return
+	}
+
+	driver, err := h.matchingService.FindNearestDriver(c, *req.Location.Latitude, *req.Location.Longitude, req.Radius)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// Only users can book rides. API keys search on behalf of services.
+	match := Match{DriverLocation: newDriverLocation(driver)}
+	if riderID := c.GetString(middleware.ContextUserID); riderID != "" {
+		match.MatchToken, err = h.matches.Issue(riderID, driver, *req.Location.Latitude, *req.Location.Longitude)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, match)
+}
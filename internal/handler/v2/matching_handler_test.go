@@ -0,0 +1,88 @@
+package v2
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+var testMatchTokens = service.NewMatchTokens("test-secret", time.Minute)
+
+func newMatchingEngine(matchingService *MockMatchingService) *gin.Engine {
+	h := NewMatchingHandler(matchingService, testMatchTokens)
+	return newTestEngine(func(routes *gin.RouterGroup) {
+		routes.POST("/match", h.FindNearestDriver)
+	})
+}
+
+func TestFindNearestDriver(t *testing.T) {
+	driver := &domain.DriverLocation{ID: "loc-1", DriverID: "driver-1", Location: domain.NewPoint(41.05, 29.01), Status: "active"}
+	body := `{"location": {"latitude": 41.0431, "longitude": 29.0099}, "radius": 5}`
+
+	t.Run("user", func(t *testing.T) {
+		matchingService := new(MockMatchingService)
+		engine := newMatchingEngine(matchingService)
+		matchingService.On("FindNearestDriver", mock.Anything, 41.0431, 29.0099, 5.0).Return(driver, nil)
+
+		w := request{method: http.MethodPost, path: "/api/v2/match", user: "rider-1", body: body}.do(engine)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var match Match
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &match))
+		assert.Equal(t, "driver-1", match.DriverID)
+		assert.Equal(t, 41.05, *match.Location.Latitude)
+
+		// The token books the driver at the searched location for the rider
+		booked, err := testMatchTokens.Verify(match.MatchToken, "rider-1")
+		require.NoError(t, err)
+		assert.Equal(t, "driver-1", booked.DriverID)
+		assert.Equal(t, domain.NewPoint(41.0431, 29.0099), booked.Pickup)
+	})
+
+	t.Run("api key", func(t *testing.T) {
+		matchingService := new(MockMatchingService)
+		engine := newMatchingEngine(matchingService)
+		matchingService.On("FindNearestDriver", mock.Anything, 41.0431, 29.0099, 5.0).Return(driver, nil)
+
+		w := request{method: http.MethodPost, path: "/api/v2/match", body: body}.do(engine)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "match_token")
+	})
+}
+
+func TestFindNearestDriverErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		err    error
+		status int
+		code   string
+	}{
+		{name: "missing radius", body: `{"location": {"latitude": 41, "longitude": 29}}`, status: http.StatusBadRequest, code: "invalid_request"},
+		{name: "invalid coordinates", body: `{"location": {"latitude": -91, "longitude": 29}, "radius": 5}`, status: http.StatusBadRequest, code: CodeInvalidCoordinates},
+		{name: "no drivers", body: `{"location": {"latitude": 41, "longitude": 29}, "radius": 5}`, err: service.ErrNoDriversFound, status: http.StatusNotFound, code: "no_drivers_found"},
+		{name: "location service down", body: `{"location": {"latitude": 41, "longitude": 29}, "radius": 5}`, err: service.ErrLocationServiceUnavailable, status: http.StatusServiceUnavailable, code: "location_service_unavailable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matchingService := new(MockMatchingService)
+			engine := newMatchingEngine(matchingService)
+			matchingService.On("FindNearestDriver", mock.Anything, 41.0, 29.0, 5.0).Return(nil, tt.err)
+
+			w := request{method: http.MethodPost, path: "/api/v2/match", user: "rider-1", body: tt.body}.do(engine)
+
+			assertProblem(t, w, tt.status, tt.code)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package v2
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+type RideHandler struct {
+	rideService service.RideService
+}
+
+func NewRideHandler(rideService service.RideService) *RideHandler {
+	return &RideHandler{
+		rideService: rideService,
+	}
+}
+
+// CreateRide godoc
+// @Summary Create ride
+// @Description Start a ride of the authenticated rider with the driver returned by /match, using the match_token of the match. The ride expires if it is not completed in time.
+// @Tags rides
+// @Accept json
+// @Produce json,application/problem+json
+// @Security BearerAuth
+// @Param request body CreateRideRequest true "Ride request"
+// @Success 201 {object} Ride "Ride created"
+// @Failure 400 {object} problem.Details "invalid_request or invalid_match_token"
+// @Failure 401 {object} problem.Details "unauthorized"
+// @Failure 403 {object} problem.Details "user_required"
+// @Failure 409 {object} problem.Details "driver_busy, match_token_used"
+// @Failure 500 {object} problem.Details "internal_error"
+// @Router /rides [post]
+func (h *RideHandler) CreateRide(c *gin.Context) {
+	riderID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req CreateRideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		invalidRequest(c)
+		return
+	}
+
+	ride, err := h.rideService.CreateRide(c, riderID, req.MatchToken)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Header("Location", "/api/v2/rides/"+ride.ID)
+	c.JSON(http.StatusCreated, newRide(ride))
+}
+
+// GetRide godoc
+// @Summary Get ride
+// @Description Get a ride of which the authenticated user is the rider or the driver
+// @Tags rides
+// @Produce json,application/problem+json
+// @Security BearerAuth
+// @Param id path string true "Ride ID"
+// @Success 200 {object} Ride "Ride"
+// @Failure 401 {object} problem.Details "unauthorized"
+// @Failure 403 {object} problem.Details "user_required or not_ride_participant"
+// @Failure 404 {object} problem.Details "ride_not_found"
+// @Failure 500 {object} problem.Details "internal_error"
+// @Router /rides/{id} [get]
+func (h *RideHandler) GetRide(c *gin.Context) {
+	ride, ok := h.participantRide(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, newRide(ride))
+}
+
+// CompleteRide godoc
+// @Summary Complete ride
+// @Description End a ride. Streams tracking the ride receive an end event and are closed.
+// @Tags rides
+// @Produce json,application/problem+json
+// @Security BearerAuth
+// @Param id path string true "Ride ID"
+// @Success 200 {object} Ride "Completed ride"
+// @Failure 401 {object} problem.Details "unauthorized"
+// @Failure 403 {object} problem.Details "user_required or not_ride_participant"
+// @Failure 404 {object} problem.Details "ride_not_found"
+// @Failure 409 {object} problem.Details "ride_ended"
+// @Failure 500 {object} problem.Details "internal_error"
+// @Router /rides/{id}/complete [post]
+func (h *RideHandler) CompleteRide(c *gin.Context) {
+	ride, ok := h.participantRide(c)
+	if !ok {
+		return
+	}
+
+	ride, err := h.rideService.CompleteRide(c, ride.ID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newRide(ride))
+}
+
+// participantRide looks up the ride of the request and checks that the
+// authenticated user is its rider or driver, or an admin
+func (h *RideHandler) participantRide(c *gin.Context) (*domain.Ride, bool) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return nil, false
+	}
+
+	ride, err := h.rideService.GetRide(c, c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return nil, false
+	}
+
+	if !ride.HasParticipant(userID) && c.GetString(middleware.ContextRole) != domain.RoleAdmin {
+		abort(c, http.StatusForbidden, CodeNotRideParticipant, "not a participant of this ride")
+		return nil, false
+	}
+
+	return ride, true
+}
+
+// Request types
+type CreateRideRequest struct {
+	// MatchToken is the match_token returned by /match
+	MatchToken string `json:"match_token" binding:"required"`
+}
@@ -0,0 +1,151 @@
+package v2
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+func newRideEngine(rideService *MockRideService) *gin.Engine {
+	h := NewRideHandler(rideService)
+	return newTestEngine(func(routes *gin.RouterGroup) {
+		routes.POST("/rides", h.CreateRide)
+		routes.GET("/rides/:id", h.GetRide)
+		routes.POST("/rides/:id/complete", h.CompleteRide)
+	})
+}
+
+func testRide(status string) *domain.Ride {
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return &domain.Ride{
+		ID:        "ride-1",
+		RiderID:   "rider-1",
+		DriverID:  "driver-1",
+		Pickup:    domain.NewPoint(41.0431, 29.0099),
+		Status:    status,
+		ExpiresAt: createdAt.Add(service.DefaultMaxRideDuration),
+		CreatedAt: createdAt,
+	}
+}
+
+func TestCreateRide(t *testing.T) {
+	rideService := new(MockRideService)
+	engine := newRideEngine(rideService)
+	rideService.On("CreateRide", mock.Anything, "rider-1", "token").Return(testRide(domain.RideStatusActive), nil)
+
+	w := request{method: http.MethodPost, path: "/api/v2/rides", user: "rider-1", body: `{"match_token": "token"}`}.do(engine)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/api/v2/rides/ride-1", w.Header().Get("Location"))
+	var ride Ride
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ride))
+	assert.Equal(t, "driver-1", ride.DriverID)
+	assert.Equal(t, 41.0431, *ride.Pickup.Latitude)
+	assert.Equal(t, 29.0099, *ride.Pickup.Longitude)
+	assert.Equal(t, domain.RideStatusActive, ride.Status)
+	assert.Equal(t, testRide(domain.RideStatusActive).ExpiresAt, ride.ExpiresAt)
+}
+
+func TestCreateRideErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   string
+		body   string
+		err    error
+		status int
+		code   string
+	}{
+		{name: "api key", body: `{"match_token": "token"}`, status: http.StatusForbidden, code: CodeUserRequired},
+		{name: "missing match token", user: "rider-1", body: `{"driver_id": "driver-1"}`, status: http.StatusBadRequest, code: "invalid_request"},
+		{name: "invalid match token", user: "rider-1", body: `{"match_token": "token"}`, err: service.ErrInvalidMatchToken, status: http.StatusBadRequest, code: "invalid_match_token"},
+		{name: "driver busy", user: "rider-1", body: `{"match_token": "token"}`, err: service.ErrDriverBusy, status: http.StatusConflict, code: "driver_busy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rideService := new(MockRideService)
+			engine := newRideEngine(rideService)
+			rideService.On("CreateRide", mock.Anything, "rider-1", "token").Return(nil, tt.err)
+
+			w := request{method: http.MethodPost, path: "/api/v2/rides", user: tt.user, body: tt.body}.do(engine)
+
+			assertProblem(t, w, tt.status, tt.code)
+		})
+	}
+}
+
+func TestGetRide(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   string
+		role   string
+		status int
+		code   string
+	}{
+		{name: "rider", user: "rider-1", status: http.StatusOK},
+		{name: "driver", user: "driver-1", status: http.StatusOK},
+		{name: "admin", user: "admin-1", role: domain.RoleAdmin, status: http.StatusOK},
+		{name: "other user", user: "rider-2", role: domain.RoleUser, status: http.StatusForbidden, code: CodeNotRideParticipant},
+		{name: "api key", status: http.StatusForbidden, code: CodeUserRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rideService := new(MockRideService)
+			engine := newRideEngine(rideService)
+			rideService.On("GetRide", mock.Anything, "ride-1").Return(testRide(domain.RideStatusActive), nil)
+
+			w := request{method: http.MethodGet, path: "/api/v2/rides/ride-1", user: tt.user, role: tt.role}.do(engine)
+
+			if tt.code != "" {
+				assertProblem(t, w, tt.status, tt.code)
+				return
+			}
+			require.Equal(t, tt.status, w.Code)
+			var ride Ride
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ride))
+			assert.Equal(t, "ride-1", ride.ID)
+		})
+	}
+}
+
+func TestGetRideNotFound(t *testing.T) {
+	rideService := new(MockRideService)
+	engine := newRideEngine(rideService)
+	rideService.On("GetRide", mock.Anything, "missing").Return(nil, service.ErrRideNotFound)
+
+	w := request{method: http.MethodGet, path: "/api/v2/rides/missing", user: "rider-1"}.do(engine)
+
+	assertProblem(t, w, http.StatusNotFound, "ride_not_found")
+}
+
+func TestCompleteRide(t *testing.T) {
+	rideService := new(MockRideService)
+	engine := newRideEngine(rideService)
+	completed := testRide(domain.RideStatusCompleted)
+	endedAt := completed.CreatedAt.Add(20 * time.Minute)
+	completed.EndedAt = &endedAt
+	rideService.On("GetRide", mock.Anything, "ride-1").Return(testRide(domain.RideStatusActive), nil)
+	rideService.On("CompleteRide", mock.Anything, "ride-1").Return(completed, nil).Once()
+	rideService.On("CompleteRide", mock.Anything, "ride-1").Return(nil, service.ErrRideEnded)
+
+	w := request{method: http.MethodPost, path: "/api/v2/rides/ride-1/complete", user: "driver-1"}.do(engine)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var ride Ride
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ride))
+	assert.Equal(t, domain.RideStatusCompleted, ride.Status)
+	assert.Equal(t, &endedAt, ride.EndedAt)
+
+	w = request{method: http.MethodPost, path: "/api/v2/rides/ride-1/complete", user: "driver-1"}.do(engine)
+	assertProblem(t, w, http.StatusConflict, "ride_ended")
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/repository/memory"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+func TestCreateWebhook(t *testing.T) {
+	h := NewWebhookHandler(service.NewWebhookService(memory.NewWebhookRepository()))
+	engine := newTestEngine(func(routes *gin.RouterGroup) {
+		routes.POST("/webhooks", h.CreateWebhook)
+	})
+
+	w := request{method: http.MethodPost, path: "/api/v1/webhooks", body: `{"url": "https://partner.example.com/hooks", "events": ["ride.matched"]}`}.do(engine)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var resp CreateWebhookResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.Secret)
+	assert.Equal(t, "https://partner.example.com/hooks", resp.Webhook.URL)
+
+	w = request{method: http.MethodPost, path: "/api/v1/webhooks", body: `{"url": "http://169.254.169.254/latest", "events": ["ride.matched"]}`}.do(engine)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "webhook url must not point to a private address"}`, w.Body.String())
+}
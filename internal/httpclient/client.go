@@ -0,0 +1,273 @@
+// Package httpclient provides an outbound HTTP client with a circuit breaker
+// per host, per-attempt timeouts and jittered retries for idempotent calls.
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/breaker"
+	"github.com/yusufatac/bitaksi-case-study/internal/requestctx"
+	"github.com/yusufatac/bitaksi-case-study/internal/tracing"
+)
+
+// IdempotencyKeyHeader marks a request as safe to retry regardless of its
+// method, following the convention of net/http
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Custom errors
+var (
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+)
+
+type Client struct {
+	httpClient     *http.Client
+	attemptTimeout time.Duration
+	maxRetries     int
+	baseBackoff    time.Duration
+	maxBackoff     time.Duration
+	breakerOptions []breaker.Option
+
+	mu       sync.Mutex
+	breakers map[string]*breaker.Breaker
+}
+
+// Option configures the client
+type Option func(*Client)
+
+// WithTransport sets the transport used to send requests
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.httpClient.Transport = transport
+	}
+}
+
+// WithAttemptTimeout bounds each attempt. The request context still bounds
+// the call as a whole, including retries.
+func WithAttemptTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.attemptTimeout = timeout
+	}
+}
+
+// WithMaxRetries sets how many times an idempotent request is retried
+func WithMaxRetries(retries int) Option {
+	return func(c *Client) {
+		c.maxRetries = retries
+	}
+}
+
+// WithBackoff sets the base and maximum delay between retries
+func WithBackoff(base, max time.Duration) Option {
+	return func(c *Client) {
+		c.baseBackoff = base
+		c.maxBackoff = max
+	}
+}
+
+// WithBreakerOptions configures the breaker created for each host. Breakers
+// are named after their host.
+func WithBreakerOptions(options ...breaker.Option) Option {
+	return func(c *Client) {
+		c.breakerOptions = options
+	}
+}
+
+func New(options ...Option) *Client {
+	c := &Client{
+		httpClient:     &http.Client{},
+		attemptTimeout: 5 * time.Second,
+		maxRetries:     2,
+		baseBackoff:    100 * time.Millisecond,
+		maxBackoff:     2 * time.Second,
+		breakers:       make(map[string]*breaker.Breaker),
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// Do sends the request through the breaker of its host, which classifies
+// each attempt. Idempotent requests are retried on network errors and 502,
+// 503 and 504 responses.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	b := c.breaker(req.URL.Host)
+	retries := 0
+	if isIdempotent(req) && (req.Body == nil || req.GetBody != nil) {
+		retries = c.maxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		if !b.AllowRequest() {
+			return nil, ErrCircuitOpen
+		}
+
+		resp, err := c.attempt(req, attempt)
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		b.Record(status, err)
+
+		if attempt >= retries || !shouldRetry(req.Context(), resp, err) {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := sleep(req.Context(), c.backoff(attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// attempt sends the request once under the per-attempt timeout, in a client
+// span, forwarding the request ID and trace context of the request context. The timeout is
+// released when the response body is closed.
+func (c *Client) attempt(req *http.Request, attempt int) (resp *http.Response, err error) {
+	ctx, span := tracing.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.String()),
+			semconv.ServerAddress(req.URL.Hostname()),
+			semconv.HTTPRequestResendCount(attempt),
+		),
+	)
+	defer func() {
+		if resp != nil {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+			if resp.StatusCode >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+			}
+		}
+		tracing.End(span, err)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, c.attemptTimeout)
+
+	attemptReq := req.Clone(ctx)
+	requestctx.Inject(ctx, attemptReq.Header)
+	tracing.Inject(ctx, attemptReq.Header)
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		attemptReq.Body = body
+	}
+
+	resp, err = c.httpClient.Do(attemptReq)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// backoff returns a random delay up to the exponential backoff of the
+// attempt ("full jitter"), so that clients do not retry in lockstep
+func (c *Client) backoff(attempt int) time.Duration {
+	ceiling := c.baseBackoff << uint(attempt)
+	if ceiling <= 0 || ceiling > c.maxBackoff {
+		ceiling = c.maxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+func (c *Client) breaker(host string) *breaker.Breaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.breakers[host]
+	if !ok {
+		options := append(append([]breaker.Option{}, c.breakerOptions...), breaker.WithName(host))
+		b = breaker.New(options...)
+		c.breakers[host] = b
+	}
+	return b
+}
+
+// Breakers returns the status of every host breaker, sorted by host
+func (c *Client) Breakers() []breaker.Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	statuses := make([]breaker.Status, 0, len(c.breakers))
+	for _, b := range c.breakers {
+		statuses = append(statuses, b.Status())
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
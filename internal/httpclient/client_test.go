@@ -0,0 +1,215 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/breaker"
+	"github.com/yusufatac/bitaksi-case-study/internal/requestctx"
+)
+
+// flakyServer fails the first failures requests with status and then succeeds,
+// echoing the request body
+func flakyServer(t *testing.T, failures int32, status int) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		io.Copy(w, r.Body)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func newTestClient(options ...Option) *Client {
+	return New(append([]Option{WithBackoff(time.Millisecond, 5*time.Millisecond)}, options...)...)
+}
+
+func TestDoRetriesIdempotentRequests(t *testing.T) {
+	server, calls := flakyServer(t, 2, http.StatusServiceUnavailable)
+	client := newTestClient(WithMaxRetries(2))
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+}
+
+func TestDoStopsAfterMaxRetries(t *testing.T) {
+	server, calls := flakyServer(t, 10, http.StatusBadGateway)
+	client := newTestClient(WithMaxRetries(2))
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+}
+
+func TestDoDoesNotRetryNonIdempotentRequests(t *testing.T) {
+	server, calls := flakyServer(t, 1, http.StatusServiceUnavailable)
+	client := newTestClient(WithMaxRetries(2))
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("payload"))
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
+
+func TestDoRetriesPostWithIdempotencyKey(t *testing.T) {
+	server, calls := flakyServer(t, 1, http.StatusServiceUnavailable)
+	client := newTestClient(WithMaxRetries(2))
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewBufferString("payload"))
+	require.NoError(t, err)
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "payload", string(body), "the body should be replayed on retry")
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+}
+
+func TestDoAttemptTimeout(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := newTestClient(WithAttemptTimeout(50*time.Millisecond), WithMaxRetries(1))
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestDoStopsWhenContextIsDone(t *testing.T) {
+	server, calls := flakyServer(t, 10, http.StatusServiceUnavailable)
+	client := New(WithMaxRetries(5), WithBackoff(time.Second, time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	_, err = client.Do(req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, atomic.LoadInt32(calls), int32(3))
+}
+
+func TestDoOpensBreakerPerHost(t *testing.T) {
+	failing, calls := flakyServer(t, 100, http.StatusInternalServerError)
+	healthy, _ := flakyServer(t, 0, http.StatusOK)
+	client := newTestClient(
+		WithMaxRetries(0),
+		WithBreakerOptions(breaker.WithFailureThreshold(2), breaker.WithResetTimeout(time.Minute)),
+	)
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, failing.URL, nil)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, failing.URL, nil)
+	_, err := client.Do(req)
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+
+	// Other hosts are not affected
+	req, _ = http.NewRequest(http.MethodGet, healthy.URL, nil)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	states := map[string]string{}
+	for _, status := range client.Breakers() {
+		states[status.Name] = status.State
+	}
+	assert.Equal(t, "open", states[strings.TrimPrefix(failing.URL, "http://")])
+	assert.Equal(t, "closed", states[strings.TrimPrefix(healthy.URL, "http://")])
+}
+
+func TestBackoffIsBounded(t *testing.T) {
+	client := New(WithBackoff(100*time.Millisecond, 300*time.Millisecond))
+
+	for attempt := 0; attempt < 10; attempt++ {
+		d := client.backoff(attempt)
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+		assert.Less(t, d, 300*time.Millisecond)
+	}
+}
+
+func TestDoForwardsRequestContext(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	tp := requestctx.NewTraceParent()
+	ctx := requestctx.WithRequestID(context.Background(), "req-1")
+	ctx = requestctx.WithTraceParent(ctx, tp)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := New().Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, "req-1", header.Get(requestctx.RequestIDHeader))
+	outbound, err := requestctx.ParseTraceParent(header.Get(requestctx.TraceParentHeader))
+	require.NoError(t, err)
+	assert.Equal(t, tp.TraceID, outbound.TraceID)
+}
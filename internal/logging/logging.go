@@ -0,0 +1,128 @@
+// Package logging builds the structured loggers used by the services. Loggers
+// write JSON or text, redact credentials, and add the request ID, trace ID
+// and user ID of the context to every record logged with one.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/requestctx"
+)
+
+// Redacted replaces the value of sensitive attributes
+const Redacted = "[REDACTED]"
+
+// Formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// sensitiveKeys are attribute keys and header names whose values are never logged
+var sensitiveKeys = map[string]bool{
+	"authorization":    true,
+	"cookie":           true,
+	"set-cookie":       true,
+	"x-api-key":        true,
+	"api_key":          true,
+	"password":         true,
+	"current_password": true,
+	"new_password":     true,
+	"token":            true,
+	"secret":           true,
+}
+
+type Config struct {
+	// Level is one of debug, info, warn or error. Defaults to info.
+	Level string
+	// Format is json or text. Defaults to json.
+	Format string
+}
+
+// New creates a logger writing to w
+func New(cfg Config, w io.Writer) *slog.Logger {
+	options := &slog.HandlerOptions{
+		Level:       ParseLevel(cfg.Level),
+		ReplaceAttr: redact,
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(cfg.Format, FormatText) {
+		handler = slog.NewTextHandler(w, options)
+	} else {
+		handler = slog.NewJSONHandler(w, options)
+	}
+
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// ParseLevel parses a level name, falling back to info
+func ParseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// IsSensitive reports whether values under key must not be logged
+func IsSensitive(key string) bool {
+	return sensitiveKeys[strings.ToLower(key)]
+}
+
+// RedactHeader returns a copy of header with sensitive values redacted
+func RedactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for key := range redacted {
+		if IsSensitive(key) {
+			redacted[key] = []string{Redacted}
+		}
+	}
+	return redacted
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if IsSensitive(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	if a.Value.Kind() == slog.KindAny {
+		if header, ok := a.Value.Any().(http.Header); ok {
+			return slog.Any(a.Key, RedactHeader(header))
+		}
+	}
+
+	return a
+}
+
+// contextHandler adds the request-scoped fields of the context to records
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id := requestctx.RequestID(ctx); id != "" {
+			r.AddAttrs(slog.String("request_id", id))
+		}
+		if tp, ok := requestctx.TraceParentFrom(ctx); ok {
+			r.AddAttrs(slog.String("trace_id", tp.TraceID))
+		}
+		if id := requestctx.UserID(ctx); id != "" {
+			r.AddAttrs(slog.String("user_id", id))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
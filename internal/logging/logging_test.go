@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/requestctx"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	return record
+}
+
+func TestRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{}, &buf)
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret-token")
+	header.Set("X-API-Key", "key-123")
+	header.Set("Accept", "application/json")
+
+	logger.Info("login",
+		"password", "hunter2",
+		"Token", "abc",
+		"headers", header,
+		"username", "alice",
+	)
+
+	out := buf.String()
+	assert.NotContains(t, out, "hunter2")
+	assert.NotContains(t, out, "secret-token")
+	assert.NotContains(t, out, "key-123")
+
+	record := decode(t, &buf)
+	assert.Equal(t, Redacted, record["password"])
+	assert.Equal(t, Redacted, record["Token"])
+	assert.Equal(t, "alice", record["username"])
+
+	headers := record["headers"].(map[string]any)
+	assert.Equal(t, []any{Redacted}, headers["Authorization"])
+	assert.Equal(t, []any{"application/json"}, headers["Accept"])
+
+	// The original header is left untouched
+	assert.Equal(t, "Bearer secret-token", header.Get("Authorization"))
+}
+
+func TestContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{}, &buf).With("service", "test")
+
+	tp := requestctx.NewTraceParent()
+	ctx := requestctx.WithRequestID(context.Background(), "req-1")
+	ctx = requestctx.WithTraceParent(ctx, tp)
+	ctx = requestctx.WithUserID(ctx, "user-1")
+
+	logger.InfoContext(ctx, "hello")
+
+	record := decode(t, &buf)
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, tp.TraceID, record["trace_id"])
+	assert.Equal(t, "user-1", record["user_id"])
+	assert.Equal(t, "test", record["service"])
+
+	// Records without request context have none of the fields
+	buf.Reset()
+	logger.Info("plain")
+	record = decode(t, &buf)
+	assert.NotContains(t, record, "request_id")
+	assert.NotContains(t, record, "user_id")
+}
+
+func TestLevelAndFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Level: "warn", Format: "text"}, &buf)
+
+	logger.Info("hidden")
+	logger.Warn("shown", "password", "hunter2")
+
+	out := buf.String()
+	assert.NotContains(t, out, "hidden")
+	assert.Contains(t, out, "msg=shown")
+	assert.Contains(t, out, "password="+Redacted)
+	assert.Equal(t, 1, strings.Count(out, "\n"))
+}
+
+func TestParseLevel(t *testing.T) {
+	assert.Equal(t, slog.LevelDebug, ParseLevel("debug"))
+	assert.Equal(t, slog.LevelError, ParseLevel("ERROR"))
+	assert.Equal(t, slog.LevelInfo, ParseLevel(""))
+	assert.Equal(t, slog.LevelInfo, ParseLevel("verbose"))
+}
@@ -0,0 +1,261 @@
+// Package metrics exposes the Prometheus metrics of the services. A Metrics
+// value owns its own registry, so tests can create one, drive the code under
+// test and assert on what was recorded.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/event"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/breaker"
+)
+
+// Route label of requests that matched no route
+const unmatchedRoute = "unmatched"
+
+// activeDriversTimeout bounds the active driver count run on every scrape
+const activeDriversTimeout = 2 * time.Second
+
+// Metrics records the measurements of a service
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests     *prometheus.CounterVec
+	httpDuration     *prometheus.HistogramVec
+	findNearby       prometheus.Histogram
+	findNearest      prometheus.Histogram
+	batchSize        prometheus.Histogram
+	matchResults     *prometheus.CounterVec
+	mongoDuration    *prometheus.HistogramVec
+	udpPackets       *prometheus.CounterVec
+	mqttMessages     *prometheus.CounterVec
+	webhookResults   *prometheus.CounterVec
+	breakerStateDesc *prometheus.Desc
+	activeDesc       *prometheus.Desc
+}
+
+// New creates the metrics of a service together with the Go runtime and
+// process collectors
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by method and route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		findNearby: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "location_find_nearby_duration_seconds",
+			Help:    "Latency of FindNearbyDrivers.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		findNearest: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "matching_find_nearest_duration_seconds",
+			Help:    "Latency of FindNearestDriver.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		batchSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "location_batch_size",
+			Help:    "Number of locations in batch updates.",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 7),
+		}),
+		matchResults: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "matching_results_total",
+			Help: "FindNearestDriver calls by result: matched, no_driver or error.",
+		}, []string{"result"}),
+		mongoDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "mongodb_command_duration_seconds",
+			Help:    "MongoDB command latency by command and outcome.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"command", "outcome"}),
+		udpPackets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "udp_packets_total",
+			Help: "UDP location datagrams by result: accepted, invalid, unauthorized, rate_limited or dropped.",
+		}, []string{"result"}),
+		mqttMessages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mqtt_messages_total",
+			Help: "MQTT location messages by result: accepted, invalid or failed.",
+		}, []string{"result"}),
+		webhookResults: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "webhook_deliveries_total",
+			Help: "Webhook delivery outcomes by event and result: delivered, retried or dead_lettered.",
+		}, []string{"event", "result"}),
+		breakerStateDesc: prometheus.NewDesc(
+			"circuit_breaker_state",
+			"Circuit breaker state; 1 for the current state of each breaker.",
+			[]string{"kind", "breaker", "state"}, nil,
+		),
+		activeDesc: prometheus.NewDesc(
+			"location_active_drivers",
+			"Drivers that reported an active location recently.",
+			nil, nil,
+		),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.findNearby,
+		m.findNearest,
+		m.batchSize,
+		m.matchResults,
+		m.mongoDuration,
+		m.udpPackets,
+		m.mqttMessages,
+		m.webhookResults,
+	)
+
+	return m
+}
+
+// Registry returns the registry the metrics are registered with
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler serves the metrics in the Prometheus text format. A failing
+// collector only drops its own metrics from the scrape.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// ObserveRequest records a served HTTP request. Requests that matched no
+// route share one label value to keep the cardinality bounded.
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	if route == "" {
+		route = unmatchedRoute
+	}
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ObserveFindNearby records the latency of a nearby driver search
+func (m *Metrics) ObserveFindNearby(duration time.Duration) {
+	m.findNearby.Observe(duration.Seconds())
+}
+
+// ObserveBatchSize records the size of a batch location update
+func (m *Metrics) ObserveBatchSize(size int) {
+	m.batchSize.Observe(float64(size))
+}
+
+// ObserveFindNearest records the latency and result of a match
+func (m *Metrics) ObserveFindNearest(duration time.Duration, result string) {
+	m.findNearest.Observe(duration.Seconds())
+	m.matchResults.WithLabelValues(result).Inc()
+}
+
+// ObserveUDPPackets records count UDP datagrams, or locations received in
+// them, with the given result
+func (m *Metrics) ObserveUDPPackets(result string, count int) {
+	m.udpPackets.WithLabelValues(result).Add(float64(count))
+}
+
+// ObserveMQTTMessage records an MQTT location message with the given result
+func (m *Metrics) ObserveMQTTMessage(result string) {
+	m.mqttMessages.WithLabelValues(result).Inc()
+}
+
+// ObserveWebhookDelivery records the outcome of a webhook delivery attempt
+func (m *Metrics) ObserveWebhookDelivery(event, result string) {
+	m.webhookResults.WithLabelValues(event, result).Inc()
+}
+
+// CommandMonitor returns a MongoDB command monitor recording the latency of
+// every command
+func (m *Metrics) CommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			m.mongoDuration.WithLabelValues(e.CommandName, "success").
+				Observe(time.Duration(e.DurationNanos).Seconds())
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			m.mongoDuration.WithLabelValues(e.CommandName, "failure").
+				Observe(time.Duration(e.DurationNanos).Seconds())
+		},
+	}
+}
+
+// RegisterBreakers exposes the state of the breakers returned by statuses,
+// read on every scrape. Kind tells breaker groups apart, e.g. route and
+// outbound.
+func (m *Metrics) RegisterBreakers(kind string, statuses func() []breaker.Status) {
+	m.registry.MustRegister(&breakerCollector{
+		desc:     m.breakerStateDesc,
+		kind:     kind,
+		statuses: statuses,
+	})
+}
+
+// RegisterActiveDrivers exposes the active driver count returned by count,
+// which is called on every scrape
+func (m *Metrics) RegisterActiveDrivers(count func(ctx context.Context) (int64, error)) {
+	m.registry.MustRegister(&activeDriversCollector{
+		desc:  m.activeDesc,
+		count: count,
+	})
+}
+
+var breakerStates = []string{
+	breaker.StateClosed.String(),
+	breaker.StateOpen.String(),
+	breaker.StateHalfOpen.String(),
+}
+
+type breakerCollector struct {
+	desc     *prometheus.Desc
+	kind     string
+	statuses func() []breaker.Status
+}
+
+// Describe sends nothing, which makes the collector unchecked: several
+// breaker collectors share one metric name with different kinds
+func (c *breakerCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c *breakerCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, status := range c.statuses() {
+		for _, state := range breakerStates {
+			value := 0.0
+			if status.State == state {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, value, c.kind, status.Name, state)
+		}
+	}
+}
+
+type activeDriversCollector struct {
+	desc  *prometheus.Desc
+	count func(ctx context.Context) (int64, error)
+}
+
+func (c *activeDriversCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *activeDriversCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), activeDriversTimeout)
+	defer cancel()
+
+	count, err := c.count(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count))
+}
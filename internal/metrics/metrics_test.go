@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/event"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/breaker"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestObserveRequest(t *testing.T) {
+	m := New()
+
+	m.ObserveRequest(http.MethodGet, "/api/v1/users/:id", 200, 10*time.Millisecond)
+	m.ObserveRequest(http.MethodGet, "/api/v1/users/:id", 200, 20*time.Millisecond)
+	m.ObserveRequest(http.MethodGet, "/api/v1/users/:id", 500, time.Millisecond)
+	m.ObserveRequest(http.MethodGet, "", 404, time.Millisecond)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/api/v1/users/:id", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/api/v1/users/:id", "500")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", unmatchedRoute, "404")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.httpDuration))
+}
+
+func TestServiceMetrics(t *testing.T) {
+	m := New()
+
+	m.ObserveFindNearby(5 * time.Millisecond)
+	m.ObserveBatchSize(50)
+	m.ObserveFindNearest(20*time.Millisecond, "matched")
+	m.ObserveFindNearest(10*time.Millisecond, "no_driver")
+	m.ObserveFindNearest(10*time.Millisecond, "no_driver")
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.matchResults.WithLabelValues("matched")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.matchResults.WithLabelValues("no_driver")))
+
+	body := scrape(t, m)
+	assert.Contains(t, body, "location_find_nearby_duration_seconds_count 1")
+	assert.Contains(t, body, "matching_find_nearest_duration_seconds_count 3")
+	assert.Contains(t, body, "location_batch_size_sum 50")
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestObserveUDPPackets(t *testing.T) {
+	m := New()
+
+	m.ObserveUDPPackets("accepted", 1)
+	m.ObserveUDPPackets("accepted", 1)
+	m.ObserveUDPPackets("dropped", 20)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.udpPackets.WithLabelValues("accepted")))
+	assert.Contains(t, scrape(t, m), `udp_packets_total{result="dropped"} 20`)
+}
+
+func TestObserveMQTTMessage(t *testing.T) {
+	m := New()
+
+	m.ObserveMQTTMessage("accepted")
+	m.ObserveMQTTMessage("invalid")
+	m.ObserveMQTTMessage("accepted")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.mqttMessages.WithLabelValues("accepted")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.mqttMessages.WithLabelValues("invalid")))
+}
+
+func TestObserveWebhookDelivery(t *testing.T) {
+	m := New()
+
+	m.ObserveWebhookDelivery("ride.matched", "retried")
+	m.ObserveWebhookDelivery("ride.matched", "delivered")
+	m.ObserveWebhookDelivery("ride.completed", "delivered")
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.webhookResults.WithLabelValues("ride.matched", "retried")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.webhookResults.WithLabelValues("ride.matched", "delivered")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.webhookResults.WithLabelValues("ride.completed", "delivered")))
+}
+
+func TestCommandMonitor(t *testing.T) {
+	m := New()
+	monitor := m.CommandMonitor()
+
+	monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", DurationNanos: int64(time.Millisecond)},
+	})
+	monitor.Failed(context.Background(), &event.CommandFailedEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "update", DurationNanos: int64(time.Millisecond)},
+	})
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `mongodb_command_duration_seconds_count{command="find",outcome="success"} 1`)
+	assert.Contains(t, body, `mongodb_command_duration_seconds_count{command="update",outcome="failure"} 1`)
+}
+
+func TestBreakerState(t *testing.T) {
+	m := New()
+
+	route := breaker.New(breaker.WithName("GET /api/v1/match"), breaker.WithFailureThreshold(1))
+	route.RecordFailure()
+	outbound := breaker.New(breaker.WithName("driver-location-api:8080"))
+
+	m.RegisterBreakers("route", func() []breaker.Status { return []breaker.Status{route.Status()} })
+	m.RegisterBreakers("outbound", func() []breaker.Status { return []breaker.Status{outbound.Status()} })
+
+	expected := `
+# HELP circuit_breaker_state Circuit breaker state; 1 for the current state of each breaker.
+# TYPE circuit_breaker_state gauge
+circuit_breaker_state{breaker="GET /api/v1/match",kind="route",state="closed"} 0
+circuit_breaker_state{breaker="GET /api/v1/match",kind="route",state="half-open"} 0
+circuit_breaker_state{breaker="GET /api/v1/match",kind="route",state="open"} 1
+circuit_breaker_state{breaker="driver-location-api:8080",kind="outbound",state="closed"} 1
+circuit_breaker_state{breaker="driver-location-api:8080",kind="outbound",state="half-open"} 0
+circuit_breaker_state{breaker="driver-location-api:8080",kind="outbound",state="open"} 0
+`
+	assert.NoError(t, testutil.GatherAndCompare(m.Registry(), strings.NewReader(expected), "circuit_breaker_state"))
+}
+
+func TestActiveDrivers(t *testing.T) {
+	m := New()
+
+	var err error
+	m.RegisterActiveDrivers(func(ctx context.Context) (int64, error) {
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		return 12, err
+	})
+
+	assert.Contains(t, scrape(t, m), "location_active_drivers 12")
+
+	// A failing count only drops its own metric
+	err = errors.New("mongo unavailable")
+	m.ObserveBatchSize(1)
+	body := scrape(t, m)
+	assert.NotContains(t, body, "location_active_drivers 12")
+	assert.Contains(t, body, "location_batch_size_count 1")
+}
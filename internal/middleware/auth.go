@@ -1,42 +1,186 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/problem"
+	"github.com/yusufatac/bitaksi-case-study/internal/requestctx"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+// APIKeyHeader is the header machine clients use to send their API key
+const APIKeyHeader = "X-API-Key"
+
+// Context keys set by RequireAuth
+const (
+	ContextUserID   = "user_id"
+	ContextUsername = "username"
+	ContextRole     = "role"
+	ContextAPIKey   = "api_key"
 )
 
+// UserLookup finds users by ID. Deleted users are not found.
+type UserLookup interface {
+	GetUser(ctx context.Context, userID string) (*domain.User, error)
+}
+
 type AuthMiddleware struct {
-	secretKey string
+	secretKey     string
+	apiKeyService service.APIKeyService
+	users         UserLookup
 }
 
-func NewAuthMiddleware(secretKey string) *AuthMiddleware {
-	return &AuthMiddleware{secretKey: secretKey}
+// AuthOption configures the auth middleware
+type AuthOption func(*AuthMiddleware)
+
+// WithUserLookup makes RequireActiveUser check tokens against the stored
+// users. Without it RequireActiveUser lets every token through.
+func WithUserLookup(users UserLookup) AuthOption {
+	return func(m *AuthMiddleware) {
+		m.users = users
+	}
 }
 
+func NewAuthMiddleware(secretKey string, apiKeyService service.APIKeyService, options ...AuthOption) *AuthMiddleware {
+	m := &AuthMiddleware{
+		secretKey:     secretKey,
+		apiKeyService: apiKeyService,
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+
+	return m
+}
+
+// RequireAuth accepts either a bearer JWT or an API key sent in X-API-Key
 func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if rawKey := c.GetHeader(APIKeyHeader); rawKey != "" {
+			m.authenticateAPIKey(c, rawKey)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header required"})
-			c.Abort()
+			problem.Abort(c, http.StatusUnauthorized, problem.CodeUnauthorized, "authorization header required")
 			return
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		claims := &service.Claims{}
+		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
 			return []byte(m.secretKey), nil
 		})
 
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or unauthorized token"})
-			c.Abort()
+		if err != nil || !token.Valid || !claims.Authenticated {
+			problem.Abort(c, http.StatusUnauthorized, problem.CodeUnauthorized, "invalid or unauthorized token")
+			return
+		}
+
+		role := claims.Role
+		if role == "" {
+			role = domain.RoleUser
+		}
+		c.Set(ContextUserID, claims.UserID)
+		c.Set(ContextUsername, claims.Username)
+		c.Set(ContextRole, role)
+		c.Request = c.Request.WithContext(requestctx.WithUserID(c.Request.Context(), claims.UserID))
+
+		c.Next()
+	}
+}
+
+func (m *AuthMiddleware) authenticateAPIKey(c *gin.Context, rawKey string) {
+	if m.apiKeyService == nil {
+		problem.Abort(c, http.StatusUnauthorized, problem.CodeUnauthorized, "api keys are not accepted")
+		return
+	}
+
+	key, err := m.apiKeyService.Authenticate(c, rawKey)
+	if err != nil {
+		if err == service.ErrInvalidAPIKey {
+			problem.Abort(c, http.StatusUnauthorized, problem.CodeUnauthorized, "invalid api key")
+		} else {
+			problem.Abort(c, http.StatusInternalServerError, problem.CodeInternal, "failed to verify api key")
+		}
+		return
+	}
+
+	c.Set(ContextAPIKey, key)
+	c.Next()
+}
+
+// RequireActiveUser rejects tokens of users that were deleted after the
+// token was issued, and takes the role from the stored user so that a
+// revoked admin role takes effect at once. Tokens are otherwise valid until
+// they expire, so sensitive routes should use it after RequireAuth.
+// API keys pass unchecked.
+func (m *AuthMiddleware) RequireActiveUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString(ContextUserID)
+		if m.users == nil || userID == "" {
+			c.Next()
+			return
+		}
+
+		user, err := m.users.GetUser(c, userID)
+		if errors.Is(err, service.ErrUserNotFound) {
+			problem.Abort(c, http.StatusUnauthorized, problem.CodeUnauthorized, "user no longer exists")
+			return
+		}
+		if err != nil {
+			problem.Abort(c, http.StatusInternalServerError, problem.CodeInternal, "failed to verify user")
+			return
+		}
+
+		role := user.Role
+		if role == "" {
+			role = domain.RoleUser
+		}
+		c.Set(ContextRole, role)
+
+		c.Next()
+	}
+}
+
+// RequireScope restricts a route to API keys holding the given scope.
+// Users authenticated with a JWT are not scoped and always pass.
+func (m *AuthMiddleware) RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get(ContextAPIKey)
+		if !exists {
+			c.Next()
+			return
+		}
+
+		if key, ok := value.(*domain.APIKey); !ok || !key.HasScope(scope) {
+			problem.Abort(c, http.StatusForbidden, problem.CodeForbidden, "api key is missing scope "+scope)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole restricts a route to users holding the given role.
+// API keys never satisfy a role requirement.
+func (m *AuthMiddleware) RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(ContextRole) != role {
+			problem.Abort(c, http.StatusForbidden, problem.CodeForbidden, "insufficient permissions")
 			return
 		}
 
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/service"
+)
+
+type fakeUserLookup map[string]*domain.User
+
+func (f fakeUserLookup) GetUser(ctx context.Context, userID string) (*domain.User, error) {
+	if userID == "broken" {
+		return nil, errors.New("connection refused")
+	}
+	user, ok := f[userID]
+	if !ok {
+		return nil, service.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func TestRequireActiveUser(t *testing.T) {
+	users := fakeUserLookup{
+		"admin":   {ID: "admin", Role: domain.RoleAdmin},
+		"demoted": {ID: "demoted", Role: domain.RoleUser},
+	}
+
+	tests := []struct {
+		name    string
+		options []AuthOption
+		userID  string
+		status  int
+	}{
+		{name: "active admin", options: []AuthOption{WithUserLookup(users)}, userID: "admin", status: http.StatusOK},
+		{name: "deleted user", options: []AuthOption{WithUserLookup(users)}, userID: "deleted", status: http.StatusUnauthorized},
+		{name: "revoked role", options: []AuthOption{WithUserLookup(users)}, userID: "demoted", status: http.StatusForbidden},
+		{name: "lookup failure", options: []AuthOption{WithUserLookup(users)}, userID: "broken", status: http.StatusInternalServerError},
+		{name: "api key", options: []AuthOption{WithUserLookup(users)}, status: http.StatusOK},
+		{name: "no lookup", userID: "deleted", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			auth := NewAuthMiddleware("secret", nil, tt.options...)
+			engine := gin.New()
+			engine.GET("/admin",
+				func(c *gin.Context) {
+					// Claims of a token issued while the user was an admin
+					if tt.userID != "" {
+						c.Set(ContextUserID, tt.userID)
+					}
+					c.Set(ContextRole, domain.RoleAdmin)
+				},
+				auth.RequireActiveUser(),
+				auth.RequireRole(domain.RoleAdmin),
+				func(c *gin.Context) {
+					c.Status(http.StatusOK)
+				},
+			)
+
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
@@ -2,148 +2,131 @@ package middleware
 
 import (
 	"net/http"
+	"sort"
 	"sync"
-	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/breaker"
+	"github.com/yusufatac/bitaksi-case-study/internal/problem"
 )
 
+// CircuitBreaker guards inbound requests with the breaker state machine
 type CircuitBreaker struct {
-	mu sync.RWMutex
-
-	failureThreshold uint
-	resetTimeout     time.Duration
-
-	failures  uint
-	lastError time.Time
-	state     State
+	*breaker.Breaker
 }
 
-type State int
+type State = breaker.State
 
 const (
-	StateClosed State = iota
-	StateOpen
-	StateHalfOpen
+	StateClosed   = breaker.StateClosed
+	StateOpen     = breaker.StateOpen
+	StateHalfOpen = breaker.StateHalfOpen
 )
 
-type Option func(*CircuitBreaker)
-
-func WithFailureThreshold(threshold uint) Option {
-	return func(cb *CircuitBreaker) {
-		cb.failureThreshold = threshold
-	}
-}
+type Option = breaker.Option
 
-func WithResetTimeout(timeout time.Duration) Option {
-	return func(cb *CircuitBreaker) {
-		cb.resetTimeout = timeout
-	}
-}
+var (
+	WithName              = breaker.WithName
+	WithFailureThreshold  = breaker.WithFailureThreshold
+	WithFailureRate       = breaker.WithFailureRate
+	WithResetTimeout      = breaker.WithResetTimeout
+	WithHalfOpenMaxProbes = breaker.WithHalfOpenMaxProbes
+	WithClassifier        = breaker.WithClassifier
+	WithOnStateChange     = breaker.WithOnStateChange
+)
 
 func NewCircuitBreaker(options ...Option) *CircuitBreaker {
-	cb := &CircuitBreaker{
-		failureThreshold: 5,
-		resetTimeout:     10 * time.Second,
-		state:            StateClosed,
-	}
-
-	for _, option := range options {
-		option(cb)
+	return &CircuitBreaker{
+		Breaker: breaker.New(options...),
 	}
-
-	return cb
 }
 
 func (cb *CircuitBreaker) Middleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		if !cb.AllowRequest() {
-			c.JSON(http.StatusServiceUnavailable, gin.H{
-				"error": "service temporarily unavailable",
-			})
-			c.Abort()
-			return
-		}
+	return cb.handle
+}
 
-		c.Next()
+func (cb *CircuitBreaker) handle(c *gin.Context) {
+	if !cb.AllowRequest() {
+		problem.Abort(c, http.StatusServiceUnavailable, problem.CodeUnavailable, "service temporarily unavailable")
+		return
+	}
 
-		// Check if request failed
-		if len(c.Errors) > 0 || c.Writer.Status() >= 500 {
+	// Record the result even when the handler panics, so that a panic
+	// counts as a failure and does not leave a half-open probe unfinished
+	defer func() {
+		if r := recover(); r != nil {
 			cb.RecordFailure()
-		} else {
-			cb.RecordSuccess()
+			panic(r)
 		}
-	}
-}
 
-func (cb *CircuitBreaker) AllowRequest() bool {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
-
-	switch cb.state {
-	case StateClosed:
-		return true
-
-	case StateOpen:
-		if time.Since(cb.lastError) > cb.resetTimeout {
-			cb.mu.RUnlock()
-			cb.mu.Lock()
-			cb.state = StateHalfOpen
-			cb.mu.Unlock()
-			cb.mu.RLock()
-			return true
+		// Let the classifier decide whether the request failed. Errors marked
+		// public were answered as client errors and are not failures.
+		var err error
+		if last := c.Errors.ByType(gin.ErrorTypePrivate).Last(); last != nil {
+			err = last
 		}
-		return false
+		cb.Record(c.Writer.Status(), err)
+	}()
 
-	case StateHalfOpen:
-		return true
+	c.Next()
+}
 
-	default:
-		return false
-	}
+// RouteCircuitBreakers keeps a separate breaker for every route, so a
+// failing endpoint does not take the healthy ones down with it
+type RouteCircuitBreakers struct {
+	mu       sync.Mutex
+	options  []Option
+	breakers map[string]*CircuitBreaker
 }
 
-func (cb *CircuitBreaker) RecordFailure() {
-	cb.mu.Lock()
-	defer cb.mu.Unlock()
+// NewRouteCircuitBreakers creates per-route breakers, each built with options
+func NewRouteCircuitBreakers(options ...Option) *RouteCircuitBreakers {
+	return &RouteCircuitBreakers{
+		options:  options,
+		breakers: make(map[string]*CircuitBreaker),
+	}
+}
 
-	switch cb.state {
-	case StateClosed:
-		cb.failures++
-		if cb.failures >= cb.failureThreshold {
-			cb.state = StateOpen
-			cb.lastError = time.Now()
+// Middleware routes each request through the breaker of its route.
+// Requests that match no route are not guarded.
+func (r *RouteCircuitBreakers) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			c.Next()
+			return
 		}
 
-	case StateHalfOpen:
-		cb.state = StateOpen
-		cb.lastError = time.Now()
-
-	default:
-		// Do nothing in other states
+		r.breaker(c.Request.Method + " " + route).handle(c)
 	}
 }
 
-func (cb *CircuitBreaker) RecordSuccess() {
-	cb.mu.Lock()
-	defer cb.mu.Unlock()
+func (r *RouteCircuitBreakers) breaker(route string) *CircuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	switch cb.state {
-	case StateHalfOpen:
-		cb.state = StateClosed
-		cb.failures = 0
-		cb.lastError = time.Time{}
+	cb, ok := r.breakers[route]
+	if !ok {
+		options := append(append([]Option{}, r.options...), WithName(route))
+		cb = NewCircuitBreaker(options...)
+		r.breakers[route] = cb
+	}
+	return cb
+}
 
-	case StateClosed:
-		cb.failures = 0
+// Breakers returns the status of every route breaker, sorted by route
+func (r *RouteCircuitBreakers) Breakers() []breaker.Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	default:
-		// Do nothing in other states
+	statuses := make([]breaker.Status, 0, len(r.breakers))
+	for _, cb := range r.breakers {
+		statuses = append(statuses, cb.Status())
 	}
-}
 
-func (cb *CircuitBreaker) State() State {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
-	return cb.state
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
 }
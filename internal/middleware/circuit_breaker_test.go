@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBreakerEngine(breakers *RouteCircuitBreakers) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(breakers.Middleware())
+	engine.GET("/failing/:id", func(c *gin.Context) {
+		c.Status(http.StatusInternalServerError)
+	})
+	engine.GET("/healthy", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return engine
+}
+
+func serve(engine *gin.Engine, path string) int {
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w.Code
+}
+
+func TestRouteCircuitBreakers(t *testing.T) {
+	breakers := NewRouteCircuitBreakers(WithFailureThreshold(2), WithResetTimeout(time.Minute))
+	engine := newBreakerEngine(breakers)
+
+	// Different paths of one route share its breaker
+	assert.Equal(t, http.StatusInternalServerError, serve(engine, "/failing/1"))
+	assert.Equal(t, http.StatusInternalServerError, serve(engine, "/failing/2"))
+	assert.Equal(t, http.StatusServiceUnavailable, serve(engine, "/failing/3"))
+
+	// Other routes keep working
+	assert.Equal(t, http.StatusOK, serve(engine, "/healthy"))
+
+	// Unknown routes are not guarded
+	assert.Equal(t, http.StatusNotFound, serve(engine, "/missing"))
+
+	statuses := breakers.Breakers()
+	if assert.Len(t, statuses, 2) {
+		assert.Equal(t, "GET /failing/:id", statuses[0].Name)
+		assert.Equal(t, "open", statuses[0].State)
+		assert.Equal(t, "GET /healthy", statuses[1].Name)
+		assert.Equal(t, "closed", statuses[1].State)
+	}
+}
+
+func TestCircuitBreakerCountsPanics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cb := NewCircuitBreaker(WithFailureThreshold(1), WithResetTimeout(time.Minute))
+	engine := gin.New()
+	engine.Use(gin.CustomRecovery(func(c *gin.Context, _ interface{}) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}), cb.Middleware())
+	engine.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	// The panic reaches the recovery middleware and opens the breaker
+	assert.Equal(t, http.StatusInternalServerError, serve(engine, "/panic"))
+	assert.Equal(t, StateOpen, cb.State())
+	assert.Equal(t, http.StatusServiceUnavailable, serve(engine, "/panic"))
+}
+
+func TestRouteCircuitBreakersConcurrent(t *testing.T) {
+	breakers := NewRouteCircuitBreakers(WithFailureThreshold(5), WithResetTimeout(time.Millisecond))
+	engine := newBreakerEngine(breakers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				serve(engine, "/failing/1")
+				serve(engine, "/healthy")
+				breakers.Breakers()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, breakers.Breakers(), 2)
+}
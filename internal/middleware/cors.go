@@ -0,0 +1,239 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORS request and response headers
+const (
+	corsOriginHeader           = "Origin"
+	corsRequestMethodHeader    = "Access-Control-Request-Method"
+	corsRequestHeadersHeader   = "Access-Control-Request-Headers"
+	corsAllowOriginHeader      = "Access-Control-Allow-Origin"
+	corsAllowMethodsHeader     = "Access-Control-Allow-Methods"
+	corsAllowHeadersHeader     = "Access-Control-Allow-Headers"
+	corsAllowCredentialsHeader = "Access-Control-Allow-Credentials"
+	corsExposeHeadersHeader    = "Access-Control-Expose-Headers"
+	corsMaxAgeHeader           = "Access-Control-Max-Age"
+)
+
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed to call the API, e.g.
+	// https://app.example.com. A pattern like https://*.example.com allows
+	// every subdomain of example.com, and * allows any origin. No origins
+	// means cross-origin requests are not allowed.
+	AllowedOrigins []string
+	// AllowedMethods are the methods preflight requests may ask for
+	AllowedMethods []string
+	// AllowedHeaders are the request headers preflight requests may ask for
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers readable by the caller
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and Authorization. It
+	// cannot be combined with the * origin.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// DefaultCORSConfig returns the methods and headers used by the API, with no
+// allowed origins
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		AllowedHeaders: []string{"Authorization", "Content-Type", "X-API-Key", "X-Request-ID", "traceparent", "tracestate", "Idempotency-Key"},
+		ExposedHeaders: []string{"Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "X-Request-ID", "traceparent", "Idempotent-Replayed"},
+		MaxAge:         10 * time.Minute,
+	}
+}
+
+// CORS applies a cross-origin resource sharing policy
+type CORS struct {
+	anyOrigin        bool
+	origins          map[string]bool
+	wildcards        []originPattern
+	methods          map[string]bool
+	headers          map[string]bool
+	allowMethods     string
+	allowHeaders     string
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+}
+
+// originPattern matches the subdomains of a wildcard origin such as
+// https://*.example.com
+type originPattern struct {
+	prefix string
+	suffix string
+}
+
+func (p originPattern) match(origin string) bool {
+	if len(origin) <= len(p.prefix)+len(p.suffix) {
+		return false
+	}
+	if !strings.HasPrefix(origin, p.prefix) || !strings.HasSuffix(origin, p.suffix) {
+		return false
+	}
+	subdomain := origin[len(p.prefix) : len(origin)-len(p.suffix)]
+	return !strings.ContainsAny(subdomain, "/:@")
+}
+
+// ErrCORSCredentialsAnyOrigin is returned by NewCORS when credentials are
+// allowed for any origin, which would let every site make authenticated
+// requests on behalf of the user
+var ErrCORSCredentialsAnyOrigin = errors.New("cors: credentials cannot be allowed for the * origin")
+
+// ErrCORSInvalidOrigin is returned by NewCORS for an origin with a * other
+// than the whole subdomain of a pattern like https://*.example.com, which
+// would otherwise be compared literally and never match
+var ErrCORSInvalidOrigin = errors.New("cors: invalid origin pattern")
+
+func NewCORS(cfg CORSConfig) (*CORS, error) {
+	c := &CORS{
+		origins:          make(map[string]bool),
+		methods:          make(map[string]bool),
+		headers:          make(map[string]bool),
+		allowMethods:     strings.Join(cfg.AllowedMethods, ", "),
+		allowHeaders:     strings.Join(cfg.AllowedHeaders, ", "),
+		exposeHeaders:    strings.Join(cfg.ExposedHeaders, ", "),
+		allowCredentials: cfg.AllowCredentials,
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch {
+		case origin == "":
+		case origin == "*":
+			c.anyOrigin = true
+		case strings.Count(origin, "*") > 1 || strings.Contains(origin, "*") && !strings.Contains(origin, "://*."):
+			return nil, fmt.Errorf("%w: %s", ErrCORSInvalidOrigin, origin)
+		case strings.Contains(origin, "*"):
+			i := strings.Index(origin, "*")
+			c.wildcards = append(c.wildcards, originPattern{prefix: origin[:i], suffix: origin[i+1:]})
+		default:
+			c.origins[origin] = true
+		}
+	}
+	for _, method := range cfg.AllowedMethods {
+		c.methods[strings.ToUpper(method)] = true
+	}
+	for _, header := range cfg.AllowedHeaders {
+		c.headers[strings.ToLower(header)] = true
+	}
+	if cfg.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+	if c.anyOrigin && c.allowCredentials {
+		return nil, ErrCORSCredentialsAnyOrigin
+	}
+
+	return c, nil
+}
+
+func (c *CORS) Middleware() gin.HandlerFunc {
+	return c.handle
+}
+
+func (c *CORS) handle(ctx *gin.Context) {
+	header := ctx.Writer.Header()
+	origin := ctx.GetHeader(corsOriginHeader)
+	preflight := ctx.Request.Method == http.MethodOptions && ctx.GetHeader(corsRequestMethodHeader) != ""
+
+	// Responses depend on the origin unless every origin gets the same "*"
+	if !c.anyOrigin {
+		header.Add("Vary", corsOriginHeader)
+	}
+	if preflight {
+		header.Add("Vary", corsRequestMethodHeader)
+		header.Add("Vary", corsRequestHeadersHeader)
+	}
+
+	if origin == "" {
+		ctx.Next()
+		return
+	}
+
+	if !c.allowedOrigin(origin) {
+		if preflight {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		// The browser blocks the response since it has no CORS headers
+		ctx.Next()
+		return
+	}
+
+	if c.anyOrigin {
+		header.Set(corsAllowOriginHeader, "*")
+	} else {
+		header.Set(corsAllowOriginHeader, origin)
+	}
+	if c.allowCredentials {
+		header.Set(corsAllowCredentialsHeader, "true")
+	}
+
+	if !preflight {
+		if c.exposeHeaders != "" {
+			header.Set(corsExposeHeadersHeader, c.exposeHeaders)
+		}
+		ctx.Next()
+		return
+	}
+
+	if !c.allowedMethod(ctx.GetHeader(corsRequestMethodHeader)) || !c.allowedHeaders(ctx.GetHeader(corsRequestHeadersHeader)) {
+		header.Del(corsAllowOriginHeader)
+		header.Del(corsAllowCredentialsHeader)
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	header.Set(corsAllowMethodsHeader, c.allowMethods)
+	if c.allowHeaders != "" {
+		header.Set(corsAllowHeadersHeader, c.allowHeaders)
+	}
+	if c.maxAge != "" {
+		header.Set(corsMaxAgeHeader, c.maxAge)
+	}
+	ctx.AbortWithStatus(http.StatusNoContent)
+}
+
+func (c *CORS) allowedOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	if c.origins[origin] {
+		return true
+	}
+	for _, pattern := range c.wildcards {
+		if pattern.match(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORS) allowedMethod(method string) bool {
+	return c.methods[strings.ToUpper(strings.TrimSpace(method))]
+}
+
+// allowedHeaders reports whether every header of a comma separated
+// Access-Control-Request-Headers value is allowed
+func (c *CORS) allowedHeaders(requested string) bool {
+	for _, name := range strings.Split(requested, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" && !c.headers[name] {
+			return false
+		}
+	}
+	return true
+}
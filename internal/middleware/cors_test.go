@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCORSEngine(t *testing.T, cfg CORSConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	cors, err := NewCORS(cfg)
+	require.NoError(t, err)
+	engine.Use(cors.Middleware())
+	engine.GET("/drivers", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return engine
+}
+
+func testCORSConfig() CORSConfig {
+	cfg := DefaultCORSConfig()
+	cfg.AllowedOrigins = []string{"https://app.example.org", "https://*.example.com"}
+	cfg.AllowCredentials = true
+	cfg.MaxAge = time.Hour
+	return cfg
+}
+
+func preflight(engine *gin.Engine, origin, method, headers string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, "/drivers", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	req.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		req.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSPreflight(t *testing.T) {
+	engine := newCORSEngine(t, testCORSConfig())
+
+	tests := []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+		allowed bool
+	}{
+		{"exact origin", "https://app.example.org", "POST", "Authorization, Content-Type", true},
+		{"origin case", "HTTPS://App.Example.org", "POST", "", true},
+		{"subdomain", "https://api.example.com", "DELETE", "", true},
+		{"nested subdomain", "https://eu.api.example.com", "GET", "", true},
+		{"header case", "https://app.example.org", "PATCH", "authorization,IDEMPOTENCY-KEY", true},
+		{"lowercase method", "https://app.example.org", "post", "", true},
+		{"unknown origin", "https://evil.org", "GET", "", false},
+		{"apex of wildcard", "https://example.com", "GET", "", false},
+		{"suffix lookalike", "https://evilexample.com", "GET", "", false},
+		{"wildcard as prefix", "https://api.example.com.evil.org", "GET", "", false},
+		{"scheme mismatch", "http://api.example.com", "GET", "", false},
+		{"port mismatch", "https://api.example.com:8443", "GET", "", false},
+		{"exact origin with port", "https://app.example.org:8443", "GET", "", false},
+		{"null origin", "null", "GET", "", false},
+		{"method not allowed", "https://app.example.org", "TRACE", "", false},
+		{"header not allowed", "https://app.example.org", "POST", "Content-Type, X-Debug", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := preflight(engine, tt.origin, tt.method, tt.headers)
+
+			vary := w.Header().Values("Vary")
+			assert.Contains(t, vary, "Origin")
+			assert.Contains(t, vary, "Access-Control-Request-Method")
+			assert.Contains(t, vary, "Access-Control-Request-Headers")
+
+			if !tt.allowed {
+				assert.Equal(t, http.StatusForbidden, w.Code)
+				assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+				assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+				return
+			}
+
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.Equal(t, tt.origin, w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+			assert.Equal(t, "GET, POST, PUT, PATCH, DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+			assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+			assert.Equal(t, "3600", w.Header().Get("Access-Control-Max-Age"))
+		})
+	}
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	engine := newCORSEngine(t, testCORSConfig())
+
+	tests := []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{"allowed origin", "https://api.example.com", true},
+		{"unknown origin", "https://evil.org", false},
+		{"same origin", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/drivers", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			// The request is served either way; the browser enforces the policy
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, []string{"Origin"}, w.Header().Values("Vary"))
+
+			if tt.allowed {
+				assert.Equal(t, tt.origin, w.Header().Get("Access-Control-Allow-Origin"))
+				assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+				assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "X-RateLimit-Remaining")
+			} else {
+				assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+				assert.Empty(t, w.Header().Get("Access-Control-Expose-Headers"))
+			}
+		})
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	cfg := DefaultCORSConfig()
+	cfg.AllowedOrigins = []string{"*"}
+	engine := newCORSEngine(t, cfg)
+
+	w := preflight(engine, "https://anything.test", "GET", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.NotContains(t, w.Header().Values("Vary"), "Origin")
+}
+
+func TestCORSAnyOriginWithCredentials(t *testing.T) {
+	cfg := DefaultCORSConfig()
+	cfg.AllowedOrigins = []string{"https://app.example.org", "*"}
+	cfg.AllowCredentials = true
+
+	cors, err := NewCORS(cfg)
+
+	assert.Nil(t, cors)
+	assert.ErrorIs(t, err, ErrCORSCredentialsAnyOrigin)
+}
+
+func TestCORSInvalidOrigin(t *testing.T) {
+	for _, origin := range []string{
+		"https://app*.example.com",
+		"*.example.com",
+		"https://*example.com",
+		"https://*.*.example.com",
+		"https://*.example.com:*",
+	} {
+		cfg := DefaultCORSConfig()
+		cfg.AllowedOrigins = []string{"https://app.example.org", origin}
+
+		cors, err := NewCORS(cfg)
+
+		assert.Nil(t, cors, origin)
+		assert.ErrorIs(t, err, ErrCORSInvalidOrigin, origin)
+	}
+}
+
+func TestCORSNoOrigins(t *testing.T) {
+	engine := newCORSEngine(t, DefaultCORSConfig())
+
+	w := preflight(engine, "https://app.example.org", "GET", "")
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSOptionsWithoutPreflight(t *testing.T) {
+	engine := newCORSEngine(t, testCORSConfig())
+
+	req := httptest.NewRequest(http.MethodOptions, "/drivers", nil)
+	req.Header.Set("Origin", "https://app.example.org")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	// Not a preflight, so it is routed like any other request
+	assert.NotEqual(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Max-Age"))
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/problem"
+)
+
+// kindResponses are the status and default code of each error kind
+var kindResponses = map[error]struct {
+	status int
+	code   string
+}{
+	domain.ErrValidation:      {http.StatusBadRequest, problem.CodeInvalidRequest},
+	domain.ErrUnauthorized:    {http.StatusUnauthorized, problem.CodeUnauthorized},
+	domain.ErrForbidden:       {http.StatusForbidden, problem.CodeForbidden},
+	domain.ErrNotFound:        {http.StatusNotFound, problem.CodeNotFound},
+	domain.ErrConflict:        {http.StatusConflict, problem.CodeConflict},
+	domain.ErrUnavailable:     {http.StatusServiceUnavailable, problem.CodeUnavailable},
+	domain.ErrTooManyRequests: {http.StatusTooManyRequests, problem.CodeRateLimited},
+	domain.ErrLocked:          {http.StatusLocked, problem.CodeLocked},
+}
+
+// retryable is implemented by errors that tell clients when to try again
+type retryable interface {
+	RetryDelay() time.Duration
+}
+
+// Errors answers requests whose handler recorded an error with c.Error and
+// wrote no response. Errors of a domain kind get the status of their kind
+// and, when they are a domain.Error, its code and message; the context a
+// service wrapped them in is not shown. A passed request deadline gets 504
+// Gateway Timeout and anything else a generic 500, so internal details never
+// reach clients. The error stays on the context for the access log.
+//
+// Errors answered with a 4xx status are marked public, so that circuit
+// breakers do not count them as failures. Errors that know when to retry
+// set Retry-After.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		writeError(c)
+	}
+}
+
+// writeError answers the last error recorded on c, unless a response has
+// been written already
+func writeError(c *gin.Context) {
+	last := c.Errors.Last()
+	if last == nil || c.Writer.Written() {
+		return
+	}
+
+	status, code, detail := errorResponse(last.Err)
+	if status < http.StatusInternalServerError {
+		last.Type = gin.ErrorTypePublic
+	}
+	var retry retryable
+	if errors.As(last.Err, &retry) && retry.RetryDelay() > 0 {
+		c.Header("Retry-After", strconv.Itoa(ceilSeconds(retry.RetryDelay())))
+	}
+	problem.Abort(c, status, code, detail)
+}
+
+// errorResponse returns the status, code and message answering err
+func errorResponse(err error) (int, string, string) {
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		response := kindResponses[domain.KindOf(domainErr)]
+		if response.status != 0 {
+			return response.status, domainErr.Code(), domainErr.Error()
+		}
+	}
+
+	if kind := domain.KindOf(err); kind != nil {
+		response := kindResponses[kind]
+		return response.status, response.code, kind.Error()
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout, problem.CodeTimeout, "request timed out"
+	}
+
+	return http.StatusInternalServerError, problem.CodeInternal, "internal server error"
+}
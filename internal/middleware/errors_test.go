@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/problem"
+)
+
+func newErrorsEngine(err error, middlewares ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(middlewares...)
+	engine.Use(Errors())
+	handler := func(c *gin.Context) {
+		_ = c.Error(err)
+	}
+	engine.GET("/v1", handler)
+	engine.GET("/v2", handler)
+	return engine
+}
+
+func TestErrors(t *testing.T) {
+	rideNotFound := domain.NewError(domain.ErrNotFound, "ride_not_found", "ride not found")
+
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		message string
+	}{
+		{name: "validation", err: domain.NewError(domain.ErrValidation, "invalid_radius", "invalid radius"), status: http.StatusBadRequest, message: "invalid radius"},
+		{name: "unauthorized", err: domain.NewError(domain.ErrUnauthorized, "invalid_token", "invalid token"), status: http.StatusUnauthorized, message: "invalid token"},
+		{name: "forbidden", err: domain.NewError(domain.ErrForbidden, "email_not_verified", "email not verified"), status: http.StatusForbidden, message: "email not verified"},
+		{name: "not found", err: rideNotFound, status: http.StatusNotFound, message: "ride not found"},
+		{name: "conflict", err: domain.NewError(domain.ErrConflict, "ride_ended", "ride has ended"), status: http.StatusConflict, message: "ride has ended"},
+		{name: "unavailable", err: domain.NewError(domain.ErrUnavailable, "location_service_unavailable", "location service unavailable"), status: http.StatusServiceUnavailable, message: "location service unavailable"},
+		{name: "too many requests", err: domain.NewError(domain.ErrTooManyRequests, "too_many_attempts", "too many attempts"), status: http.StatusTooManyRequests, message: "too many attempts"},
+		{name: "locked", err: domain.NewError(domain.ErrLocked, "account_locked", "account is locked"), status: http.StatusLocked, message: "account is locked"},
+		{name: "wrapped", err: fmt.Errorf("find ride r-1: %w", rideNotFound), status: http.StatusNotFound, message: "ride not found"},
+		{name: "bare kind", err: fmt.Errorf("%w: duplicate key E11000", domain.ErrConflict), status: http.StatusConflict, message: "conflict"},
+		{name: "deadline", err: fmt.Errorf("find drivers: %w", context.DeadlineExceeded), status: http.StatusGatewayTimeout, message: "request timed out"},
+		{name: "internal", err: fmt.Errorf("mongo: connection refused"), status: http.StatusInternalServerError, message: "internal server error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newErrorsEngine(tt.err).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1", nil))
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.JSONEq(t, fmt.Sprintf(`{"error": %q}`, tt.message), w.Body.String())
+		})
+	}
+}
+
+func TestErrorsProblem(t *testing.T) {
+	err := fmt.Errorf("get ride: %w", domain.NewError(domain.ErrNotFound, "ride_not_found", "ride not found"))
+	engine := newErrorsEngine(err, problem.Routes("/v2"))
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, problem.MediaType, w.Header().Get("Content-Type"))
+
+	var details problem.Details
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &details))
+	assert.Equal(t, "ride_not_found", details.Code)
+	assert.Equal(t, "ride not found", details.Detail)
+	assert.Equal(t, http.StatusNotFound, details.Status)
+}
+
+// delayedError asks clients to retry after a delay
+type delayedError struct {
+	error
+	delay time.Duration
+}
+
+func (e delayedError) Unwrap() error {
+	return e.error
+}
+
+func (e delayedError) RetryDelay() time.Duration {
+	return e.delay
+}
+
+func TestErrorsRetryAfter(t *testing.T) {
+	err := delayedError{error: domain.NewError(domain.ErrLocked, "account_locked", "account is locked"), delay: 1500 * time.Millisecond}
+
+	w := httptest.NewRecorder()
+	newErrorsEngine(err).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1", nil))
+
+	assert.Equal(t, http.StatusLocked, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+}
+
+func TestErrorsKeepsWrittenResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(Errors())
+	engine.GET("/", func(c *gin.Context) {
+		_ = c.Error(fmt.Errorf("cache: connection refused"))
+		c.JSON(http.StatusAccepted, gin.H{"status": "queued"})
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+}
+
+func TestErrorsCircuitBreaker(t *testing.T) {
+	clientErr := domain.NewError(domain.ErrValidation, "invalid_radius", "invalid radius")
+	breakers := NewRouteCircuitBreakers(WithFailureThreshold(1), WithResetTimeout(time.Minute))
+	engine := newErrorsEngine(clientErr, breakers.Middleware())
+
+	// Client errors do not open the breaker
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusBadRequest, serve(engine, "/v1"))
+	}
+
+	breakers = NewRouteCircuitBreakers(WithFailureThreshold(1), WithResetTimeout(time.Minute))
+	engine = newErrorsEngine(fmt.Errorf("mongo: connection refused"), breakers.Middleware())
+
+	// Internal errors do
+	assert.Equal(t, http.StatusInternalServerError, serve(engine, "/v1"))
+	assert.Equal(t, http.StatusServiceUnavailable, serve(engine, "/v1"))
+}
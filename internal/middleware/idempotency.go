@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/httpclient"
+	"github.com/yusufatac/bitaksi-case-study/internal/problem"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+// IdempotentReplayedHeader marks a response replayed from the idempotency store
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength bounds the keys accepted from clients
+const maxIdempotencyKeyLength = 255
+
+// replayedHeaders are the response headers stored with a response. Headers
+// describing the request itself, such as the request ID, are not replayed.
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// Idempotency stores the first response to a request carrying an
+// Idempotency-Key and replays it for retries with the same key. Keys are
+// scoped to the client, so two users can send the same key.
+type Idempotency struct {
+	repo        repository.IdempotencyRepository
+	ttl         time.Duration
+	lockTimeout time.Duration
+	now         func() time.Time
+}
+
+// IdempotencyOption configures the idempotency middleware
+type IdempotencyOption func(*Idempotency)
+
+// WithIdempotencyTTL sets how long responses are kept for replay
+func WithIdempotencyTTL(ttl time.Duration) IdempotencyOption {
+	return func(m *Idempotency) {
+		m.ttl = ttl
+	}
+}
+
+// WithIdempotencyLockTimeout sets how long a key stays locked by a request
+// that never completes, e.g. because the instance serving it crashed
+func WithIdempotencyLockTimeout(timeout time.Duration) IdempotencyOption {
+	return func(m *Idempotency) {
+		m.lockTimeout = timeout
+	}
+}
+
+func NewIdempotency(repo repository.IdempotencyRepository, options ...IdempotencyOption) *Idempotency {
+	m := &Idempotency{
+		repo:        repo,
+		ttl:         24 * time.Hour,
+		lockTimeout: time.Minute,
+		now:         time.Now,
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+
+	return m
+}
+
+// Middleware honors the Idempotency-Key header. Requests without it are
+// passed through. Responses with a 5xx status are not stored, so the
+// request can be retried with the same key.
+func (m *Idempotency) Middleware() gin.HandlerFunc {
+	return m.handle
+}
+
+func (m *Idempotency) handle(c *gin.Context) {
+	key := c.GetHeader(httpclient.IdempotencyKeyHeader)
+	if key == "" {
+		c.Next()
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		problem.Abort(c, http.StatusBadRequest, problem.CodeInvalidRequest, "Idempotency-Key is too long")
+		return
+	}
+
+	hash, err := requestHash(c)
+	if err != nil {
+		problem.Abort(c, http.StatusBadRequest, problem.CodeInvalidRequest, "failed to read request body")
+		return
+	}
+
+	now := m.now()
+	record := &domain.IdempotencyRecord{
+		Key:         clientKey(c) + ":" + key,
+		RequestHash: hash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(m.lockTimeout),
+	}
+
+	// A record that expires or is released between the two calls lets the
+	// request through on the second try
+	for attempt := 0; ; attempt++ {
+		created, err := m.repo.CreateIdempotencyRecord(c, record)
+		if err != nil {
+			m.unavailable(c, err)
+			return
+		}
+		if created {
+			break
+		}
+
+		existing, err := m.repo.GetIdempotencyRecord(c, record.Key)
+		if err != nil {
+			m.unavailable(c, err)
+			return
+		}
+		if existing != nil || attempt > 0 {
+			m.replay(c, record, existing)
+			return
+		}
+	}
+
+	writer := &capturingWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+
+	c.Next()
+
+	// Errors only answers once this middleware has returned, so the error a
+	// handler recorded is answered here to store the response it gets
+	writeError(c)
+
+	// Store the outcome even if the client went away meanwhile
+	ctx := context.WithoutCancel(c.Request.Context())
+	status := writer.Status()
+	if status >= http.StatusInternalServerError {
+		if err := m.repo.DeleteIdempotencyRecord(ctx, record.Key); err != nil {
+			c.Error(err)
+		}
+		return
+	}
+
+	record.Status = status
+	record.Header = http.Header{}
+	for _, name := range replayedHeaders {
+		if value := writer.Header().Get(name); value != "" {
+			record.Header.Set(name, value)
+		}
+	}
+	record.Body = writer.body.Bytes()
+	record.ExpiresAt = m.now().Add(m.ttl)
+	if err := m.repo.CompleteIdempotencyRecord(ctx, record); err != nil {
+		c.Error(err)
+	}
+}
+
+// replay answers a request whose key is in use by the existing record
+func (m *Idempotency) replay(c *gin.Context, record, existing *domain.IdempotencyRecord) {
+	switch {
+	case existing == nil || !existing.IsCompleted():
+		problem.Abort(c, http.StatusConflict, problem.CodeIdempotencyPending, "request with this Idempotency-Key is still being processed")
+	case existing.RequestHash != record.RequestHash:
+		problem.Abort(c, http.StatusUnprocessableEntity, problem.CodeIdempotencyReused, "Idempotency-Key was used with a different request")
+	default:
+		for name, values := range existing.Header {
+			for _, value := range values {
+				c.Writer.Header().Add(name, value)
+			}
+		}
+		c.Header(IdempotentReplayedHeader, "true")
+		c.Status(existing.Status)
+		c.Writer.Write(existing.Body)
+	}
+	c.Abort()
+}
+
+func (m *Idempotency) unavailable(c *gin.Context, err error) {
+	c.Error(err)
+	problem.Abort(c, http.StatusServiceUnavailable, problem.CodeUnavailable, "idempotency store unavailable")
+}
+
+// requestHash fingerprints the method, path and body of the request, so a
+// key cannot be reused for a different request. The body is restored for
+// the handler.
+func requestHash(c *gin.Context) (string, error) {
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		body, err = io.ReadAll(c.Request.Body)
+		if err != nil {
+			return "", err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	h := sha256.New()
+	io.WriteString(h, c.Request.Method+"\n"+c.Request.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// capturingWriter keeps a copy of the response body
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository/memory"
+)
+
+type idempotencyTest struct {
+	engine  *gin.Engine
+	calls   int32
+	status  int32
+	release chan struct{}
+}
+
+func newIdempotencyTest(options ...IdempotencyOption) *idempotencyTest {
+	gin.SetMode(gin.TestMode)
+	test := &idempotencyTest{status: http.StatusCreated}
+
+	idempotency := NewIdempotency(memory.NewIdempotencyRepository(), options...)
+	test.engine = gin.New()
+	test.engine.Use(func(c *gin.Context) {
+		c.Set(ContextUserID, c.GetHeader("X-User"))
+	})
+	test.engine.POST("/rides", idempotency.Middleware(), func(c *gin.Context) {
+		n := atomic.AddInt32(&test.calls, 1)
+		if test.release != nil {
+			<-test.release
+		}
+		c.Header("Location", "/rides/1")
+		c.JSON(int(atomic.LoadInt32(&test.status)), gin.H{"call": n})
+	})
+	return test
+}
+
+func (test *idempotencyTest) post(user, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/rides", strings.NewReader(body))
+	req.Header.Set("X-User", user)
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	test.engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysFirstResponse(t *testing.T) {
+	test := newIdempotencyTest()
+
+	first := test.post("alice", "key-1", `{"lat":41}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(IdempotentReplayedHeader))
+
+	second := test.post("alice", "key-1", `{"lat":41}`)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "/rides/1", second.Header().Get("Location"))
+	assert.Equal(t, "application/json; charset=utf-8", second.Header().Get("Content-Type"))
+	assert.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, int32(1), test.calls)
+
+	// Keys are scoped to the client, and requests without a key are not stored
+	assert.Equal(t, `{"call":2}`, test.post("bob", "key-1", `{"lat":41}`).Body.String())
+	assert.Equal(t, `{"call":3}`, test.post("alice", "", `{"lat":41}`).Body.String())
+	assert.Equal(t, `{"call":4}`, test.post("alice", "", `{"lat":41}`).Body.String())
+}
+
+func TestIdempotencyKeyReusedForDifferentRequest(t *testing.T) {
+	test := newIdempotencyTest()
+
+	test.post("alice", "key-1", `{"lat":41}`)
+	w := test.post("alice", "key-1", `{"lat":42}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, int32(1), test.calls)
+}
+
+func TestIdempotencyInFlightConflict(t *testing.T) {
+	test := newIdempotencyTest()
+	test.release = make(chan struct{})
+
+	var first *httptest.ResponseRecorder
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		first = test.post("alice", "key-1", `{}`)
+	}()
+
+	// Wait for the first request to reach the handler
+	for atomic.LoadInt32(&test.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Equal(t, http.StatusConflict, test.post("alice", "key-1", `{}`).Code)
+
+	close(test.release)
+	wg.Wait()
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, http.StatusCreated, test.post("alice", "key-1", `{}`).Code)
+	assert.Equal(t, int32(1), test.calls)
+}
+
+func TestIdempotencyServerErrorsAreNotStored(t *testing.T) {
+	test := newIdempotencyTest()
+	test.status = http.StatusServiceUnavailable
+
+	assert.Equal(t, http.StatusServiceUnavailable, test.post("alice", "key-1", `{}`).Code)
+
+	atomic.StoreInt32(&test.status, http.StatusCreated)
+	w := test.post("alice", "key-1", `{}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, int32(2), test.calls)
+}
+
+func TestIdempotencyWithRecordedErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	errs := []error{
+		fmt.Errorf("locations: %w", domain.ErrUnavailable),
+		domain.NewError(domain.ErrNotFound, "ride_not_found", "ride not found"),
+	}
+	var calls int
+
+	engine := gin.New()
+	engine.Use(Errors())
+	engine.POST("/rides", NewIdempotency(memory.NewIdempotencyRepository()).Middleware(), func(c *gin.Context) {
+		_ = c.Error(errs[calls])
+		calls++
+	})
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/rides", strings.NewReader(`{}`))
+		req.Header.Set("Idempotency-Key", "key-1")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	// The 503 is not stored, so the retry reaches the handler
+	assert.Equal(t, http.StatusServiceUnavailable, post().Code)
+
+	first := post()
+	assert.Equal(t, http.StatusNotFound, first.Code)
+	assert.Contains(t, first.Body.String(), "ride not found")
+
+	replayed := post()
+	assert.Equal(t, http.StatusNotFound, replayed.Code)
+	assert.Equal(t, first.Body.String(), replayed.Body.String())
+	assert.Equal(t, "true", replayed.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyTTL(t *testing.T) {
+	test := newIdempotencyTest(WithIdempotencyTTL(10 * time.Millisecond))
+
+	test.post("alice", "key-1", `{}`)
+	time.Sleep(20 * time.Millisecond)
+	w := test.post("alice", "key-1", `{}`)
+
+	assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, int32(2), test.calls)
+}
+
+func TestIdempotencyKeyTooLong(t *testing.T) {
+	test := newIdempotencyTest()
+
+	w := test.post("alice", strings.Repeat("k", 256), `{}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, int32(0), test.calls)
+}
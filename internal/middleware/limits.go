@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/problem"
+)
+
+// MaxBodySize rejects requests whose body is larger than the limit of their
+// route with 413 Request Entity Too Large. Routes missing from routeLimits,
+// keyed by route pattern, get defaultLimit; a limit of 0 disables the check.
+// Bodies are read up front, so handlers never see a truncated body.
+func MaxBodySize(defaultLimit int64, routeLimits map[string]int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := defaultLimit
+		if routeLimit, ok := routeLimits[c.FullPath()]; ok {
+			limit = routeLimit
+		}
+		if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			bodyTooLarge(c)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				bodyTooLarge(c)
+				return
+			}
+			problem.Abort(c, http.StatusBadRequest, problem.CodeInvalidRequest, "failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		c.Next()
+	}
+}
+
+func bodyTooLarge(c *gin.Context) {
+	// Do not keep the connection around to read the rest of the body
+	c.Header("Connection", "close")
+	problem.Abort(c, http.StatusRequestEntityTooLarge, problem.CodeBodyTooLarge, "request body too large")
+}
+
+// RequestTimeout sets a deadline on the request context. Handlers pass the
+// context down to services, repositories and outbound calls, so a slow
+// MongoDB query or upstream is abandoned once the deadline passes. Exempt
+// routes, keyed by route pattern, are long-lived streams and get no deadline.
+func RequestTimeout(timeout time.Duration, exempt ...string) gin.HandlerFunc {
+	exemptRoutes := make(map[string]bool, len(exempt))
+	for _, route := range exempt {
+		exemptRoutes[route] = true
+	}
+
+	return func(c *gin.Context) {
+		if timeout <= 0 || exemptRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
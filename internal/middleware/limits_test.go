@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBodyLimitEngine() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(MaxBodySize(10, map[string]int64{"/batch": 100, "/unlimited": 0}))
+
+	echo := func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	}
+	engine.POST("/single", echo)
+	engine.POST("/batch", echo)
+	engine.POST("/unlimited", echo)
+	return engine
+}
+
+func TestMaxBodySize(t *testing.T) {
+	engine := newBodyLimitEngine()
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		chunked  bool
+		expected int
+	}{
+		{"under default", "/single", "0123456789", false, http.StatusOK},
+		{"over default", "/single", "0123456789a", false, http.StatusRequestEntityTooLarge},
+		{"over default without length", "/single", "0123456789a", true, http.StatusRequestEntityTooLarge},
+		{"route override", "/batch", strings.Repeat("x", 100), false, http.StatusOK},
+		{"over route override", "/batch", strings.Repeat("x", 101), true, http.StatusRequestEntityTooLarge},
+		{"disabled for route", "/unlimited", strings.Repeat("x", 1000), true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.chunked {
+				// Hide the length so the body has to be read to be measured
+				body = io.MultiReader(body)
+			}
+			req := httptest.NewRequest(http.MethodPost, tt.path, body)
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+			if tt.expected == http.StatusOK {
+				assert.Equal(t, tt.body, w.Body.String(), "the handler gets the whole body")
+			} else {
+				assert.JSONEq(t, `{"error":"request body too large"}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.ContextWithFallback = true
+	engine.Use(RequestTimeout(20 * time.Millisecond))
+
+	var handlerCtx context.Context
+	engine.GET("/slow", func(c *gin.Context) {
+		handlerCtx = c.Request.Context()
+		_, hasDeadline := c.Deadline()
+		assert.True(t, hasDeadline)
+
+		// Stands in for a MongoDB call that honors the context
+		select {
+		case <-c.Done():
+			c.String(http.StatusGatewayTimeout, c.Err().Error())
+		case <-time.After(time.Second):
+			c.Status(http.StatusOK)
+		}
+	})
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, context.DeadlineExceeded.Error(), w.Body.String())
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Error(t, handlerCtx.Err())
+}
+
+func TestRequestTimeoutExemptRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(RequestTimeout(time.Second, "/stream/:id"))
+
+	hasDeadline := func(c *gin.Context) {
+		_, ok := c.Request.Context().Deadline()
+		c.JSON(http.StatusOK, ok)
+	}
+	engine.GET("/stream/:id", hasDeadline)
+	engine.GET("/other", hasDeadline)
+
+	for path, expected := range map[string]string{"/stream/1": "false", "/other": "true"} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, expected, w.Body.String(), path)
+	}
+}
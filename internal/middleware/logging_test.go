@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/logging"
+	"github.com/yusufatac/bitaksi-case-study/internal/requestctx"
+)
+
+func newLoggingEngine(buf *bytes.Buffer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := logging.New(logging.Config{}, buf)
+
+	engine := gin.New()
+	engine.ContextWithFallback = true
+	engine.Use(RequestContext(), AccessLog(logger), Recovery(logger))
+	engine.GET("/drivers/:id", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(requestctx.WithUserID(c.Request.Context(), "user-1"))
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+	})
+	engine.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	return engine
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	engine := newLoggingEngine(&buf)
+
+	req := httptest.NewRequest(http.MethodGet, "/drivers/42?token=secret", nil)
+	req.Header.Set(requestctx.RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "GET", record["method"])
+	assert.Equal(t, "/drivers/:id", record["route"])
+	assert.Equal(t, "/drivers/42", record["path"])
+	assert.Equal(t, float64(http.StatusOK), record["status"])
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, "user-1", record["user_id"])
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+func TestRecovery(t *testing.T) {
+	var buf bytes.Buffer
+	engine := newLoggingEngine(&buf)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"error":"internal server error"}`, w.Body.String())
+
+	decoder := json.NewDecoder(&buf)
+	var panicRecord, accessRecord map[string]any
+	require.NoError(t, decoder.Decode(&panicRecord))
+	require.NoError(t, decoder.Decode(&accessRecord))
+	assert.Equal(t, "panic recovered", panicRecord["msg"])
+	assert.Equal(t, "boom", panicRecord["panic"])
+	assert.Equal(t, "ERROR", accessRecord["level"])
+	assert.Equal(t, float64(http.StatusInternalServerError), accessRecord["status"])
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type observedRequest struct {
+	method string
+	route  string
+	status int
+}
+
+type fakeObserver struct {
+	requests []observedRequest
+}
+
+func (o *fakeObserver) ObserveRequest(method, route string, status int, _ time.Duration) {
+	o.requests = append(o.requests, observedRequest{method, route, status})
+}
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	observer := &fakeObserver{}
+
+	engine := gin.New()
+	engine.Use(Metrics(observer))
+	engine.GET("/drivers/:id", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	engine.POST("/drivers", func(c *gin.Context) {
+		c.Status(http.StatusBadRequest)
+	})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/drivers/1", nil),
+		httptest.NewRequest(http.MethodGet, "/drivers/2", nil),
+		httptest.NewRequest(http.MethodPost, "/drivers", nil),
+		httptest.NewRequest(http.MethodGet, "/missing", nil),
+	} {
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, []observedRequest{
+		{http.MethodGet, "/drivers/:id", http.StatusOK},
+		{http.MethodGet, "/drivers/:id", http.StatusOK},
+		{http.MethodPost, "/drivers", http.StatusBadRequest},
+		{http.MethodGet, "", http.StatusNotFound},
+	}, observer.requests)
+}
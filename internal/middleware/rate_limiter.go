@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/problem"
+)
+
+// RateLimit is a token bucket budget: Burst requests at once, refilled at
+// Rate requests per second. A zero Rate disables limiting.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitResult is the outcome of taking a token from a bucket
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long to wait until a token is available
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// RateLimitStore keeps the token buckets
+type RateLimitStore interface {
+	Take(key string, limit RateLimit, now time.Time) RateLimitResult
+}
+
+// RateLimiter limits requests per client with a separate budget for each
+// route group. Clients are identified by user ID, then API key, then IP.
+type RateLimiter struct {
+	store  RateLimitStore
+	limits map[string]RateLimit
+	now    func() time.Time
+}
+
+func NewRateLimiter(store RateLimitStore, limits map[string]RateLimit) *RateLimiter {
+	return &RateLimiter{
+		store:  store,
+		limits: limits,
+		now:    time.Now,
+	}
+}
+
+// Limit returns a middleware enforcing the budget of the given route group.
+// It must run after RequireAuth to key authenticated requests by principal.
+// Groups without a budget are not limited.
+func (rl *RateLimiter) Limit(group string) gin.HandlerFunc {
+	limit, ok := rl.limits[group]
+	if !ok || limit.Rate <= 0 || limit.Burst <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		result := rl.store.Take(group+"|"+clientKey(c), limit, rl.now())
+
+		header := c.Writer.Header()
+		header.Set("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			problem.Abort(c, http.StatusTooManyRequests, problem.CodeRateLimited, "rate limit exceeded")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// clientKey identifies the client making the request
+func clientKey(c *gin.Context) string {
+	if userID := c.GetString(ContextUserID); userID != "" {
+		return "user:" + userID
+	}
+	if value, exists := c.Get(ContextAPIKey); exists {
+		if key, ok := value.(*domain.APIKey); ok {
+			return "key:" + key.ID
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// sweepInterval is the number of takes on a shard between removals of full buckets
+const sweepInterval = 1024
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	// fullAt is when the bucket refills completely; it can be forgotten after that
+	fullAt time.Time
+}
+
+type rateLimitShard struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	takes   int
+}
+
+type memoryRateLimitStore struct {
+	shards []*rateLimitShard
+}
+
+// NewMemoryRateLimitStore creates an in-memory store split into shards,
+// each with its own lock, to reduce contention between clients
+func NewMemoryRateLimitStore(shards int) RateLimitStore {
+	if shards < 1 {
+		shards = 1
+	}
+
+	s := &memoryRateLimitStore{
+		shards: make([]*rateLimitShard, shards),
+	}
+	for i := range s.shards {
+		s.shards[i] = &rateLimitShard{
+			buckets: make(map[string]*tokenBucket),
+		}
+	}
+	return s
+}
+
+func (s *memoryRateLimitStore) Take(key string, limit RateLimit, now time.Time) RateLimitResult {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.takes++
+	if shard.takes%sweepInterval == 0 {
+		shard.sweep(now)
+	}
+
+	burst := float64(limit.Burst)
+	bucket, ok := shard.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, updatedAt: now}
+		shard.buckets[key] = bucket
+	}
+
+	// Refill for the time elapsed since the last take
+	if elapsed := now.Sub(bucket.updatedAt).Seconds(); elapsed > 0 {
+		bucket.tokens = math.Min(burst, bucket.tokens+elapsed*limit.Rate)
+		bucket.updatedAt = now
+	}
+
+	result := RateLimitResult{}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - bucket.tokens) / limit.Rate)
+	}
+
+	result.Remaining = int(bucket.tokens)
+	result.Reset = secondsToDuration((burst - bucket.tokens) / limit.Rate)
+	bucket.fullAt = now.Add(result.Reset)
+
+	return result
+}
+
+func (s *memoryRateLimitStore) shard(key string) *rateLimitShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+// sweep removes buckets that have refilled, since a new bucket starts full
+func (s *rateLimitShard) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		if !now.Before(bucket.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+func TestMemoryRateLimitStoreTake(t *testing.T) {
+	store := NewMemoryRateLimitStore(4)
+	limit := RateLimit{Rate: 2, Burst: 3}
+	now := time.Now()
+
+	for i := 2; i >= 0; i-- {
+		result := store.Take("client", limit, now)
+		require.True(t, result.Allowed)
+		assert.Equal(t, i, result.Remaining)
+	}
+
+	result := store.Take("client", limit, now)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, result.Reset)
+
+	// Other keys have their own bucket
+	assert.True(t, store.Take("other", limit, now).Allowed)
+
+	// Half a second refills one token at 2 per second
+	result = store.Take("client", limit, now.Add(500*time.Millisecond))
+	assert.True(t, result.Allowed)
+	assert.False(t, store.Take("client", limit, now.Add(500*time.Millisecond)).Allowed)
+
+	// Refills never exceed the burst
+	result = store.Take("client", limit, now.Add(time.Hour))
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining)
+}
+
+func TestMemoryRateLimitStoreSweep(t *testing.T) {
+	store := NewMemoryRateLimitStore(1).(*memoryRateLimitStore)
+	limit := RateLimit{Rate: 1, Burst: 1}
+	now := time.Now()
+
+	store.Take("idle", limit, now)
+	for i := 1; i < sweepInterval; i++ {
+		store.Take("busy", limit, now.Add(time.Duration(i)*time.Millisecond))
+	}
+	store.Take("busy", limit, now.Add(2*time.Second))
+
+	_, ok := store.shards[0].buckets["idle"]
+	assert.False(t, ok, "refilled bucket should be swept")
+	_, ok = store.shards[0].buckets["busy"]
+	assert.True(t, ok)
+}
+
+func TestMemoryRateLimitStoreConcurrent(t *testing.T) {
+	store := NewMemoryRateLimitStore(8)
+	limit := RateLimit{Rate: 0.001, Burst: 100}
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if store.Take("client", limit, now).Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 100, allowed)
+}
+
+func newRateLimitedEngine(rl *RateLimiter, group string, principal func(c *gin.Context)) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/", principal, rl.Limit(group), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return engine
+}
+
+func TestRateLimiterLimit(t *testing.T) {
+	now := time.Now()
+	rl := NewRateLimiter(NewMemoryRateLimitStore(1), map[string]RateLimit{
+		"locations": {Rate: 0.5, Burst: 2},
+	})
+	rl.now = func() time.Time { return now }
+
+	engine := newRateLimitedEngine(rl, "locations", func(c *gin.Context) {
+		c.Set(ContextUserID, "user-1")
+	})
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "4", w.Header().Get("X-RateLimit-Reset"))
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+}
+
+func TestRateLimiterUnknownGroup(t *testing.T) {
+	rl := NewRateLimiter(NewMemoryRateLimitStore(1), map[string]RateLimit{
+		"disabled": {Rate: 0, Burst: 1},
+	})
+
+	for _, group := range []string{"disabled", "missing"} {
+		engine := newRateLimitedEngine(rl, group, func(c *gin.Context) {})
+		for i := 0; i < 5; i++ {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal func(c *gin.Context)
+		expected  string
+	}{
+		{
+			name:      "user",
+			principal: func(c *gin.Context) { c.Set(ContextUserID, "user-1") },
+			expected:  "user:user-1",
+		},
+		{
+			name:      "api key",
+			principal: func(c *gin.Context) { c.Set(ContextAPIKey, &domain.APIKey{ID: "key-1"}) },
+			expected:  "key:key-1",
+		},
+		{
+			name:      "anonymous",
+			principal: func(c *gin.Context) {},
+			expected:  "ip:192.0.2.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			tt.principal(c)
+
+			assert.Equal(t, tt.expected, clientKey(c))
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/requestctx"
+)
+
+// RequestContext accepts or generates the request ID and W3C traceparent of
+// each request, stores them in the request context and echoes them in the
+// response. The echoed traceparent identifies this service's span, which is
+// the parent of any outbound call made while serving the request.
+func RequestContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestctx.RequestIDHeader)
+		if !requestctx.ValidRequestID(requestID) {
+			requestID = requestctx.NewRequestID()
+		}
+
+		traceParent, err := requestctx.ParseTraceParent(c.GetHeader(requestctx.TraceParentHeader))
+		if err != nil {
+			traceParent = requestctx.NewTraceParent()
+		} else {
+			traceParent = traceParent.Child()
+		}
+
+		ctx := requestctx.WithRequestID(c.Request.Context(), requestID)
+		ctx = requestctx.WithTraceParent(ctx, traceParent)
+		if err == nil {
+			if state := c.GetHeader(requestctx.TraceStateHeader); state != "" {
+				ctx = requestctx.WithTraceState(ctx, state)
+			}
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Header(requestctx.RequestIDHeader, requestID)
+		c.Header(requestctx.TraceParentHeader, traceParent.String())
+
+		c.Next()
+	}
+}
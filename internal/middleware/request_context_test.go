@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/requestctx"
+)
+
+// serveRequestContext runs a request through RequestContext and returns the
+// response with the context seen by the handler
+func serveRequestContext(t *testing.T, header http.Header) (*httptest.ResponseRecorder, context.Context) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.ContextWithFallback = true
+	engine.Use(RequestContext())
+
+	var ctx context.Context
+	engine.GET("/", func(c *gin.Context) {
+		ctx = c
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	require.NotNil(t, ctx)
+
+	return w, ctx
+}
+
+func TestRequestContextGenerates(t *testing.T) {
+	w, ctx := serveRequestContext(t, http.Header{})
+
+	requestID := w.Header().Get(requestctx.RequestIDHeader)
+	assert.NotEmpty(t, requestID)
+	assert.Equal(t, requestID, requestctx.RequestID(ctx))
+
+	tp, err := requestctx.ParseTraceParent(w.Header().Get(requestctx.TraceParentHeader))
+	require.NoError(t, err)
+
+	stored, ok := requestctx.TraceParentFrom(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, tp, stored)
+}
+
+func TestRequestContextAccepts(t *testing.T) {
+	incoming := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	w, ctx := serveRequestContext(t, http.Header{
+		"X-Request-Id": {"req-1"},
+		"Traceparent":  {incoming},
+		"Tracestate":   {"vendor=value"},
+	})
+
+	assert.Equal(t, "req-1", w.Header().Get(requestctx.RequestIDHeader))
+	assert.Equal(t, "req-1", requestctx.RequestID(ctx))
+	assert.Equal(t, "vendor=value", requestctx.TraceState(ctx))
+
+	tp, err := requestctx.ParseTraceParent(w.Header().Get(requestctx.TraceParentHeader))
+	require.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tp.TraceID, "the trace is continued")
+	assert.NotEqual(t, "00f067aa0ba902b7", tp.ParentID, "this service gets its own span")
+}
+
+func TestRequestContextRejectsInvalid(t *testing.T) {
+	w, ctx := serveRequestContext(t, http.Header{
+		"X-Request-Id": {"bad id\n"},
+		"Traceparent":  {"garbage"},
+		"Tracestate":   {"vendor=value"},
+	})
+
+	assert.NotEqual(t, "bad id\n", w.Header().Get(requestctx.RequestIDHeader))
+	assert.True(t, requestctx.ValidRequestID(w.Header().Get(requestctx.RequestIDHeader)))
+	assert.Empty(t, requestctx.TraceState(ctx), "tracestate is dropped with an invalid traceparent")
+
+	_, err := requestctx.ParseTraceParent(w.Header().Get(requestctx.TraceParentHeader))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type responseControllerKey struct{}
+
+// ErrNoResponseController is returned by DisableDeadlines when the server
+// handler was not wrapped with ResponseControllers
+var ErrNoResponseController = errors.New("no response controller on the request")
+
+// ResponseControllers makes the http.ResponseController of each response
+// available to gin handlers. gin's response writer hides the one of the
+// underlying connection, which streaming handlers need to lift the server
+// read and write timeouts.
+func ResponseControllers(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), responseControllerKey{}, http.NewResponseController(w))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// DisableDeadlines lifts the server read and write timeouts for the
+// connection of c, so that a long-lived response such as a Server-Sent
+// Events stream is not cut off. The read deadline also bounds the server's
+// check for clients going away, which would otherwise cancel the request.
+func DisableDeadlines(c *gin.Context) error {
+	rc, ok := c.Request.Context().Value(responseControllerKey{}).(*http.ResponseController)
+	if !ok {
+		return ErrNoResponseController
+	}
+
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		return err
+	}
+	return rc.SetWriteDeadline(time.Time{})
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisableDeadlines(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+
+	stream := func(c *gin.Context) {
+		if c.Query("disable") == "true" {
+			require.NoError(t, DisableDeadlines(c))
+		}
+		for i := 0; i < 5; i++ {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+			_, _ = c.Writer.WriteString("tick\n")
+			c.Writer.Flush()
+		}
+	}
+	engine.GET("/stream", stream)
+
+	server := httptest.NewUnstartedServer(ResponseControllers(engine))
+	server.Config.ReadTimeout = 100 * time.Millisecond
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	read := func(query string) (string, error) {
+		resp, err := http.Get(server.URL + "/stream?" + query)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	body, err := read("disable=true")
+	require.NoError(t, err)
+	assert.Equal(t, "tick\ntick\ntick\ntick\ntick\n", body, "the stream outlives the server timeouts")
+
+	body, _ = read("disable=false")
+	assert.NotEqual(t, "tick\ntick\ntick\ntick\ntick\n", body, "the server timeouts cut the stream off")
+}
+
+func TestDisableDeadlinesWithoutResponseController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+
+	var err error
+	engine.GET("/stream", func(c *gin.Context) {
+		err = DisableDeadlines(c)
+	})
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stream", nil))
+
+	assert.ErrorIs(t, err, ErrNoResponseController)
+}
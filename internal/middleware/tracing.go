@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/requestctx"
+	"github.com/yusufatac/bitaksi-case-study/internal/tracing"
+)
+
+// Tracing starts a server span for every request, continuing the trace of
+// the caller's traceparent. It runs after RequestContext and replaces the
+// traceparent stored in the request context, and echoed in the response,
+// with the span, so logs and outbound calls point at the recorded span.
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		ctx := tracing.Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := tracing.Start(ctx, strings.TrimSpace(c.Request.Method+" "+route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(c.Request.URL.Path),
+			),
+		)
+		defer span.End()
+
+		if tp, ok := tracing.TraceParent(ctx); ok {
+			ctx = requestctx.WithTraceParent(ctx, tp)
+			c.Header(requestctx.TraceParentHeader, tp.String())
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if last := c.Errors.Last(); last != nil {
+			span.RecordError(last.Err)
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/httpclient"
+	"github.com/yusufatac/bitaksi-case-study/internal/requestctx"
+	"github.com/yusufatac/bitaksi-case-study/internal/tracing"
+)
+
+func useSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+	return recorder
+}
+
+func newTracingEngine() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.ContextWithFallback = true
+	engine.Use(RequestContext(), Tracing())
+	return engine
+}
+
+// A match request and the driver location call it makes share one trace
+func TestTracingAcrossServices(t *testing.T) {
+	recorder := useSpanRecorder(t)
+
+	locationAPI := newTracingEngine()
+	locationAPI.POST("/api/v1/locations/nearby", func(c *gin.Context) {
+		_, span := tracing.Start(c, "LocationService.FindNearbyDrivers")
+		span.End()
+		c.JSON(http.StatusOK, []string{})
+	})
+	locationServer := httptest.NewServer(locationAPI)
+	defer locationServer.Close()
+
+	client := httpclient.New()
+	matchingAPI := newTracingEngine()
+	matchingAPI.POST("/api/v1/match", func(c *gin.Context) {
+		req, _ := http.NewRequestWithContext(c, http.MethodPost, locationServer.URL+"/api/v1/locations/nearby", nil)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		c.Status(http.StatusNotFound)
+	})
+
+	w := httptest.NewRecorder()
+	matchingAPI.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/match", nil))
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	require.Len(t, spans, 4)
+
+	match := spans["POST /api/v1/match"]
+	outbound := spans["HTTP POST"]
+	nearby := spans["POST /api/v1/locations/nearby"]
+	service := spans["LocationService.FindNearbyDrivers"]
+	require.NotNil(t, match)
+	require.NotNil(t, outbound)
+	require.NotNil(t, nearby)
+	require.NotNil(t, service)
+
+	traceID := match.SpanContext().TraceID()
+	for _, span := range spans {
+		assert.Equal(t, traceID, span.SpanContext().TraceID(), span.Name())
+	}
+	assert.False(t, match.Parent().IsValid())
+	assert.Equal(t, match.SpanContext().SpanID(), outbound.Parent().SpanID())
+	assert.Equal(t, outbound.SpanContext().SpanID(), nearby.Parent().SpanID())
+	assert.True(t, nearby.Parent().IsRemote())
+	assert.Equal(t, nearby.SpanContext().SpanID(), service.Parent().SpanID())
+	assert.Equal(t, trace.SpanKindServer, match.SpanKind())
+	assert.Equal(t, trace.SpanKindClient, outbound.SpanKind())
+
+	// The echoed traceparent names the server span
+	tp, err := requestctx.ParseTraceParent(w.Header().Get(requestctx.TraceParentHeader))
+	require.NoError(t, err)
+	assert.Equal(t, traceID.String(), tp.TraceID)
+	assert.Equal(t, match.SpanContext().SpanID().String(), tp.ParentID)
+}
+
+func TestTracingRecordsServerErrors(t *testing.T) {
+	recorder := useSpanRecorder(t)
+
+	engine := newTracingEngine()
+	engine.GET("/failing", func(c *gin.Context) {
+		c.Status(http.StatusServiceUnavailable)
+	})
+
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/failing", nil))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GET /failing", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+}
+
+// With tracing disabled the traceparent of RequestContext is kept
+func TestTracingDisabled(t *testing.T) {
+	otel.SetTracerProvider(noop.NewTracerProvider())
+	engine := newTracingEngine()
+	var stored requestctx.TraceParent
+	engine.GET("/", func(c *gin.Context) {
+		stored, _ = requestctx.TraceParentFrom(c)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(requestctx.TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", stored.TraceID)
+	assert.NotEqual(t, "00f067aa0ba902b7", stored.ParentID)
+	assert.Equal(t, stored.String(), w.Header().Get(requestctx.TraceParentHeader))
+}
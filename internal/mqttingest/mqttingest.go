@@ -0,0 +1,232 @@
+// Package mqttingest bridges driver locations published to an MQTT broker
+// into the location service.
+//
+// Telematics boxes publish to a topic per driver, e.g.
+// "fleet/{driver_id}/location". The bridge subscribes to the matching filter,
+// takes the driver ID from the topic and saves every message through the
+// location service. It subscribes again whenever it reconnects.
+//
+// Messages that cannot be parsed are dropped. Messages are acknowledged once
+// handled, even when the save failed, so a broken database does not make the
+// broker redeliver them forever.
+package mqttingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+// Message results recorded by Metrics
+const (
+	ResultAccepted = "accepted"
+	// ResultInvalid is a message on an unexpected topic or with a payload
+	// that is not a valid location
+	ResultInvalid = "invalid"
+	// ResultFailed is a valid location that could not be saved
+	ResultFailed = "failed"
+)
+
+// disconnectQuiesce is how long Stop waits for in-flight work, in milliseconds
+const disconnectQuiesce = 250
+
+// ErrConnectTimeout is returned by Start when the broker cannot be reached in time
+var ErrConnectTimeout = errors.New("mqttingest: timed out connecting to broker")
+
+// Saver saves batches of driver locations
+type Saver interface {
+	UpdateDriverLocations(ctx context.Context, locations []domain.DriverLocation) error
+}
+
+// Metrics records what happened to received messages
+type Metrics interface {
+	ObserveMQTTMessage(result string)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveMQTTMessage(string) {}
+
+// Config tunes the bridge
+type Config struct {
+	// BrokerURL is the broker address, e.g. tcp://localhost:1883 or ssl://host:8883
+	BrokerURL string
+	ClientID  string
+	Username  string
+	Password  string
+	// TopicPattern is the topic devices publish to, with a {driver_id} level
+	TopicPattern string
+	// QoS is the subscription quality of service, 0, 1 or 2
+	QoS byte
+	// ConnectTimeout bounds Start
+	ConnectTimeout time.Duration
+	// SaveTimeout bounds the save of each message
+	SaveTimeout time.Duration
+}
+
+// DefaultConfig returns the bridge settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		BrokerURL:      "tcp://localhost:1883",
+		ClientID:       "driver-location",
+		TopicPattern:   "fleet/" + DriverIDPlaceholder + "/location",
+		QoS:            1,
+		ConnectTimeout: 10 * time.Second,
+		SaveTimeout:    5 * time.Second,
+	}
+}
+
+// Bridge subscribes to driver locations and saves them
+type Bridge struct {
+	saver   Saver
+	config  Config
+	pattern TopicPattern
+	metrics Metrics
+	logger  *slog.Logger
+	client  mqtt.Client
+
+	// subscribed receives the outcome of the subscription of every connection
+	subscribed chan error
+}
+
+// Option configures the bridge
+type Option func(*Bridge)
+
+// WithMetrics sets where the bridge records what happened to messages
+func WithMetrics(metrics Metrics) Option {
+	return func(b *Bridge) {
+		b.metrics = metrics
+	}
+}
+
+// WithLogger sets the logger of the bridge
+func WithLogger(logger *slog.Logger) Option {
+	return func(b *Bridge) {
+		b.logger = logger
+	}
+}
+
+// NewBridge creates a bridge saving the locations published on
+// config.TopicPattern through saver
+func NewBridge(saver Saver, config Config, options ...Option) (*Bridge, error) {
+	pattern, err := ParseTopicPattern(config.TopicPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bridge{
+		saver:      saver,
+		config:     config,
+		pattern:    pattern,
+		metrics:    noopMetrics{},
+		logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		subscribed: make(chan error, 1),
+	}
+	for _, option := range options {
+		option(b)
+	}
+
+	clientOptions := mqtt.NewClientOptions().
+		AddBroker(config.BrokerURL).
+		SetClientID(config.ClientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetConnectTimeout(config.ConnectTimeout).
+		SetAutoReconnect(true).
+		SetOnConnectHandler(b.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			b.logger.Warn("mqtt connection lost", "error", err)
+		})
+	b.client = mqtt.NewClient(clientOptions)
+
+	return b, nil
+}
+
+// Start connects to the broker and subscribes. It returns once the first
+// subscription is in place; later reconnects happen in the background.
+func (b *Bridge) Start() error {
+	token := b.client.Connect()
+	if !token.WaitTimeout(b.config.ConnectTimeout) {
+		b.client.Disconnect(0)
+		return ErrConnectTimeout
+	}
+	if err := token.Error(); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-b.subscribed:
+		if err != nil {
+			b.client.Disconnect(0)
+		}
+		return err
+	case <-time.After(b.config.ConnectTimeout):
+		b.client.Disconnect(0)
+		return ErrConnectTimeout
+	}
+}
+
+// Stop disconnects from the broker
+func (b *Bridge) Stop() {
+	b.client.Disconnect(disconnectQuiesce)
+}
+
+func (b *Bridge) onConnect(client mqtt.Client) {
+	filter := b.pattern.Filter()
+	token := client.Subscribe(filter, b.config.QoS, func(_ mqtt.Client, msg mqtt.Message) {
+		b.handleMessage(msg.Topic(), msg.Payload())
+	})
+
+	var err error
+	if !token.WaitTimeout(b.config.ConnectTimeout) {
+		err = ErrConnectTimeout
+	} else {
+		err = token.Error()
+	}
+	if err != nil {
+		b.logger.Error("mqtt subscribe failed", "filter", filter, "error", err)
+	} else {
+		b.logger.Info("mqtt subscribed", "filter", filter)
+	}
+
+	// Only Start waits for the outcome; reconnects do not block on it
+	select {
+	case b.subscribed <- err:
+	default:
+	}
+}
+
+// handleMessage saves the location of a message and records the result
+func (b *Bridge) handleMessage(topic string, payload []byte) string {
+	result := b.save(topic, payload)
+	b.metrics.ObserveMQTTMessage(result)
+	return result
+}
+
+func (b *Bridge) save(topic string, payload []byte) string {
+	driverID, ok := b.pattern.DriverID(topic)
+	if !ok {
+		b.logger.Debug("mqtt message on unexpected topic", "topic", topic)
+		return ResultInvalid
+	}
+	location, err := ParseLocation(driverID, payload)
+	if err != nil {
+		b.logger.Debug("invalid mqtt message", "topic", topic, "error", err)
+		return ResultInvalid
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.config.SaveTimeout)
+	defer cancel()
+	if err := b.saver.UpdateDriverLocations(ctx, []domain.DriverLocation{location}); err != nil {
+		b.logger.Error("failed to save mqtt location", "driver_id", driverID, "error", err)
+		return ResultFailed
+	}
+
+	return ResultAccepted
+}
@@ -0,0 +1,256 @@
+package mqttingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+type fakeSaver struct {
+	mu        sync.Mutex
+	locations []domain.DriverLocation
+	err       error
+}
+
+func (f *fakeSaver) UpdateDriverLocations(ctx context.Context, locations []domain.DriverLocation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.locations = append(f.locations, locations...)
+	return nil
+}
+
+func (f *fakeSaver) saved() []domain.DriverLocation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]domain.DriverLocation(nil), f.locations...)
+}
+
+type fakeMetrics struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (f *fakeMetrics) ObserveMQTTMessage(result string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.counts == nil {
+		f.counts = make(map[string]int)
+	}
+	f.counts[result]++
+}
+
+func (f *fakeMetrics) count(result string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counts[result]
+}
+
+// startBroker runs an embedded broker on a free local port and returns it
+// with its URL
+func startBroker(t *testing.T) (*mochi.Server, string) {
+	t.Helper()
+
+	broker := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	require.NoError(t, broker.AddHook(new(auth.AllowHook), nil))
+	listener := listeners.NewTCP(listeners.Config{ID: "tcp", Address: "127.0.0.1:0"})
+	require.NoError(t, broker.AddListener(listener))
+	require.NoError(t, broker.Serve())
+	t.Cleanup(func() { broker.Close() })
+
+	return broker, "tcp://" + listener.Address()
+}
+
+func testConfig(brokerURL string) Config {
+	config := DefaultConfig()
+	config.BrokerURL = brokerURL
+	config.ClientID = "test-bridge"
+	config.ConnectTimeout = 5 * time.Second
+	return config
+}
+
+func TestTopicPattern(t *testing.T) {
+	pattern, err := ParseTopicPattern("fleet/{driver_id}/location")
+	require.NoError(t, err)
+	assert.Equal(t, "fleet/+/location", pattern.Filter())
+
+	driverID, ok := pattern.DriverID("fleet/driver-1/location")
+	assert.True(t, ok)
+	assert.Equal(t, "driver-1", driverID)
+
+	for _, topic := range []string{"fleet/driver-1/status", "fleet//location", "fleet/driver-1/location/extra", "other/driver-1/location"} {
+		_, ok := pattern.DriverID(topic)
+		assert.False(t, ok, topic)
+	}
+}
+
+func TestTopicPatternWildcardLevel(t *testing.T) {
+	pattern, err := ParseTopicPattern("+/vehicles/{driver_id}")
+	require.NoError(t, err)
+	assert.Equal(t, "+/vehicles/+", pattern.Filter())
+
+	driverID, ok := pattern.DriverID("istanbul/vehicles/driver-1")
+	assert.True(t, ok)
+	assert.Equal(t, "driver-1", driverID)
+}
+
+func TestParseTopicPatternErrors(t *testing.T) {
+	for _, pattern := range []string{
+		"fleet/location",
+		"fleet/{driver_id}/{driver_id}",
+		"fleet/{driver_id}/#",
+		"fleet/{driver_id}x/location",
+		"fleet+/{driver_id}",
+	} {
+		_, err := ParseTopicPattern(pattern)
+		assert.ErrorIs(t, err, ErrInvalidTopicPattern, pattern)
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	location, err := ParseLocation("driver-1", []byte(`{"latitude": 41.0431, "longitude": 29.0099}`))
+	require.NoError(t, err)
+	assert.Equal(t, domain.DriverLocation{
+		DriverID: "driver-1",
+		Location: domain.NewPoint(41.0431, 29.0099),
+		Status:   "active",
+	}, location)
+
+	location, err = ParseLocation("driver-1", []byte(`{"driver_id": "driver-1", "latitude": 0, "longitude": 0, "status": "busy"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "busy", location.Status)
+	assert.Equal(t, domain.NewPoint(0, 0), location.Location)
+}
+
+func TestParseLocationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		err     error
+	}{
+		{name: "not json", payload: `41.0,29.0`, err: ErrInvalidPayload},
+		{name: "missing longitude", payload: `{"latitude": 41}`, err: ErrInvalidPayload},
+		{name: "latitude", payload: `{"latitude": 91, "longitude": 29}`, err: ErrInvalidLatitude},
+		{name: "longitude", payload: `{"latitude": 41, "longitude": -181}`, err: ErrInvalidLongitude},
+		{name: "other driver", payload: `{"driver_id": "driver-2", "latitude": 41, "longitude": 29}`, err: ErrDriverIDMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseLocation("driver-1", []byte(tt.payload))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestNewBridgeInvalidPattern(t *testing.T) {
+	config := DefaultConfig()
+	config.TopicPattern = "fleet/#"
+
+	_, err := NewBridge(&fakeSaver{}, config)
+	assert.ErrorIs(t, err, ErrInvalidTopicPattern)
+}
+
+func TestBridge(t *testing.T) {
+	broker, url := startBroker(t)
+	saver := &fakeSaver{}
+	metrics := &fakeMetrics{}
+
+	bridge, err := NewBridge(saver, testConfig(url), WithMetrics(metrics))
+	require.NoError(t, err)
+	require.NoError(t, bridge.Start())
+	defer bridge.Stop()
+
+	require.NoError(t, broker.Publish("fleet/driver-1/location", []byte(`{"latitude": 41.0431, "longitude": 29.0099}`), false, 1))
+	require.NoError(t, broker.Publish("fleet/driver-2/location", []byte(`not json`), false, 1))
+	require.NoError(t, broker.Publish("fleet/driver-2/location", []byte(`{"latitude": 40.99, "longitude": 29.1, "status": "busy"}`), false, 1))
+	// Not matched by the subscription
+	require.NoError(t, broker.Publish("fleet/driver-3/status", []byte(`{"latitude": 41, "longitude": 29}`), false, 1))
+
+	require.Eventually(t, func() bool { return len(saver.saved()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	saved := saver.saved()
+	assert.Equal(t, "driver-1", saved[0].DriverID)
+	assert.Equal(t, domain.NewPoint(41.0431, 29.0099), saved[0].Location)
+	assert.Equal(t, "driver-2", saved[1].DriverID)
+	assert.Equal(t, "busy", saved[1].Status)
+
+	assert.Equal(t, 2, metrics.count(ResultAccepted))
+	assert.Equal(t, 1, metrics.count(ResultInvalid))
+}
+
+func TestBridgeCustomPattern(t *testing.T) {
+	broker, url := startBroker(t)
+	saver := &fakeSaver{}
+
+	config := testConfig(url)
+	config.TopicPattern = "tenants/+/drivers/{driver_id}"
+	bridge, err := NewBridge(saver, config)
+	require.NoError(t, err)
+	require.NoError(t, bridge.Start())
+	defer bridge.Stop()
+
+	require.NoError(t, broker.Publish("tenants/acme/drivers/driver-9", []byte(`{"latitude": 41, "longitude": 29}`), false, 0))
+
+	require.Eventually(t, func() bool { return len(saver.saved()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "driver-9", saver.saved()[0].DriverID)
+}
+
+func TestBridgeSaveFailure(t *testing.T) {
+	broker, url := startBroker(t)
+	metrics := &fakeMetrics{}
+
+	bridge, err := NewBridge(&fakeSaver{err: errors.New("mongo down")}, testConfig(url), WithMetrics(metrics))
+	require.NoError(t, err)
+	require.NoError(t, bridge.Start())
+	defer bridge.Stop()
+
+	require.NoError(t, broker.Publish("fleet/driver-1/location", []byte(`{"latitude": 41, "longitude": 29}`), false, 1))
+
+	require.Eventually(t, func() bool { return metrics.count(ResultFailed) == 1 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestBridgeResubscribesAfterReconnect(t *testing.T) {
+	broker, url := startBroker(t)
+	saver := &fakeSaver{}
+
+	bridge, err := NewBridge(saver, testConfig(url))
+	require.NoError(t, err)
+	require.NoError(t, bridge.Start())
+	defer bridge.Stop()
+
+	// Drop the bridge's connection; it reconnects on its own
+	client, ok := broker.Clients.Get("test-bridge")
+	require.True(t, ok)
+	client.Stop(errors.New("kicked"))
+
+	require.Eventually(t, func() bool {
+		_ = broker.Publish("fleet/driver-1/location", []byte(`{"latitude": 41, "longitude": 29}`), false, 0)
+		return len(saver.saved()) > 0
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+func TestBridgeStartUnreachableBroker(t *testing.T) {
+	config := testConfig("tcp://127.0.0.1:1")
+	config.ConnectTimeout = time.Second
+
+	bridge, err := NewBridge(&fakeSaver{}, config)
+	require.NoError(t, err)
+	assert.Error(t, bridge.Start())
+}
@@ -0,0 +1,121 @@
+package mqttingest
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+// DriverIDPlaceholder marks the topic level holding the driver ID
+const DriverIDPlaceholder = "{driver_id}"
+
+// Custom errors
+var (
+	ErrInvalidTopicPattern = errors.New("mqttingest: topic pattern must have exactly one {driver_id} level and no # wildcard")
+	ErrInvalidPayload      = errors.New("mqttingest: payload must be a JSON object with latitude and longitude")
+	ErrInvalidLatitude     = errors.New("mqttingest: latitude out of range")
+	ErrInvalidLongitude    = errors.New("mqttingest: longitude out of range")
+	ErrDriverIDMismatch    = errors.New("mqttingest: payload driver_id does not match the topic")
+)
+
+// TopicPattern is a topic with a {driver_id} level, e.g.
+// "fleet/{driver_id}/location". Other levels are literal or the + wildcard.
+type TopicPattern struct {
+	levels      []string
+	driverLevel int
+}
+
+// ParseTopicPattern parses a topic pattern
+func ParseTopicPattern(pattern string) (TopicPattern, error) {
+	levels := strings.Split(pattern, "/")
+	driverLevel := -1
+	for i, level := range levels {
+		switch {
+		case level == DriverIDPlaceholder:
+			if driverLevel >= 0 {
+				return TopicPattern{}, ErrInvalidTopicPattern
+			}
+			driverLevel = i
+		case strings.Contains(level, "#"):
+			return TopicPattern{}, ErrInvalidTopicPattern
+		case level != "+" && strings.ContainsAny(level, "+{}"):
+			return TopicPattern{}, ErrInvalidTopicPattern
+		}
+	}
+	if driverLevel < 0 {
+		return TopicPattern{}, ErrInvalidTopicPattern
+	}
+
+	return TopicPattern{levels: levels, driverLevel: driverLevel}, nil
+}
+
+// Filter returns the MQTT topic filter matching the pattern
+func (p TopicPattern) Filter() string {
+	levels := make([]string, len(p.levels))
+	copy(levels, p.levels)
+	levels[p.driverLevel] = "+"
+	return strings.Join(levels, "/")
+}
+
+// DriverID returns the driver ID of a topic matching the pattern
+func (p TopicPattern) DriverID(topic string) (string, bool) {
+	levels := strings.Split(topic, "/")
+	if len(levels) != len(p.levels) {
+		return "", false
+	}
+	for i, level := range p.levels {
+		if i != p.driverLevel && level != "+" && level != levels[i] {
+			return "", false
+		}
+	}
+
+	driverID := levels[p.driverLevel]
+	if driverID == "" {
+		return "", false
+	}
+	return driverID, true
+}
+
+// Payload is the message devices publish. DriverID is optional; the topic
+// identifies the driver. Status defaults to "active".
+type Payload struct {
+	DriverID  string   `json:"driver_id,omitempty"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Status    string   `json:"status,omitempty"`
+}
+
+// ParseLocation parses the payload of a message published for a driver
+func ParseLocation(driverID string, data []byte) (domain.DriverLocation, error) {
+	var payload Payload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return domain.DriverLocation{}, ErrInvalidPayload
+	}
+	if payload.Latitude == nil || payload.Longitude == nil {
+		return domain.DriverLocation{}, ErrInvalidPayload
+	}
+	if payload.DriverID != "" && payload.DriverID != driverID {
+		return domain.DriverLocation{}, ErrDriverIDMismatch
+	}
+
+	lat, lon := *payload.Latitude, *payload.Longitude
+	if lat < -90 || lat > 90 {
+		return domain.DriverLocation{}, ErrInvalidLatitude
+	}
+	if lon < -180 || lon > 180 {
+		return domain.DriverLocation{}, ErrInvalidLongitude
+	}
+
+	status := payload.Status
+	if status == "" {
+		status = "active"
+	}
+
+	return domain.DriverLocation{
+		DriverID: driverID,
+		Location: domain.NewPoint(lat, lon),
+		Status:   status,
+	}, nil
+}
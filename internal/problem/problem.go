@@ -0,0 +1,114 @@
+// Package problem writes RFC 7807 problem details.
+//
+// Routes under a prefix registered with Routes answer errors with an
+// application/problem+json body carrying a machine-readable code. Other
+// routes keep the {"error": "..."} body of API v1, so shared middleware can
+// reject a request with Abort without knowing which API version it serves.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MediaType is the media type of problem details
+const MediaType = "application/problem+json"
+
+// TypeBlank is the problem type of problems described by their status and
+// code alone
+const TypeBlank = "about:blank"
+
+// contextKey marks requests answered with problem details
+const contextKey = "problem_details"
+
+// Machine-readable problem codes shared by all routes
+const (
+	CodeInvalidRequest     = "invalid_request"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeConflict           = "conflict"
+	CodeBodyTooLarge       = "body_too_large"
+	CodeRateLimited        = "rate_limited"
+	CodeLocked             = "locked"
+	CodeUnavailable        = "service_unavailable"
+	CodeTimeout            = "timeout"
+	CodeInternal           = "internal_error"
+	CodeIdempotencyReused  = "idempotency_key_reused"
+	CodeIdempotencyPending = "idempotency_key_in_progress"
+)
+
+// Details is an RFC 7807 problem
+type Details struct {
+	// Type is a URI identifying the problem type
+	Type string `json:"type"`
+	// Title is the reason phrase of the status
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	// Detail explains this occurrence of the problem
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request that caused the problem
+	Instance string `json:"instance,omitempty"`
+	// Code identifies the problem for programs, e.g. "rate_limited"
+	Code string `json:"code"`
+}
+
+// New creates a problem with the given status, code and detail
+func New(status int, code, detail string) Details {
+	return Details{
+		Type:   TypeBlank,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Routes marks the requests whose route starts with one of prefixes as
+// answered with problem details. It must run before any middleware that
+// calls Abort.
+func Routes(prefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.FullPath()
+		for _, prefix := range prefixes {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				c.Set(contextKey, true)
+				break
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// Enabled reports whether the request is answered with problem details
+func Enabled(c *gin.Context) bool {
+	return c.GetBool(contextKey)
+}
+
+// Write writes a problem details response
+func Write(c *gin.Context, problem Details) {
+	if problem.Instance == "" && c.Request != nil && c.Request.URL != nil {
+		problem.Instance = c.Request.URL.Path
+	}
+	body, err := json.Marshal(problem)
+	if err != nil {
+		c.Status(problem.Status)
+		return
+	}
+	c.Data(problem.Status, MediaType, body)
+}
+
+// Abort rejects the request. Routes answered with problem details get a
+// problem with code and detail; others get {"error": detail}.
+func Abort(c *gin.Context, status int, code, detail string) {
+	if Enabled(c) {
+		Write(c, New(status, code, detail))
+	} else {
+		c.JSON(status, gin.H{"error": detail})
+	}
+	c.Abort()
+}
@@ -0,0 +1,55 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEngine() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(Routes("/api/v2"))
+	reject := func(c *gin.Context) {
+		Abort(c, http.StatusTooManyRequests, CodeRateLimited, "rate limit exceeded")
+	}
+	engine.GET("/api/v1/drivers", reject)
+	engine.GET("/api/v2/drivers", reject)
+	engine.GET("/api/v20/drivers", reject)
+	return engine
+}
+
+func TestAbortWithProblem(t *testing.T) {
+	w := httptest.NewRecorder()
+	newEngine().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v2/drivers?near=1", nil))
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, MediaType, w.Header().Get("Content-Type"))
+
+	var got Details
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, Details{
+		Type:     TypeBlank,
+		Title:    "Too Many Requests",
+		Status:   http.StatusTooManyRequests,
+		Detail:   "rate limit exceeded",
+		Instance: "/api/v2/drivers",
+		Code:     CodeRateLimited,
+	}, got)
+}
+
+func TestAbortOutsideProblemRoutes(t *testing.T) {
+	for _, path := range []string{"/api/v1/drivers", "/api/v20/drivers"} {
+		w := httptest.NewRecorder()
+		newEngine().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, path)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/json", path)
+		assert.JSONEq(t, `{"error": "rate limit exceeded"}`, w.Body.String(), path)
+	}
+}
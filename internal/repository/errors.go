@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+// Custom errors
+var (
+	// ErrDuplicateEmail is returned when a write would give two users that
+	// are not deleted the same email address
+	ErrDuplicateEmail = fmt.Errorf("%w: email already exists", domain.ErrConflict)
+
+	// ErrDuplicateActiveRide is returned when a ride would give a driver a
+	// second active ride
+	ErrDuplicateActiveRide = fmt.Errorf("%w: driver already has an active ride", domain.ErrConflict)
+)
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/yusufatac/bitaksi-case-study/internal/domain"
 )
@@ -16,11 +17,15 @@ type LocationRepository interface {
 
 	// FindNearbyDrivers finds drivers within a specified radius
 	FindNearbyDrivers(ctx context.Context, lat, lon, radius float64) ([]*domain.DriverLocation, error)
+
+	// CountActiveDrivers counts active drivers whose location was updated at or after since
+	CountActiveDrivers(ctx context.Context, since time.Time) (int64, error)
 }
 
 // UserRepository defines the interface for user operations
 type UserRepository interface {
-	// CreateUser creates a new user
+	// CreateUser creates a new user. It returns ErrDuplicateEmail when
+	// another user has the email address.
 	CreateUser(ctx context.Context, user *domain.User) error
 
 	// GetUserByUsername retrieves a user by username
@@ -28,4 +33,152 @@ type UserRepository interface {
 
 	// UpdateLastLogin updates the last login timestamp
 	UpdateLastLogin(ctx context.Context, userID string) error
+
+	// UpdateStatus updates the status of the user with the given username
+	UpdateStatus(ctx context.Context, username, status string) error
+
+	// GetUserByEmail retrieves a user by email
+	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
+
+	// UpdatePassword replaces the password hash of the user with the given username
+	UpdatePassword(ctx context.Context, username, passwordHash string) error
+
+	// GetUserByID retrieves a user by ID
+	GetUserByID(ctx context.Context, id string) (*domain.User, error)
+
+	// UpdateEmail replaces the email of a user and puts the user back into
+	// pending until the new address is verified. It returns
+	// ErrDuplicateEmail when another user has the address.
+	UpdateEmail(ctx context.Context, userID, email string) error
+
+	// DeleteUser soft deletes a user and frees its email address
+	DeleteUser(ctx context.Context, userID string) error
+
+	// ListUsers returns a page of users matching the filter and the total number of matches
+	ListUsers(ctx context.Context, filter domain.UserFilter) ([]*domain.User, int64, error)
+}
+
+// APIKeyRepository defines the interface for API key operations
+type APIKeyRepository interface {
+	// CreateAPIKey stores a new API key
+	CreateAPIKey(ctx context.Context, key *domain.APIKey) error
+
+	// GetAPIKeyByID retrieves an API key by its ID
+	GetAPIKeyByID(ctx context.Context, id string) (*domain.APIKey, error)
+
+	// GetAPIKeyByPrefix retrieves an API key by its public prefix
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error)
+
+	// ListAPIKeys returns all API keys, newest first
+	ListAPIKeys(ctx context.Context) ([]*domain.APIKey, error)
+
+	// RevokeAPIKey marks an API key as revoked
+	RevokeAPIKey(ctx context.Context, id string, revokedAt time.Time) error
+
+	// UpdateLastUsed updates the last used timestamp
+	UpdateLastUsed(ctx context.Context, id string, usedAt time.Time) error
+}
+
+// LoginAttemptRepository defines the interface for failed login tracking
+type LoginAttemptRepository interface {
+	// GetLoginAttempt retrieves the failed login state for a key
+	GetLoginAttempt(ctx context.Context, key string) (*domain.LoginAttempt, error)
+
+	// BeginLoginAttempt atomically counts a login in progress for a key and
+	// returns the new state
+	BeginLoginAttempt(ctx context.Context, key string, at time.Time) (*domain.LoginAttempt, error)
+
+	// EndLoginAttempt stops counting a login in progress that did not fail
+	EndLoginAttempt(ctx context.Context, key string) error
+
+	// RecordFailedLogin increments the failure count for a key and returns the new state
+	RecordFailedLogin(ctx context.Context, key string, at time.Time) (*domain.LoginAttempt, error)
+
+	// LockLogin locks a key until the given time
+	LockLogin(ctx context.Context, key string, until time.Time) error
+
+	// ResetLoginAttempts clears the failed login state for a key
+	ResetLoginAttempts(ctx context.Context, key string) error
+
+	// ResetExpiredLock clears the failed login state for a key if it is
+	// still locked until the given time. Concurrent logins that find the
+	// same expired lock reset it only once.
+	ResetExpiredLock(ctx context.Context, key string, lockedUntil time.Time) error
+}
+
+// UserTokenRepository tracks which single-use tokens have been redeemed
+type UserTokenRepository interface {
+	// MarkTokenUsed records a token as used. It returns false when the token
+	// was already used. Records may be discarded after expiresAt.
+	MarkTokenUsed(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error)
+}
+
+// IdempotencyRepository defines the interface for idempotency key storage
+type IdempotencyRepository interface {
+	// CreateIdempotencyRecord stores a new record unless an unexpired record
+	// with the same key exists, and reports whether it was stored
+	CreateIdempotencyRecord(ctx context.Context, record *domain.IdempotencyRecord) (bool, error)
+
+	// GetIdempotencyRecord retrieves the unexpired record with the given key
+	GetIdempotencyRecord(ctx context.Context, key string) (*domain.IdempotencyRecord, error)
+
+	// CompleteIdempotencyRecord stores the response and expiry of a record.
+	// Only a pending record with the same key and request hash is completed.
+	CompleteIdempotencyRecord(ctx context.Context, record *domain.IdempotencyRecord) error
+
+	// DeleteIdempotencyRecord removes the record with the given key
+	DeleteIdempotencyRecord(ctx context.Context, key string) error
+}
+
+// RideRepository defines the interface for ride operations
+type RideRepository interface {
+	// CreateRide stores a new ride. It returns ErrDuplicateActiveRide when
+	// the ride is active and its driver already has an active ride.
+	CreateRide(ctx context.Context, ride *domain.Ride) error
+
+	// GetRideByID retrieves a ride by its ID
+	GetRideByID(ctx context.Context, id string) (*domain.Ride, error)
+
+	// GetActiveRideByDriver retrieves the active ride of a driver, if any
+	GetActiveRideByDriver(ctx context.Context, driverID string) (*domain.Ride, error)
+
+	// EndRide moves an active ride to the given status and reports whether
+	// it was still active
+	EndRide(ctx context.Context, id, status string, endedAt time.Time) (bool, error)
+}
+
+// WebhookRepository defines the interface for webhook subscriptions and the
+// log of their deliveries
+type WebhookRepository interface {
+	// CreateSubscription stores a new subscription
+	CreateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) error
+
+	// GetSubscription retrieves a subscription by its ID
+	GetSubscription(ctx context.Context, id string) (*domain.WebhookSubscription, error)
+
+	// ListSubscriptions returns all subscriptions, newest first
+	ListSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error)
+
+	// ListSubscriptionsForEvent returns the active subscriptions to an event
+	ListSubscriptionsForEvent(ctx context.Context, event string) ([]*domain.WebhookSubscription, error)
+
+	// UpdateSubscription saves the URL, events and active flag of a subscription
+	UpdateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) error
+
+	// DeleteSubscription removes a subscription and its deliveries, and
+	// reports whether it existed
+	DeleteSubscription(ctx context.Context, id string) (bool, error)
+
+	// CreateDelivery stores a new delivery
+	CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error
+
+	// UpdateDelivery saves the status and attempts of a delivery
+	UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error
+
+	// ListDeliveries returns the deliveries matching the filter, newest first
+	ListDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter) ([]*domain.WebhookDelivery, error)
+
+	// ListPendingDeliveries returns the deliveries of all subscriptions that
+	// are still pending, oldest first
+	ListPendingDeliveries(ctx context.Context) ([]*domain.WebhookDelivery, error)
 }
@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expiry is a key and the time it expires
+type expiry struct {
+	key string
+	at  time.Time
+}
+
+// expiryQueue orders keys by the time they expire, so that expired entries
+// are dropped without scanning every entry. Keys can be queued more than
+// once when their expiry changes; callers compare the expiry they get back
+// with the current one.
+type expiryQueue []expiry
+
+func (q expiryQueue) Len() int           { return len(q) }
+func (q expiryQueue) Less(i, j int) bool { return q[i].at.Before(q[j].at) }
+func (q expiryQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *expiryQueue) Push(x any) {
+	*q = append(*q, x.(expiry))
+}
+
+func (q *expiryQueue) Pop() any {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
+
+// add queues key to expire at the given time
+func (q *expiryQueue) add(key string, at time.Time) {
+	heap.Push(q, expiry{key: key, at: at})
+}
+
+// expire removes the keys that expire at or before now from the queue and
+// passes each to drop
+func (q *expiryQueue) expire(now time.Time, drop func(key string, at time.Time)) {
+	for q.Len() > 0 && !(*q)[0].at.After(now) {
+		e := heap.Pop(q).(expiry)
+		drop(e.key, e.at)
+	}
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+type idempotencyRepository struct {
+	mu       sync.Mutex
+	records  map[string]domain.IdempotencyRecord
+	expiries expiryQueue
+}
+
+// NewIdempotencyRepository creates a new in-memory idempotency key repository
+func NewIdempotencyRepository() repository.IdempotencyRepository {
+	return &idempotencyRepository{
+		records: make(map[string]domain.IdempotencyRecord),
+	}
+}
+
+func (r *idempotencyRepository) CreateIdempotencyRecord(ctx context.Context, record *domain.IdempotencyRecord) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prune(time.Now())
+
+	if _, ok := r.records[record.Key]; ok {
+		return false, nil
+	}
+	r.store(record)
+	return true, nil
+}
+
+func (r *idempotencyRepository) GetIdempotencyRecord(ctx context.Context, key string) (*domain.IdempotencyRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.records[key]
+	if !ok || record.IsExpired(time.Now()) {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+// CompleteIdempotencyRecord only completes the pending record of the same
+// request, so a request whose record expired cannot overwrite the record of
+// another request that took its key
+func (r *idempotencyRepository) CompleteIdempotencyRecord(ctx context.Context, record *domain.IdempotencyRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.records[record.Key]
+	if !ok || existing.IsCompleted() || existing.RequestHash != record.RequestHash {
+		return nil
+	}
+	r.store(record)
+	return nil
+}
+
+func (r *idempotencyRepository) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.records, key)
+	return nil
+}
+
+// store saves a record and queues it to expire
+func (r *idempotencyRepository) store(record *domain.IdempotencyRecord) {
+	r.records[record.Key] = *record
+	r.expiries.add(record.Key, record.ExpiresAt)
+}
+
+// prune drops the records that have expired
+func (r *idempotencyRepository) prune(now time.Time) {
+	r.expiries.expire(now, func(key string, at time.Time) {
+		if record, ok := r.records[key]; ok && record.ExpiresAt.Equal(at) {
+			delete(r.records, key)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+func TestIdempotencyRepositoryPrunesExpiredRecords(t *testing.T) {
+	repo := NewIdempotencyRepository().(*idempotencyRepository)
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, key := range []string{"a", "b"} {
+		created, err := repo.CreateIdempotencyRecord(ctx, &domain.IdempotencyRecord{Key: key, ExpiresAt: now.Add(-time.Second)})
+		require.NoError(t, err)
+		require.True(t, created)
+	}
+	created, err := repo.CreateIdempotencyRecord(ctx, &domain.IdempotencyRecord{Key: "c", ExpiresAt: now.Add(time.Minute)})
+	require.NoError(t, err)
+	require.True(t, created)
+
+	assert.Len(t, repo.records, 1, "expired records are dropped on the next write")
+	assert.Len(t, repo.expiries, 1)
+}
+
+func TestIdempotencyRepositoryCompletesOnlyItsRecord(t *testing.T) {
+	repo := NewIdempotencyRepository()
+	ctx := context.Background()
+	now := time.Now()
+
+	pending := &domain.IdempotencyRecord{Key: "k", RequestHash: "second", ExpiresAt: now.Add(time.Minute)}
+	_, err := repo.CreateIdempotencyRecord(ctx, pending)
+	require.NoError(t, err)
+
+	// A request whose record expired and was taken over by another request
+	stale := &domain.IdempotencyRecord{Key: "k", RequestHash: "first", Status: 201, ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, repo.CompleteIdempotencyRecord(ctx, stale))
+
+	found, err := repo.GetIdempotencyRecord(ctx, "k")
+	require.NoError(t, err)
+	assert.False(t, found.IsCompleted())
+
+	completed := *pending
+	completed.Status = 201
+	completed.ExpiresAt = now.Add(time.Hour)
+	require.NoError(t, repo.CompleteIdempotencyRecord(ctx, &completed))
+
+	found, err = repo.GetIdempotencyRecord(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, 201, found.Status)
+
+	// Completed records are not completed again
+	again := completed
+	again.Status = 200
+	require.NoError(t, repo.CompleteIdempotencyRecord(ctx, &again))
+	found, err = repo.GetIdempotencyRecord(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, 201, found.Status)
+}
+
+func TestUserTokenRepositoryPrunesExpiredTokens(t *testing.T) {
+	repo := NewUserTokenRepository().(*userTokenRepository)
+	ctx := context.Background()
+	now := time.Now()
+
+	fresh, err := repo.MarkTokenUsed(ctx, "old", now.Add(-time.Second))
+	require.NoError(t, err)
+	assert.True(t, fresh)
+
+	fresh, err = repo.MarkTokenUsed(ctx, "new", now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, fresh)
+	assert.Len(t, repo.used, 1)
+
+	fresh, err = repo.MarkTokenUsed(ctx, "new", now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.False(t, fresh, "tokens are used once")
+}
@@ -0,0 +1,131 @@
+// Package memory provides in-process repository implementations for
+// single-instance deployments and local development
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+// Failed login state is dropped once it is older than loginAttemptTTL.
+// Expired entries are swept every pruneInterval writes.
+const (
+	loginAttemptTTL = 24 * time.Hour
+	pruneInterval   = 1024
+)
+
+type loginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]domain.LoginAttempt
+	writes   int
+}
+
+// NewLoginAttemptRepository creates a new in-memory login attempt repository
+func NewLoginAttemptRepository() repository.LoginAttemptRepository {
+	return &loginAttemptRepository{
+		attempts: make(map[string]domain.LoginAttempt),
+	}
+}
+
+func (r *loginAttemptRepository) GetLoginAttempt(ctx context.Context, key string) (*domain.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+	return &attempt, nil
+}
+
+func (r *loginAttemptRepository) BeginLoginAttempt(ctx context.Context, key string, at time.Time) (*domain.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.countWrite(at)
+
+	attempt, ok := r.attempts[key]
+	if !ok {
+		// New keys expire like keys with failures
+		attempt = domain.LoginAttempt{Key: key, LastFailureAt: at}
+	}
+	attempt.Attempts++
+	r.attempts[key] = attempt
+
+	return &attempt, nil
+}
+
+func (r *loginAttemptRepository) EndLoginAttempt(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempt, ok := r.attempts[key]; ok && attempt.Attempts > 0 {
+		attempt.Attempts--
+		r.attempts[key] = attempt
+	}
+	return nil
+}
+
+func (r *loginAttemptRepository) RecordFailedLogin(ctx context.Context, key string, at time.Time) (*domain.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.countWrite(at)
+
+	attempt := r.attempts[key]
+	attempt.Key = key
+	attempt.Failures++
+	attempt.LastFailureAt = at
+	r.attempts[key] = attempt
+
+	return &attempt, nil
+}
+
+func (r *loginAttemptRepository) LockLogin(ctx context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempt, ok := r.attempts[key]; ok {
+		attempt.LockedUntil = until
+		r.attempts[key] = attempt
+	}
+	return nil
+}
+
+func (r *loginAttemptRepository) ResetLoginAttempts(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
+
+func (r *loginAttemptRepository) ResetExpiredLock(ctx context.Context, key string, lockedUntil time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempt, ok := r.attempts[key]; ok && attempt.LockedUntil.Equal(lockedUntil) {
+		delete(r.attempts, key)
+	}
+	return nil
+}
+
+// countWrite sweeps expired entries every pruneInterval writes
+func (r *loginAttemptRepository) countWrite(now time.Time) {
+	r.writes++
+	if r.writes%pruneInterval == 0 {
+		r.prune(now)
+	}
+}
+
+func (r *loginAttemptRepository) prune(now time.Time) {
+	for key, attempt := range r.attempts {
+		if now.Sub(attempt.LastFailureAt) > loginAttemptTTL && !attempt.IsLocked(now) {
+			delete(r.attempts, key)
+		}
+	}
+}
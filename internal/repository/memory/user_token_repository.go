@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+type userTokenRepository struct {
+	mu       sync.Mutex
+	used     map[string]time.Time
+	expiries expiryQueue
+}
+
+// NewUserTokenRepository creates a new in-memory repository for used single-use tokens
+func NewUserTokenRepository() repository.UserTokenRepository {
+	return &userTokenRepository{
+		used: make(map[string]time.Time),
+	}
+}
+
+func (r *userTokenRepository) MarkTokenUsed(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.expiries.expire(time.Now(), func(id string, at time.Time) {
+		if r.used[id].Equal(at) {
+			delete(r.used, id)
+		}
+	})
+
+	if _, ok := r.used[tokenID]; ok {
+		return false, nil
+	}
+	r.used[tokenID] = expiresAt
+	r.expiries.add(tokenID, expiresAt)
+	return true, nil
+}
@@ -0,0 +1,188 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+// maxWebhookDeliveries is how many deliveries are kept per subscription.
+// The oldest are dropped first.
+const maxWebhookDeliveries = 1000
+
+type webhookRepository struct {
+	mu            sync.Mutex
+	subscriptions map[string]domain.WebhookSubscription
+	deliveries    map[string]domain.WebhookDelivery
+	// log holds the delivery IDs of each subscription, oldest first
+	log map[string][]string
+}
+
+// NewWebhookRepository creates a new in-memory webhook repository
+func NewWebhookRepository() repository.WebhookRepository {
+	return &webhookRepository{
+		subscriptions: make(map[string]domain.WebhookSubscription),
+		deliveries:    make(map[string]domain.WebhookDelivery),
+		log:           make(map[string][]string),
+	}
+}
+
+func (r *webhookRepository) CreateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscriptions[subscription.ID] = copySubscription(subscription)
+	return nil
+}
+
+func (r *webhookRepository) GetSubscription(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	subscription, ok := r.subscriptions[id]
+	if !ok {
+		return nil, nil
+	}
+	found := copySubscription(&subscription)
+	return &found, nil
+}
+
+func (r *webhookRepository) ListSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	return r.findSubscriptions(func(*domain.WebhookSubscription) bool { return true }), nil
+}
+
+func (r *webhookRepository) ListSubscriptionsForEvent(ctx context.Context, event string) ([]*domain.WebhookSubscription, error) {
+	return r.findSubscriptions(func(s *domain.WebhookSubscription) bool { return s.Wants(event) }), nil
+}
+
+func (r *webhookRepository) findSubscriptions(match func(*domain.WebhookSubscription) bool) []*domain.WebhookSubscription {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	subscriptions := []*domain.WebhookSubscription{}
+	for _, subscription := range r.subscriptions {
+		if match(&subscription) {
+			found := copySubscription(&subscription)
+			subscriptions = append(subscriptions, &found)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].CreatedAt.After(subscriptions[j].CreatedAt)
+	})
+	return subscriptions
+}
+
+func (r *webhookRepository) UpdateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.subscriptions[subscription.ID]
+	if !ok {
+		return nil
+	}
+	existing.URL = subscription.URL
+	existing.Events = append([]string(nil), subscription.Events...)
+	existing.Active = subscription.Active
+	existing.UpdatedAt = subscription.UpdatedAt
+	r.subscriptions[subscription.ID] = existing
+	return nil
+}
+
+func (r *webhookRepository) DeleteSubscription(ctx context.Context, id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.subscriptions[id]; !ok {
+		return false, nil
+	}
+	delete(r.subscriptions, id)
+	for _, deliveryID := range r.log[id] {
+		delete(r.deliveries, deliveryID)
+	}
+	delete(r.log, id)
+	return true, nil
+}
+
+func (r *webhookRepository) CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deliveries[delivery.ID] = copyDelivery(delivery)
+
+	log := append(r.log[delivery.SubscriptionID], delivery.ID)
+	if len(log) > maxWebhookDeliveries {
+		for _, deliveryID := range log[:len(log)-maxWebhookDeliveries] {
+			delete(r.deliveries, deliveryID)
+		}
+		log = append([]string(nil), log[len(log)-maxWebhookDeliveries:]...)
+	}
+	r.log[delivery.SubscriptionID] = log
+	return nil
+}
+
+func (r *webhookRepository) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.deliveries[delivery.ID]; ok {
+		r.deliveries[delivery.ID] = copyDelivery(delivery)
+	}
+	return nil
+}
+
+func (r *webhookRepository) ListDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter) ([]*domain.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deliveries := []*domain.WebhookDelivery{}
+	log := r.log[filter.SubscriptionID]
+	for i := len(log) - 1; i >= 0; i-- {
+		delivery := r.deliveries[log[i]]
+		if filter.Status != "" && delivery.Status != filter.Status {
+			continue
+		}
+		found := copyDelivery(&delivery)
+		deliveries = append(deliveries, &found)
+		if filter.Limit > 0 && len(deliveries) == filter.Limit {
+			break
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *webhookRepository) ListPendingDeliveries(ctx context.Context) ([]*domain.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deliveries := []*domain.WebhookDelivery{}
+	for _, delivery := range r.deliveries {
+		if delivery.Status == domain.WebhookDeliveryPending {
+			found := copyDelivery(&delivery)
+			deliveries = append(deliveries, &found)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.Before(deliveries[j].CreatedAt)
+	})
+	return deliveries, nil
+}
+
+// copySubscription copies a subscription so callers cannot change the
+// stored one through its slice
+func copySubscription(subscription *domain.WebhookSubscription) domain.WebhookSubscription {
+	c := *subscription
+	c.Events = append([]string(nil), subscription.Events...)
+	return c
+}
+
+// copyDelivery copies a delivery so callers cannot change the stored one
+// through its slices
+func copyDelivery(delivery *domain.WebhookDelivery) domain.WebhookDelivery {
+	c := *delivery
+	c.Payload = append([]byte(nil), delivery.Payload...)
+	c.Attempts = append([]domain.WebhookAttempt(nil), delivery.Attempts...)
+	return c
+}
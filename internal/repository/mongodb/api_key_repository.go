@@ -0,0 +1,117 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+type apiKeyRepository struct {
+	collection *mongo.Collection
+}
+
+// NewAPIKeyRepository creates a new MongoDB API key repository
+func NewAPIKeyRepository(db *mongo.Database) repository.APIKeyRepository {
+	collection := db.Collection("api_keys")
+
+	// Create unique index for prefix lookups
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "prefix", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, indexModel)
+	if err != nil {
+		panic(err) // In production, handle this error appropriately
+	}
+
+	return &apiKeyRepository{
+		collection: collection,
+	}
+}
+
+func (r *apiKeyRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	_, err := r.collection.InsertOne(ctx, newAPIKeyDocument(key))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateAPIKey
+	}
+	return err
+}
+
+func (r *apiKeyRepository) GetAPIKeyByID(ctx context.Context, id string) (*domain.APIKey, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *apiKeyRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	return r.findOne(ctx, bson.M{"prefix": prefix})
+}
+
+func (r *apiKeyRepository) ListAPIKeys(ctx context.Context) ([]*domain.APIKey, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []apiKeyDocument
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	keys := make([]*domain.APIKey, len(docs))
+	for i := range docs {
+		keys[i] = docs[i].toDomain()
+	}
+
+	return keys, nil
+}
+
+func (r *apiKeyRepository) RevokeAPIKey(ctx context.Context, id string, revokedAt time.Time) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{
+		"$set": bson.M{
+			"revoked_at": revokedAt,
+		},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *apiKeyRepository) UpdateLastUsed(ctx context.Context, id string, usedAt time.Time) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{
+		"$set": bson.M{
+			"last_used_at": usedAt,
+		},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *apiKeyRepository) findOne(ctx context.Context, filter bson.M) (*domain.APIKey, error) {
+	var doc apiKeyDocument
+	err := r.collection.FindOne(ctx, filter).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return doc.toDomain(), nil
+}
@@ -0,0 +1,115 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+type idempotencyRepository struct {
+	collection *mongo.Collection
+}
+
+// NewIdempotencyRepository creates a new MongoDB idempotency key repository
+func NewIdempotencyRepository(db *mongo.Database) repository.IdempotencyRepository {
+	collection := db.Collection("idempotency_keys")
+
+	// Remove records once they expire
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "expires_at", Value: 1},
+		},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, indexModel)
+	if err != nil {
+		panic(err) // In production, handle this error appropriately
+	}
+
+	return &idempotencyRepository{
+		collection: collection,
+	}
+}
+
+// CreateIdempotencyRecord inserts the record. The TTL monitor only runs
+// about once a minute, so an expired record with the same key may still be
+// there; it is replaced.
+func (r *idempotencyRepository) CreateIdempotencyRecord(ctx context.Context, record *domain.IdempotencyRecord) (bool, error) {
+	doc := newIdempotencyDocument(record)
+
+	_, err := r.collection.InsertOne(ctx, doc)
+	if err == nil {
+		return true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return false, err
+	}
+
+	filter := bson.M{
+		"_id":        doc.Key,
+		"expires_at": bson.M{"$lte": time.Now()},
+	}
+	result, err := r.collection.ReplaceOne(ctx, filter, doc)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+func (r *idempotencyRepository) GetIdempotencyRecord(ctx context.Context, key string) (*domain.IdempotencyRecord, error) {
+	filter := bson.M{
+		"_id":        key,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+
+	var doc idempotencyDocument
+	err := r.collection.FindOne(ctx, filter).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return doc.toDomain(), nil
+}
+
+// CompleteIdempotencyRecord only completes the pending record of the same
+// request, so a request whose record expired cannot overwrite the record of
+// another request that took its key
+func (r *idempotencyRepository) CompleteIdempotencyRecord(ctx context.Context, record *domain.IdempotencyRecord) error {
+	doc := newIdempotencyDocument(record)
+
+	update := bson.M{
+		"$set": bson.M{
+			"status":     doc.Status,
+			"header":     doc.Header,
+			"body":       doc.Body,
+			"expires_at": doc.ExpiresAt,
+		},
+	}
+
+	filter := bson.M{
+		"_id":          doc.Key,
+		"request_hash": doc.RequestHash,
+		"status":       0,
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *idempotencyRepository) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
@@ -5,20 +5,25 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/yusufatac/bitaksi-case-study/internal/domain"
 	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+	"github.com/yusufatac/bitaksi-case-study/internal/tracing"
 )
 
+// locationCollection holds the latest location of every driver
+const locationCollection = "driver_locations"
+
 type locationRepository struct {
 	collection *mongo.Collection
 }
 
 // NewLocationRepository creates a new MongoDB location repository
 func NewLocationRepository(db *mongo.Database) repository.LocationRepository {
-	collection := db.Collection("driver_locations")
+	collection := db.Collection(locationCollection)
 
 	// Create geospatial index
 	indexModel := mongo.IndexModel{
@@ -40,31 +45,58 @@ func NewLocationRepository(db *mongo.Database) repository.LocationRepository {
 	}
 }
 
-func (r *locationRepository) SaveLocation(ctx context.Context, location *domain.DriverLocation) error {
-	opts := options.Update().SetUpsert(true)
-	filter := bson.M{"driver_id": location.DriverID}
+func (r *locationRepository) SaveLocation(ctx context.Context, location *domain.DriverLocation) (err error) {
+	ctx, span := tracing.StartMongo(ctx, locationCollection, "SaveLocation")
+	defer func() { tracing.End(span, err) }()
+
+	doc, err := newDriverLocationDocument(location)
+	if err != nil {
+		return err
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"_id": 1})
+	filter := bson.M{"driver_id": doc.DriverID}
 	update := bson.M{
 		"$set": bson.M{
-			"location":  location.Location,
-			"status":    location.Status,
-			"timestamp": location.Timestamp,
+			"location":  doc.Location,
+			"status":    doc.Status,
+			"timestamp": doc.Timestamp,
 		},
 	}
 
-	_, err := r.collection.UpdateOne(ctx, filter, update, opts)
-	return err
+	var saved driverLocationDocument
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&saved); err != nil {
+		return err
+	}
+
+	location.ID = hexFromObjectID(saved.ID)
+	return nil
 }
 
-func (r *locationRepository) SaveLocations(ctx context.Context, locations []*domain.DriverLocation) error {
+// SaveLocations upserts the locations in one unordered bulk write. IDs are
+// written back for drivers inserted by this call; existing drivers keep
+// their ID, which is not returned by a bulk update.
+func (r *locationRepository) SaveLocations(ctx context.Context, locations []*domain.DriverLocation) (err error) {
+	ctx, span := tracing.StartMongo(ctx, locationCollection, "SaveLocations")
+	defer func() { tracing.End(span, err) }()
+
 	operations := make([]mongo.WriteModel, len(locations))
 
 	for i, loc := range locations {
-		filter := bson.M{"driver_id": loc.DriverID}
+		doc, err := newDriverLocationDocument(loc)
+		if err != nil {
+			return err
+		}
+
+		filter := bson.M{"driver_id": doc.DriverID}
 		update := bson.M{
 			"$set": bson.M{
-				"location":  loc.Location,
-				"status":    loc.Status,
-				"timestamp": loc.Timestamp,
+				"location":  doc.Location,
+				"status":    doc.Status,
+				"timestamp": doc.Timestamp,
 			},
 		}
 		operations[i] = mongo.NewUpdateOneModel().
@@ -74,11 +106,24 @@ func (r *locationRepository) SaveLocations(ctx context.Context, locations []*dom
 	}
 
 	opts := options.BulkWrite().SetOrdered(false)
-	_, err := r.collection.BulkWrite(ctx, operations, opts)
-	return err
+	result, err := r.collection.BulkWrite(ctx, operations, opts)
+	if err != nil {
+		return err
+	}
+
+	for i, id := range result.UpsertedIDs {
+		if objectID, ok := id.(primitive.ObjectID); ok {
+			locations[i].ID = hexFromObjectID(objectID)
+		}
+	}
+
+	return nil
 }
 
-func (r *locationRepository) FindNearbyDrivers(ctx context.Context, lat, lon, radius float64) ([]*domain.DriverLocation, error) {
+func (r *locationRepository) FindNearbyDrivers(ctx context.Context, lat, lon, radius float64) (locations []*domain.DriverLocation, err error) {
+	ctx, span := tracing.StartMongo(ctx, locationCollection, "FindNearbyDrivers")
+	defer func() { tracing.End(span, err) }()
+
 	filter := bson.M{
 		"location": bson.M{
 			"$near": bson.M{
@@ -102,10 +147,24 @@ func (r *locationRepository) FindNearbyDrivers(ctx context.Context, lat, lon, ra
 	}
 	defer cursor.Close(ctx)
 
-	var locations []*domain.DriverLocation
-	if err = cursor.All(ctx, &locations); err != nil {
+	var docs []driverLocationDocument
+	if err = cursor.All(ctx, &docs); err != nil {
 		return nil, err
 	}
 
+	locations = make([]*domain.DriverLocation, len(docs))
+	for i := range docs {
+		locations[i] = docs[i].toDomain()
+	}
+
 	return locations, nil
 }
+
+func (r *locationRepository) CountActiveDrivers(ctx context.Context, since time.Time) (int64, error) {
+	filter := bson.M{
+		"status":    "active",
+		"timestamp": bson.M{"$gte": since},
+	}
+
+	return r.collection.CountDocuments(ctx, filter)
+}
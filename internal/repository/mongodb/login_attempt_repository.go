@@ -0,0 +1,127 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+// loginAttemptTTL is how long failed login state is kept after the last failure
+const loginAttemptTTL = 24 * time.Hour
+
+type loginAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+// NewLoginAttemptRepository creates a new MongoDB login attempt repository
+func NewLoginAttemptRepository(db *mongo.Database) repository.LoginAttemptRepository {
+	collection := db.Collection("login_attempts")
+
+	// Expire stale attempts so the collection does not grow forever
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "last_failure_at", Value: 1},
+		},
+		Options: options.Index().SetExpireAfterSeconds(int32(loginAttemptTTL.Seconds())),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, indexModel)
+	if err != nil {
+		panic(err) // In production, handle this error appropriately
+	}
+
+	return &loginAttemptRepository{
+		collection: collection,
+	}
+}
+
+func (r *loginAttemptRepository) GetLoginAttempt(ctx context.Context, key string) (*domain.LoginAttempt, error) {
+	var doc loginAttemptDocument
+	err := r.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return doc.toDomain(), nil
+}
+
+func (r *loginAttemptRepository) BeginLoginAttempt(ctx context.Context, key string, at time.Time) (*domain.LoginAttempt, error) {
+	filter := bson.M{"_id": key}
+	update := bson.M{
+		"$inc": bson.M{"attempts": 1},
+		// New keys expire like keys with failures
+		"$setOnInsert": bson.M{"last_failure_at": at},
+	}
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	var doc loginAttemptDocument
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	return doc.toDomain(), nil
+}
+
+func (r *loginAttemptRepository) EndLoginAttempt(ctx context.Context, key string) error {
+	filter := bson.M{"_id": key, "attempts": bson.M{"$gt": 0}}
+	update := bson.M{
+		"$inc": bson.M{"attempts": -1},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *loginAttemptRepository) RecordFailedLogin(ctx context.Context, key string, at time.Time) (*domain.LoginAttempt, error) {
+	filter := bson.M{"_id": key}
+	update := bson.M{
+		"$inc": bson.M{"failures": 1},
+		"$set": bson.M{"last_failure_at": at},
+	}
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	var doc loginAttemptDocument
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	return doc.toDomain(), nil
+}
+
+func (r *loginAttemptRepository) LockLogin(ctx context.Context, key string, until time.Time) error {
+	filter := bson.M{"_id": key}
+	update := bson.M{
+		"$set": bson.M{
+			"locked_until": until,
+		},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *loginAttemptRepository) ResetLoginAttempts(ctx context.Context, key string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+func (r *loginAttemptRepository) ResetExpiredLock(ctx context.Context, key string, lockedUntil time.Time) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key, "locked_until": lockedUntil})
+	return err
+}
@@ -0,0 +1,368 @@
+package mongodb
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+// The domain types carry no persistence details. Every collection has a
+// document type below that is mapped to and from the domain type, and the
+// ID strategy of each collection lives here:
+//
+//   - users, driver_locations: _id is an ObjectID generated by the
+//     repository on insert. The domain ID is its hex string, and a domain ID
+//     that is not valid hex never matches a document.
+//   - api_keys, rides, webhook_subscriptions, webhook_deliveries: _id is the
+//     UUID string generated by the service or dispatcher.
+//   - login_attempts, used_tokens: _id is the natural key (e.g. "user:alice"
+//     or a token ID), so lookups never need a secondary index.
+
+// objectIDFromHex converts a domain ID to an ObjectID
+func objectIDFromHex(id string) (primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, ErrInvalidID
+	}
+	return objectID, nil
+}
+
+// hexFromObjectID converts an ObjectID to a domain ID
+func hexFromObjectID(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.Hex()
+}
+
+type userDocument struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Username    string             `bson:"username"`
+	Password    string             `bson:"password"`
+	Email       string             `bson:"email,omitempty"`
+	Status      string             `bson:"status"`
+	Role        string             `bson:"role"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	LastLoginAt time.Time          `bson:"last_login_at"`
+	// DeletedEmail holds the email of a deleted user, which is kept out of
+	// the unique email index
+	DeletedEmail string `bson:"deleted_email,omitempty"`
+}
+
+func newUserDocument(user *domain.User) (*userDocument, error) {
+	doc := &userDocument{
+		Username:    user.Username,
+		Password:    user.Password,
+		Email:       user.Email,
+		Status:      user.Status,
+		Role:        user.Role,
+		CreatedAt:   user.CreatedAt,
+		LastLoginAt: user.LastLoginAt,
+	}
+
+	if user.ID != "" {
+		id, err := objectIDFromHex(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		doc.ID = id
+	}
+
+	return doc, nil
+}
+
+func (d *userDocument) toDomain() *domain.User {
+	email := d.Email
+	if email == "" {
+		email = d.DeletedEmail
+	}
+
+	return &domain.User{
+		ID:          hexFromObjectID(d.ID),
+		Username:    d.Username,
+		Password:    d.Password,
+		Email:       email,
+		Status:      d.Status,
+		Role:        d.Role,
+		CreatedAt:   d.CreatedAt,
+		LastLoginAt: d.LastLoginAt,
+	}
+}
+
+type pointDocument struct {
+	Type        string    `bson:"type"`
+	Coordinates []float64 `bson:"coordinates"`
+}
+
+func newPointDocument(p domain.Point) pointDocument {
+	return pointDocument{
+		Type:        p.Type,
+		Coordinates: p.Coordinates,
+	}
+}
+
+func (d pointDocument) toDomain() domain.Point {
+	return domain.Point{
+		Type:        d.Type,
+		Coordinates: d.Coordinates,
+	}
+}
+
+type driverLocationDocument struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	DriverID  string             `bson:"driver_id"`
+	Location  pointDocument      `bson:"location"`
+	Status    string             `bson:"status"`
+	Timestamp time.Time          `bson:"timestamp"`
+}
+
+func newDriverLocationDocument(location *domain.DriverLocation) (*driverLocationDocument, error) {
+	doc := &driverLocationDocument{
+		DriverID:  location.DriverID,
+		Location:  newPointDocument(location.Location),
+		Status:    location.Status,
+		Timestamp: location.Timestamp,
+	}
+
+	if location.ID != "" {
+		id, err := objectIDFromHex(location.ID)
+		if err != nil {
+			return nil, err
+		}
+		doc.ID = id
+	}
+
+	return doc, nil
+}
+
+func (d *driverLocationDocument) toDomain() *domain.DriverLocation {
+	return &domain.DriverLocation{
+		ID:        hexFromObjectID(d.ID),
+		DriverID:  d.DriverID,
+		Location:  d.Location.toDomain(),
+		Status:    d.Status,
+		Timestamp: d.Timestamp,
+	}
+}
+
+type apiKeyDocument struct {
+	ID         string     `bson:"_id"`
+	Name       string     `bson:"name"`
+	Prefix     string     `bson:"prefix"`
+	Hash       string     `bson:"hash"`
+	Scopes     []string   `bson:"scopes"`
+	CreatedAt  time.Time  `bson:"created_at"`
+	LastUsedAt *time.Time `bson:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `bson:"revoked_at,omitempty"`
+}
+
+func newAPIKeyDocument(key *domain.APIKey) *apiKeyDocument {
+	return &apiKeyDocument{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Hash:       key.Hash,
+		Scopes:     key.Scopes,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+	}
+}
+
+func (d *apiKeyDocument) toDomain() *domain.APIKey {
+	return &domain.APIKey{
+		ID:         d.ID,
+		Name:       d.Name,
+		Prefix:     d.Prefix,
+		Hash:       d.Hash,
+		Scopes:     d.Scopes,
+		CreatedAt:  d.CreatedAt,
+		LastUsedAt: d.LastUsedAt,
+		RevokedAt:  d.RevokedAt,
+	}
+}
+
+type loginAttemptDocument struct {
+	Key           string    `bson:"_id"`
+	Failures      int       `bson:"failures"`
+	Attempts      int       `bson:"attempts"`
+	LastFailureAt time.Time `bson:"last_failure_at"`
+	LockedUntil   time.Time `bson:"locked_until"`
+}
+
+func (d *loginAttemptDocument) toDomain() *domain.LoginAttempt {
+	return &domain.LoginAttempt{
+		Key:           d.Key,
+		Failures:      d.Failures,
+		Attempts:      d.Attempts,
+		LastFailureAt: d.LastFailureAt,
+		LockedUntil:   d.LockedUntil,
+	}
+}
+
+type idempotencyDocument struct {
+	Key         string              `bson:"_id"`
+	RequestHash string              `bson:"request_hash"`
+	Status      int                 `bson:"status"`
+	Header      map[string][]string `bson:"header,omitempty"`
+	Body        []byte              `bson:"body,omitempty"`
+	CreatedAt   time.Time           `bson:"created_at"`
+	ExpiresAt   time.Time           `bson:"expires_at"`
+}
+
+func newIdempotencyDocument(record *domain.IdempotencyRecord) *idempotencyDocument {
+	return &idempotencyDocument{
+		Key:         record.Key,
+		RequestHash: record.RequestHash,
+		Status:      record.Status,
+		Header:      record.Header,
+		Body:        record.Body,
+		CreatedAt:   record.CreatedAt,
+		ExpiresAt:   record.ExpiresAt,
+	}
+}
+
+func (d *idempotencyDocument) toDomain() *domain.IdempotencyRecord {
+	return &domain.IdempotencyRecord{
+		Key:         d.Key,
+		RequestHash: d.RequestHash,
+		Status:      d.Status,
+		Header:      d.Header,
+		Body:        d.Body,
+		CreatedAt:   d.CreatedAt,
+		ExpiresAt:   d.ExpiresAt,
+	}
+}
+
+type rideDocument struct {
+	ID        string        `bson:"_id"`
+	RiderID   string        `bson:"rider_id"`
+	DriverID  string        `bson:"driver_id"`
+	Pickup    pointDocument `bson:"pickup"`
+	Status    string        `bson:"status"`
+	ExpiresAt time.Time     `bson:"expires_at"`
+	CreatedAt time.Time     `bson:"created_at"`
+	EndedAt   *time.Time    `bson:"ended_at,omitempty"`
+}
+
+func newRideDocument(ride *domain.Ride) *rideDocument {
+	return &rideDocument{
+		ID:        ride.ID,
+		RiderID:   ride.RiderID,
+		DriverID:  ride.DriverID,
+		Pickup:    newPointDocument(ride.Pickup),
+		Status:    ride.Status,
+		ExpiresAt: ride.ExpiresAt,
+		CreatedAt: ride.CreatedAt,
+		EndedAt:   ride.EndedAt,
+	}
+}
+
+func (d *rideDocument) toDomain() *domain.Ride {
+	return &domain.Ride{
+		ID:        d.ID,
+		RiderID:   d.RiderID,
+		DriverID:  d.DriverID,
+		Pickup:    d.Pickup.toDomain(),
+		Status:    d.Status,
+		ExpiresAt: d.ExpiresAt,
+		CreatedAt: d.CreatedAt,
+		EndedAt:   d.EndedAt,
+	}
+}
+
+type webhookSubscriptionDocument struct {
+	ID        string    `bson:"_id"`
+	URL       string    `bson:"url"`
+	Events    []string  `bson:"events"`
+	Secret    string    `bson:"secret"`
+	Active    bool      `bson:"active"`
+	CreatedAt time.Time `bson:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+func newWebhookSubscriptionDocument(subscription *domain.WebhookSubscription) *webhookSubscriptionDocument {
+	return &webhookSubscriptionDocument{
+		ID:        subscription.ID,
+		URL:       subscription.URL,
+		Events:    subscription.Events,
+		Secret:    subscription.Secret,
+		Active:    subscription.Active,
+		CreatedAt: subscription.CreatedAt,
+		UpdatedAt: subscription.UpdatedAt,
+	}
+}
+
+func (d *webhookSubscriptionDocument) toDomain() *domain.WebhookSubscription {
+	return &domain.WebhookSubscription{
+		ID:        d.ID,
+		URL:       d.URL,
+		Events:    d.Events,
+		Secret:    d.Secret,
+		Active:    d.Active,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+	}
+}
+
+type webhookAttemptDocument struct {
+	At         time.Time `bson:"at"`
+	StatusCode int       `bson:"status_code,omitempty"`
+	Error      string    `bson:"error,omitempty"`
+	DurationMs int64     `bson:"duration_ms"`
+}
+
+type webhookDeliveryDocument struct {
+	ID             string                   `bson:"_id"`
+	SubscriptionID string                   `bson:"subscription_id"`
+	Event          string                   `bson:"event"`
+	Payload        []byte                   `bson:"payload"`
+	Status         string                   `bson:"status"`
+	Attempts       []webhookAttemptDocument `bson:"attempts"`
+	NextAttemptAt  *time.Time               `bson:"next_attempt_at,omitempty"`
+	Error          string                   `bson:"error,omitempty"`
+	CreatedAt      time.Time                `bson:"created_at"`
+	CompletedAt    *time.Time               `bson:"completed_at,omitempty"`
+}
+
+func newWebhookDeliveryDocument(delivery *domain.WebhookDelivery) *webhookDeliveryDocument {
+	attempts := make([]webhookAttemptDocument, len(delivery.Attempts))
+	for i, attempt := range delivery.Attempts {
+		attempts[i] = webhookAttemptDocument(attempt)
+	}
+
+	return &webhookDeliveryDocument{
+		ID:             delivery.ID,
+		SubscriptionID: delivery.SubscriptionID,
+		Event:          delivery.Event,
+		Payload:        delivery.Payload,
+		Status:         delivery.Status,
+		Attempts:       attempts,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		Error:          delivery.Error,
+		CreatedAt:      delivery.CreatedAt,
+		CompletedAt:    delivery.CompletedAt,
+	}
+}
+
+func (d *webhookDeliveryDocument) toDomain() *domain.WebhookDelivery {
+	attempts := make([]domain.WebhookAttempt, len(d.Attempts))
+	for i, attempt := range d.Attempts {
+		attempts[i] = domain.WebhookAttempt(attempt)
+	}
+
+	return &domain.WebhookDelivery{
+		ID:             d.ID,
+		SubscriptionID: d.SubscriptionID,
+		Event:          d.Event,
+		Payload:        d.Payload,
+		Status:         d.Status,
+		Attempts:       attempts,
+		NextAttemptAt:  d.NextAttemptAt,
+		Error:          d.Error,
+		CreatedAt:      d.CreatedAt,
+		CompletedAt:    d.CompletedAt,
+	}
+}
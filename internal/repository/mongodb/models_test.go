@@ -0,0 +1,161 @@
+package mongodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+func TestUserDocumentRoundTrip(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	user := &domain.User{
+		ID:          primitive.NewObjectID().Hex(),
+		Username:    "alice",
+		Password:    "hash",
+		Email:       "alice@example.com",
+		Status:      domain.UserStatusActive,
+		Role:        domain.RoleAdmin,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	}
+
+	doc, err := newUserDocument(user)
+	require.NoError(t, err)
+
+	raw, err := bson.Marshal(doc)
+	require.NoError(t, err)
+
+	// The ID must be stored as an ObjectID, not as its hex string
+	assert.Equal(t, bson.TypeObjectID, bson.Raw(raw).Lookup("_id").Type)
+
+	var decoded userDocument
+	require.NoError(t, bson.Unmarshal(raw, &decoded))
+	assert.Equal(t, user, decoded.toDomain())
+}
+
+func TestUserDocumentWithoutID(t *testing.T) {
+	doc, err := newUserDocument(&domain.User{Username: "alice"})
+	require.NoError(t, err)
+	assert.True(t, doc.ID.IsZero())
+
+	// An unset ID is omitted so the repository decides it
+	raw, err := bson.Marshal(doc)
+	require.NoError(t, err)
+	_, err = bson.Raw(raw).LookupErr("_id")
+	assert.Error(t, err)
+
+	assert.Empty(t, doc.toDomain().ID)
+}
+
+func TestUserDocumentInvalidID(t *testing.T) {
+	_, err := newUserDocument(&domain.User{ID: "not-an-object-id"})
+	assert.Equal(t, ErrInvalidID, err)
+}
+
+func TestDriverLocationDocumentRoundTrip(t *testing.T) {
+	location := &domain.DriverLocation{
+		ID:       primitive.NewObjectID().Hex(),
+		DriverID: "driver-1",
+		Location: domain.Point{
+			Type:        "Point",
+			Coordinates: []float64{29.0, 41.0},
+		},
+		Status:    "active",
+		Timestamp: time.Now().UTC().Truncate(time.Millisecond),
+	}
+
+	doc, err := newDriverLocationDocument(location)
+	require.NoError(t, err)
+
+	raw, err := bson.Marshal(doc)
+	require.NoError(t, err)
+	assert.Equal(t, bson.TypeObjectID, bson.Raw(raw).Lookup("_id").Type)
+	assert.Equal(t, "Point", bson.Raw(raw).Lookup("location", "type").StringValue())
+
+	var decoded driverLocationDocument
+	require.NoError(t, bson.Unmarshal(raw, &decoded))
+	assert.Equal(t, location, decoded.toDomain())
+}
+
+func TestDriverLocationDocumentInvalidID(t *testing.T) {
+	_, err := newDriverLocationDocument(&domain.DriverLocation{ID: "driver-1"})
+	assert.Equal(t, ErrInvalidID, err)
+}
+
+func TestAPIKeyDocumentRoundTrip(t *testing.T) {
+	usedAt := time.Now().UTC().Truncate(time.Millisecond)
+	key := &domain.APIKey{
+		ID:         "0b6c2b8e-5f2a-4d7c-9a51-3d1f0c4b7e21",
+		Name:       "importer",
+		Prefix:     "abcd1234",
+		Hash:       "hash",
+		Scopes:     []string{domain.ScopeLocationsWrite},
+		CreatedAt:  usedAt,
+		LastUsedAt: &usedAt,
+	}
+
+	raw, err := bson.Marshal(newAPIKeyDocument(key))
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, bson.Raw(raw).Lookup("_id").StringValue())
+
+	_, err = bson.Raw(raw).LookupErr("revoked_at")
+	assert.Error(t, err, "unset revoked_at should be omitted")
+
+	var decoded apiKeyDocument
+	require.NoError(t, bson.Unmarshal(raw, &decoded))
+	assert.Equal(t, key, decoded.toDomain())
+}
+
+func TestLoginAttemptDocumentDecode(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	raw, err := bson.Marshal(bson.M{
+		"_id":             "user:alice",
+		"failures":        3,
+		"last_failure_at": now,
+	})
+	require.NoError(t, err)
+
+	var doc loginAttemptDocument
+	require.NoError(t, bson.Unmarshal(raw, &doc))
+
+	attempt := doc.toDomain()
+	assert.Equal(t, "user:alice", attempt.Key)
+	assert.Equal(t, 3, attempt.Failures)
+	assert.Equal(t, now, attempt.LastFailureAt)
+	assert.True(t, attempt.LockedUntil.IsZero())
+}
+
+func TestWebhookDeliveryDocumentRoundTrip(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	delivery := &domain.WebhookDelivery{
+		ID:             "5e0f6c1a-8b2d-4e3f-9a7c-1d2e3f4a5b6c",
+		SubscriptionID: "9c8b7a6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d",
+		Event:          domain.EventRideCompleted,
+		Payload:        []byte(`{"event":"ride.completed"}`),
+		Status:         domain.WebhookDeliveryDeadLetter,
+		Attempts: []domain.WebhookAttempt{
+			{At: now, StatusCode: 500, Error: "unexpected status 500", DurationMs: 12},
+			{At: now, Error: "connection refused"},
+		},
+		Error:       "gave up after 2 attempts",
+		CreatedAt:   now,
+		CompletedAt: &now,
+	}
+
+	raw, err := bson.Marshal(newWebhookDeliveryDocument(delivery))
+	require.NoError(t, err)
+	assert.Equal(t, delivery.ID, bson.Raw(raw).Lookup("_id").StringValue())
+
+	_, err = bson.Raw(raw).LookupErr("next_attempt_at")
+	assert.Error(t, err, "unset next_attempt_at should be omitted")
+
+	var decoded webhookDeliveryDocument
+	require.NoError(t, bson.Unmarshal(raw, &decoded))
+	assert.Equal(t, delivery, decoded.toDomain())
+}
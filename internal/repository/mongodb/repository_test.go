@@ -0,0 +1,398 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+// testDatabase connects to the MongoDB given by MONGODB_TEST_URI and returns
+// a throwaway database that is dropped when the test ends. Tests are skipped
+// when the variable is not set.
+func testDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	require.NoError(t, client.Ping(ctx, nil))
+
+	db := client.Database(fmt.Sprintf("repository_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = db.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+
+	return db
+}
+
+func TestUserRepositoryRoundTrip(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	user := domain.NewUser(domain.UserCredentials{
+		Username: "alice",
+		Password: "hash",
+		Email:    "alice@example.com",
+	})
+	require.NoError(t, repo.CreateUser(ctx, user))
+	require.NotEmpty(t, user.ID, "CreateUser should write the generated ID back")
+
+	found, err := repo.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, user.Username, found.Username)
+	assert.Equal(t, user.ID, found.ID)
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, repo.UpdateLastLogin(ctx, user.ID))
+
+	found, err = repo.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, user.ID, found.ID)
+	assert.True(t, found.LastLoginAt.After(user.LastLoginAt), "UpdateLastLogin should match the stored ObjectID")
+
+	found, err = repo.GetUserByID(ctx, "not-an-object-id")
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+
+	err = repo.CreateUser(ctx, domain.NewUser(domain.UserCredentials{
+		Username: "alice",
+		Password: "hash",
+		Email:    "other@example.com",
+	}))
+	assert.Equal(t, ErrDuplicateUsername, err)
+}
+
+func TestUserRepositoryUniqueEmail(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	alice := domain.NewUser(domain.UserCredentials{Username: "alice", Password: "hash", Email: "alice@example.com"})
+	require.NoError(t, repo.CreateUser(ctx, alice))
+	bob := domain.NewUser(domain.UserCredentials{Username: "bob", Password: "hash", Email: "bob@example.com"})
+	require.NoError(t, repo.CreateUser(ctx, bob))
+
+	err := repo.CreateUser(ctx, domain.NewUser(domain.UserCredentials{Username: "carol", Password: "hash", Email: "alice@example.com"}))
+	assert.Equal(t, repository.ErrDuplicateEmail, err)
+	assert.Equal(t, repository.ErrDuplicateEmail, repo.UpdateEmail(ctx, bob.ID, "alice@example.com"))
+
+	// Deleted users free their address
+	require.NoError(t, repo.DeleteUser(ctx, alice.ID))
+	require.NoError(t, repo.UpdateEmail(ctx, bob.ID, "alice@example.com"))
+
+	found, err := repo.GetUserByID(ctx, bob.ID)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, "alice@example.com", found.Email)
+	assert.Equal(t, domain.UserStatusPending, found.Status)
+	assert.Equal(t, "hash", found.Password, "UpdateEmail should leave the other fields alone")
+
+	deleted, _, err := repo.ListUsers(ctx, domain.UserFilter{Status: domain.UserStatusDeleted, Page: 1, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	assert.Equal(t, "alice@example.com", deleted[0].Email)
+}
+
+func TestLocationRepositoryRoundTrip(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewLocationRepository(db)
+	ctx := context.Background()
+
+	location := &domain.DriverLocation{
+		DriverID: "driver-1",
+		Location: domain.Point{
+			Type:        "Point",
+			Coordinates: []float64{29.0, 41.0},
+		},
+		Status:    "active",
+		Timestamp: time.Now().UTC(),
+	}
+	require.NoError(t, repo.SaveLocation(ctx, location))
+	require.NotEmpty(t, location.ID)
+
+	// Saving again updates the same document and keeps its ID
+	id := location.ID
+	location.ID = ""
+	location.Location.Coordinates = []float64{29.001, 41.001}
+	require.NoError(t, repo.SaveLocation(ctx, location))
+	assert.Equal(t, id, location.ID)
+
+	batch := []*domain.DriverLocation{
+		{
+			DriverID:  "driver-2",
+			Location:  domain.Point{Type: "Point", Coordinates: []float64{29.002, 41.002}},
+			Status:    "active",
+			Timestamp: time.Now().UTC(),
+		},
+	}
+	require.NoError(t, repo.SaveLocations(ctx, batch))
+	assert.NotEmpty(t, batch[0].ID)
+
+	nearby, err := repo.FindNearbyDrivers(ctx, 41.0, 29.0, 1000)
+	require.NoError(t, err)
+	require.Len(t, nearby, 2)
+
+	ids := map[string]string{}
+	for _, l := range nearby {
+		ids[l.DriverID] = l.ID
+	}
+	assert.Equal(t, id, ids["driver-1"])
+	assert.Equal(t, batch[0].ID, ids["driver-2"])
+
+	active, err := repo.CountActiveDrivers(ctx, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), active)
+
+	active, err = repo.CountActiveDrivers(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), active)
+}
+
+func TestIdempotencyRepository(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewIdempotencyRepository(db)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	record := &domain.IdempotencyRecord{
+		Key:         "user:1:key-1",
+		RequestHash: "hash",
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Minute),
+	}
+	created, err := repo.CreateIdempotencyRecord(ctx, record)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	created, err = repo.CreateIdempotencyRecord(ctx, record)
+	require.NoError(t, err)
+	assert.False(t, created, "an unexpired record keeps its key")
+
+	// Another request cannot complete the record
+	other := *record
+	other.RequestHash = "other-hash"
+	other.Status = 200
+	require.NoError(t, repo.CompleteIdempotencyRecord(ctx, &other))
+	found, err := repo.GetIdempotencyRecord(ctx, record.Key)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.False(t, found.IsCompleted())
+
+	record.Status = 201
+	record.Header = map[string][]string{"Content-Type": {"application/json"}}
+	record.Body = []byte(`{"id":"1"}`)
+	require.NoError(t, repo.CompleteIdempotencyRecord(ctx, record))
+
+	found, err = repo.GetIdempotencyRecord(ctx, record.Key)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, record, found)
+
+	// An expired record is replaced
+	expired := &domain.IdempotencyRecord{Key: "user:1:key-2", ExpiresAt: now.Add(-time.Second)}
+	_, err = repo.CreateIdempotencyRecord(ctx, expired)
+	require.NoError(t, err)
+	found, err = repo.GetIdempotencyRecord(ctx, expired.Key)
+	require.NoError(t, err)
+	assert.Nil(t, found)
+
+	expired.ExpiresAt = now.Add(time.Minute)
+	created, err = repo.CreateIdempotencyRecord(ctx, expired)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	require.NoError(t, repo.DeleteIdempotencyRecord(ctx, record.Key))
+	found, err = repo.GetIdempotencyRecord(ctx, record.Key)
+	require.NoError(t, err)
+	assert.Nil(t, found)
+}
+
+func TestRideRepository(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewRideRepository(db)
+	ctx := context.Background()
+
+	ride := &domain.Ride{
+		ID:        "6f0c2a5e-8d4b-4f1e-9a3c-2b7d5e1f0a9c",
+		RiderID:   "rider-1",
+		DriverID:  "driver-1",
+		Pickup:    domain.NewPoint(41.0431, 29.0099),
+		Status:    domain.RideStatusActive,
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	require.NoError(t, repo.CreateRide(ctx, ride))
+
+	found, err := repo.GetRideByID(ctx, ride.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ride, found)
+
+	second := *ride
+	second.ID = "0b1e4c7d-2f3a-4e5b-8c6d-9a0f1e2d3c4b"
+	assert.Equal(t, repository.ErrDuplicateActiveRide, repo.CreateRide(ctx, &second), "a driver has one active ride")
+
+	endedAt := ride.CreatedAt.Add(time.Minute)
+	ended, err := repo.EndRide(ctx, ride.ID, domain.RideStatusCompleted, endedAt)
+	require.NoError(t, err)
+	assert.True(t, ended)
+
+	ended, err = repo.EndRide(ctx, ride.ID, domain.RideStatusCompleted, endedAt)
+	require.NoError(t, err)
+	assert.False(t, ended, "a ride ends only once")
+
+	found, err = repo.GetRideByID(ctx, ride.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusCompleted, found.Status)
+	assert.Equal(t, endedAt, found.EndedAt.UTC())
+
+	found, err = repo.GetRideByID(ctx, "missing")
+	require.NoError(t, err)
+	assert.Nil(t, found)
+}
+
+func TestWebhookRepository(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewWebhookRepository(db)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	subscription := &domain.WebhookSubscription{
+		ID:        "3a1f5c7e-2b4d-4c6e-8f0a-1b3d5f7a9c2e",
+		URL:       "https://partner.example.com/hooks",
+		Events:    []string{domain.EventRideMatched},
+		Secret:    "whsec_secret",
+		Active:    true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	require.NoError(t, repo.CreateSubscription(ctx, subscription))
+
+	subscriptions, err := repo.ListSubscriptionsForEvent(ctx, domain.EventRideMatched)
+	require.NoError(t, err)
+	require.Len(t, subscriptions, 1)
+	assert.Equal(t, subscription, subscriptions[0])
+
+	subscriptions, err = repo.ListSubscriptionsForEvent(ctx, domain.EventRideCompleted)
+	require.NoError(t, err)
+	assert.Empty(t, subscriptions)
+
+	subscription.Active = false
+	require.NoError(t, repo.UpdateSubscription(ctx, subscription))
+	subscriptions, err = repo.ListSubscriptionsForEvent(ctx, domain.EventRideMatched)
+	require.NoError(t, err)
+	assert.Empty(t, subscriptions, "inactive subscriptions get no events")
+
+	for i, status := range []string{domain.WebhookDeliverySucceeded, domain.WebhookDeliveryDeadLetter} {
+		require.NoError(t, repo.CreateDelivery(ctx, &domain.WebhookDelivery{
+			ID:             fmt.Sprintf("delivery-%d", i),
+			SubscriptionID: subscription.ID,
+			Event:          domain.EventRideMatched,
+			Payload:        []byte(`{}`),
+			Status:         domain.WebhookDeliveryPending,
+			Attempts:       []domain.WebhookAttempt{},
+			CreatedAt:      now.Add(time.Duration(i) * time.Second),
+		}))
+		require.NoError(t, repo.UpdateDelivery(ctx, &domain.WebhookDelivery{
+			ID:       fmt.Sprintf("delivery-%d", i),
+			Status:   status,
+			Attempts: []domain.WebhookAttempt{{At: now, StatusCode: 200}},
+		}))
+	}
+
+	deliveries, err := repo.ListDeliveries(ctx, domain.WebhookDeliveryFilter{SubscriptionID: subscription.ID})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, "delivery-1", deliveries[0].ID, "newest first")
+	assert.Len(t, deliveries[0].Attempts, 1)
+
+	deliveries, err = repo.ListDeliveries(ctx, domain.WebhookDeliveryFilter{
+		SubscriptionID: subscription.ID,
+		Status:         domain.WebhookDeliverySucceeded,
+	})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, "delivery-0", deliveries[0].ID)
+
+	require.NoError(t, repo.CreateDelivery(ctx, &domain.WebhookDelivery{
+		ID:             "delivery-2",
+		SubscriptionID: subscription.ID,
+		Event:          domain.EventRideMatched,
+		Payload:        []byte(`{}`),
+		Status:         domain.WebhookDeliveryPending,
+		Attempts:       []domain.WebhookAttempt{},
+		CreatedAt:      now.Add(2 * time.Second),
+	}))
+	deliveries, err = repo.ListPendingDeliveries(ctx)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, "delivery-2", deliveries[0].ID)
+
+	deleted, err := repo.DeleteSubscription(ctx, subscription.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	deliveries, err = repo.ListDeliveries(ctx, domain.WebhookDeliveryFilter{SubscriptionID: subscription.ID})
+	require.NoError(t, err)
+	assert.Empty(t, deliveries, "deliveries are deleted with their subscription")
+
+	deleted, err = repo.DeleteSubscription(ctx, subscription.ID)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+}
+
+func TestLoginAttemptRepository(t *testing.T) {
+	db := testDatabase(t)
+	repo := NewLoginAttemptRepository(db)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	attempt, err := repo.BeginLoginAttempt(ctx, "user:alice", now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempt.Attempts)
+	assert.Equal(t, 0, attempt.Failures)
+	assert.Equal(t, now, attempt.LastFailureAt, "new keys expire like keys with failures")
+
+	attempt, err = repo.BeginLoginAttempt(ctx, "user:alice", now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempt.Attempts)
+
+	require.NoError(t, repo.EndLoginAttempt(ctx, "user:alice"))
+	attempt, err = repo.RecordFailedLogin(ctx, "user:alice", now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempt.Attempts, "failed logins stay counted")
+	assert.Equal(t, 1, attempt.Failures)
+
+	// Only the lock that was seen is reset
+	require.NoError(t, repo.LockLogin(ctx, "user:alice", now))
+	require.NoError(t, repo.ResetExpiredLock(ctx, "user:alice", now.Add(-time.Second)))
+	attempt, err = repo.GetLoginAttempt(ctx, "user:alice")
+	require.NoError(t, err)
+	require.NotNil(t, attempt)
+
+	require.NoError(t, repo.ResetExpiredLock(ctx, "user:alice", now))
+	attempt, err = repo.GetLoginAttempt(ctx, "user:alice")
+	require.NoError(t, err)
+	assert.Nil(t, attempt)
+}
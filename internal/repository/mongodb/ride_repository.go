@@ -0,0 +1,110 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+type rideRepository struct {
+	collection *mongo.Collection
+}
+
+// NewRideRepository creates a new MongoDB ride repository
+func NewRideRepository(db *mongo.Database) repository.RideRepository {
+	collection := db.Collection("rides")
+
+	// Create index to find the active ride of a driver, and a unique index
+	// that keeps a driver from having two active rides
+	indexModels := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "driver_id", Value: 1},
+				{Key: "status", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "driver_id", Value: 1},
+			},
+			Options: options.Index().
+				SetName("driver_active_ride").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": domain.RideStatusActive}),
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, indexModels)
+	if err != nil {
+		panic(err) // In production, handle this error appropriately
+	}
+
+	return &rideRepository{
+		collection: collection,
+	}
+}
+
+func (r *rideRepository) CreateRide(ctx context.Context, ride *domain.Ride) error {
+	_, err := r.collection.InsertOne(ctx, newRideDocument(ride))
+	if mongo.IsDuplicateKeyError(err) {
+		return repository.ErrDuplicateActiveRide
+	}
+	return err
+}
+
+func (r *rideRepository) GetRideByID(ctx context.Context, id string) (*domain.Ride, error) {
+	var doc rideDocument
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return doc.toDomain(), nil
+}
+
+func (r *rideRepository) GetActiveRideByDriver(ctx context.Context, driverID string) (*domain.Ride, error) {
+	var doc rideDocument
+	err := r.collection.FindOne(ctx, bson.M{"driver_id": driverID, "status": domain.RideStatusActive}).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return doc.toDomain(), nil
+}
+
+// EndRide only matches active rides, so a ride ends exactly once even when
+// it is ended concurrently
+func (r *rideRepository) EndRide(ctx context.Context, id, status string, endedAt time.Time) (bool, error) {
+	filter := bson.M{
+		"_id":    id,
+		"status": domain.RideStatusActive,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":   status,
+			"ended_at": endedAt,
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
@@ -3,36 +3,73 @@ package mongodb
 import (
 	"context"
 	"errors"
+	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/yusufatac/bitaksi-case-study/internal/domain"
 	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+	"github.com/yusufatac/bitaksi-case-study/internal/tracing"
 )
 
+// userCollection holds the users
+const userCollection = "users"
+
+// emailIndex is the unique index of the email addresses of users that are
+// not deleted
+const emailIndex = "email_unique"
+
 type userRepository struct {
 	collection *mongo.Collection
 }
 
 // NewUserRepository creates a new MongoDB user repository
 func NewUserRepository(db *mongo.Database) repository.UserRepository {
-	collection := db.Collection("users")
+	collection := db.Collection(userCollection)
 
-	// Create unique index for username
-	indexModel := mongo.IndexModel{
-		Keys: bson.D{
-			{Key: "username", Value: 1},
+	// Create unique indexes for username and email. Deleted users keep
+	// their email in deleted_email, so their address can be used again.
+	indexModels := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "username", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "email", Value: 1},
+			},
+			Options: options.Index().
+				SetName(emailIndex).
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"email": bson.M{"$gt": ""}}),
 		},
-		Options: options.Index().SetUnique(true),
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	_, err := collection.Indexes().CreateOne(ctx, indexModel)
+	// Users deleted before deleted_email existed still hold their email, and
+	// the plain email index it replaces has the same keys
+	_, err := collection.UpdateMany(ctx,
+		bson.M{"status": domain.UserStatusDeleted, "email": bson.M{"$gt": ""}},
+		deleteUserPipeline,
+	)
+	if err != nil {
+		panic(err) // In production, handle this error appropriately
+	}
+	if _, err := collection.Indexes().DropOne(ctx, "email_1"); err != nil && !isIndexNotFound(err) {
+		panic(err) // In production, handle this error appropriately
+	}
+
+	_, err = collection.Indexes().CreateMany(ctx, indexModels)
 	if err != nil {
 		panic(err) // In production, handle this error appropriately
 	}
@@ -42,45 +79,253 @@ func NewUserRepository(db *mongo.Database) repository.UserRepository {
 	}
 }
 
-func (r *userRepository) CreateUser(ctx context.Context, user *domain.User) error {
-	_, err := r.collection.InsertOne(ctx, user)
+func (r *userRepository) CreateUser(ctx context.Context, user *domain.User) (err error) {
+	ctx, span := tracing.StartMongo(ctx, userCollection, "CreateUser")
+	defer func() { tracing.End(span, err) }()
+
+	doc, err := newUserDocument(user)
+	if err != nil {
+		return err
+	}
+	if doc.ID.IsZero() {
+		doc.ID = primitive.NewObjectID()
+	}
+
+	_, err = r.collection.InsertOne(ctx, doc)
+	if isDuplicateKeyOn(err, emailIndex) {
+		return repository.ErrDuplicateEmail
+	}
 	if mongo.IsDuplicateKeyError(err) {
 		return ErrDuplicateUsername
 	}
-	return err
+	if err != nil {
+		return err
+	}
+
+	user.ID = hexFromObjectID(doc.ID)
+	return nil
 }
 
-func (r *userRepository) GetUserByUsername(ctx context.Context, username string) (*domain.User, error) {
+func (r *userRepository) GetUserByUsername(ctx context.Context, username string) (user *domain.User, err error) {
+	ctx, span := tracing.StartMongo(ctx, userCollection, "GetUserByUsername")
+	defer func() { tracing.End(span, err) }()
+
 	filter := bson.M{
 		"username": username,
-		"status":   bson.M{"$ne": "deleted"},
+		"status":   bson.M{"$ne": domain.UserStatusDeleted},
 	}
 
-	var user domain.User
-	err := r.collection.FindOne(ctx, filter).Decode(&user)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, nil
-		}
-		return nil, err
+	return r.findOne(ctx, filter)
+}
+
+func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (user *domain.User, err error) {
+	ctx, span := tracing.StartMongo(ctx, userCollection, "GetUserByEmail")
+	defer func() { tracing.End(span, err) }()
+
+	filter := bson.M{
+		"email":  email,
+		"status": bson.M{"$ne": domain.UserStatusDeleted},
 	}
 
-	return &user, nil
+	return r.findOne(ctx, filter)
 }
 
-func (r *userRepository) UpdateLastLogin(ctx context.Context, userID string) error {
-	filter := bson.M{"_id": userID}
+func (r *userRepository) UpdateLastLogin(ctx context.Context, userID string) (err error) {
+	ctx, span := tracing.StartMongo(ctx, userCollection, "UpdateLastLogin")
+	defer func() { tracing.End(span, err) }()
+
+	objectID, err := objectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": objectID}
 	update := bson.M{
 		"$set": bson.M{
 			"last_login_at": time.Now(),
 		},
 	}
 
-	_, err := r.collection.UpdateOne(ctx, filter, update)
+	_, err = r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *userRepository) UpdateStatus(ctx context.Context, username, status string) (err error) {
+	ctx, span := tracing.StartMongo(ctx, userCollection, "UpdateStatus")
+	defer func() { tracing.End(span, err) }()
+
+	filter := bson.M{"username": username}
+	update := bson.M{
+		"$set": bson.M{
+			"status": status,
+		},
+	}
+
+	_, err = r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, username, passwordHash string) (err error) {
+	ctx, span := tracing.StartMongo(ctx, userCollection, "UpdatePassword")
+	defer func() { tracing.End(span, err) }()
+
+	filter := bson.M{"username": username}
+	update := bson.M{
+		"$set": bson.M{
+			"password": passwordHash,
+		},
+	}
+
+	_, err = r.collection.UpdateOne(ctx, filter, update)
 	return err
 }
 
+func (r *userRepository) GetUserByID(ctx context.Context, id string) (user *domain.User, err error) {
+	ctx, span := tracing.StartMongo(ctx, userCollection, "GetUserByID")
+	defer func() { tracing.End(span, err) }()
+
+	objectID, err := objectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	filter := bson.M{
+		"_id":    objectID,
+		"status": bson.M{"$ne": domain.UserStatusDeleted},
+	}
+
+	return r.findOne(ctx, filter)
+}
+
+func (r *userRepository) UpdateEmail(ctx context.Context, userID, email string) (err error) {
+	ctx, span := tracing.StartMongo(ctx, userCollection, "UpdateEmail")
+	defer func() { tracing.End(span, err) }()
+
+	objectID, err := objectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{
+		"_id":    objectID,
+		"status": bson.M{"$ne": domain.UserStatusDeleted},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"email":  email,
+			"status": domain.UserStatusPending,
+		},
+	}
+
+	_, err = r.collection.UpdateOne(ctx, filter, update)
+	if mongo.IsDuplicateKeyError(err) {
+		return repository.ErrDuplicateEmail
+	}
+	return err
+}
+
+// deleteUserPipeline soft deletes users, moving their email out of the
+// unique email index
+var deleteUserPipeline = mongo.Pipeline{
+	{{Key: "$set", Value: bson.M{"status": domain.UserStatusDeleted, "deleted_email": "$email"}}},
+	{{Key: "$unset", Value: "email"}},
+}
+
+func (r *userRepository) DeleteUser(ctx context.Context, userID string) (err error) {
+	ctx, span := tracing.StartMongo(ctx, userCollection, "DeleteUser")
+	defer func() { tracing.End(span, err) }()
+
+	objectID, err := objectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{
+		"_id":    objectID,
+		"status": bson.M{"$ne": domain.UserStatusDeleted},
+	}
+
+	_, err = r.collection.UpdateOne(ctx, filter, deleteUserPipeline)
+	return err
+}
+
+func (r *userRepository) ListUsers(ctx context.Context, filter domain.UserFilter) (users []*domain.User, total int64, err error) {
+	ctx, span := tracing.StartMongo(ctx, userCollection, "ListUsers")
+	defer func() { tracing.End(span, err) }()
+
+	query := bson.M{}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	} else {
+		query["status"] = bson.M{"$ne": domain.UserStatusDeleted}
+	}
+	if filter.Query != "" {
+		pattern := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(filter.Query), Options: "i"}
+		query["$or"] = bson.A{
+			bson.M{"username": pattern},
+			bson.M{"email": pattern},
+			bson.M{"deleted_email": pattern},
+		}
+	}
+
+	total, err = r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((filter.Page - 1) * filter.Limit)).
+		SetLimit(int64(filter.Limit))
+
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []userDocument
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, 0, err
+	}
+
+	users = make([]*domain.User, len(docs))
+	for i := range docs {
+		users[i] = docs[i].toDomain()
+	}
+
+	return users, total, nil
+}
+
+func (r *userRepository) findOne(ctx context.Context, filter bson.M) (*domain.User, error) {
+	var doc userDocument
+	err := r.collection.FindOne(ctx, filter).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return doc.toDomain(), nil
+}
+
+// isDuplicateKeyOn reports whether err is a duplicate key error of the
+// named index
+func isDuplicateKeyOn(err error, index string) bool {
+	return mongo.IsDuplicateKeyError(err) && strings.Contains(err.Error(), "index: "+index+" ")
+}
+
+// isIndexNotFound reports whether err says that the index or its collection
+// does not exist
+func isIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && (cmdErr.HasErrorCode(27) || cmdErr.HasErrorCode(26))
+}
+
 // Custom errors
 var (
-	ErrDuplicateUsername = errors.New("username already exists")
+	ErrDuplicateUsername = fmt.Errorf("%w: username already exists", domain.ErrConflict)
+	ErrDuplicateAPIKey   = fmt.Errorf("%w: api key prefix already exists", domain.ErrConflict)
+	ErrInvalidID         = fmt.Errorf("%w: invalid id", domain.ErrNotFound)
 )
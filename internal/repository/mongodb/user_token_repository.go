@@ -0,0 +1,56 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+type userTokenRepository struct {
+	collection *mongo.Collection
+}
+
+// NewUserTokenRepository creates a new MongoDB repository for used single-use tokens
+func NewUserTokenRepository(db *mongo.Database) repository.UserTokenRepository {
+	collection := db.Collection("used_tokens")
+
+	// Drop records once the token could no longer be used anyway
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "expires_at", Value: 1},
+		},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, indexModel)
+	if err != nil {
+		panic(err) // In production, handle this error appropriately
+	}
+
+	return &userTokenRepository{
+		collection: collection,
+	}
+}
+
+func (r *userTokenRepository) MarkTokenUsed(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	_, err := r.collection.InsertOne(ctx, bson.M{
+		"_id":        tokenID,
+		"used_at":    time.Now(),
+		"expires_at": expiresAt,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,208 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+// webhookDeliveryRetention is how long deliveries are kept in the log
+const webhookDeliveryRetention = 30 * 24 * time.Hour
+
+type webhookRepository struct {
+	subscriptions *mongo.Collection
+	deliveries    *mongo.Collection
+}
+
+// NewWebhookRepository creates a new MongoDB webhook repository
+func NewWebhookRepository(db *mongo.Database) repository.WebhookRepository {
+	subscriptions := db.Collection("webhook_subscriptions")
+	deliveries := db.Collection("webhook_deliveries")
+
+	// Index event lookups, list the log of a subscription newest first, find
+	// pending deliveries and remove deliveries once they are older than the
+	// retention
+	subscriptionIndex := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "events", Value: 1},
+		},
+	}
+	deliveryIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "subscription_id", Value: 1},
+				{Key: "created_at", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "status", Value: 1},
+				{Key: "created_at", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "created_at", Value: 1},
+			},
+			Options: options.Index().SetExpireAfterSeconds(int32(webhookDeliveryRetention.Seconds())),
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := subscriptions.Indexes().CreateOne(ctx, subscriptionIndex); err != nil {
+		panic(err) // In production, handle this error appropriately
+	}
+	if _, err := deliveries.Indexes().CreateMany(ctx, deliveryIndexes); err != nil {
+		panic(err) // In production, handle this error appropriately
+	}
+
+	return &webhookRepository{
+		subscriptions: subscriptions,
+		deliveries:    deliveries,
+	}
+}
+
+func (r *webhookRepository) CreateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) error {
+	_, err := r.subscriptions.InsertOne(ctx, newWebhookSubscriptionDocument(subscription))
+	return err
+}
+
+func (r *webhookRepository) GetSubscription(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	var doc webhookSubscriptionDocument
+	err := r.subscriptions.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return doc.toDomain(), nil
+}
+
+func (r *webhookRepository) ListSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	return r.findSubscriptions(ctx, bson.M{})
+}
+
+func (r *webhookRepository) ListSubscriptionsForEvent(ctx context.Context, event string) ([]*domain.WebhookSubscription, error) {
+	return r.findSubscriptions(ctx, bson.M{"events": event, "active": true})
+}
+
+func (r *webhookRepository) findSubscriptions(ctx context.Context, filter bson.M) ([]*domain.WebhookSubscription, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := r.subscriptions.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []webhookSubscriptionDocument
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	subscriptions := make([]*domain.WebhookSubscription, len(docs))
+	for i := range docs {
+		subscriptions[i] = docs[i].toDomain()
+	}
+
+	return subscriptions, nil
+}
+
+func (r *webhookRepository) UpdateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) error {
+	update := bson.M{
+		"$set": bson.M{
+			"url":        subscription.URL,
+			"events":     subscription.Events,
+			"active":     subscription.Active,
+			"updated_at": subscription.UpdatedAt,
+		},
+	}
+
+	_, err := r.subscriptions.UpdateOne(ctx, bson.M{"_id": subscription.ID}, update)
+	return err
+}
+
+func (r *webhookRepository) DeleteSubscription(ctx context.Context, id string) (bool, error) {
+	result, err := r.subscriptions.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, err
+	}
+	if result.DeletedCount == 0 {
+		return false, nil
+	}
+
+	if _, err := r.deliveries.DeleteMany(ctx, bson.M{"subscription_id": id}); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+func (r *webhookRepository) CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	_, err := r.deliveries.InsertOne(ctx, newWebhookDeliveryDocument(delivery))
+	return err
+}
+
+func (r *webhookRepository) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	doc := newWebhookDeliveryDocument(delivery)
+	update := bson.M{
+		"$set": bson.M{
+			"status":          doc.Status,
+			"attempts":        doc.Attempts,
+			"next_attempt_at": doc.NextAttemptAt,
+			"error":           doc.Error,
+			"completed_at":    doc.CompletedAt,
+		},
+	}
+
+	_, err := r.deliveries.UpdateOne(ctx, bson.M{"_id": delivery.ID}, update)
+	return err
+}
+
+func (r *webhookRepository) ListDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter) ([]*domain.WebhookDelivery, error) {
+	query := bson.M{"subscription_id": filter.SubscriptionID}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+
+	return r.findDeliveries(ctx, query, opts)
+}
+
+func (r *webhookRepository) ListPendingDeliveries(ctx context.Context) ([]*domain.WebhookDelivery, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	return r.findDeliveries(ctx, bson.M{"status": domain.WebhookDeliveryPending}, opts)
+}
+
+func (r *webhookRepository) findDeliveries(ctx context.Context, query bson.M, opts *options.FindOptions) ([]*domain.WebhookDelivery, error) {
+	cursor, err := r.deliveries.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []webhookDeliveryDocument
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]*domain.WebhookDelivery, len(docs))
+	for i := range docs {
+		deliveries[i] = docs[i].toDomain()
+	}
+
+	return deliveries, nil
+}
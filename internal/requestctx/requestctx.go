@@ -0,0 +1,198 @@
+// Package requestctx carries the request ID, W3C trace context and user of a
+// request through its context.Context, and propagates the request ID and
+// trace context to outbound calls.
+package requestctx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Propagated headers
+const (
+	RequestIDHeader   = "X-Request-ID"
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+// maxRequestIDLength bounds request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// Custom errors
+var (
+	ErrInvalidTraceParent = errors.New("invalid traceparent")
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	traceParentKey
+	traceStateKey
+	userIDKey
+)
+
+// TraceParent is a W3C trace context traceparent
+// (https://www.w3.org/TR/trace-context/#traceparent-header)
+type TraceParent struct {
+	TraceID  string
+	ParentID string
+	Flags    string
+}
+
+// NewTraceParent starts a new sampled trace
+func NewTraceParent() TraceParent {
+	return TraceParent{
+		TraceID:  randomHex(16),
+		ParentID: randomHex(8),
+		Flags:    "01",
+	}
+}
+
+// ParseTraceParent parses a version 00 traceparent header. Higher versions
+// are accepted as long as they start with the version 00 fields.
+func ParseTraceParent(value string) (TraceParent, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return TraceParent{}, ErrInvalidTraceParent
+	}
+
+	version, traceID, parentID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return TraceParent{}, ErrInvalidTraceParent
+	}
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return TraceParent{}, ErrInvalidTraceParent
+	}
+	if !isLowerHex(parentID, 16) || parentID == strings.Repeat("0", 16) {
+		return TraceParent{}, ErrInvalidTraceParent
+	}
+	if !isLowerHex(flags, 2) {
+		return TraceParent{}, ErrInvalidTraceParent
+	}
+
+	return TraceParent{TraceID: traceID, ParentID: parentID, Flags: flags}, nil
+}
+
+// Child returns the traceparent of a new span in the same trace
+func (tp TraceParent) Child() TraceParent {
+	return TraceParent{
+		TraceID:  tp.TraceID,
+		ParentID: randomHex(8),
+		Flags:    tp.Flags,
+	}
+}
+
+// IsZero reports whether tp is unset
+func (tp TraceParent) IsZero() bool {
+	return tp.TraceID == ""
+}
+
+func (tp TraceParent) String() string {
+	return "00-" + tp.TraceID + "-" + tp.ParentID + "-" + tp.Flags
+}
+
+// NewRequestID generates a request ID
+func NewRequestID() string {
+	return uuid.NewString()
+}
+
+// ValidRequestID reports whether a request ID sent by a client can be kept.
+// Only short, printable ASCII IDs are accepted so they are safe to log.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID of ctx, or "" if there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithTraceParent returns a copy of ctx carrying the traceparent of the
+// current span
+func WithTraceParent(ctx context.Context, tp TraceParent) context.Context {
+	return context.WithValue(ctx, traceParentKey, tp)
+}
+
+// TraceParentFrom returns the traceparent of the current span of ctx
+func TraceParentFrom(ctx context.Context) (TraceParent, bool) {
+	tp, ok := ctx.Value(traceParentKey).(TraceParent)
+	return tp, ok
+}
+
+// WithTraceState returns a copy of ctx carrying the vendor tracestate
+func WithTraceState(ctx context.Context, state string) context.Context {
+	return context.WithValue(ctx, traceStateKey, state)
+}
+
+// TraceState returns the vendor tracestate of ctx, or "" if there is none
+func TraceState(ctx context.Context) string {
+	state, _ := ctx.Value(traceStateKey).(string)
+	return state
+}
+
+// WithUserID returns a copy of ctx carrying the ID of the authenticated user
+func WithUserID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, userIDKey, id)
+}
+
+// UserID returns the ID of the authenticated user of ctx, or "" if there is none
+func UserID(ctx context.Context) string {
+	id, _ := ctx.Value(userIDKey).(string)
+	return id
+}
+
+// Inject sets the propagated headers of an outbound request made under ctx.
+// The outbound call gets its own span in the trace of ctx.
+func Inject(ctx context.Context, header http.Header) {
+	if id := RequestID(ctx); id != "" {
+		header.Set(RequestIDHeader, id)
+	}
+	if tp, ok := TraceParentFrom(ctx); ok {
+		header.Set(TraceParentHeader, tp.Child().String())
+		if state := TraceState(ctx); state != "" {
+			header.Set(TraceStateHeader, state)
+		}
+	}
+}
+
+func isLowerHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand never fails on supported platforms
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,86 @@
+package requestctx
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	tp, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tp.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", tp.ParentID)
+	assert.Equal(t, "01", tp.Flags)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", tp.String())
+
+	// Future versions may append fields
+	_, err = ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	assert.NoError(t, err)
+}
+
+func TestParseTraceParentInvalid(t *testing.T) {
+	invalid := []string{
+		"",
+		"garbage",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+	}
+
+	for _, value := range invalid {
+		_, err := ParseTraceParent(value)
+		assert.Equal(t, ErrInvalidTraceParent, err, value)
+	}
+}
+
+func TestNewTraceParent(t *testing.T) {
+	tp := NewTraceParent()
+
+	parsed, err := ParseTraceParent(tp.String())
+	require.NoError(t, err)
+	assert.Equal(t, tp, parsed)
+
+	child := tp.Child()
+	assert.Equal(t, tp.TraceID, child.TraceID)
+	assert.Equal(t, tp.Flags, child.Flags)
+	assert.NotEqual(t, tp.ParentID, child.ParentID)
+}
+
+func TestValidRequestID(t *testing.T) {
+	assert.True(t, ValidRequestID("abc-123"))
+	assert.True(t, ValidRequestID(NewRequestID()))
+	assert.False(t, ValidRequestID(""))
+	assert.False(t, ValidRequestID("has space"))
+	assert.False(t, ValidRequestID("line\nbreak"))
+	assert.False(t, ValidRequestID(strings.Repeat("a", maxRequestIDLength+1)))
+}
+
+func TestInject(t *testing.T) {
+	header := http.Header{}
+	Inject(context.Background(), header)
+	assert.Empty(t, header)
+
+	tp := NewTraceParent()
+	ctx := WithRequestID(context.Background(), "req-1")
+	ctx = WithTraceParent(ctx, tp)
+	ctx = WithTraceState(ctx, "vendor=value")
+
+	Inject(ctx, header)
+	assert.Equal(t, "req-1", header.Get(RequestIDHeader))
+	assert.Equal(t, "vendor=value", header.Get(TraceStateHeader))
+
+	outbound, err := ParseTraceParent(header.Get(TraceParentHeader))
+	require.NoError(t, err)
+	assert.Equal(t, tp.TraceID, outbound.TraceID)
+	assert.NotEqual(t, tp.ParentID, outbound.ParentID, "the outbound call gets its own span")
+}
@@ -1,58 +1,190 @@
 package router
 
 import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"github.com/swaggo/swag"
 
+	_ "github.com/yusufatac/bitaksi-case-study/docs"
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
 	"github.com/yusufatac/bitaksi-case-study/internal/handler"
+	handlerv2 "github.com/yusufatac/bitaksi-case-study/internal/handler/v2"
+	"github.com/yusufatac/bitaksi-case-study/internal/metrics"
 	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+	"github.com/yusufatac/bitaksi-case-study/internal/problem"
+)
+
+// Route groups with their own rate limit budget
+const (
+	RateLimitGroupAuth      = "auth"
+	RateLimitGroupLocations = "locations"
+	RateLimitGroupMatch     = "match"
+	RateLimitGroupUsers     = "users"
+	RateLimitGroupAdmin     = "admin"
+	RateLimitGroupRides     = "rides"
+)
+
+// Long-lived streaming routes, exempt from the request timeout
+const (
+	routeDriverStream = "/api/v1/drivers/stream"
+	routeRideTrack    = "/api/v1/rides/:id/track"
 )
 
+// API version prefixes. Errors on API v2 routes are problem details.
+const (
+	prefixV1 = "/api/v1"
+	prefixV2 = "/api/v2"
+)
+
+// swaggerInstanceV2 is the swag instance documenting API v2
+const swaggerInstanceV2 = "v2"
+
+// Limits bound the size and duration of requests. Zero values disable a limit.
+type Limits struct {
+	// MaxBodyBytes is the largest request body accepted by most routes
+	MaxBodyBytes int64
+	// MaxBatchBodyBytes is the largest body accepted by batch routes
+	MaxBatchBodyBytes int64
+	// RequestTimeout is the deadline of every request context
+	RequestTimeout time.Duration
+}
+
 type Router struct {
 	Engine         *gin.Engine
 	authMiddleware *middleware.AuthMiddleware
+	rateLimiter    *middleware.RateLimiter
+	idempotency    *middleware.Idempotency
+	metrics        *metrics.Metrics
+	cors           *middleware.CORS
 
-	locationHandler *handler.LocationHandler
-	matchingHandler *handler.MatchingHandler
-	authHandler     *handler.AuthHandler
+	handlers Handlers
+	v2       V2Handlers
 }
 
-func NewRouter(
-	authMiddleware *middleware.AuthMiddleware,
-	locationHandler *handler.LocationHandler,
-	matchingHandler *handler.MatchingHandler,
-	authHandler *handler.AuthHandler,
-) *Router {
+// Options are the dependencies and settings of a Router
+type Options struct {
+	Logger  *slog.Logger
+	Metrics *metrics.Metrics
+	Limits  Limits
+	// TrustedProxies are the proxies whose X-Forwarded-For and X-Real-IP
+	// headers are used to find the client IP
+	TrustedProxies []string
+
+	CORS        *middleware.CORS
+	Auth        *middleware.AuthMiddleware
+	RateLimiter *middleware.RateLimiter
+	Idempotency *middleware.Idempotency
+
+	Handlers Handlers
+	V2       V2Handlers
+}
+
+// Handlers serve API v1. Each service only sets the handlers of the routes
+// it registers.
+type Handlers struct {
+	Location     *handler.LocationHandler
+	Matching     *handler.MatchingHandler
+	Auth         *handler.AuthHandler
+	APIKey       *handler.APIKeyHandler
+	User         *handler.UserHandler
+	Breaker      *handler.BreakerHandler
+	DriverStream *handler.DriverStreamHandler
+	Ride         *handler.RideHandler
+	Webhook      *handler.WebhookHandler
+}
+
+// V2Handlers serve API v2. Routes of nil handlers are not registered.
+type V2Handlers struct {
+	Location *handlerv2.LocationHandler
+	Matching *handlerv2.MatchingHandler
+	Ride     *handlerv2.RideHandler
+}
+
+func NewRouter(opts Options) (*Router, error) {
+	engine := gin.New()
+	// Only take the client IP from X-Forwarded-For and X-Real-IP when the
+	// request comes from a trusted proxy. Otherwise clients could pick the
+	// IP used for login lockouts and rate limits.
+	if err := engine.SetTrustedProxies(opts.TrustedProxies); err != nil {
+		return nil, err
+	}
+	// Let handlers pass the gin context to services as a context.Context
+	// that carries the values and deadline of the request context
+	engine.ContextWithFallback = true
+	engine.Use(
+		middleware.RequestContext(),
+		problem.Routes(prefixV2),
+		middleware.Tracing(),
+		middleware.AccessLog(opts.Logger),
+		middleware.Metrics(opts.Metrics),
+		middleware.Recovery(opts.Logger),
+		middleware.MaxBodySize(opts.Limits.MaxBodyBytes, map[string]int64{
+			prefixV1 + "/locations/batch": opts.Limits.MaxBatchBodyBytes,
+			prefixV2 + "/locations/batch": opts.Limits.MaxBatchBodyBytes,
+		}),
+		middleware.RequestTimeout(opts.Limits.RequestTimeout, routeDriverStream, routeRideTrack),
+	)
+	engine.NoRoute(notFound)
+
 	return &Router{
-		Engine:          gin.Default(),
-		authMiddleware:  authMiddleware,
-		locationHandler: locationHandler,
-		matchingHandler: matchingHandler,
-		authHandler:     authHandler,
+		Engine:         engine,
+		metrics:        opts.Metrics,
+		cors:           opts.CORS,
+		authMiddleware: opts.Auth,
+		rateLimiter:    opts.RateLimiter,
+		idempotency:    opts.Idempotency,
+		handlers:       opts.Handlers,
+		v2:             opts.V2,
+	}, nil
+}
+
+// notFound answers unknown API v2 paths with a problem, which problem.Routes
+// cannot enable for requests that match no route. Other paths get the
+// default 404 of gin.
+func notFound(c *gin.Context) {
+	path := c.Request.URL.Path
+	if path == prefixV2 || strings.HasPrefix(path, prefixV2+"/") {
+		problem.Write(c, problem.New(http.StatusNotFound, problem.CodeNotFound, "route not found"))
 	}
 }
 
 func (r *Router) SetupDriverLocationRoutes() {
 	// Enable CORS
-	r.Engine.Use(corsMiddleware())
+	r.Engine.Use(r.cors.Middleware())
 
-	// Swagger documentation
-	r.Engine.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// Answer errors recorded by handlers. Registered here so that it runs
+	// inside route circuit breakers added before the routes.
+	r.Engine.Use(middleware.Errors())
+
+	r.setupSwaggerRoutes()
 
 	// Health check
 	r.Engine.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Prometheus metrics
+	r.Engine.GET("/metrics", gin.WrapH(r.metrics.Handler()))
+
 	// API v1 routes
-	v1 := r.Engine.Group("/api/v1")
+	v1 := r.Engine.Group(prefixV1)
 
 	// Public routes
 	auth := v1.Group("/auth")
+	auth.Use(r.rateLimiter.Limit(RateLimitGroupAuth))
 	{
-		auth.POST("/register", r.authHandler.Register)
-		auth.POST("/login", r.authHandler.Login)
+		auth.POST("/register", r.handlers.Auth.Register)
+		auth.POST("/login", r.handlers.Auth.Login)
+		auth.POST("/verify-email", r.handlers.Auth.VerifyEmail)
+		auth.POST("/verify-email/resend", r.handlers.Auth.ResendVerification)
+		auth.POST("/password/forgot", r.handlers.Auth.ForgotPassword)
+		auth.POST("/password/reset", r.handlers.Auth.ResetPassword)
 	}
 
 	// Protected routes
@@ -61,34 +193,100 @@ func (r *Router) SetupDriverLocationRoutes() {
 	{
 		// Location routes
 		locations := protected.Group("/locations")
+		locations.Use(r.rateLimiter.Limit(RateLimitGroupLocations))
+		{
+			locations.POST("", r.authMiddleware.RequireScope(domain.ScopeLocationsWrite), r.idempotency.Middleware(), r.handlers.Location.UpdateLocation)
+			locations.POST("/batch", r.authMiddleware.RequireScope(domain.ScopeLocationsWrite), r.idempotency.Middleware(), r.handlers.Location.UpdateLocations)
+			locations.POST("/nearby", r.authMiddleware.RequireScope(domain.ScopeLocationsRead), r.handlers.Location.FindNearbyDrivers)
+		}
+
+		// Driver location streaming over WebSocket
+		drivers := protected.Group("/drivers")
+		drivers.Use(r.rateLimiter.Limit(RateLimitGroupLocations))
+		{
+			drivers.GET("/stream", r.authMiddleware.RequireScope(domain.ScopeLocationsWrite), r.handlers.DriverStream.Stream)
+		}
+
+		// Ride routes
+		rides := protected.Group("/rides")
+		rides.Use(r.authMiddleware.RequireActiveUser(), r.rateLimiter.Limit(RateLimitGroupRides))
+		{
+			rides.POST("", r.idempotency.Middleware(), r.handlers.Ride.CreateRide)
+			rides.GET("/:id", r.handlers.Ride.GetRide)
+			rides.POST("/:id/complete", r.handlers.Ride.CompleteRide)
+			rides.GET("/:id/track", r.handlers.Ride.TrackRide)
+		}
+
+		// Webhook subscriptions to ride events
+		webhooks := protected.Group("/admin/webhooks")
+		webhooks.Use(r.authMiddleware.RequireActiveUser(), r.authMiddleware.RequireRole(domain.RoleAdmin), r.rateLimiter.Limit(RateLimitGroupAdmin))
+		{
+			webhooks.GET("", r.handlers.Webhook.ListWebhooks)
+			webhooks.POST("", r.handlers.Webhook.CreateWebhook)
+			webhooks.GET("/:id", r.handlers.Webhook.GetWebhook)
+			webhooks.PATCH("/:id", r.handlers.Webhook.UpdateWebhook)
+			webhooks.DELETE("/:id", r.handlers.Webhook.DeleteWebhook)
+			webhooks.GET("/:id/deliveries", r.handlers.Webhook.ListDeliveries)
+		}
+
+		r.setupUserRoutes(protected)
+		r.setupAdminRoutes(protected)
+	}
+
+	// API v2 routes
+	v2 := r.Engine.Group(prefixV2)
+	v2.Use(r.authMiddleware.RequireAuth())
+	if r.v2.Location != nil {
+		locations := v2.Group("/locations")
+		locations.Use(r.rateLimiter.Limit(RateLimitGroupLocations))
+		{
+			locations.POST("", r.authMiddleware.RequireScope(domain.ScopeLocationsWrite), r.idempotency.Middleware(), r.v2.Location.UpdateLocation)
+			locations.POST("/batch", r.authMiddleware.RequireScope(domain.ScopeLocationsWrite), r.idempotency.Middleware(), r.v2.Location.UpdateLocations)
+			locations.POST("/nearby", r.authMiddleware.RequireScope(domain.ScopeLocationsRead), r.v2.Location.FindNearbyDrivers)
+		}
+	}
+	if r.v2.Ride != nil {
+		rides := v2.Group("/rides")
+		rides.Use(r.authMiddleware.RequireActiveUser(), r.rateLimiter.Limit(RateLimitGroupRides))
 		{
-			locations.POST("", r.locationHandler.UpdateLocation)
-			locations.POST("/batch", r.locationHandler.UpdateLocations)
-			locations.POST("/nearby", r.locationHandler.FindNearbyDrivers)
+			rides.POST("", r.idempotency.Middleware(), r.v2.Ride.CreateRide)
+			rides.GET("/:id", r.v2.Ride.GetRide)
+			rides.POST("/:id/complete", r.v2.Ride.CompleteRide)
 		}
 	}
 }
 
 func (r *Router) SetupMatchingApiRoutes() {
 	// Enable CORS
-	r.Engine.Use(corsMiddleware())
+	r.Engine.Use(r.cors.Middleware())
 
-	// Swagger documentation
-	r.Engine.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// Answer errors recorded by handlers. Registered here so that it runs
+	// inside route circuit breakers added before the routes.
+	r.Engine.Use(middleware.Errors())
+
+	r.setupSwaggerRoutes()
 
 	// Health check
 	r.Engine.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Prometheus metrics
+	r.Engine.GET("/metrics", gin.WrapH(r.metrics.Handler()))
+
 	// API v1 routes
-	v1 := r.Engine.Group("/api/v1")
+	v1 := r.Engine.Group(prefixV1)
 
 	// Public routes
 	auth := v1.Group("/auth")
+	auth.Use(r.rateLimiter.Limit(RateLimitGroupAuth))
 	{
-		auth.POST("/register", r.authHandler.Register)
-		auth.POST("/login", r.authHandler.Login)
+		auth.POST("/register", r.handlers.Auth.Register)
+		auth.POST("/login", r.handlers.Auth.Login)
+		auth.POST("/verify-email", r.handlers.Auth.VerifyEmail)
+		auth.POST("/verify-email/resend", r.handlers.Auth.ResendVerification)
+		auth.POST("/password/forgot", r.handlers.Auth.ForgotPassword)
+		auth.POST("/password/reset", r.handlers.Auth.ResetPassword)
 	}
 
 	// Protected routes
@@ -97,28 +295,88 @@ func (r *Router) SetupMatchingApiRoutes() {
 	{
 		// Matching routes
 		match := protected.Group("/match")
+		match.Use(r.rateLimiter.Limit(RateLimitGroupMatch))
 		{
-			match.POST("", r.matchingHandler.FindNearestDriver)
+			match.POST("", r.authMiddleware.RequireScope(domain.ScopeLocationsRead), r.idempotency.Middleware(), r.handlers.Matching.FindNearestDriver)
 		}
+
+		// Outbound circuit breaker introspection
+		breakers := protected.Group("/admin/breakers")
+		breakers.Use(r.authMiddleware.RequireActiveUser(), r.authMiddleware.RequireRole(domain.RoleAdmin), r.rateLimiter.Limit(RateLimitGroupAdmin))
+		{
+			breakers.GET("", r.handlers.Breaker.ListBreakers)
+		}
+
+		r.setupUserRoutes(protected)
+		r.setupAdminRoutes(protected)
 	}
-}
 
-func (r *Router) Run(addr string) error {
-	return r.Engine.Run(addr)
+	// API v2 routes
+	v2 := r.Engine.Group(prefixV2)
+	v2.Use(r.authMiddleware.RequireAuth())
+	if r.v2.Matching != nil {
+		match := v2.Group("/match")
+		match.Use(r.rateLimiter.Limit(RateLimitGroupMatch))
+		{
+			match.POST("", r.authMiddleware.RequireScope(domain.ScopeLocationsRead), r.idempotency.Middleware(), r.v2.Matching.FindNearestDriver)
+		}
+	}
 }
 
-// CORS middleware
-func corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Authorization, Content-Type")
+// setupSwaggerRoutes serves the documentation of API v1 under /swagger and
+// of API v2 under /swagger-v2
+func (r *Router) setupSwaggerRoutes() {
+	r.Engine.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+	// gin-swagger always serves the default instance as doc.json
+	ui := ginSwagger.WrapHandler(swaggerFiles.NewHandler())
+	r.Engine.GET("/swagger-v2/*any", func(c *gin.Context) {
+		if c.Param("any") != "/doc.json" {
+			ui(c)
 			return
 		}
+		doc, err := swag.ReadDoc(swaggerInstanceV2)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read API documentation"})
+			return
+		}
+		c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(doc))
+	})
+}
+
+// setupUserRoutes registers the account routes shared by both services
+func (r *Router) setupUserRoutes(protected *gin.RouterGroup) {
+	me := protected.Group("/users/me")
+	me.Use(r.authMiddleware.RequireActiveUser(), r.rateLimiter.Limit(RateLimitGroupUsers))
+	{
+		me.GET("", r.handlers.User.GetMe)
+		me.PATCH("", r.handlers.User.UpdateMe)
+		me.DELETE("", r.handlers.User.DeleteMe)
+		me.POST("/password", r.handlers.User.ChangePassword)
+	}
+}
+
+// setupAdminRoutes registers the routes shared by both services that
+// require the admin role
+func (r *Router) setupAdminRoutes(protected *gin.RouterGroup) {
+	admin := protected.Group("/admin")
+	admin.Use(r.authMiddleware.RequireActiveUser(), r.authMiddleware.RequireRole(domain.RoleAdmin), r.rateLimiter.Limit(RateLimitGroupAdmin))
+	{
+		apiKeys := admin.Group("/api-keys")
+		{
+			apiKeys.GET("", r.handlers.APIKey.ListAPIKeys)
+			apiKeys.POST("", r.handlers.APIKey.CreateAPIKey)
+			apiKeys.DELETE("/:id", r.handlers.APIKey.RevokeAPIKey)
+		}
 
-		c.Next()
+		users := admin.Group("/users")
+		{
+			users.GET("", r.handlers.User.ListUsers)
+			users.POST("/:username/unlock", r.handlers.Auth.UnlockUser)
+		}
 	}
 }
+
+func (r *Router) Run(addr string) error {
+	return r.Engine.Run(addr)
+}
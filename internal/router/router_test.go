@@ -0,0 +1,125 @@
+package router
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/metrics"
+	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+	"github.com/yusufatac/bitaksi-case-study/internal/problem"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newTestRouter(t *testing.T, trustedProxies []string) *Router {
+	t.Helper()
+
+	r, err := NewRouter(Options{Logger: slog.Default(), Metrics: metrics.New(), TrustedProxies: trustedProxies})
+	require.NoError(t, err)
+	return r
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   string
+		want           string
+	}{
+		{
+			name:         "no trusted proxies ignores X-Forwarded-For",
+			remoteAddr:   "203.0.113.7:4711",
+			forwardedFor: "198.51.100.1",
+			want:         "203.0.113.7",
+		},
+		{
+			name:           "untrusted peer cannot spoof X-Forwarded-For",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "203.0.113.7:4711",
+			forwardedFor:   "198.51.100.1",
+			want:           "203.0.113.7",
+		},
+		{
+			name:           "trusted proxy forwards the client IP",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.1.2.3:4711",
+			forwardedFor:   "198.51.100.1",
+			want:           "198.51.100.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRouter(t, tt.trustedProxies)
+			r.Engine.GET("/ip", func(c *gin.Context) {
+				c.String(http.StatusOK, c.ClientIP())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			req.Header.Set("X-Real-IP", tt.forwardedFor)
+			w := httptest.NewRecorder()
+			r.Engine.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Body.String())
+		})
+	}
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	rateLimiter := middleware.NewRateLimiter(middleware.NewMemoryRateLimitStore(1), map[string]middleware.RateLimit{
+		RateLimitGroupAuth: {Rate: 0.001, Burst: 2},
+	})
+	r, err := NewRouter(Options{Logger: slog.Default(), Metrics: metrics.New(), RateLimiter: rateLimiter})
+	require.NoError(t, err)
+	r.Engine.POST("/login", r.rateLimiter.Limit(RateLimitGroupAuth), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	codes := make([]int, 0, 3)
+	for _, forwardedFor := range []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"} {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = "203.0.113.7:4711"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		r.Engine.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+}
+
+func TestNewRouterInvalidTrustedProxies(t *testing.T) {
+	_, err := NewRouter(Options{Logger: slog.Default(), Metrics: metrics.New(), TrustedProxies: []string{"not-an-ip"}})
+
+	assert.Error(t, err)
+}
+
+func TestNoRoute(t *testing.T) {
+	r := newTestRouter(t, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/unknown", nil)
+	w := httptest.NewRecorder()
+	r.Engine.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, problem.MediaType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"code":"not_found","detail":"route not found","instance":"/api/v2/unknown"}`, w.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/unknown", nil)
+	w = httptest.NewRecorder()
+	r.Engine.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NotEqual(t, problem.MediaType, w.Header().Get("Content-Type"))
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+// API keys look like "bk_<prefix>_<secret>". The prefix is stored in clear
+// text for lookups, the full key is only ever stored as a SHA-256 hash.
+const (
+	apiKeyTag         = "bk"
+	apiKeyPrefixBytes = 4
+	apiKeySecretBytes = 32
+)
+
+// Custom errors
+var (
+	ErrInvalidAPIKey  = domain.NewError(domain.ErrUnauthorized, "invalid_api_key", "invalid api key")
+	ErrAPIKeyNotFound = domain.NewError(domain.ErrNotFound, "api_key_not_found", "api key not found")
+	ErrInvalidScope   = domain.NewError(domain.ErrValidation, "invalid_scope", "invalid scope")
+	ErrNoScopes       = domain.NewError(domain.ErrValidation, "no_scopes", "at least one scope is required")
+)
+
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, name string, scopes []string) (*domain.APIKey, string, error)
+	ListAPIKeys(ctx context.Context) ([]*domain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string) error
+	Authenticate(ctx context.Context, rawKey string) (*domain.APIKey, error)
+}
+
+type apiKeyService struct {
+	repo   repository.APIKeyRepository
+	logger *slog.Logger
+}
+
+// APIKeyOption configures the API key service
+type APIKeyOption func(*apiKeyService)
+
+// WithAPIKeyLogger sets the logger of the API key service
+func WithAPIKeyLogger(logger *slog.Logger) APIKeyOption {
+	return func(s *apiKeyService) {
+		s.logger = logger
+	}
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository, options ...APIKeyOption) APIKeyService {
+	s := &apiKeyService{
+		repo:   repo,
+		logger: slog.Default(),
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// CreateAPIKey generates a new key and returns it together with the plain
+// text secret. The secret cannot be recovered afterwards.
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, name string, scopes []string) (*domain.APIKey, string, error) {
+	if len(scopes) == 0 {
+		return nil, "", ErrNoScopes
+	}
+	for _, scope := range scopes {
+		if !domain.IsValidScope(scope) {
+			return nil, "", ErrInvalidScope
+		}
+	}
+
+	prefix, err := randomHex(apiKeyPrefixBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(apiKeySecretBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	rawKey := apiKeyTag + "_" + prefix + "_" + secret
+
+	key := &domain.APIKey{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hashAPIKey(rawKey),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	return key, rawKey, nil
+}
+
+func (s *apiKeyService) ListAPIKeys(ctx context.Context) ([]*domain.APIKey, error) {
+	return s.repo.ListAPIKeys(ctx)
+}
+
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, id string) error {
+	key, err := s.repo.GetAPIKeyByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return ErrAPIKeyNotFound
+	}
+	if key.IsRevoked() {
+		return nil
+	}
+
+	return s.repo.RevokeAPIKey(ctx, id, time.Now())
+}
+
+// Authenticate resolves a raw key sent by a client to the stored API key
+func (s *apiKeyService) Authenticate(ctx context.Context, rawKey string) (*domain.APIKey, error) {
+	prefix, ok := parseAPIKeyPrefix(rawKey)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.GetAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get api key", "prefix", prefix, "error", err)
+		return nil, err
+	}
+	if key == nil || key.IsRevoked() {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashAPIKey(rawKey))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Last used is informational, a failed update should not reject the request
+	if err := s.repo.UpdateLastUsed(ctx, key.ID, time.Now()); err != nil {
+		s.logger.WarnContext(ctx, "failed to update api key last use", "api_key_id", key.ID, "error", err)
+	}
+
+	return key, nil
+}
+
+func parseAPIKeyPrefix(rawKey string) (string, bool) {
+	parts := strings.Split(rawKey, "_")
+	if len(parts) != 3 || parts[0] != apiKeyTag {
+		return "", false
+	}
+	if len(parts[1]) != apiKeyPrefixBytes*2 || len(parts[2]) != apiKeySecretBytes*2 {
+		return "", false
+	}
+	return parts[1], true
+}
+
+func hashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+// MockAPIKeyRepository is a mock implementation of the APIKeyRepository interface
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) GetAPIKeyByID(ctx context.Context, id string) (*domain.APIKey, error) {
+	args := m.Called(ctx, id)
+	key, _ := args.Get(0).(*domain.APIKey)
+	return key, args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	args := m.Called(ctx, prefix)
+	key, _ := args.Get(0).(*domain.APIKey)
+	return key, args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) ListAPIKeys(ctx context.Context) ([]*domain.APIKey, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) RevokeAPIKey(ctx context.Context, id string, revokedAt time.Time) error {
+	args := m.Called(ctx, id, revokedAt)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) UpdateLastUsed(ctx context.Context, id string, usedAt time.Time) error {
+	args := m.Called(ctx, id, usedAt)
+	return args.Error(0)
+}
+
+func TestCreateAndAuthenticateAPIKey(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	service := NewAPIKeyService(mockRepo)
+
+	var stored *domain.APIKey
+	mockRepo.On("CreateAPIKey", mock.Anything, mock.AnythingOfType("*domain.APIKey")).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*domain.APIKey) }).
+		Return(nil)
+
+	key, rawKey, err := service.CreateAPIKey(context.Background(), "matching", []string{domain.ScopeLocationsRead})
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, rawKey)
+	assert.NotContains(t, key.Hash, rawKey)
+	assert.Contains(t, rawKey, key.Prefix)
+
+	mockRepo.On("GetAPIKeyByPrefix", mock.Anything, key.Prefix).Return(stored, nil)
+	mockRepo.On("UpdateLastUsed", mock.Anything, key.ID, mock.Anything).Return(nil)
+
+	authenticated, err := service.Authenticate(context.Background(), rawKey)
+
+	assert.NoError(t, err)
+	assert.Equal(t, key.ID, authenticated.ID)
+	assert.True(t, authenticated.HasScope(domain.ScopeLocationsRead))
+	assert.False(t, authenticated.HasScope(domain.ScopeLocationsWrite))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateAPIKeyInvalidScope(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	service := NewAPIKeyService(mockRepo)
+
+	_, _, err := service.CreateAPIKey(context.Background(), "matching", []string{"locations:delete"})
+	assert.Equal(t, ErrInvalidScope, err)
+
+	_, _, err = service.CreateAPIKey(context.Background(), "matching", nil)
+	assert.Equal(t, ErrNoScopes, err)
+
+	mockRepo.AssertNotCalled(t, "CreateAPIKey", mock.Anything, mock.Anything)
+}
+
+func TestAuthenticateRejectsWrongSecret(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	service := NewAPIKeyService(mockRepo)
+
+	prefix := "0123abcd"
+	stored := &domain.APIKey{
+		ID:     "1",
+		Prefix: prefix,
+		Hash:   hashAPIKey("bk_" + prefix + "_" + strings.Repeat("a", 64)),
+	}
+	mockRepo.On("GetAPIKeyByPrefix", mock.Anything, prefix).Return(stored, nil)
+
+	_, err := service.Authenticate(context.Background(), "bk_"+prefix+"_"+strings.Repeat("b", 64))
+
+	assert.Equal(t, ErrInvalidAPIKey, err)
+	mockRepo.AssertNotCalled(t, "UpdateLastUsed", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthenticateRejectsRevokedKey(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	service := NewAPIKeyService(mockRepo)
+
+	prefix := "0123abcd"
+	rawKey := "bk_" + prefix + "_" + strings.Repeat("a", 64)
+	revokedAt := time.Now()
+	stored := &domain.APIKey{
+		ID:        "1",
+		Prefix:    prefix,
+		Hash:      hashAPIKey(rawKey),
+		RevokedAt: &revokedAt,
+	}
+	mockRepo.On("GetAPIKeyByPrefix", mock.Anything, prefix).Return(stored, nil)
+
+	_, err := service.Authenticate(context.Background(), rawKey)
+
+	assert.Equal(t, ErrInvalidAPIKey, err)
+}
+
+func TestAuthenticateRejectsMalformedKey(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	service := NewAPIKeyService(mockRepo)
+
+	for _, rawKey := range []string{"", "secret", "bk_short_key", "xx_0123abcd_" + strings.Repeat("a", 64)} {
+		_, err := service.Authenticate(context.Background(), rawKey)
+		assert.Equal(t, ErrInvalidAPIKey, err, rawKey)
+	}
+
+	mockRepo.AssertNotCalled(t, "GetAPIKeyByPrefix", mock.Anything, mock.Anything)
+}
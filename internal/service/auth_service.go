@@ -3,45 +3,126 @@ package service
 import (
 	"context"
 	"errors"
+	"log"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/mailer"
 	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository/memory"
 )
 
 // Custom errors
 var (
-	ErrUserAlreadyExists  = errors.New("user already exists")
-	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrInvalidToken       = errors.New("invalid token")
+	ErrUserAlreadyExists  = domain.NewError(domain.ErrConflict, "user_already_exists", "user already exists")
+	ErrInvalidCredentials = domain.NewError(domain.ErrUnauthorized, "invalid_credentials", "invalid credentials")
+	ErrInvalidToken       = domain.NewError(domain.ErrUnauthorized, "invalid_token", "invalid token")
+	ErrUserNotFound       = domain.NewError(domain.ErrNotFound, "user_not_found", "user not found")
+	ErrEmailInUse         = domain.NewError(domain.ErrConflict, "email_in_use", "email address is already in use")
 )
 
 type AuthService interface {
 	Register(ctx context.Context, creds domain.UserCredentials) (*domain.User, error)
-	Login(ctx context.Context, username, password string) (string, error)
+	Login(ctx context.Context, username, password, clientIP string) (string, error)
 	ValidateToken(token string) (*Claims, error)
+	UnlockUser(ctx context.Context, username string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, email string) error
+	SendVerification(ctx context.Context, user *domain.User) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	CheckPassword(ctx context.Context, user *domain.User, password string) error
 }
 
 type Claims struct {
 	UserID        string `json:"user_id"`
 	Username      string `json:"username"`
+	Role          string `json:"role"`
 	Authenticated bool   `json:"authenticated"`
 	jwt.RegisteredClaims
 }
 
 type authService struct {
-	userRepo repository.UserRepository
-	jwtKey   []byte
+	userRepo    repository.UserRepository
+	attemptRepo repository.LoginAttemptRepository
+	policy      LockoutPolicy
+	tokenRepo   repository.UserTokenRepository
+	mailer      mailer.Mailer
+	appBaseURL  string
+	jwtKey      []byte
+	logger      *slog.Logger
 }
 
-func NewAuthService(userRepo repository.UserRepository, jwtSecret string) AuthService {
-	return &authService{
-		userRepo: userRepo,
-		jwtKey:   []byte(jwtSecret),
+// AuthOption configures the auth service
+type AuthOption func(*authService)
+
+// WithLoginAttemptRepository sets where failed login state is kept.
+// An in-memory repository is used by default.
+func WithLoginAttemptRepository(repo repository.LoginAttemptRepository) AuthOption {
+	return func(s *authService) {
+		s.attemptRepo = repo
+	}
+}
+
+// WithLockoutPolicy sets the backoff and lockout applied to failed logins
+func WithLockoutPolicy(policy LockoutPolicy) AuthOption {
+	return func(s *authService) {
+		s.policy = policy
+	}
+}
+
+// WithUserTokenRepository sets where redeemed verification and reset tokens
+// are recorded. An in-memory repository is used by default.
+func WithUserTokenRepository(repo repository.UserTokenRepository) AuthOption {
+	return func(s *authService) {
+		s.tokenRepo = repo
+	}
+}
+
+// WithMailer sets the mailer used for verification and reset emails.
+// Emails are written to the standard logger by default.
+func WithMailer(m mailer.Mailer) AuthOption {
+	return func(s *authService) {
+		s.mailer = m
+	}
+}
+
+// WithAppBaseURL sets the base URL used for links in emails
+func WithAppBaseURL(url string) AuthOption {
+	return func(s *authService) {
+		s.appBaseURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithAuthLogger sets the logger of the auth service
+func WithAuthLogger(logger *slog.Logger) AuthOption {
+	return func(s *authService) {
+		s.logger = logger
+	}
+}
+
+func NewAuthService(userRepo repository.UserRepository, jwtSecret string, options ...AuthOption) AuthService {
+	s := &authService{
+		userRepo:    userRepo,
+		attemptRepo: memory.NewLoginAttemptRepository(),
+		policy:      DefaultLockoutPolicy(),
+		tokenRepo:   memory.NewUserTokenRepository(),
+		mailer:      mailer.NewLogMailer(log.Writer()),
+		appBaseURL:  "http://localhost:8080",
+		jwtKey:      []byte(jwtSecret),
+		logger:      slog.Default(),
+	}
+
+	for _, option := range options {
+		option(s)
 	}
+
+	return s
 }
 
 func (s *authService) Register(ctx context.Context, creds domain.UserCredentials) (*domain.User, error) {
@@ -54,6 +135,16 @@ func (s *authService) Register(ctx context.Context, creds domain.UserCredentials
 		return nil, ErrUserAlreadyExists
 	}
 
+	// Email addresses identify accounts for verification and password
+	// resets, so they must be unique
+	emailOwner, err := s.userRepo.GetUserByEmail(ctx, creds.Email)
+	if err != nil {
+		return nil, err
+	}
+	if emailOwner != nil {
+		return nil, ErrEmailInUse
+	}
+
 	// Create new user
 	user := domain.NewUser(creds)
 	if err := user.HashPassword(); err != nil {
@@ -61,28 +152,67 @@ func (s *authService) Register(ctx context.Context, creds domain.UserCredentials
 	}
 
 	if err := s.userRepo.CreateUser(ctx, user); err != nil {
+		// Lost a race with a concurrent registration of the same username or email
+		if errors.Is(err, repository.ErrDuplicateEmail) {
+			return nil, ErrEmailInUse
+		}
+		if errors.Is(err, domain.ErrConflict) {
+			return nil, ErrUserAlreadyExists
+		}
+		s.logger.ErrorContext(ctx, "failed to create user", "username", user.Username, "error", err)
 		return nil, err
 	}
 
+	// The user can ask for a new email, so a delivery failure does not fail registration
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		s.logger.WarnContext(ctx, "failed to send verification email", "username", user.Username, "error", err)
+	}
+
 	return user, nil
 }
 
-func (s *authService) Login(ctx context.Context, username, password string) (string, error) {
+func (s *authService) Login(ctx context.Context, username, password, clientIP string) (string, error) {
+	now := time.Now()
+
+	// Refuse attempts while backing off or locked out
+	if err := s.beginLogin(ctx, username, clientIP, now); err != nil {
+		var blocked *LoginBlockedError
+		if !errors.As(err, &blocked) {
+			s.logger.ErrorContext(ctx, "failed to check login attempts", "username", username, "error", err)
+		}
+		return "", err
+	}
+
 	user, err := s.userRepo.GetUserByUsername(ctx, username)
 	if err != nil {
+		s.endLogin(ctx, username, clientIP)
+		s.logger.ErrorContext(ctx, "failed to get user", "username", username, "error", err)
 		return "", err
 	}
-	if user == nil {
-		return "", ErrInvalidCredentials
-	}
 
 	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+	if user == nil || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		if err := s.recordLoginFailure(ctx, username, clientIP, now); err != nil {
+			s.logger.ErrorContext(ctx, "failed to record login failure", "username", username, "error", err)
+			return "", err
+		}
 		return "", ErrInvalidCredentials
 	}
 
+	// The client IP keeps its failures, the username starts over
+	if err := s.attemptRepo.ResetLoginAttempts(ctx, userAttemptKey(username)); err != nil {
+		s.endAttempt(ctx, ipAttemptKey(clientIP))
+		return "", err
+	}
+	s.endAttempt(ctx, ipAttemptKey(clientIP))
+
+	if user.Status == domain.UserStatusPending {
+		return "", ErrEmailNotVerified
+	}
+
 	// Update last login
 	if err := s.userRepo.UpdateLastLogin(ctx, user.ID); err != nil {
+		s.logger.ErrorContext(ctx, "failed to update last login", "username", username, "error", err)
 		return "", err
 	}
 
@@ -90,6 +220,7 @@ func (s *authService) Login(ctx context.Context, username, password string) (str
 	claims := &Claims{
 		UserID:        user.ID,
 		Username:      user.Username,
+		Role:          user.Role,
 		Authenticated: true,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
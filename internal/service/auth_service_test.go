@@ -2,7 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
 	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -10,6 +13,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/mailer"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
 )
 
 // MockUserRepository is a mock implementation of the UserRepository interface
@@ -19,7 +24,8 @@ type MockUserRepository struct {
 
 func (m *MockUserRepository) GetUserByUsername(ctx context.Context, username string) (*domain.User, error) {
 	args := m.Called(ctx, username)
-	return args.Get(0).(*domain.User), args.Error(1)
+	user, _ := args.Get(0).(*domain.User)
+	return user, args.Error(1)
 }
 
 func (m *MockUserRepository) CreateUser(ctx context.Context, user *domain.User) error {
@@ -32,16 +38,78 @@ func (m *MockUserRepository) UpdateLastLogin(ctx context.Context, userID string)
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) UpdateStatus(ctx context.Context, username, status string) error {
+	args := m.Called(ctx, username, status)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	args := m.Called(ctx, email)
+	user, _ := args.Get(0).(*domain.User)
+	return user, args.Error(1)
+}
+
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, username, passwordHash string) error {
+	args := m.Called(ctx, username, passwordHash)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) GetUserByID(ctx context.Context, id string) (*domain.User, error) {
+	args := m.Called(ctx, id)
+	user, _ := args.Get(0).(*domain.User)
+	return user, args.Error(1)
+}
+
+func (m *MockUserRepository) UpdateEmail(ctx context.Context, userID, email string) error {
+	args := m.Called(ctx, userID, email)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) DeleteUser(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) ListUsers(ctx context.Context, filter domain.UserFilter) ([]*domain.User, int64, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*domain.User), args.Get(1).(int64), args.Error(2)
+}
+
+// MockMailer records the messages it is asked to send
+type MockMailer struct {
+	messages []mailer.Message
+	err      error
+}
+
+func (m *MockMailer) Send(ctx context.Context, msg mailer.Message) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// tokenFromMessage extracts the token query parameter from an email body
+func tokenFromMessage(t *testing.T, msg mailer.Message) string {
+	_, token, found := strings.Cut(msg.Body, "token=")
+	assert.True(t, found)
+	token, _, _ = strings.Cut(token, "\n")
+	return token
+}
+
 func TestRegister(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewAuthService(mockRepo, "test-secret")
+	mockMailer := new(MockMailer)
+	service := NewAuthService(mockRepo, "test-secret", WithMailer(mockMailer))
 
 	creds := domain.UserCredentials{
 		Username: "testuser",
 		Password: "password",
+		Email:    "test@example.com",
 	}
 
 	mockRepo.On("GetUserByUsername", mock.Anything, creds.Username).Return(nil, nil)
+	mockRepo.On("GetUserByEmail", mock.Anything, creds.Email).Return(nil, nil)
 	mockRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*domain.User")).Return(nil)
 
 	user, err := service.Register(context.Background(), creds)
@@ -49,6 +117,9 @@ func TestRegister(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 	assert.Equal(t, creds.Username, user.Username)
+	assert.Equal(t, domain.UserStatusPending, user.Status)
+	assert.Len(t, mockMailer.messages, 1)
+	assert.Equal(t, creds.Email, mockMailer.messages[0].To)
 	mockRepo.AssertExpectations(t)
 }
 
@@ -66,7 +137,7 @@ func TestLogin(t *testing.T) {
 	mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
 	mockRepo.On("UpdateLastLogin", mock.Anything, user.ID).Return(nil)
 
-	token, err := service.Login(context.Background(), user.Username, "password")
+	token, err := service.Login(context.Background(), user.Username, "password", "127.0.0.1")
 
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
@@ -99,3 +170,400 @@ func TestValidateToken(t *testing.T) {
 	assert.Equal(t, claims.Username, validatedClaims.Username)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestLoginBacksOffAfterFailure(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewAuthService(mockRepo, "test-secret")
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	user := &domain.User{
+		ID:       "1",
+		Username: "testuser",
+		Password: string(hashedPassword),
+		Status:   domain.UserStatusActive,
+	}
+
+	mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+
+	_, err := service.Login(context.Background(), user.Username, "wrong", "127.0.0.1")
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	// The correct password is refused until the backoff has passed
+	_, err = service.Login(context.Background(), user.Username, "password", "127.0.0.1")
+	var blocked *LoginBlockedError
+	assert.ErrorAs(t, err, &blocked)
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+	assert.True(t, blocked.RetryAfter > 0)
+}
+
+func TestLoginLocksAccount(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	policy := LockoutPolicy{MaxFailures: 3, MaxIPFailures: 10, Cooldown: 50 * time.Millisecond}
+	service := NewAuthService(mockRepo, "test-secret", WithLockoutPolicy(policy))
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	user := &domain.User{
+		ID:       "1",
+		Username: "testuser",
+		Password: string(hashedPassword),
+		Status:   domain.UserStatusActive,
+	}
+
+	mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+
+	for i := 0; i < policy.MaxFailures; i++ {
+		_, err := service.Login(context.Background(), user.Username, "wrong", "127.0.0.1")
+		assert.Equal(t, ErrInvalidCredentials, err)
+	}
+
+	_, err := service.Login(context.Background(), user.Username, "password", "127.0.0.1")
+	assert.ErrorIs(t, err, ErrAccountLocked)
+
+	// The account unlocks itself once the cooldown has passed
+	time.Sleep(policy.Cooldown)
+	mockRepo.On("UpdateLastLogin", mock.Anything, user.ID).Return(nil)
+
+	token, err := service.Login(context.Background(), user.Username, "password", "127.0.0.1")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLoginLocksClientIP(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	policy := LockoutPolicy{MaxFailures: 10, MaxIPFailures: 2, Cooldown: time.Minute}
+	service := NewAuthService(mockRepo, "test-secret", WithLockoutPolicy(policy))
+
+	mockRepo.On("GetUserByUsername", mock.Anything, mock.Anything).Return(nil, nil)
+
+	_, err := service.Login(context.Background(), "alice", "wrong", "10.0.0.1")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = service.Login(context.Background(), "bob", "wrong", "10.0.0.1")
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	_, err = service.Login(context.Background(), "carol", "wrong", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+
+	// Other clients are not affected
+	_, err = service.Login(context.Background(), "carol", "wrong", "10.0.0.2")
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func TestUnlockUser(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	policy := LockoutPolicy{MaxFailures: 1, MaxIPFailures: 10, Cooldown: time.Hour}
+	service := NewAuthService(mockRepo, "test-secret", WithLockoutPolicy(policy))
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	user := &domain.User{
+		ID:       "1",
+		Username: "testuser",
+		Password: string(hashedPassword),
+		Status:   domain.UserStatusActive,
+	}
+
+	mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+	mockRepo.On("UpdateLastLogin", mock.Anything, user.ID).Return(nil)
+
+	_, err := service.Login(context.Background(), user.Username, "wrong", "127.0.0.1")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = service.Login(context.Background(), user.Username, "password", "127.0.0.1")
+	assert.ErrorIs(t, err, ErrAccountLocked)
+
+	assert.NoError(t, service.UnlockUser(context.Background(), user.Username))
+
+	_, err = service.Login(context.Background(), user.Username, "password", "127.0.0.1")
+	assert.NoError(t, err)
+	mockRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLockoutKeepsPendingUserPending(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	policy := LockoutPolicy{MaxFailures: 2, MaxIPFailures: 10, Cooldown: 50 * time.Millisecond}
+	service := NewAuthService(mockRepo, "test-secret", WithLockoutPolicy(policy))
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	user := &domain.User{
+		ID:       "1",
+		Username: "testuser",
+		Password: string(hashedPassword),
+		Status:   domain.UserStatusPending,
+	}
+
+	mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+
+	lock := func() {
+		for i := 0; i < policy.MaxFailures; i++ {
+			_, err := service.Login(context.Background(), user.Username, "wrong", "127.0.0.1")
+			assert.Equal(t, ErrInvalidCredentials, err)
+		}
+		_, err := service.Login(context.Background(), user.Username, "password", "127.0.0.1")
+		assert.ErrorIs(t, err, ErrAccountLocked)
+	}
+
+	// Unlocked by an admin
+	lock()
+	assert.NoError(t, service.UnlockUser(context.Background(), user.Username))
+	_, err := service.Login(context.Background(), user.Username, "password", "127.0.0.1")
+	assert.Equal(t, ErrEmailNotVerified, err)
+
+	// Unlocked by the cooldown
+	lock()
+	time.Sleep(policy.Cooldown)
+	_, err = service.Login(context.Background(), user.Username, "password", "127.0.0.1")
+	assert.Equal(t, ErrEmailNotVerified, err)
+
+	assert.Equal(t, domain.UserStatusPending, user.Status)
+	mockRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpdateLastLogin", mock.Anything, mock.Anything)
+}
+
+func TestLoginLimitsConcurrentAttempts(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	// Without backoff only the failure limit holds concurrent logins back
+	policy := LockoutPolicy{MaxFailures: 3, MaxIPFailures: 100, Cooldown: time.Minute}
+	service := NewAuthService(mockRepo, "test-secret", WithLockoutPolicy(policy))
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	user := &domain.User{
+		ID:       "1",
+		Username: "testuser",
+		Password: string(hashedPassword),
+		Status:   domain.UserStatusActive,
+	}
+	mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+
+	const logins = 20
+	errs := make(chan error, logins)
+	var wg sync.WaitGroup
+	for i := 0; i < logins; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.Login(context.Background(), user.Username, "wrong", "127.0.0.1")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	checked := 0
+	for err := range errs {
+		if errors.Is(err, ErrInvalidCredentials) {
+			checked++
+			continue
+		}
+		var blocked *LoginBlockedError
+		assert.ErrorAs(t, err, &blocked)
+	}
+	assert.Equal(t, policy.MaxFailures, checked)
+
+	_, err := service.Login(context.Background(), user.Username, "password", "127.0.0.1")
+	assert.ErrorIs(t, err, ErrAccountLocked)
+}
+
+func TestLockoutPolicyBackoff(t *testing.T) {
+	policy := LockoutPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	assert.Equal(t, time.Duration(0), policy.backoff(0))
+	assert.Equal(t, time.Second, policy.backoff(1))
+	assert.Equal(t, 2*time.Second, policy.backoff(2))
+	assert.Equal(t, 4*time.Second, policy.backoff(3))
+	assert.Equal(t, 5*time.Second, policy.backoff(4))
+	assert.Equal(t, 5*time.Second, policy.backoff(50))
+}
+
+func TestLoginRequiresVerifiedEmail(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewAuthService(mockRepo, "test-secret")
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	user := &domain.User{
+		ID:       "1",
+		Username: "testuser",
+		Password: string(hashedPassword),
+		Status:   domain.UserStatusPending,
+	}
+
+	mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+
+	_, err := service.Login(context.Background(), user.Username, "password", "127.0.0.1")
+
+	assert.Equal(t, ErrEmailNotVerified, err)
+	mockRepo.AssertNotCalled(t, "UpdateLastLogin", mock.Anything, mock.Anything)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockMailer := new(MockMailer)
+	service := NewAuthService(mockRepo, "test-secret", WithMailer(mockMailer))
+
+	user := &domain.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Status:   domain.UserStatusPending,
+	}
+
+	mockRepo.On("GetUserByEmail", mock.Anything, user.Email).Return(user, nil)
+	mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+	mockRepo.On("UpdateStatus", mock.Anything, user.Username, domain.UserStatusActive).Return(nil).Once()
+
+	assert.NoError(t, service.ResendVerification(context.Background(), user.Email))
+	assert.Len(t, mockMailer.messages, 1)
+	token := tokenFromMessage(t, mockMailer.messages[0])
+
+	assert.NoError(t, service.VerifyEmail(context.Background(), token))
+
+	// Tokens are single use
+	assert.Equal(t, ErrInvalidUserToken, service.VerifyEmail(context.Background(), token))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRegisterEmailInUse(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewAuthService(mockRepo, "test-secret")
+
+	creds := domain.UserCredentials{
+		Username: "testuser",
+		Password: "password",
+		Email:    "taken@example.com",
+	}
+
+	mockRepo.On("GetUserByUsername", mock.Anything, creds.Username).Return(nil, nil)
+	mockRepo.On("GetUserByEmail", mock.Anything, creds.Email).Return(&domain.User{ID: "other", Email: creds.Email}, nil)
+
+	_, err := service.Register(context.Background(), creds)
+
+	assert.Equal(t, ErrEmailInUse, err)
+	mockRepo.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+}
+
+func TestRegisterEmailTakenConcurrently(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewAuthService(mockRepo, "test-secret")
+
+	creds := domain.UserCredentials{
+		Username: "testuser",
+		Password: "password",
+		Email:    "taken@example.com",
+	}
+
+	mockRepo.On("GetUserByUsername", mock.Anything, creds.Username).Return(nil, nil)
+	mockRepo.On("GetUserByEmail", mock.Anything, creds.Email).Return(nil, nil)
+	mockRepo.On("CreateUser", mock.Anything, mock.Anything).Return(repository.ErrDuplicateEmail)
+
+	_, err := service.Register(context.Background(), creds)
+
+	assert.Equal(t, ErrEmailInUse, err)
+}
+
+func TestResendVerificationDoesNotRevealAccounts(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockMailer := new(MockMailer)
+	service := NewAuthService(mockRepo, "test-secret", WithMailer(mockMailer))
+
+	user := &domain.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Status:   domain.UserStatusActive,
+	}
+	mockRepo.On("GetUserByEmail", mock.Anything, user.Email).Return(user, nil)
+	mockRepo.On("GetUserByEmail", mock.Anything, "unknown@example.com").Return(nil, nil)
+
+	assert.NoError(t, service.ResendVerification(context.Background(), user.Email))
+	assert.NoError(t, service.ResendVerification(context.Background(), "unknown@example.com"))
+	assert.Empty(t, mockMailer.messages)
+}
+
+func TestVerifyEmailRejectsOtherTokens(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewAuthService(mockRepo, "test-secret")
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	user := &domain.User{
+		ID:       "1",
+		Username: "testuser",
+		Password: string(hashedPassword),
+		Status:   domain.UserStatusActive,
+	}
+	mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+	mockRepo.On("UpdateLastLogin", mock.Anything, user.ID).Return(nil)
+
+	// An access token cannot be used as a verification token
+	accessToken, err := service.Login(context.Background(), user.Username, "password", "127.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, ErrInvalidUserToken, service.VerifyEmail(context.Background(), accessToken))
+
+	// Neither can a password reset token
+	resetToken, err := service.(*authService).issueUserToken(user, tokenPurposeResetPassword, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, ErrInvalidUserToken, service.VerifyEmail(context.Background(), resetToken))
+
+	// Or an expired one
+	expiredToken, err := service.(*authService).issueUserToken(user, tokenPurposeVerifyEmail, -time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, ErrInvalidUserToken, service.VerifyEmail(context.Background(), expiredToken))
+}
+
+func TestResetPassword(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockMailer := new(MockMailer)
+	service := NewAuthService(mockRepo, "test-secret", WithMailer(mockMailer))
+
+	user := &domain.User{
+		Username: "testuser",
+		Email:    "test@example.com",
+		Status:   domain.UserStatusActive,
+	}
+
+	mockRepo.On("GetUserByEmail", mock.Anything, user.Email).Return(user, nil)
+	mockRepo.On("GetUserByEmail", mock.Anything, "unknown@example.com").Return(nil, nil)
+	mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+	mockRepo.On("UpdatePassword", mock.Anything, user.Username, mock.MatchedBy(func(hash string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte("new-password")) == nil
+	})).Return(nil).Once()
+
+	// Unknown addresses are silently ignored
+	assert.NoError(t, service.RequestPasswordReset(context.Background(), "unknown@example.com"))
+	assert.Len(t, mockMailer.messages, 0)
+
+	assert.NoError(t, service.RequestPasswordReset(context.Background(), user.Email))
+	assert.Len(t, mockMailer.messages, 1)
+	token := tokenFromMessage(t, mockMailer.messages[0])
+
+	assert.NoError(t, service.ResetPassword(context.Background(), token, "new-password"))
+	assert.Equal(t, ErrInvalidUserToken, service.ResetPassword(context.Background(), token, "new-password"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUserTokensAreBoundToTheUser(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockMailer := new(MockMailer)
+	service := NewAuthService(mockRepo, "test-secret", WithMailer(mockMailer))
+
+	user := &domain.User{
+		Username: "testuser",
+		Email:    "old@example.com",
+		Password: "old-hash",
+		Status:   domain.UserStatusPending,
+	}
+	mockRepo.On("GetUserByEmail", mock.Anything, user.Email).Return(user, nil)
+	mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+
+	assert.NoError(t, service.ResendVerification(context.Background(), user.Email))
+	assert.NoError(t, service.RequestPasswordReset(context.Background(), user.Email))
+	assert.Len(t, mockMailer.messages, 2)
+	verifyToken := tokenFromMessage(t, mockMailer.messages[0])
+	resetToken := tokenFromMessage(t, mockMailer.messages[1])
+
+	// A verification token does not verify another address
+	user.Email = "new@example.com"
+	assert.Equal(t, ErrInvalidUserToken, service.VerifyEmail(context.Background(), verifyToken))
+
+	// A reset token is void once the password has changed
+	user.Password = "new-hash"
+	assert.Equal(t, ErrInvalidUserToken, service.ResetPassword(context.Background(), resetToken, "password"))
+
+	mockRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -2,53 +2,154 @@ package service
 
 import (
 	"context"
-	"errors"
+	"log/slog"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/yusufatac/bitaksi-case-study/internal/domain"
 	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+	"github.com/yusufatac/bitaksi-case-study/internal/tracing"
 )
 
 type LocationService interface {
 	UpdateDriverLocation(ctx context.Context, driverID string, lat, lon float64) error
 	UpdateDriverLocations(ctx context.Context, locations []domain.DriverLocation) error
 	FindNearbyDrivers(ctx context.Context, lat, lon, radius float64) ([]*domain.DriverLocation, error)
+	CountActiveDrivers(ctx context.Context) (int64, error)
+}
+
+// LocationPublisher is told about every saved driver location, e.g. to push
+// it to riders tracking the driver
+type LocationPublisher interface {
+	PublishLocation(location domain.DriverLocation)
 }
 
+// noopPublisher is used when no publisher is configured
+type noopPublisher struct{}
+
+func (noopPublisher) PublishLocation(domain.DriverLocation) {}
+
+// defaultActiveDriverWindow is how recently a driver must have reported a
+// location to count as active
+const defaultActiveDriverWindow = 5 * time.Minute
+
 type locationService struct {
-	repo repository.LocationRepository
+	repo         repository.LocationRepository
+	logger       *slog.Logger
+	metrics      LocationMetrics
+	publisher    LocationPublisher
+	activeWindow time.Duration
+}
+
+// LocationOption configures the location service
+type LocationOption func(*locationService)
+
+// WithLocationLogger sets the logger of the location service
+func WithLocationLogger(logger *slog.Logger) LocationOption {
+	return func(s *locationService) {
+		s.logger = logger
+	}
+}
+
+// WithLocationMetrics sets where the location service records its metrics
+func WithLocationMetrics(metrics LocationMetrics) LocationOption {
+	return func(s *locationService) {
+		s.metrics = metrics
+	}
+}
+
+// WithLocationPublisher sets where saved locations are published
+func WithLocationPublisher(publisher LocationPublisher) LocationOption {
+	return func(s *locationService) {
+		s.publisher = publisher
+	}
+}
+
+// WithActiveDriverWindow sets how recently a driver must have reported a
+// location to count as active
+func WithActiveDriverWindow(window time.Duration) LocationOption {
+	return func(s *locationService) {
+		s.activeWindow = window
+	}
 }
 
-func NewLocationService(repo repository.LocationRepository) LocationService {
-	return &locationService{
-		repo: repo,
+func NewLocationService(repo repository.LocationRepository, options ...LocationOption) LocationService {
+	s := &locationService{
+		repo:         repo,
+		logger:       slog.Default(),
+		metrics:      noopMetrics{},
+		publisher:    noopPublisher{},
+		activeWindow: defaultActiveDriverWindow,
 	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
 }
 
-func (s *locationService) UpdateDriverLocation(ctx context.Context, driverID string, lat, lon float64) error {
+// defaultDriverStatus is the status of location updates that do not set one
+const defaultDriverStatus = "active"
+
+func (s *locationService) UpdateDriverLocation(ctx context.Context, driverID string, lat, lon float64) (err error) {
+	ctx, span := tracing.Start(ctx, "LocationService.UpdateDriverLocation",
+		trace.WithAttributes(attribute.String("driver.id", driverID)))
+	defer func() { tracing.End(span, err) }()
+
 	location := &domain.DriverLocation{
 		DriverID:  driverID,
 		Location:  domain.NewPoint(lat, lon),
-		Status:    "active",
+		Status:    defaultDriverStatus,
 		Timestamp: time.Now(),
 	}
 
-	return s.repo.SaveLocation(ctx, location)
+	if err := s.repo.SaveLocation(ctx, location); err != nil {
+		s.logger.ErrorContext(ctx, "failed to save driver location", "driver_id", driverID, "error", err)
+		return err
+	}
+	s.publisher.PublishLocation(*location)
+
+	return nil
 }
 
-func (s *locationService) UpdateDriverLocations(ctx context.Context, locations []domain.DriverLocation) error {
-	// Convert to pointer slice and set timestamps
+func (s *locationService) UpdateDriverLocations(ctx context.Context, locations []domain.DriverLocation) (err error) {
+	ctx, span := tracing.Start(ctx, "LocationService.UpdateDriverLocations",
+		trace.WithAttributes(attribute.Int("batch.size", len(locations))))
+	defer func() { tracing.End(span, err) }()
+
+	s.metrics.ObserveBatchSize(len(locations))
+
+	// Convert to pointer slice and set timestamps. Updates without a status,
+	// from JSON or binary batches alike, are active.
 	now := time.Now()
 	locationPtrs := make([]*domain.DriverLocation, len(locations))
 	for i := range locations {
+		if locations[i].Status == "" {
+			locations[i].Status = defaultDriverStatus
+		}
 		locations[i].Timestamp = now
 		locationPtrs[i] = &locations[i]
 	}
 
-	return s.repo.SaveLocations(ctx, locationPtrs)
+	if err := s.repo.SaveLocations(ctx, locationPtrs); err != nil {
+		s.logger.ErrorContext(ctx, "failed to save driver locations", "count", len(locationPtrs), "error", err)
+		return err
+	}
+	for _, location := range locationPtrs {
+		s.publisher.PublishLocation(*location)
+	}
+
+	return nil
 }
 
-func (s *locationService) FindNearbyDrivers(ctx context.Context, lat, lon, radius float64) ([]*domain.DriverLocation, error) {
+func (s *locationService) FindNearbyDrivers(ctx context.Context, lat, lon, radius float64) (drivers []*domain.DriverLocation, err error) {
+	ctx, span := tracing.Start(ctx, "LocationService.FindNearbyDrivers",
+		trace.WithAttributes(attribute.Float64("search.radius", radius)))
+	defer func() { tracing.End(span, err) }()
+
 	// Validate input
 	if lat < -90 || lat > 90 {
 		return nil, ErrInvalidLatitude
@@ -60,12 +161,33 @@ func (s *locationService) FindNearbyDrivers(ctx context.Context, lat, lon, radiu
 		return nil, ErrInvalidRadius
 	}
 
-	return s.repo.FindNearbyDrivers(ctx, lat, lon, radius)
+	start := time.Now()
+	drivers, err = s.repo.FindNearbyDrivers(ctx, lat, lon, radius)
+	s.metrics.ObserveFindNearby(time.Since(start))
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to find nearby drivers", "radius", radius, "error", err)
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("drivers.found", len(drivers)))
+	return drivers, nil
+}
+
+// CountActiveDrivers counts the active drivers that reported a location
+// within the active driver window
+func (s *locationService) CountActiveDrivers(ctx context.Context) (int64, error) {
+	count, err := s.repo.CountActiveDrivers(ctx, time.Now().Add(-s.activeWindow))
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to count active drivers", "error", err)
+		return 0, err
+	}
+
+	return count, nil
 }
 
 // Custom errors
 var (
-	ErrInvalidLatitude  = errors.New("latitude must be between -90 and 90")
-	ErrInvalidLongitude = errors.New("longitude must be between -180 and 180")
-	ErrInvalidRadius    = errors.New("radius must be greater than 0")
+	ErrInvalidLatitude  = domain.NewError(domain.ErrValidation, "invalid_coordinates", "latitude must be between -90 and 90")
+	ErrInvalidLongitude = domain.NewError(domain.ErrValidation, "invalid_coordinates", "longitude must be between -180 and 180")
+	ErrInvalidRadius    = domain.NewError(domain.ErrValidation, "invalid_radius", "radius must be greater than 0")
 )
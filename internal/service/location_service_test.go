@@ -30,20 +30,25 @@ func (m *MockLocationRepository) FindNearbyDrivers(ctx context.Context, lat, lon
 	return args.Get(0).([]*domain.DriverLocation), args.Error(1)
 }
 
+func (m *MockLocationRepository) CountActiveDrivers(ctx context.Context, since time.Time) (int64, error) {
+	args := m.Called(ctx, since)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func TestUpdateDriverLocation(t *testing.T) {
 	mockRepo := new(MockLocationRepository)
 	service := NewLocationService(mockRepo)
 
 	driverID := "driver1"
 	lat, lon := 40.7128, -74.0060
-	location := &domain.DriverLocation{
-		DriverID:  driverID,
-		Location:  domain.NewPoint(lat, lon),
-		Status:    "active",
-		Timestamp: time.Now(),
-	}
+	expected := domain.NewPoint(lat, lon)
 
-	mockRepo.On("SaveLocation", mock.Anything, location).Return(nil)
+	mockRepo.On("SaveLocation", mock.Anything, mock.MatchedBy(func(location *domain.DriverLocation) bool {
+		return location.DriverID == driverID &&
+			assert.ObjectsAreEqual(expected, location.Location) &&
+			location.Status == "active" &&
+			!location.Timestamp.IsZero()
+	})).Return(nil)
 
 	err := service.UpdateDriverLocation(context.Background(), driverID, lat, lon)
 
@@ -83,6 +88,25 @@ func TestUpdateDriverLocations(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestUpdateDriverLocationsDefaultsStatus(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := NewLocationService(mockRepo)
+
+	// As decoded from a binary batch, which has no default of its own
+	locations := []domain.DriverLocation{
+		{DriverID: "driver1", Location: domain.NewPoint(40.7128, -74.0060)},
+		{DriverID: "driver2", Location: domain.NewPoint(34.0522, -118.2437), Status: "busy"},
+	}
+	mockRepo.On("SaveLocations", mock.Anything, mock.Anything).Return(nil)
+
+	err := service.UpdateDriverLocations(context.Background(), locations)
+
+	assert.NoError(t, err)
+	saved := mockRepo.Calls[0].Arguments.Get(1).([]*domain.DriverLocation)
+	assert.Equal(t, "active", saved[0].Status)
+	assert.Equal(t, "busy", saved[1].Status)
+}
+
 func TestFindNearbyDrivers(t *testing.T) {
 	mockRepo := new(MockLocationRepository)
 	service := NewLocationService(mockRepo)
@@ -106,3 +130,36 @@ func TestFindNearbyDrivers(t *testing.T) {
 	assert.Equal(t, drivers, result)
 	mockRepo.AssertExpectations(t)
 }
+
+type recordingPublisher struct {
+	locations []domain.DriverLocation
+}
+
+func (p *recordingPublisher) PublishLocation(location domain.DriverLocation) {
+	p.locations = append(p.locations, location)
+}
+
+func TestLocationServicePublishesSavedLocations(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	publisher := &recordingPublisher{}
+	service := NewLocationService(mockRepo, WithLocationPublisher(publisher))
+
+	mockRepo.On("SaveLocation", mock.Anything, mock.Anything).Return(assert.AnError).Once()
+	assert.Error(t, service.UpdateDriverLocation(context.Background(), "driver1", 41, 29))
+	assert.Empty(t, publisher.locations, "failed saves are not published")
+
+	mockRepo.On("SaveLocation", mock.Anything, mock.Anything).Return(nil).Once()
+	mockRepo.On("SaveLocations", mock.Anything, mock.Anything).Return(nil).Once()
+	assert.NoError(t, service.UpdateDriverLocation(context.Background(), "driver1", 41, 29))
+	assert.NoError(t, service.UpdateDriverLocations(context.Background(), []domain.DriverLocation{
+		{DriverID: "driver2", Location: domain.NewPoint(42, 30)},
+		{DriverID: "driver3", Location: domain.NewPoint(43, 31)},
+	}))
+
+	if assert.Len(t, publisher.locations, 3) {
+		assert.Equal(t, "driver1", publisher.locations[0].DriverID)
+		assert.Equal(t, domain.NewPoint(41, 29), publisher.locations[0].Location)
+		assert.Equal(t, "driver3", publisher.locations[2].DriverID)
+	}
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+// Custom errors
+var (
+	ErrTooManyAttempts = domain.NewError(domain.ErrTooManyRequests, "too_many_attempts", "too many failed login attempts")
+	ErrAccountLocked   = domain.NewError(domain.ErrLocked, "account_locked", "account is locked")
+)
+
+// LoginBlockedError is returned when a login is refused before the password
+// is checked. It wraps ErrTooManyAttempts or ErrAccountLocked.
+type LoginBlockedError struct {
+	Reason     error
+	RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	return fmt.Sprintf("%v, retry after %s", e.Reason, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LoginBlockedError) Unwrap() error {
+	return e.Reason
+}
+
+// RetryDelay returns how long the client has to wait before logging in again
+func (e *LoginBlockedError) RetryDelay() time.Duration {
+	return e.RetryAfter
+}
+
+// LockoutPolicy controls the backoff and lockout applied to failed logins.
+// Every failure delays the next attempt by BaseDelay * 2^(failures-1), capped
+// at MaxDelay. Once a key reaches its failure limit it is locked for Cooldown.
+type LockoutPolicy struct {
+	MaxFailures   int
+	MaxIPFailures int
+	BaseDelay     time.Duration
+	MaxDelay      time.Duration
+	Cooldown      time.Duration
+}
+
+// DefaultLockoutPolicy returns the policy used when none is configured
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		MaxFailures:   5,
+		MaxIPFailures: 20,
+		BaseDelay:     time.Second,
+		MaxDelay:      30 * time.Second,
+		Cooldown:      15 * time.Minute,
+	}
+}
+
+// backoff returns the delay required after the given number of failures
+func (p LockoutPolicy) backoff(failures int) time.Duration {
+	if failures <= 0 || p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+func userAttemptKey(username string) string {
+	return "user:" + username
+}
+
+func ipAttemptKey(clientIP string) string {
+	return "ip:" + clientIP
+}
+
+// beginLogin counts a login against the username and the client IP before
+// the password is checked. Counting and checking is a single step, so of
+// many concurrent logins only as many as the failure limit allows go ahead.
+// Refused logins are not counted.
+func (s *authService) beginLogin(ctx context.Context, username, clientIP string, now time.Time) error {
+	userKey := userAttemptKey(username)
+	if err := s.beginAttempt(ctx, userKey, s.policy.MaxFailures, ErrAccountLocked, now); err != nil {
+		return err
+	}
+
+	if err := s.beginAttempt(ctx, ipAttemptKey(clientIP), s.policy.MaxIPFailures, ErrTooManyAttempts, now); err != nil {
+		s.endAttempt(ctx, userKey)
+		return err
+	}
+
+	return nil
+}
+
+// beginAttempt counts a login against a key and refuses it while the key is
+// locked, has as many logins in progress or failed as its limit, or is still
+// backing off. Keys whose lock has expired start over.
+func (s *authService) beginAttempt(ctx context.Context, key string, limit int, lockedReason error, now time.Time) error {
+	attempt, err := s.attemptRepo.BeginLoginAttempt(ctx, key, now)
+	if err != nil {
+		return err
+	}
+
+	if attempt.LockExpired(now) {
+		if err := s.attemptRepo.ResetExpiredLock(ctx, key, attempt.LockedUntil); err != nil {
+			return err
+		}
+		if attempt, err = s.attemptRepo.BeginLoginAttempt(ctx, key, now); err != nil {
+			return err
+		}
+	}
+
+	var blocked error
+	switch {
+	case attempt.IsLocked(now):
+		blocked = &LoginBlockedError{Reason: lockedReason, RetryAfter: attempt.LockedUntil.Sub(now)}
+	case limit > 0 && attempt.Attempts > limit:
+		// The logins in progress decide whether the key gets locked
+		blocked = &LoginBlockedError{Reason: ErrTooManyAttempts, RetryAfter: max(s.policy.backoff(attempt.Failures+1), time.Second)}
+	default:
+		if next := attempt.LastFailureAt.Add(s.policy.backoff(attempt.Failures)); next.After(now) {
+			blocked = &LoginBlockedError{Reason: ErrTooManyAttempts, RetryAfter: next.Sub(now)}
+		}
+	}
+
+	if blocked != nil {
+		s.endAttempt(ctx, key)
+		return blocked
+	}
+	return nil
+}
+
+// endAttempt stops counting a login that did not fail. Failing to do so
+// only counts the login as a failure, so errors are logged.
+func (s *authService) endAttempt(ctx context.Context, key string) {
+	if err := s.attemptRepo.EndLoginAttempt(ctx, key); err != nil {
+		s.logger.ErrorContext(ctx, "failed to end login attempt", "key", key, "error", err)
+	}
+}
+
+// endLogin stops counting a login that was neither refused nor failed
+func (s *authService) endLogin(ctx context.Context, username, clientIP string) {
+	s.endAttempt(ctx, userAttemptKey(username))
+	s.endAttempt(ctx, ipAttemptKey(clientIP))
+}
+
+// recordLoginFailure counts a failed login against the username and the
+// client IP, locking them once they reach their failure limit
+func (s *authService) recordLoginFailure(ctx context.Context, username, clientIP string, now time.Time) error {
+	if err := s.recordFailure(ctx, userAttemptKey(username), s.policy.MaxFailures, now); err != nil {
+		return err
+	}
+	return s.recordFailure(ctx, ipAttemptKey(clientIP), s.policy.MaxIPFailures, now)
+}
+
+// recordFailure counts a failure against a key, locking it once it reaches limit
+func (s *authService) recordFailure(ctx context.Context, key string, limit int, now time.Time) error {
+	attempt, err := s.attemptRepo.RecordFailedLogin(ctx, key, now)
+	if err != nil {
+		return err
+	}
+	if attempt.Failures >= limit {
+		return s.attemptRepo.LockLogin(ctx, key, now.Add(s.policy.Cooldown))
+	}
+	return nil
+}
+
+// CheckPassword checks the password of a signed-in user, e.g. before it is
+// changed. It counts against the failure limit of the username like a
+// login, so a stolen session cannot be used to guess the password.
+func (s *authService) CheckPassword(ctx context.Context, user *domain.User, password string) error {
+	now := time.Now()
+	key := userAttemptKey(user.Username)
+
+	if err := s.beginAttempt(ctx, key, s.policy.MaxFailures, ErrAccountLocked, now); err != nil {
+		return err
+	}
+
+	if err := user.ComparePassword(password); err != nil {
+		if err := s.recordFailure(ctx, key, s.policy.MaxFailures, now); err != nil {
+			s.logger.ErrorContext(ctx, "failed to record password check failure", "username", user.Username, "error", err)
+			return err
+		}
+		return ErrInvalidPassword
+	}
+
+	return s.attemptRepo.ResetLoginAttempts(ctx, key)
+}
+
+// UnlockUser clears the lockout of a user before the cooldown expires. The
+// lockout is kept with the failed logins, the user itself is not changed.
+func (s *authService) UnlockUser(ctx context.Context, username string) error {
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	return s.attemptRepo.ResetLoginAttempts(ctx, userAttemptKey(username))
+}
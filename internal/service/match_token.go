@@ -0,0 +1,91 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+// DefaultMatchTokenTTL is how long a rider has to book the driver they were
+// matched with
+const DefaultMatchTokenTTL = 5 * time.Minute
+
+// Custom errors
+var (
+	ErrInvalidMatchToken = domain.NewError(domain.ErrValidation, "invalid_match_token", "invalid or expired match token")
+)
+
+type matchTokenClaims struct {
+	DriverID  string  `json:"driver_id"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	jwt.RegisteredClaims
+}
+
+// MatchTokens signs the matches handed out by the matching API, so that a
+// ride can only be created with a driver the rider was actually matched with.
+// Both services derive the key from the shared JWT secret.
+type MatchTokens struct {
+	key []byte
+	ttl time.Duration
+}
+
+// NewMatchTokens creates match tokens that expire after ttl
+func NewMatchTokens(jwtSecret string, ttl time.Duration) *MatchTokens {
+	// A separate key keeps match tokens from being mistaken for access or
+	// user tokens
+	mac := hmac.New(sha256.New, []byte(jwtSecret))
+	mac.Write([]byte("match-token"))
+
+	return &MatchTokens{
+		key: mac.Sum(nil),
+		ttl: ttl,
+	}
+}
+
+// Issue signs the match of driver to a rider searching around lat, lon
+func (t *MatchTokens) Issue(riderID string, driver *domain.DriverLocation, lat, lon float64) (string, error) {
+	now := time.Now()
+	claims := &matchTokenClaims{
+		DriverID:  driver.DriverID,
+		Latitude:  lat,
+		Longitude: lon,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   riderID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(t.ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(t.key)
+}
+
+// Verify returns the match signed by a token issued to riderID
+func (t *MatchTokens) Verify(tokenString, riderID string) (*domain.Match, error) {
+	claims := &matchTokenClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return t.key, nil
+	})
+	if err != nil || !token.Valid || claims.ExpiresAt == nil || claims.ID == "" || claims.DriverID == "" || claims.Subject != riderID {
+		return nil, ErrInvalidMatchToken
+	}
+
+	return &domain.Match{
+		TokenID:   claims.ID,
+		RiderID:   claims.Subject,
+		DriverID:  claims.DriverID,
+		Pickup:    domain.NewPoint(claims.Latitude, claims.Longitude),
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
@@ -6,16 +6,35 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 	"github.com/umahmood/haversine"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/httpclient"
+	"github.com/yusufatac/bitaksi-case-study/internal/tracing"
 )
 
 var (
-	ErrNoDriversFound = errors.New("no drivers found within the specified radius")
+	ErrNoDriversFound             = domain.NewError(domain.ErrNotFound, "no_drivers_found", "no drivers found within the specified radius")
+	ErrLocationServiceUnavailable = domain.NewError(domain.ErrUnavailable, "location_service_unavailable", "driver location service is unavailable")
+)
+
+const (
+	// serviceTokenMargin is how long before its expiry the service account's
+	// token is replaced, so that it does not expire in flight
+	serviceTokenMargin = time.Minute
+	// serviceTokenTTL is how long a token without an expiry is reused
+	serviceTokenTTL = 5 * time.Minute
 )
 
 type MatchingService interface {
@@ -25,66 +44,132 @@ type MatchingService interface {
 
 type matchingService struct {
 	locationService LocationService
+	locationAPIURL  string
+	apiKey          string
+	httpClient      *httpclient.Client
+	logger          *slog.Logger
+	metrics         MatchingMetrics
+
+	// The token of the service account, reused until it expires. Logins
+	// count against the rate limit of the auth routes.
+	tokenMu      sync.Mutex
+	token        string
+	tokenExpires time.Time
 }
 
-func NewMatchingService(locationService LocationService) MatchingService {
-	return &matchingService{
-		locationService: locationService,
+// MatchingOption configures the matching service
+type MatchingOption func(*matchingService)
+
+// WithLocationAPIURL sets the base URL of the driver location API
+func WithLocationAPIURL(url string) MatchingOption {
+	return func(s *matchingService) {
+		s.locationAPIURL = strings.TrimSuffix(url, "/")
 	}
 }
 
-func (s *matchingService) FindNearestDriver(ctx context.Context, lat, lon, radius float64) (*domain.DriverLocation, error) {
-	// Step 1: Login to get the JWT token
-	loginURL := "http://driver-location-api:8080/api/v1/auth/login"
-	loginReqBody, _ := json.Marshal(map[string]string{
-		"username": "yusuf",
-		"password": "secret",
-	})
+// WithAPIKey makes the service authenticate against the driver location API
+// with an API key instead of logging in with a user account
+func WithAPIKey(apiKey string) MatchingOption {
+	return func(s *matchingService) {
+		s.apiKey = apiKey
+	}
+}
 
-	loginResp, err := http.Post(loginURL, "application/json", bytes.NewBuffer(loginReqBody))
-	if err != nil {
-		return nil, err
+// WithHTTPClient sets the client used to call the driver location API
+func WithHTTPClient(client *httpclient.Client) MatchingOption {
+	return func(s *matchingService) {
+		s.httpClient = client
 	}
-	defer loginResp.Body.Close()
+}
 
-	if loginResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to login, status code: %d", loginResp.StatusCode)
+// WithMatchingLogger sets the logger of the matching service
+func WithMatchingLogger(logger *slog.Logger) MatchingOption {
+	return func(s *matchingService) {
+		s.logger = logger
 	}
+}
 
-	var loginRespBody map[string]string
-	if err := json.NewDecoder(loginResp.Body).Decode(&loginRespBody); err != nil {
-		return nil, err
+// WithMatchingMetrics sets where the matching service records its metrics
+func WithMatchingMetrics(metrics MatchingMetrics) MatchingOption {
+	return func(s *matchingService) {
+		s.metrics = metrics
 	}
+}
 
-	token, ok := loginRespBody["token"]
-	if !ok {
-		return nil, errors.New("token not found in login response")
+func NewMatchingService(locationService LocationService, options ...MatchingOption) MatchingService {
+	s := &matchingService{
+		locationService: locationService,
+		locationAPIURL:  "http://driver-location-api:8080",
+		httpClient:      httpclient.New(),
+		logger:          slog.Default(),
+		metrics:         noopMetrics{},
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+func (s *matchingService) FindNearestDriver(ctx context.Context, lat, lon, radius float64) (driver *domain.DriverLocation, err error) {
+	ctx, span := tracing.Start(ctx, "MatchingService.FindNearestDriver",
+		trace.WithAttributes(attribute.Float64("search.radius", radius)))
+	defer func() { tracing.End(span, err) }()
+
+	start := time.Now()
+	driver, err = s.findNearestDriver(ctx, lat, lon, radius)
+
+	result := MatchResultMatched
+	switch {
+	case errors.Is(err, ErrNoDriversFound):
+		result = MatchResultNoDriver
+	case err != nil:
+		result = MatchResultError
+	}
+	s.metrics.ObserveFindNearest(time.Since(start), result)
+	span.SetAttributes(attribute.String("match.result", result))
+
+	return driver, err
+}
+
+func (s *matchingService) findNearestDriver(ctx context.Context, lat, lon, radius float64) (*domain.DriverLocation, error) {
+	// Step 1: Resolve credentials for the driver location API
+	authHeader, authValue, err := s.credentials(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	// Step 2: Use the token to get nearby drivers
-	url := "http://driver-location-api:8080/api/v1/locations/nearby"
+	// Step 2: Use the credentials to get nearby drivers
+	url := s.locationAPIURL + "/api/v1/locations/nearby"
 	reqBody, _ := json.Marshal(map[string]interface{}{
 		"latitude":  lat,
 		"longitude": lon,
 		"radius":    radius,
 	})
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqBody))
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(authHeader, authValue)
 	req.Header.Set("Content-Type", "application/json")
+	// The nearby search is read-only, so it is safe to retry
+	req.Header.Set(httpclient.IdempotencyKeyHeader, uuid.NewString())
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusUnauthorized && authHeader == "Authorization" {
+		// The token was revoked or its user changed, log in again next time
+		s.forgetToken(authValue)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get nearby drivers, status code: %d", resp.StatusCode)
+		s.logger.ErrorContext(ctx, "failed to get nearby drivers from the driver location API", "status", resp.StatusCode)
+		return nil, statusError("failed to get nearby drivers", resp.StatusCode)
 	}
 
 	body, _ := ioutil.ReadAll(resp.Body)
@@ -121,6 +206,112 @@ func (s *matchingService) FindNearestDriver(ctx context.Context, lat, lon, radiu
 	return driversWithDistance[0].driver, nil
 }
 
+// credentials returns the header used to authenticate against the driver
+// location API, logging in with the service account when no API key is set
+func (s *matchingService) credentials(ctx context.Context) (string, string, error) {
+	if s.apiKey != "" {
+		return "X-API-Key", s.apiKey, nil
+	}
+
+	// Concurrent matches wait for a single login
+	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
+
+	if s.token == "" || !time.Now().Before(s.tokenExpires) {
+		token, err := s.login(ctx)
+		if err != nil {
+			return "", "", err
+		}
+		s.token = token
+		s.tokenExpires = tokenExpiry(token).Add(-serviceTokenMargin)
+	}
+
+	return "Authorization", "Bearer " + s.token, nil
+}
+
+// login logs in to the driver location API with the service account
+func (s *matchingService) login(ctx context.Context) (string, error) {
+	loginURL := s.locationAPIURL + "/api/v1/auth/login"
+	loginReqBody, _ := json.Marshal(map[string]string{
+		"username": "yusuf",
+		"password": "secret",
+	})
+
+	loginReq, err := http.NewRequestWithContext(ctx, "POST", loginURL, bytes.NewBuffer(loginReqBody))
+	if err != nil {
+		return "", err
+	}
+	loginReq.Header.Set("Content-Type", "application/json")
+
+	loginResp, err := s.do(loginReq)
+	if err != nil {
+		return "", err
+	}
+	defer loginResp.Body.Close()
+
+	if loginResp.StatusCode != http.StatusOK {
+		s.logger.ErrorContext(ctx, "failed to log in to the driver location API", "status", loginResp.StatusCode)
+		return "", statusError("failed to login", loginResp.StatusCode)
+	}
+
+	var loginRespBody map[string]string
+	if err := json.NewDecoder(loginResp.Body).Decode(&loginRespBody); err != nil {
+		return "", err
+	}
+
+	token, ok := loginRespBody["token"]
+	if !ok {
+		return "", errors.New("token not found in login response")
+	}
+
+	return token, nil
+}
+
+// forgetToken drops the cached token of the service account unless it has
+// already been replaced
+func (s *matchingService) forgetToken(authValue string) {
+	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
+
+	if "Bearer "+s.token == authValue {
+		s.token = ""
+	}
+}
+
+// tokenExpiry reads the expiry of a token issued by the driver location
+// API. The signature is checked by the API, not here.
+func tokenExpiry(token string) time.Time {
+	var claims jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &claims); err == nil && claims.ExpiresAt != nil {
+		return claims.ExpiresAt.Time
+	}
+	return time.Now().Add(serviceTokenTTL + serviceTokenMargin)
+}
+
+// do sends a request to the driver location API. Failures to reach it,
+// including an open circuit, wrap ErrLocationServiceUnavailable unless the
+// request context is done.
+func (s *matchingService) do(req *http.Request) (*http.Response, error) {
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		s.logger.ErrorContext(req.Context(), "driver location API call failed", "path", req.URL.Path, "error", err)
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrLocationServiceUnavailable, err)
+	}
+	return resp, nil
+}
+
+// statusError describes an unexpected response of the driver location API.
+// Server errors wrap ErrLocationServiceUnavailable.
+func statusError(action string, statusCode int) error {
+	if statusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%w: %s, status code: %d", ErrLocationServiceUnavailable, action, statusCode)
+	}
+	return fmt.Errorf("%s, status code: %d", action, statusCode)
+}
+
 // CalculateDistance calculates the distance between two points using the Haversine formula
 func (s *matchingService) CalculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
 	c1 := haversine.Coord{Lat: lat1, Lon: lon1}
@@ -8,10 +8,13 @@ import (
 	"io/ioutil"
 	"net/http"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/httpclient"
 )
 
 // MockLocationService is a mock implementation of the LocationService interface
@@ -34,31 +37,42 @@ func (m *MockLocationService) FindNearbyDrivers(ctx context.Context, lat, lon, r
 	return args.Get(0).([]*domain.DriverLocation), args.Error(1)
 }
 
+func (m *MockLocationService) CountActiveDrivers(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 // MockTransport is a mock implementation of http.RoundTripper
 type MockTransport struct {
 	loginResponseBody         []byte
 	nearbyDriversResponseBody []byte
+	nearbyStatus              int
+	logins                    int
 }
 
 func (m *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	var body []byte
+	status := http.StatusOK
 	if req.URL.Path == "/api/v1/auth/login" {
+		m.logins++
 		body = m.loginResponseBody
 	} else if req.URL.Path == "/api/v1/locations/nearby" {
 		body = m.nearbyDriversResponseBody
+		if m.nearbyStatus != 0 {
+			status = m.nearbyStatus
+		}
 	} else {
 		return nil, errors.New("unexpected URL")
 	}
 
 	return &http.Response{
-		StatusCode: http.StatusOK,
+		StatusCode: status,
 		Body:       ioutil.NopCloser(bytes.NewBuffer(body)),
 	}, nil
 }
 
 func TestFindNearestDriver(t *testing.T) {
 	mockLocationService := new(MockLocationService)
-	service := NewMatchingService(mockLocationService)
 
 	// Mock the login response
 	loginResponse := map[string]string{"token": "mockToken"}
@@ -75,17 +89,11 @@ func TestFindNearestDriver(t *testing.T) {
 	nearbyDriversResponseBody, _ := json.Marshal(nearbyDriversResponse)
 
 	// Mock the HTTP client
-	httpClient := &http.Client{
-		Transport: &MockTransport{
-			loginResponseBody:         loginResponseBody,
-			nearbyDriversResponseBody: nearbyDriversResponseBody,
-		},
-	}
-
-	// Replace the default HTTP client with the mock client
-	oldClient := http.DefaultClient
-	http.DefaultClient = httpClient
-	defer func() { http.DefaultClient = oldClient }()
+	httpClient := httpclient.New(httpclient.WithTransport(&MockTransport{
+		loginResponseBody:         loginResponseBody,
+		nearbyDriversResponseBody: nearbyDriversResponseBody,
+	}))
+	service := NewMatchingService(mockLocationService, WithHTTPClient(httpClient))
 
 	// Call the method
 	lat, lon, radius := 40.730610, -73.935242, 10.0
@@ -102,9 +110,41 @@ func TestCalculateDistance(t *testing.T) {
 
 	lat1, lon1 := 40.7128, -74.0060
 	lat2, lon2 := 34.0522, -118.2437
-	expectedDistance := 3940.07 // Approximate distance in km
+	expectedDistance := 3935.75 // Approximate distance in km (mean earth radius)
 
 	distance := service.CalculateDistance(lat1, lon1, lat2, lon2)
 
 	assert.InDelta(t, expectedDistance, distance, 1.0)
 }
+
+func TestFindNearestDriverReusesServiceToken(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	loginResponseBody, _ := json.Marshal(map[string]string{"token": token})
+	nearbyDriversResponseBody, _ := json.Marshal([]*domain.DriverLocation{
+		{ID: "1", DriverID: "driver1", Location: domain.NewPoint(40.7128, -74.0060), Status: "active"},
+	})
+
+	transport := &MockTransport{
+		loginResponseBody:         loginResponseBody,
+		nearbyDriversResponseBody: nearbyDriversResponseBody,
+	}
+	service := NewMatchingService(nil, WithHTTPClient(httpclient.New(httpclient.WithTransport(transport))))
+
+	for i := 0; i < 3; i++ {
+		_, err := service.FindNearestDriver(context.Background(), 40.73, -73.93, 10)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, transport.logins)
+
+	// A rejected token is replaced by the next match
+	transport.nearbyStatus = http.StatusUnauthorized
+	_, err = service.FindNearestDriver(context.Background(), 40.73, -73.93, 10)
+	assert.Error(t, err)
+	transport.nearbyStatus = 0
+	_, err = service.FindNearestDriver(context.Background(), 40.73, -73.93, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, transport.logins)
+}
@@ -0,0 +1,28 @@
+package service
+
+import "time"
+
+// Match results recorded by MatchingMetrics
+const (
+	MatchResultMatched  = "matched"
+	MatchResultNoDriver = "no_driver"
+	MatchResultError    = "error"
+)
+
+// LocationMetrics records measurements of the location service
+type LocationMetrics interface {
+	ObserveFindNearby(duration time.Duration)
+	ObserveBatchSize(size int)
+}
+
+// MatchingMetrics records measurements of the matching service
+type MatchingMetrics interface {
+	ObserveFindNearest(duration time.Duration, result string)
+}
+
+// noopMetrics is used when no metrics are configured
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveFindNearby(time.Duration)          {}
+func (noopMetrics) ObserveBatchSize(int)                     {}
+func (noopMetrics) ObserveFindNearest(time.Duration, string) {}
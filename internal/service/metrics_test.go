@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/httpclient"
+)
+
+// fakeMetrics records what the services observe
+type fakeMetrics struct {
+	mu          sync.Mutex
+	findNearby  int
+	batchSizes  []int
+	matchResult []string
+}
+
+func (m *fakeMetrics) ObserveFindNearby(time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.findNearby++
+}
+
+func (m *fakeMetrics) ObserveBatchSize(size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batchSizes = append(m.batchSizes, size)
+}
+
+func (m *fakeMetrics) ObserveFindNearest(_ time.Duration, result string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.matchResult = append(m.matchResult, result)
+}
+
+func TestLocationServiceMetrics(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	metrics := &fakeMetrics{}
+	service := NewLocationService(mockRepo, WithLocationMetrics(metrics))
+
+	mockRepo.On("SaveLocations", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("FindNearbyDrivers", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]*domain.DriverLocation{}, nil)
+
+	locations := make([]domain.DriverLocation, 3)
+	assert.NoError(t, service.UpdateDriverLocations(context.Background(), locations))
+	_, err := service.FindNearbyDrivers(context.Background(), 41, 29, 100)
+	assert.NoError(t, err)
+
+	// Invalid searches never reach the repository and are not timed
+	_, err = service.FindNearbyDrivers(context.Background(), 91, 29, 100)
+	assert.Equal(t, ErrInvalidLatitude, err)
+
+	assert.Equal(t, []int{3}, metrics.batchSizes)
+	assert.Equal(t, 1, metrics.findNearby)
+}
+
+func TestCountActiveDrivers(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := NewLocationService(mockRepo, WithActiveDriverWindow(time.Minute))
+
+	mockRepo.On("CountActiveDrivers", mock.Anything, mock.MatchedBy(func(since time.Time) bool {
+		age := time.Since(since)
+		return age >= time.Minute && age < time.Minute+time.Second
+	})).Return(int64(7), nil)
+
+	count, err := service.CountActiveDrivers(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), count)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMatchingServiceMetrics(t *testing.T) {
+	loginResponseBody, _ := json.Marshal(map[string]string{"token": "mockToken"})
+	found, _ := json.Marshal([]*domain.DriverLocation{{
+		DriverID: "driver1",
+		Location: domain.NewPoint(40.7128, -74.0060),
+		Status:   "active",
+	}})
+
+	tests := []struct {
+		name     string
+		nearby   []byte
+		expected string
+	}{
+		{"matched", found, MatchResultMatched},
+		{"no driver", []byte("[]"), MatchResultNoDriver},
+		{"error", []byte("not json"), MatchResultError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := &fakeMetrics{}
+			httpClient := httpclient.New(httpclient.WithTransport(&MockTransport{
+				loginResponseBody:         loginResponseBody,
+				nearbyDriversResponseBody: tt.nearby,
+			}))
+			service := NewMatchingService(nil, WithHTTPClient(httpClient), WithMatchingMetrics(metrics))
+
+			_, _ = service.FindNearestDriver(context.Background(), 40.73, -73.93, 10)
+
+			assert.Equal(t, []string{tt.expected}, metrics.matchResult)
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository/memory"
+)
+
+// DefaultMaxRideDuration is how long a ride may stay active before it
+// expires. It bounds how long riders can track a driver.
+const DefaultMaxRideDuration = 4 * time.Hour
+
+// Custom errors
+var (
+	ErrRideNotFound    = domain.NewError(domain.ErrNotFound, "ride_not_found", "ride not found")
+	ErrRideEnded       = domain.NewError(domain.ErrConflict, "ride_ended", "ride has already ended")
+	ErrMissingDriverID = domain.NewError(domain.ErrValidation, "missing_driver_id", "driver_id is required")
+	ErrDriverBusy      = domain.NewError(domain.ErrConflict, "driver_busy", "driver is on another ride")
+	ErrMatchTokenUsed  = domain.NewError(domain.ErrConflict, "match_token_used", "match token has already been used")
+)
+
+// RideNotifier is told when a ride ends, e.g. to close the streams of riders
+// tracking it
+type RideNotifier interface {
+	RideEnded(rideID string)
+}
+
+// noopRideNotifier is used when no notifier is configured
+type noopRideNotifier struct{}
+
+func (noopRideNotifier) RideEnded(string) {}
+
+// EventPublisher is told about ride events, e.g. to call the webhooks
+// subscribed to them. Publishing must not block the caller.
+type EventPublisher interface {
+	Publish(ctx context.Context, event string, data any)
+}
+
+// noopEventPublisher is used when no publisher is configured
+type noopEventPublisher struct{}
+
+func (noopEventPublisher) Publish(context.Context, string, any) {}
+
+type RideService interface {
+	CreateRide(ctx context.Context, riderID, matchToken string) (*domain.Ride, error)
+	GetRide(ctx context.Context, rideID string) (*domain.Ride, error)
+	CompleteRide(ctx context.Context, rideID string) (*domain.Ride, error)
+}
+
+type rideService struct {
+	repo        repository.RideRepository
+	matches     *MatchTokens
+	usedTokens  repository.UserTokenRepository
+	maxDuration time.Duration
+	notifier    RideNotifier
+	events      EventPublisher
+	logger      *slog.Logger
+}
+
+// RideOption configures the ride service
+type RideOption func(*rideService)
+
+// WithRideNotifier sets who is told when a ride ends
+func WithRideNotifier(notifier RideNotifier) RideOption {
+	return func(s *rideService) {
+		s.notifier = notifier
+	}
+}
+
+// WithRideEvents sets who is told when a ride is matched or completed
+func WithRideEvents(events EventPublisher) RideOption {
+	return func(s *rideService) {
+		s.events = events
+	}
+}
+
+// WithUsedMatchTokens sets where redeemed match tokens are recorded. An
+// in-memory repository is used by default.
+func WithUsedMatchTokens(repo repository.UserTokenRepository) RideOption {
+	return func(s *rideService) {
+		s.usedTokens = repo
+	}
+}
+
+// WithMaxRideDuration sets how long a ride may stay active before it expires
+func WithMaxRideDuration(d time.Duration) RideOption {
+	return func(s *rideService) {
+		s.maxDuration = d
+	}
+}
+
+// WithRideLogger sets the logger of the ride service
+func WithRideLogger(logger *slog.Logger) RideOption {
+	return func(s *rideService) {
+		s.logger = logger
+	}
+}
+
+// NewRideService creates a ride service. Rides are created from the match
+// tokens handed out by the matching API, verified with matches.
+func NewRideService(repo repository.RideRepository, matches *MatchTokens, options ...RideOption) RideService {
+	s := &rideService{
+		repo:        repo,
+		matches:     matches,
+		usedTokens:  memory.NewUserTokenRepository(),
+		maxDuration: DefaultMaxRideDuration,
+		notifier:    noopRideNotifier{},
+		events:      noopEventPublisher{},
+		logger:      slog.Default(),
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// CreateRide starts a ride of a rider with the driver of their match token,
+// picked up where they searched, and publishes the ride.matched event. The
+// driver must not be on another ride, and a match token books one ride.
+func (s *rideService) CreateRide(ctx context.Context, riderID, matchToken string) (*domain.Ride, error) {
+	match, err := s.matches.Verify(matchToken, riderID)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.repo.GetActiveRideByDriver(ctx, match.DriverID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get active ride of driver", "driver_id", match.DriverID, "error", err)
+		return nil, err
+	}
+	if current != nil {
+		current, err = s.expireOverdue(ctx, current)
+		if err != nil {
+			return nil, err
+		}
+		if current.IsActive() {
+			return nil, ErrDriverBusy
+		}
+	}
+
+	// Only used up once the driver is known to be free, so that the rider
+	// can retry
+	fresh, err := s.usedTokens.MarkTokenUsed(ctx, match.TokenID, match.ExpiresAt)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to mark match token used", "error", err)
+		return nil, err
+	}
+	if !fresh {
+		return nil, ErrMatchTokenUsed
+	}
+
+	now := time.Now()
+	ride := &domain.Ride{
+		ID:        uuid.New().String(),
+		RiderID:   riderID,
+		DriverID:  match.DriverID,
+		Pickup:    match.Pickup,
+		Status:    domain.RideStatusActive,
+		ExpiresAt: now.Add(s.maxDuration),
+		CreatedAt: now,
+	}
+
+	if err := s.repo.CreateRide(ctx, ride); err != nil {
+		// Lost a race with another ride of the driver
+		if errors.Is(err, repository.ErrDuplicateActiveRide) {
+			return nil, ErrDriverBusy
+		}
+		s.logger.ErrorContext(ctx, "failed to create ride", "error", err)
+		return nil, err
+	}
+	s.events.Publish(ctx, domain.EventRideMatched, ride)
+
+	return ride, nil
+}
+
+// GetRide returns a ride, expiring it first when it is overdue
+func (s *rideService) GetRide(ctx context.Context, rideID string) (*domain.Ride, error) {
+	ride, err := s.repo.GetRideByID(ctx, rideID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get ride", "ride_id", rideID, "error", err)
+		return nil, err
+	}
+	if ride == nil {
+		return nil, ErrRideNotFound
+	}
+
+	return s.expireOverdue(ctx, ride)
+}
+
+// expireOverdue ends an active ride that is past its expiry and closes the
+// streams tracking it. Rides are only expired when they are read, so that no
+// background job is needed.
+func (s *rideService) expireOverdue(ctx context.Context, ride *domain.Ride) (*domain.Ride, error) {
+	// Rides created before rides had an expiry
+	if ride.ExpiresAt.IsZero() {
+		ride.ExpiresAt = ride.CreatedAt.Add(s.maxDuration)
+	}
+	if !ride.IsActive() || time.Now().Before(ride.ExpiresAt) {
+		return ride, nil
+	}
+
+	endedAt := ride.ExpiresAt
+	ended, err := s.repo.EndRide(ctx, ride.ID, domain.RideStatusExpired, endedAt)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to expire ride", "ride_id", ride.ID, "error", err)
+		return nil, err
+	}
+	if !ended {
+		// Completed or expired meanwhile
+		ride, err = s.repo.GetRideByID(ctx, ride.ID)
+		if err != nil {
+			return nil, err
+		}
+		if ride == nil {
+			return nil, ErrRideNotFound
+		}
+		return ride, nil
+	}
+
+	ride.Status = domain.RideStatusExpired
+	ride.EndedAt = &endedAt
+	s.notifier.RideEnded(ride.ID)
+
+	return ride, nil
+}
+
+// CompleteRide ends an active ride, closes the streams tracking it and
+// publishes the ride.completed event
+func (s *rideService) CompleteRide(ctx context.Context, rideID string) (*domain.Ride, error) {
+	ride, err := s.GetRide(ctx, rideID)
+	if err != nil {
+		return nil, err
+	}
+	if !ride.IsActive() {
+		return nil, ErrRideEnded
+	}
+
+	endedAt := time.Now()
+	ended, err := s.repo.EndRide(ctx, rideID, domain.RideStatusCompleted, endedAt)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to complete ride", "ride_id", rideID, "error", err)
+		return nil, err
+	}
+	if !ended {
+		return nil, ErrRideEnded
+	}
+
+	ride.Status = domain.RideStatusCompleted
+	ride.EndedAt = &endedAt
+	s.notifier.RideEnded(rideID)
+	s.events.Publish(ctx, domain.EventRideCompleted, ride)
+
+	return ride, nil
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+// MockRideRepository is a mock implementation of the RideRepository interface
+type MockRideRepository struct {
+	mock.Mock
+}
+
+func (m *MockRideRepository) CreateRide(ctx context.Context, ride *domain.Ride) error {
+	args := m.Called(ctx, ride)
+	return args.Error(0)
+}
+
+func (m *MockRideRepository) GetRideByID(ctx context.Context, id string) (*domain.Ride, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Ride), args.Error(1)
+}
+
+func (m *MockRideRepository) GetActiveRideByDriver(ctx context.Context, driverID string) (*domain.Ride, error) {
+	args := m.Called(ctx, driverID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Ride), args.Error(1)
+}
+
+func (m *MockRideRepository) EndRide(ctx context.Context, id, status string, endedAt time.Time) (bool, error) {
+	args := m.Called(ctx, id, status, endedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+type recordingRideNotifier struct {
+	ended []string
+}
+
+func (n *recordingRideNotifier) RideEnded(rideID string) {
+	n.ended = append(n.ended, rideID)
+}
+
+type recordingEventPublisher struct {
+	events []string
+	rides  []*domain.Ride
+}
+
+func (p *recordingEventPublisher) Publish(_ context.Context, event string, data any) {
+	p.events = append(p.events, event)
+	p.rides = append(p.rides, data.(*domain.Ride))
+}
+
+var testMatchTokens = NewMatchTokens("test-secret", time.Minute)
+
+func issueMatchToken(t *testing.T, riderID, driverID string) string {
+	token, err := testMatchTokens.Issue(riderID, &domain.DriverLocation{DriverID: driverID}, 41.0431, 29.0099)
+	require.NoError(t, err)
+	return token
+}
+
+func TestCreateRide(t *testing.T) {
+	mockRepo := new(MockRideRepository)
+	events := &recordingEventPublisher{}
+	service := NewRideService(mockRepo, testMatchTokens, WithRideEvents(events))
+
+	mockRepo.On("GetActiveRideByDriver", mock.Anything, "driver1").Return(nil, nil)
+	mockRepo.On("CreateRide", mock.Anything, mock.Anything).Return(nil)
+
+	ride, err := service.CreateRide(context.Background(), "rider1", issueMatchToken(t, "rider1", "driver1"))
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, ride.ID)
+	assert.Equal(t, "rider1", ride.RiderID)
+	assert.Equal(t, "driver1", ride.DriverID)
+	assert.Equal(t, domain.NewPoint(41.0431, 29.0099), ride.Pickup)
+	assert.True(t, ride.IsActive())
+	assert.WithinDuration(t, ride.CreatedAt.Add(DefaultMaxRideDuration), ride.ExpiresAt, 0)
+	assert.Equal(t, []string{domain.EventRideMatched}, events.events)
+	assert.Equal(t, []*domain.Ride{ride}, events.rides)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateRideRequiresMatch(t *testing.T) {
+	service := NewRideService(new(MockRideRepository), testMatchTokens)
+	otherSecret := NewMatchTokens("other-secret", time.Minute)
+	forged, err := otherSecret.Issue("rider1", &domain.DriverLocation{DriverID: "driver1"}, 41, 29)
+	require.NoError(t, err)
+	expired, err := NewMatchTokens("test-secret", -time.Minute).Issue("rider1", &domain.DriverLocation{DriverID: "driver1"}, 41, 29)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "missing", token: ""},
+		{name: "garbage", token: "not-a-token"},
+		{name: "other rider", token: issueMatchToken(t, "rider2", "driver1")},
+		{name: "forged", token: forged},
+		{name: "expired", token: expired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CreateRide(context.Background(), "rider1", tt.token)
+			assert.Equal(t, ErrInvalidMatchToken, err)
+		})
+	}
+}
+
+func TestCreateRideDriverBusy(t *testing.T) {
+	mockRepo := new(MockRideRepository)
+	service := NewRideService(mockRepo, testMatchTokens)
+
+	busy := &domain.Ride{ID: "ride1", DriverID: "driver1", Status: domain.RideStatusActive, CreatedAt: time.Now()}
+	mockRepo.On("GetActiveRideByDriver", mock.Anything, "driver1").Return(busy, nil)
+
+	_, err := service.CreateRide(context.Background(), "rider1", issueMatchToken(t, "rider1", "driver1"))
+
+	assert.Equal(t, ErrDriverBusy, err)
+	mockRepo.AssertNotCalled(t, "CreateRide", mock.Anything, mock.Anything)
+}
+
+func TestCreateRideDriverTakenConcurrently(t *testing.T) {
+	mockRepo := new(MockRideRepository)
+	service := NewRideService(mockRepo, testMatchTokens)
+
+	// The driver was free when looked up, but booked before the insert
+	mockRepo.On("GetActiveRideByDriver", mock.Anything, "driver1").Return(nil, nil)
+	mockRepo.On("CreateRide", mock.Anything, mock.Anything).Return(repository.ErrDuplicateActiveRide)
+
+	_, err := service.CreateRide(context.Background(), "rider1", issueMatchToken(t, "rider1", "driver1"))
+
+	assert.Equal(t, ErrDriverBusy, err)
+}
+
+func TestCreateRideMatchTokenIsSingleUse(t *testing.T) {
+	mockRepo := new(MockRideRepository)
+	service := NewRideService(mockRepo, testMatchTokens)
+
+	mockRepo.On("GetActiveRideByDriver", mock.Anything, "driver1").Return(nil, nil)
+	mockRepo.On("CreateRide", mock.Anything, mock.Anything).Return(nil).Once()
+
+	token := issueMatchToken(t, "rider1", "driver1")
+	_, err := service.CreateRide(context.Background(), "rider1", token)
+	require.NoError(t, err)
+
+	// Even after the first ride has ended
+	_, err = service.CreateRide(context.Background(), "rider1", token)
+	assert.Equal(t, ErrMatchTokenUsed, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateRideExpiresOverdueRideOfDriver(t *testing.T) {
+	mockRepo := new(MockRideRepository)
+	service := NewRideService(mockRepo, testMatchTokens, WithMaxRideDuration(time.Hour))
+
+	overdue := &domain.Ride{ID: "ride1", DriverID: "driver1", Status: domain.RideStatusActive, CreatedAt: time.Now().Add(-2 * time.Hour)}
+	mockRepo.On("GetActiveRideByDriver", mock.Anything, "driver1").Return(overdue, nil)
+	mockRepo.On("EndRide", mock.Anything, "ride1", domain.RideStatusExpired, mock.Anything).Return(true, nil)
+	mockRepo.On("CreateRide", mock.Anything, mock.Anything).Return(nil)
+
+	_, err := service.CreateRide(context.Background(), "rider1", issueMatchToken(t, "rider1", "driver1"))
+
+	require.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetRideExpiresOverdueRide(t *testing.T) {
+	mockRepo := new(MockRideRepository)
+	notifier := &recordingRideNotifier{}
+	service := NewRideService(mockRepo, testMatchTokens, WithRideNotifier(notifier))
+
+	expiresAt := time.Now().Add(-time.Minute)
+	ride := &domain.Ride{ID: "ride1", Status: domain.RideStatusActive, ExpiresAt: expiresAt, CreatedAt: expiresAt.Add(-DefaultMaxRideDuration)}
+	mockRepo.On("GetRideByID", mock.Anything, "ride1").Return(ride, nil)
+	mockRepo.On("EndRide", mock.Anything, "ride1", domain.RideStatusExpired, expiresAt).Return(true, nil)
+
+	expired, err := service.GetRide(context.Background(), "ride1")
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusExpired, expired.Status)
+	assert.Equal(t, expiresAt, *expired.EndedAt)
+	assert.Equal(t, []string{"ride1"}, notifier.ended)
+
+	_, err = service.CompleteRide(context.Background(), "ride1")
+	assert.Equal(t, ErrRideEnded, err)
+}
+
+func TestGetRideNotFound(t *testing.T) {
+	mockRepo := new(MockRideRepository)
+	service := NewRideService(mockRepo, testMatchTokens)
+
+	mockRepo.On("GetRideByID", mock.Anything, "missing").Return(nil, nil)
+
+	_, err := service.GetRide(context.Background(), "missing")
+
+	assert.Equal(t, ErrRideNotFound, err)
+}
+
+func TestCompleteRide(t *testing.T) {
+	mockRepo := new(MockRideRepository)
+	notifier := &recordingRideNotifier{}
+	events := &recordingEventPublisher{}
+	service := NewRideService(mockRepo, testMatchTokens, WithRideNotifier(notifier), WithRideEvents(events))
+
+	ride := &domain.Ride{ID: "ride1", RiderID: "rider1", DriverID: "driver1", Status: domain.RideStatusActive, CreatedAt: time.Now()}
+	mockRepo.On("GetRideByID", mock.Anything, "ride1").Return(ride, nil)
+	mockRepo.On("EndRide", mock.Anything, "ride1", domain.RideStatusCompleted, mock.Anything).Return(true, nil)
+
+	completed, err := service.CompleteRide(context.Background(), "ride1")
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusCompleted, completed.Status)
+	assert.NotNil(t, completed.EndedAt)
+	assert.Equal(t, []string{"ride1"}, notifier.ended)
+	assert.Equal(t, []string{domain.EventRideCompleted}, events.events)
+	assert.Equal(t, []*domain.Ride{completed}, events.rides)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCompleteRideAlreadyEnded(t *testing.T) {
+	mockRepo := new(MockRideRepository)
+	notifier := &recordingRideNotifier{}
+	events := &recordingEventPublisher{}
+	service := NewRideService(mockRepo, testMatchTokens, WithRideNotifier(notifier), WithRideEvents(events))
+
+	ended := &domain.Ride{ID: "ride1", Status: domain.RideStatusCompleted}
+	mockRepo.On("GetRideByID", mock.Anything, "ride1").Return(ended, nil)
+	_, err := service.CompleteRide(context.Background(), "ride1")
+	assert.Equal(t, ErrRideEnded, err)
+
+	// Completed concurrently between the lookup and the update
+	active := &domain.Ride{ID: "ride2", Status: domain.RideStatusActive, CreatedAt: time.Now()}
+	mockRepo.On("GetRideByID", mock.Anything, "ride2").Return(active, nil)
+	mockRepo.On("EndRide", mock.Anything, "ride2", domain.RideStatusCompleted, mock.Anything).Return(false, nil)
+	_, err = service.CompleteRide(context.Background(), "ride2")
+	assert.Equal(t, ErrRideEnded, err)
+
+	assert.Empty(t, notifier.ended)
+	assert.Empty(t, events.events)
+}
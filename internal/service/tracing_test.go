@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+func TestLocationServiceSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	mockRepo := new(MockLocationRepository)
+	service := NewLocationService(mockRepo)
+	mockRepo.On("FindNearbyDrivers", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]*domain.DriverLocation{{DriverID: "driver1"}}, nil)
+
+	_, err := service.FindNearbyDrivers(context.Background(), 41, 29, 500)
+	require.NoError(t, err)
+	_, err = service.FindNearbyDrivers(context.Background(), 41, 29, -1)
+	require.Equal(t, ErrInvalidRadius, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "LocationService.FindNearbyDrivers", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.Int("drivers.found", 1))
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, ErrInvalidRadius.Error(), spans[1].Status().Description)
+}
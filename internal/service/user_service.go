@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+// Pagination limits for listing users
+const (
+	defaultUserPageSize = 20
+	maxUserPageSize     = 100
+)
+
+// Custom errors
+var (
+	ErrInvalidPassword = domain.NewError(domain.ErrUnauthorized, "invalid_password", "current password is incorrect")
+	ErrSamePassword    = domain.NewError(domain.ErrValidation, "same_password", "new password must differ from the current password")
+)
+
+// UserPage is a page of users returned by ListUsers
+type UserPage struct {
+	Users []*domain.User
+	Page  int
+	Limit int
+	Total int64
+}
+
+type UserService interface {
+	GetUser(ctx context.Context, userID string) (*domain.User, error)
+	UpdateUser(ctx context.Context, userID string, update domain.UserUpdate) (*domain.User, error)
+	ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) error
+	DeleteUser(ctx context.Context, userID string) error
+	ListUsers(ctx context.Context, filter domain.UserFilter) (*UserPage, error)
+}
+
+type userService struct {
+	userRepo    repository.UserRepository
+	authService AuthService
+	logger      *slog.Logger
+}
+
+// UserOption configures the user service
+type UserOption func(*userService)
+
+// WithUserLogger sets the logger of the user service
+func WithUserLogger(logger *slog.Logger) UserOption {
+	return func(s *userService) {
+		s.logger = logger
+	}
+}
+
+// NewUserService creates a user service. The auth service is used to send a
+// new verification email when a user changes their email address.
+func NewUserService(userRepo repository.UserRepository, authService AuthService, options ...UserOption) UserService {
+	s := &userService{
+		userRepo:    userRepo,
+		authService: authService,
+		logger:      slog.Default(),
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+func (s *userService) GetUser(ctx context.Context, userID string) (*domain.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get user", "error", err)
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// UpdateUser applies a profile update. Changing the email address puts the
+// account back into pending until the new address is verified.
+func (s *userService) UpdateUser(ctx context.Context, userID string, update domain.UserUpdate) (*domain.User, error) {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	emailChanged := update.Email != nil && *update.Email != user.Email
+	if emailChanged {
+		owner, err := s.userRepo.GetUserByEmail(ctx, *update.Email)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to look up email owner", "error", err)
+			return nil, err
+		}
+		if owner != nil && owner.ID != user.ID {
+			return nil, ErrEmailInUse
+		}
+
+		if err := s.userRepo.UpdateEmail(ctx, user.ID, *update.Email); err != nil {
+			// Lost a race with another user taking the address
+			if errors.Is(err, repository.ErrDuplicateEmail) {
+				return nil, ErrEmailInUse
+			}
+			s.logger.ErrorContext(ctx, "failed to update email", "error", err)
+			return nil, err
+		}
+		user.Email = *update.Email
+		user.Status = domain.UserStatusPending
+
+		// The user can ask for a new email, so a delivery failure does not fail the update
+		if err := s.authService.SendVerification(ctx, user); err != nil {
+			s.logger.WarnContext(ctx, "failed to send verification email", "error", err)
+		}
+	}
+
+	return user, nil
+}
+
+// ChangePassword replaces the password of a user. Wrong current passwords
+// count as failed logins, so they lock the account like failed logins do.
+func (s *userService) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) error {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.authService.CheckPassword(ctx, user, currentPassword); err != nil {
+		return err
+	}
+	if currentPassword == newPassword {
+		return ErrSamePassword
+	}
+
+	user.Password = newPassword
+	if err := user.HashPassword(); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, user.Username, user.Password); err != nil {
+		s.logger.ErrorContext(ctx, "failed to update password", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteUser soft deletes a user. Deleted users are hidden from lookups and
+// can no longer log in.
+func (s *userService) DeleteUser(ctx context.Context, userID string) error {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.DeleteUser(ctx, user.ID); err != nil {
+		s.logger.ErrorContext(ctx, "failed to delete user", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *userService) ListUsers(ctx context.Context, filter domain.UserFilter) (*UserPage, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 {
+		filter.Limit = defaultUserPageSize
+	}
+	if filter.Limit > maxUserPageSize {
+		filter.Limit = maxUserPageSize
+	}
+
+	users, total, err := s.userRepo.ListUsers(ctx, filter)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list users", "error", err)
+		return nil, err
+	}
+
+	return &UserPage{
+		Users: users,
+		Page:  filter.Page,
+		Limit: filter.Limit,
+		Total: total,
+	}, nil
+}
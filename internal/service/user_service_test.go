@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+func newTestUser(t *testing.T, password string) *domain.User {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	return &domain.User{
+		ID:       "64b7f0c2e1d3a4b5c6d7e8f9",
+		Username: "testuser",
+		Password: string(hashedPassword),
+		Email:    "test@example.com",
+		Status:   domain.UserStatusActive,
+		Role:     domain.RoleUser,
+	}
+}
+
+func TestGetUserNotFound(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo, NewAuthService(mockRepo, "test-secret"))
+
+	mockRepo.On("GetUserByID", mock.Anything, "missing").Return(nil, nil)
+
+	_, err := service.GetUser(context.Background(), "missing")
+
+	assert.Equal(t, ErrUserNotFound, err)
+}
+
+func TestUpdateUserEmailRequiresVerification(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockMailer := new(MockMailer)
+	service := NewUserService(mockRepo, NewAuthService(mockRepo, "test-secret", WithMailer(mockMailer)))
+
+	user := newTestUser(t, "password")
+	newEmail := "new@example.com"
+
+	mockRepo.On("GetUserByID", mock.Anything, user.ID).Return(user, nil)
+	mockRepo.On("UpdateEmail", mock.Anything, user.ID, newEmail).Return(nil)
+	mockRepo.On("GetUserByEmail", mock.Anything, newEmail).Return(nil, nil)
+
+	updated, err := service.UpdateUser(context.Background(), user.ID, domain.UserUpdate{Email: &newEmail})
+
+	assert.NoError(t, err)
+	assert.Equal(t, newEmail, updated.Email)
+	assert.Equal(t, domain.UserStatusPending, updated.Status)
+	assert.Len(t, mockMailer.messages, 1)
+	assert.Equal(t, newEmail, mockMailer.messages[0].To)
+	assert.Contains(t, mockMailer.messages[0].Body, user.Username)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateUserEmailInUse(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo, NewAuthService(mockRepo, "test-secret"))
+
+	user := newTestUser(t, "password")
+	taken := "taken@example.com"
+
+	mockRepo.On("GetUserByID", mock.Anything, user.ID).Return(user, nil)
+	mockRepo.On("GetUserByEmail", mock.Anything, taken).Return(&domain.User{ID: "other", Email: taken}, nil)
+
+	_, err := service.UpdateUser(context.Background(), user.ID, domain.UserUpdate{Email: &taken})
+
+	assert.Equal(t, ErrEmailInUse, err)
+	mockRepo.AssertNotCalled(t, "UpdateEmail", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateUserEmailTakenConcurrently(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockMailer := new(MockMailer)
+	service := NewUserService(mockRepo, NewAuthService(mockRepo, "test-secret", WithMailer(mockMailer)))
+
+	user := newTestUser(t, "password")
+	newEmail := "new@example.com"
+
+	// The address was free when looked up, but taken before the update
+	mockRepo.On("GetUserByID", mock.Anything, user.ID).Return(user, nil)
+	mockRepo.On("GetUserByEmail", mock.Anything, newEmail).Return(nil, nil)
+	mockRepo.On("UpdateEmail", mock.Anything, user.ID, newEmail).Return(repository.ErrDuplicateEmail)
+
+	_, err := service.UpdateUser(context.Background(), user.ID, domain.UserUpdate{Email: &newEmail})
+
+	assert.Equal(t, ErrEmailInUse, err)
+	assert.Empty(t, mockMailer.messages)
+}
+
+func TestUpdateUserEmailSendFailure(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockMailer := new(MockMailer)
+	mockMailer.err = errors.New("smtp unavailable")
+	service := NewUserService(mockRepo, NewAuthService(mockRepo, "test-secret", WithMailer(mockMailer)))
+
+	user := newTestUser(t, "password")
+	newEmail := "new@example.com"
+
+	mockRepo.On("GetUserByID", mock.Anything, user.ID).Return(user, nil)
+	mockRepo.On("GetUserByEmail", mock.Anything, newEmail).Return(nil, nil)
+	mockRepo.On("UpdateEmail", mock.Anything, user.ID, newEmail).Return(nil)
+
+	updated, err := service.UpdateUser(context.Background(), user.ID, domain.UserUpdate{Email: &newEmail})
+
+	assert.NoError(t, err, "the user can ask for a new verification email")
+	assert.Equal(t, domain.UserStatusPending, updated.Status)
+}
+
+func TestChangePassword(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	// Without backoff, so that the wrong password does not delay the next try
+	policy := LockoutPolicy{MaxFailures: 5, MaxIPFailures: 20, Cooldown: time.Minute}
+	service := NewUserService(mockRepo, NewAuthService(mockRepo, "test-secret", WithLockoutPolicy(policy)))
+
+	user := newTestUser(t, "password")
+
+	mockRepo.On("GetUserByID", mock.Anything, user.ID).Return(user, nil)
+	mockRepo.On("UpdatePassword", mock.Anything, user.Username, mock.MatchedBy(func(hash string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte("new-password")) == nil
+	})).Return(nil).Once()
+
+	assert.Equal(t, ErrInvalidPassword, service.ChangePassword(context.Background(), user.ID, "wrong", "new-password"))
+	assert.Equal(t, ErrSamePassword, service.ChangePassword(context.Background(), user.ID, "password", "password"))
+	assert.NoError(t, service.ChangePassword(context.Background(), user.ID, "password", "new-password"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestChangePasswordLocksAccount(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	policy := LockoutPolicy{MaxFailures: 3, MaxIPFailures: 10, Cooldown: time.Minute}
+	authService := NewAuthService(mockRepo, "test-secret", WithLockoutPolicy(policy))
+	service := NewUserService(mockRepo, authService)
+
+	user := newTestUser(t, "password")
+	mockRepo.On("GetUserByID", mock.Anything, user.ID).Return(user, nil)
+	mockRepo.On("GetUserByUsername", mock.Anything, user.Username).Return(user, nil)
+
+	for i := 0; i < policy.MaxFailures; i++ {
+		assert.Equal(t, ErrInvalidPassword, service.ChangePassword(context.Background(), user.ID, "wrong", "new-password"))
+	}
+
+	// Guessing the current password locks the account for logins too
+	err := service.ChangePassword(context.Background(), user.ID, "password", "new-password")
+	assert.ErrorIs(t, err, ErrAccountLocked)
+	_, err = authService.Login(context.Background(), user.Username, "password", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrAccountLocked)
+	mockRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeleteUser(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo, NewAuthService(mockRepo, "test-secret"))
+
+	user := newTestUser(t, "password")
+
+	mockRepo.On("GetUserByID", mock.Anything, user.ID).Return(user, nil)
+	mockRepo.On("DeleteUser", mock.Anything, user.ID).Return(nil)
+
+	assert.NoError(t, service.DeleteUser(context.Background(), user.ID))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListUsersClampsPagination(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := NewUserService(mockRepo, NewAuthService(mockRepo, "test-secret"))
+
+	users := []*domain.User{newTestUser(t, "password")}
+	expected := domain.UserFilter{Query: "test", Page: 1, Limit: maxUserPageSize}
+	mockRepo.On("ListUsers", mock.Anything, expected).Return(users, int64(1), nil)
+
+	page, err := service.ListUsers(context.Background(), domain.UserFilter{Query: "test", Page: 0, Limit: 1000})
+
+	assert.NoError(t, err)
+	assert.Equal(t, users, page.Users)
+	assert.Equal(t, 1, page.Page)
+	assert.Equal(t, maxUserPageSize, page.Limit)
+	assert.Equal(t, int64(1), page.Total)
+	mockRepo.AssertExpectations(t)
+}
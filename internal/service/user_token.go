@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/mailer"
+)
+
+// Purposes of single-use user tokens
+const (
+	tokenPurposeVerifyEmail   = "verify_email"
+	tokenPurposeResetPassword = "reset_password"
+)
+
+// Lifetimes of single-use user tokens
+const (
+	verifyEmailTokenTTL   = 24 * time.Hour
+	resetPasswordTokenTTL = time.Hour
+)
+
+// Custom errors
+var (
+	ErrInvalidUserToken = domain.NewError(domain.ErrValidation, "invalid_or_expired_token", "invalid or expired token")
+	ErrEmailNotVerified = domain.NewError(domain.ErrForbidden, "email_not_verified", "email address is not verified")
+)
+
+type userTokenClaims struct {
+	Purpose string `json:"purpose"`
+	// Fingerprint binds the token to the state of the user it was issued
+	// for, see userTokenFingerprint
+	Fingerprint string `json:"fpr"`
+	jwt.RegisteredClaims
+}
+
+// userTokenKey derives a separate signing key per purpose so a user token can
+// never be mistaken for an access token or a token with another purpose
+func (s *authService) userTokenKey(purpose string) []byte {
+	mac := hmac.New(sha256.New, s.jwtKey)
+	mac.Write([]byte("user-token:" + purpose))
+	return mac.Sum(nil)
+}
+
+// userTokenFingerprint identifies what a token of the given purpose vouches
+// for: the email address for verification, the current password for reset.
+// Changing either invalidates the tokens issued before.
+func (s *authService) userTokenFingerprint(user *domain.User, purpose string) string {
+	mac := hmac.New(sha256.New, s.userTokenKey(purpose))
+	switch purpose {
+	case tokenPurposeVerifyEmail:
+		mac.Write([]byte(user.Email))
+	case tokenPurposeResetPassword:
+		mac.Write([]byte(user.Password))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issueUserToken creates a signed, expiring token for the given user and purpose
+func (s *authService) issueUserToken(user *domain.User, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := &userTokenClaims{
+		Purpose:     purpose,
+		Fingerprint: s.userTokenFingerprint(user, purpose),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   user.Username,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(s.userTokenKey(purpose))
+}
+
+// redeemUserToken validates a token and marks it as used. It returns the
+// user the token was issued for, provided the user has not changed what the
+// token vouches for since.
+func (s *authService) redeemUserToken(ctx context.Context, tokenString, purpose string) (*domain.User, error) {
+	claims := &userTokenClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return s.userTokenKey(purpose), nil
+	})
+	if err != nil || !token.Valid || claims.Purpose != purpose || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, ErrInvalidUserToken
+	}
+
+	user, err := s.userRepo.GetUserByUsername(ctx, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !hmac.Equal([]byte(claims.Fingerprint), []byte(s.userTokenFingerprint(user, purpose))) {
+		return nil, ErrInvalidUserToken
+	}
+
+	fresh, err := s.tokenRepo.MarkTokenUsed(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, ErrInvalidUserToken
+	}
+
+	return user, nil
+}
+
+func (s *authService) sendVerificationEmail(ctx context.Context, user *domain.User) error {
+	token, err := s.issueUserToken(user, tokenPurposeVerifyEmail, verifyEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening the link below:\n\n%s/verify-email?token=%s\n\nThe link expires in %s.",
+			user.Username, s.appBaseURL, token, verifyEmailTokenTTL),
+	})
+}
+
+func (s *authService) sendPasswordResetEmail(ctx context.Context, user *domain.User) error {
+	token, err := s.issueUserToken(user, tokenPurposeResetPassword, resetPasswordTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nReset your password by opening the link below:\n\n%s/reset-password?token=%s\n\nThe link expires in %s. If you did not ask for a reset, ignore this email.",
+			user.Username, s.appBaseURL, token, resetPasswordTokenTTL),
+	})
+}
+
+// VerifyEmail activates the account a verification token was issued for
+func (s *authService) VerifyEmail(ctx context.Context, token string) error {
+	user, err := s.redeemUserToken(ctx, token, tokenPurposeVerifyEmail)
+	if err != nil {
+		return err
+	}
+	if user.Status != domain.UserStatusPending {
+		return nil
+	}
+
+	return s.userRepo.UpdateStatus(ctx, user.Username, domain.UserStatusActive)
+}
+
+// ResendVerification sends a new verification email to a pending user.
+// Unknown and already verified addresses are ignored so callers cannot
+// probe for accounts.
+func (s *authService) ResendVerification(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Status != domain.UserStatusPending {
+		return nil
+	}
+
+	return s.sendVerificationEmail(ctx, user)
+}
+
+// SendVerification sends a verification email to the address of user
+func (s *authService) SendVerification(ctx context.Context, user *domain.User) error {
+	return s.sendVerificationEmail(ctx, user)
+}
+
+// RequestPasswordReset emails a reset link to the owner of the address.
+// Unknown addresses are ignored so callers cannot probe for accounts.
+func (s *authService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	return s.sendPasswordResetEmail(ctx, user)
+}
+
+// ResetPassword sets a new password for the user a reset token was issued for.
+// Receiving the reset email proves ownership of the address, so a pending
+// account is verified as well.
+func (s *authService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	user, err := s.redeemUserToken(ctx, token, tokenPurposeResetPassword)
+	if err != nil {
+		return err
+	}
+
+	user.Password = newPassword
+	if err := user.HashPassword(); err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(ctx, user.Username, user.Password); err != nil {
+		return err
+	}
+
+	if user.Status == domain.UserStatusPending {
+		return s.userRepo.UpdateStatus(ctx, user.Username, domain.UserStatusActive)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+	"github.com/yusufatac/bitaksi-case-study/internal/webhook"
+)
+
+// Webhook secrets look like "whsec_<secret>". Unlike API keys they are
+// stored in clear text, as deliveries are signed with them.
+const (
+	webhookSecretTag   = "whsec"
+	webhookSecretBytes = 32
+)
+
+// Delivery log page sizes
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+// Custom errors
+var (
+	ErrWebhookNotFound       = domain.NewError(domain.ErrNotFound, "webhook_not_found", "webhook not found")
+	ErrInvalidWebhookURL     = domain.NewError(domain.ErrValidation, "invalid_webhook_url", "webhook url must be an absolute http or https url")
+	ErrPrivateWebhookURL     = domain.NewError(domain.ErrValidation, "private_webhook_url", "webhook url must not point to a private address")
+	ErrInvalidWebhookEvent   = domain.NewError(domain.ErrValidation, "invalid_webhook_event", "invalid webhook event")
+	ErrNoWebhookEvents       = domain.NewError(domain.ErrValidation, "no_webhook_events", "at least one event is required")
+	ErrInvalidDeliveryStatus = domain.NewError(domain.ErrValidation, "invalid_delivery_status", "invalid delivery status")
+)
+
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, rawURL string, events []string) (*domain.WebhookSubscription, string, error)
+	GetWebhook(ctx context.Context, id string) (*domain.WebhookSubscription, error)
+	ListWebhooks(ctx context.Context) ([]*domain.WebhookSubscription, error)
+	UpdateWebhook(ctx context.Context, id string, update domain.WebhookUpdate) (*domain.WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	ListDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter) ([]*domain.WebhookDelivery, error)
+}
+
+type webhookService struct {
+	repo         repository.WebhookRepository
+	logger       *slog.Logger
+	allowPrivate bool
+}
+
+// WebhookOption configures the webhook service
+type WebhookOption func(*webhookService)
+
+// WithWebhookLogger sets the logger of the webhook service
+func WithWebhookLogger(logger *slog.Logger) WebhookOption {
+	return func(s *webhookService) {
+		s.logger = logger
+	}
+}
+
+// WithPrivateWebhookURLs allows webhook URLs pointing to loopback, private
+// and link-local addresses, e.g. for local development. The dispatcher must
+// allow them too.
+func WithPrivateWebhookURLs(allow bool) WebhookOption {
+	return func(s *webhookService) {
+		s.allowPrivate = allow
+	}
+}
+
+func NewWebhookService(repo repository.WebhookRepository, options ...WebhookOption) WebhookService {
+	s := &webhookService{
+		repo:   repo,
+		logger: slog.Default(),
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// CreateWebhook subscribes rawURL to events and returns the subscription
+// together with its signing secret. The secret is not returned afterwards.
+func (s *webhookService) CreateWebhook(ctx context.Context, rawURL string, events []string) (*domain.WebhookSubscription, string, error) {
+	if err := s.validateWebhook(rawURL, events); err != nil {
+		return nil, "", err
+	}
+
+	secret, err := randomHex(webhookSecretBytes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	subscription := &domain.WebhookSubscription{
+		ID:        uuid.New().String(),
+		URL:       rawURL,
+		Events:    events,
+		Secret:    webhookSecretTag + "_" + secret,
+		Active:    true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := s.repo.CreateSubscription(ctx, subscription); err != nil {
+		s.logger.ErrorContext(ctx, "failed to create webhook", "error", err)
+		return nil, "", err
+	}
+
+	return subscription, subscription.Secret, nil
+}
+
+func (s *webhookService) GetWebhook(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	subscription, err := s.repo.GetSubscription(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get webhook", "webhook_id", id, "error", err)
+		return nil, err
+	}
+	if subscription == nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	return subscription, nil
+}
+
+func (s *webhookService) ListWebhooks(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	return s.repo.ListSubscriptions(ctx)
+}
+
+// UpdateWebhook changes the URL, events or active flag of a subscription.
+// Deliveries already queued are sent to the new URL.
+func (s *webhookService) UpdateWebhook(ctx context.Context, id string, update domain.WebhookUpdate) (*domain.WebhookSubscription, error) {
+	subscription, err := s.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.URL != nil {
+		subscription.URL = *update.URL
+	}
+	if update.Events != nil {
+		subscription.Events = update.Events
+	}
+	if update.Active != nil {
+		subscription.Active = *update.Active
+	}
+	if err := s.validateWebhook(subscription.URL, subscription.Events); err != nil {
+		return nil, err
+	}
+	subscription.UpdatedAt = time.Now()
+
+	if err := s.repo.UpdateSubscription(ctx, subscription); err != nil {
+		s.logger.ErrorContext(ctx, "failed to update webhook", "webhook_id", id, "error", err)
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// DeleteWebhook removes a subscription together with its delivery log
+func (s *webhookService) DeleteWebhook(ctx context.Context, id string) error {
+	deleted, err := s.repo.DeleteSubscription(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to delete webhook", "webhook_id", id, "error", err)
+		return err
+	}
+	if !deleted {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// ListDeliveries returns the newest deliveries of a subscription, optionally
+// only those with a status, e.g. the dead letters
+func (s *webhookService) ListDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter) ([]*domain.WebhookDelivery, error) {
+	switch filter.Status {
+	case "", domain.WebhookDeliveryPending, domain.WebhookDeliverySucceeded, domain.WebhookDeliveryDeadLetter:
+	default:
+		return nil, ErrInvalidDeliveryStatus
+	}
+	if filter.Limit < 1 {
+		filter.Limit = defaultDeliveryLimit
+	}
+	if filter.Limit > maxDeliveryLimit {
+		filter.Limit = maxDeliveryLimit
+	}
+
+	if _, err := s.GetWebhook(ctx, filter.SubscriptionID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListDeliveries(ctx, filter)
+}
+
+// validateWebhook checks that rawURL is an absolute http or https URL and
+// that events is a non-empty list of known events. Unless allowed, URLs of
+// localhost or of a non-public IP are refused here already; names resolving
+// to such addresses are refused by the dispatcher when connecting.
+func (s *webhookService) validateWebhook(rawURL string, events []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+	if !s.allowPrivate {
+		host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+			return ErrPrivateWebhookURL
+		}
+		if ip := net.ParseIP(host); ip != nil && !webhook.IsPublicIP(ip) {
+			return ErrPrivateWebhookURL
+		}
+	}
+
+	if len(events) == 0 {
+		return ErrNoWebhookEvents
+	}
+	for _, event := range events {
+		if !domain.IsValidWebhookEvent(event) {
+			return ErrInvalidWebhookEvent
+		}
+	}
+
+	return nil
+}
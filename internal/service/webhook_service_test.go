@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository/memory"
+)
+
+func TestCreateWebhook(t *testing.T) {
+	service := NewWebhookService(memory.NewWebhookRepository())
+	ctx := context.Background()
+
+	subscription, secret, err := service.CreateWebhook(ctx, "https://partner.example.com/hooks", []string{domain.EventRideMatched})
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, subscription.ID)
+	assert.True(t, subscription.Active)
+	assert.True(t, strings.HasPrefix(secret, "whsec_"))
+	assert.Equal(t, secret, subscription.Secret)
+
+	found, err := service.GetWebhook(ctx, subscription.ID)
+	require.NoError(t, err)
+	assert.Equal(t, subscription.URL, found.URL)
+	assert.Equal(t, secret, found.Secret)
+}
+
+func TestCreateWebhookValidation(t *testing.T) {
+	service := NewWebhookService(memory.NewWebhookRepository())
+	events := []string{domain.EventRideCompleted}
+
+	tests := []struct {
+		name   string
+		url    string
+		events []string
+		err    error
+	}{
+		{name: "relative url", url: "/hooks", events: events, err: ErrInvalidWebhookURL},
+		{name: "unsupported scheme", url: "ftp://partner.example.com", events: events, err: ErrInvalidWebhookURL},
+		{name: "no host", url: "https://", events: events, err: ErrInvalidWebhookURL},
+		{name: "localhost", url: "http://localhost:8080/hooks", events: events, err: ErrPrivateWebhookURL},
+		{name: "loopback", url: "http://127.0.0.1/hooks", events: events, err: ErrPrivateWebhookURL},
+		{name: "private", url: "https://10.0.0.5/hooks", events: events, err: ErrPrivateWebhookURL},
+		{name: "link-local", url: "http://169.254.169.254/latest/meta-data", events: events, err: ErrPrivateWebhookURL},
+		{name: "ipv6 loopback", url: "http://[::1]:8080/hooks", events: events, err: ErrPrivateWebhookURL},
+		{name: "no events", url: "https://partner.example.com", events: nil, err: ErrNoWebhookEvents},
+		{name: "unknown event", url: "https://partner.example.com", events: []string{"ride.cancelled"}, err: ErrInvalidWebhookEvent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := service.CreateWebhook(context.Background(), tt.url, tt.events)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestCreateWebhookPrivateURLsAllowed(t *testing.T) {
+	service := NewWebhookService(memory.NewWebhookRepository(), WithPrivateWebhookURLs(true))
+
+	_, _, err := service.CreateWebhook(context.Background(), "http://localhost:8080/hooks", []string{domain.EventRideCompleted})
+
+	assert.NoError(t, err)
+}
+
+func TestUpdateWebhook(t *testing.T) {
+	service := NewWebhookService(memory.NewWebhookRepository())
+	ctx := context.Background()
+	subscription, _, err := service.CreateWebhook(ctx, "https://partner.example.com/hooks", []string{domain.EventRideMatched})
+	require.NoError(t, err)
+
+	inactive := false
+	updated, err := service.UpdateWebhook(ctx, subscription.ID, domain.WebhookUpdate{
+		Events: []string{domain.EventRideMatched, domain.EventRideCompleted},
+		Active: &inactive,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, subscription.URL, updated.URL)
+	assert.Equal(t, []string{domain.EventRideMatched, domain.EventRideCompleted}, updated.Events)
+	assert.False(t, updated.Active)
+
+	invalid := "not a url"
+	_, err = service.UpdateWebhook(ctx, subscription.ID, domain.WebhookUpdate{URL: &invalid})
+	assert.Equal(t, ErrInvalidWebhookURL, err)
+
+	_, err = service.UpdateWebhook(ctx, "missing", domain.WebhookUpdate{Active: &inactive})
+	assert.Equal(t, ErrWebhookNotFound, err)
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	service := NewWebhookService(memory.NewWebhookRepository())
+	ctx := context.Background()
+	subscription, _, err := service.CreateWebhook(ctx, "https://partner.example.com/hooks", []string{domain.EventRideMatched})
+	require.NoError(t, err)
+
+	require.NoError(t, service.DeleteWebhook(ctx, subscription.ID))
+
+	_, err = service.GetWebhook(ctx, subscription.ID)
+	assert.Equal(t, ErrWebhookNotFound, err)
+	assert.Equal(t, ErrWebhookNotFound, service.DeleteWebhook(ctx, subscription.ID))
+}
+
+func TestListDeliveries(t *testing.T) {
+	repo := memory.NewWebhookRepository()
+	service := NewWebhookService(repo)
+	ctx := context.Background()
+	subscription, _, err := service.CreateWebhook(ctx, "https://partner.example.com/hooks", []string{domain.EventRideMatched})
+	require.NoError(t, err)
+
+	start := time.Now()
+	for i, status := range []string{domain.WebhookDeliverySucceeded, domain.WebhookDeliveryDeadLetter, domain.WebhookDeliveryPending} {
+		require.NoError(t, repo.CreateDelivery(ctx, &domain.WebhookDelivery{
+			ID:             string(rune('a' + i)),
+			SubscriptionID: subscription.ID,
+			Event:          domain.EventRideMatched,
+			Status:         status,
+			CreatedAt:      start.Add(time.Duration(i) * time.Second),
+		}))
+	}
+
+	deliveries, err := service.ListDeliveries(ctx, domain.WebhookDeliveryFilter{SubscriptionID: subscription.ID})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 3)
+	assert.Equal(t, "c", deliveries[0].ID, "newest first")
+
+	deadLetters, err := service.ListDeliveries(ctx, domain.WebhookDeliveryFilter{
+		SubscriptionID: subscription.ID,
+		Status:         domain.WebhookDeliveryDeadLetter,
+	})
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, "b", deadLetters[0].ID)
+
+	_, err = service.ListDeliveries(ctx, domain.WebhookDeliveryFilter{SubscriptionID: subscription.ID, Status: "lost"})
+	assert.Equal(t, ErrInvalidDeliveryStatus, err)
+
+	_, err = service.ListDeliveries(ctx, domain.WebhookDeliveryFilter{SubscriptionID: "missing"})
+	assert.Equal(t, ErrWebhookNotFound, err)
+}
@@ -0,0 +1,312 @@
+// Package stream runs driver location streaming sessions over WebSocket.
+//
+// A driver sends compact location frames, JSON arrays of [seq, lat, lon]
+// with a sequence number that increases with every frame. Frames are
+// coalesced on the server: at most one location is saved per flush interval,
+// the latest one, and its acknowledgment covers every earlier frame too.
+//
+// The server sends a ping every ping interval and drops clients that do not
+// answer within the pong wait. Acknowledgments and errors go through a
+// bounded queue; a client that stops reading them is disconnected instead of
+// being buffered without limit.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Message types sent to the client
+const (
+	MessageAck   = "ack"
+	MessageError = "error"
+)
+
+// maxSeq is the largest sequence number that survives a float64 round trip
+const maxSeq = 1 << 53
+
+// Custom errors
+var (
+	ErrInvalidFrame     = errors.New("frame must be a [seq, lat, lon] array")
+	ErrInvalidSeq       = errors.New("seq must be a positive integer")
+	ErrStaleSeq         = errors.New("seq must be greater than the previous frame")
+	ErrInvalidLatitude  = errors.New("latitude must be between -90 and 90")
+	ErrInvalidLongitude = errors.New("longitude must be between -180 and 180")
+	ErrSaveFailed       = errors.New("failed to save location")
+)
+
+// Saver persists the location of a driver
+type Saver interface {
+	UpdateDriverLocation(ctx context.Context, driverID string, lat, lon float64) error
+}
+
+// Config tunes a streaming session
+type Config struct {
+	// FlushInterval is the minimum time between two saves of a driver's
+	// location. Frames received in between are coalesced.
+	FlushInterval time.Duration
+	// PingInterval is how often the server pings the client
+	PingInterval time.Duration
+	// PongWait is how long the server waits for any frame or pong before
+	// dropping the client. It must be longer than PingInterval.
+	PongWait time.Duration
+	// WriteWait bounds a single write to the client
+	WriteWait time.Duration
+	// SaveTimeout bounds a single save
+	SaveTimeout time.Duration
+	// MaxFrameBytes is the largest frame accepted from the client
+	MaxFrameBytes int64
+	// SendBuffer is the number of messages queued for the client before it
+	// is considered too slow and disconnected
+	SendBuffer int
+}
+
+// DefaultConfig returns the default session settings
+func DefaultConfig() Config {
+	return Config{
+		FlushInterval: time.Second,
+		PingInterval:  25 * time.Second,
+		PongWait:      60 * time.Second,
+		WriteWait:     10 * time.Second,
+		SaveTimeout:   5 * time.Second,
+		MaxFrameBytes: 256,
+		SendBuffer:    16,
+	}
+}
+
+// Frame is a location reported by a driver
+type Frame struct {
+	Seq uint64
+	Lat float64
+	Lon float64
+}
+
+// Message is an acknowledgment or error sent to the client. Seq of an ack
+// is the latest saved frame; every frame before it is acknowledged as well.
+type Message struct {
+	Type  string `json:"type"`
+	Seq   uint64 `json:"seq,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// ParseFrame parses a [seq, lat, lon] frame
+func ParseFrame(data []byte) (Frame, error) {
+	var values []float64
+	if err := json.Unmarshal(data, &values); err != nil || len(values) != 3 {
+		return Frame{}, ErrInvalidFrame
+	}
+
+	seq, lat, lon := values[0], values[1], values[2]
+	if seq < 1 || seq > maxSeq || seq != math.Trunc(seq) {
+		return Frame{}, ErrInvalidSeq
+	}
+	if lat < -90 || lat > 90 {
+		return Frame{}, ErrInvalidLatitude
+	}
+	if lon < -180 || lon > 180 {
+		return Frame{}, ErrInvalidLongitude
+	}
+
+	return Frame{Seq: uint64(seq), Lat: lat, Lon: lon}, nil
+}
+
+type session struct {
+	conn     *websocket.Conn
+	driverID string
+	saver    Saver
+	config   Config
+	logger   *slog.Logger
+
+	send      chan Message
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// ready is signaled when a frame is pending
+	ready   chan struct{}
+	mu      sync.Mutex
+	pending *Frame
+	lastSeq uint64
+}
+
+// Serve runs a session for an upgraded connection until the client goes
+// away or is dropped, then closes the connection. Any pending location is
+// saved before Serve returns. ctx is used for the saves and must outlive
+// the HTTP request that was upgraded.
+func Serve(ctx context.Context, conn *websocket.Conn, driverID string, saver Saver, config Config, logger *slog.Logger) {
+	s := &session{
+		conn:     conn,
+		driverID: driverID,
+		saver:    saver,
+		config:   config,
+		logger:   logger,
+		send:     make(chan Message, config.SendBuffer),
+		done:     make(chan struct{}),
+		ready:    make(chan struct{}, 1),
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.writeLoop(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		s.flushLoop(ctx)
+	}()
+
+	s.readLoop(ctx)
+	s.shutdown(websocket.CloseNormalClosure, "")
+	wg.Wait()
+}
+
+func (s *session) readLoop(ctx context.Context) {
+	s.conn.SetReadLimit(s.config.MaxFrameBytes)
+	_ = s.conn.SetReadDeadline(time.Now().Add(s.config.PongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(s.config.PongWait))
+	})
+
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.logger.DebugContext(ctx, "driver stream read failed", "driver_id", s.driverID, "error", err)
+			}
+			return
+		}
+		_ = s.conn.SetReadDeadline(time.Now().Add(s.config.PongWait))
+
+		frame, err := ParseFrame(data)
+		if err != nil {
+			s.enqueue(ctx, Message{Type: MessageError, Error: err.Error()})
+			continue
+		}
+
+		s.mu.Lock()
+		if frame.Seq <= s.lastSeq {
+			s.mu.Unlock()
+			s.enqueue(ctx, Message{Type: MessageError, Seq: frame.Seq, Error: ErrStaleSeq.Error()})
+			continue
+		}
+		s.lastSeq = frame.Seq
+		s.pending = &frame
+		s.mu.Unlock()
+
+		select {
+		case s.ready <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// flushLoop saves the pending frame as soon as there is one, then waits for
+// the flush interval so that the frames arriving meanwhile are coalesced
+func (s *session) flushLoop(ctx context.Context) {
+	for {
+		select {
+		case <-s.ready:
+		case <-s.done:
+			s.flush(ctx, false)
+			return
+		}
+
+		s.flush(ctx, true)
+
+		select {
+		case <-time.After(s.config.FlushInterval):
+		case <-s.done:
+			s.flush(ctx, false)
+			return
+		}
+	}
+}
+
+// flush saves the pending frame, if any, and reports the outcome to the
+// client when ack is set
+func (s *session) flush(ctx context.Context, ack bool) {
+	s.mu.Lock()
+	frame := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	if frame == nil {
+		return
+	}
+
+	saveCtx, cancel := context.WithTimeout(ctx, s.config.SaveTimeout)
+	err := s.saver.UpdateDriverLocation(saveCtx, s.driverID, frame.Lat, frame.Lon)
+	cancel()
+
+	if !ack {
+		return
+	}
+	if err != nil {
+		s.enqueue(ctx, Message{Type: MessageError, Seq: frame.Seq, Error: ErrSaveFailed.Error()})
+		return
+	}
+	s.enqueue(ctx, Message{Type: MessageAck, Seq: frame.Seq})
+}
+
+func (s *session) writeLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.config.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg := <-s.send:
+			_ = s.conn.SetWriteDeadline(time.Now().Add(s.config.WriteWait))
+			if err := s.conn.WriteJSON(msg); err != nil {
+				s.logger.DebugContext(ctx, "driver stream write failed", "driver_id", s.driverID, "error", err)
+				s.shutdown(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ticker.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.config.WriteWait)); err != nil {
+				s.logger.DebugContext(ctx, "driver stream ping failed", "driver_id", s.driverID, "error", err)
+				s.shutdown(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// enqueue queues a message for the client. A client whose queue is full is
+// not keeping up and is disconnected.
+func (s *session) enqueue(ctx context.Context, msg Message) {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	select {
+	case s.send <- msg:
+	default:
+		s.logger.WarnContext(ctx, "driver stream client too slow, disconnecting", "driver_id", s.driverID)
+		s.shutdown(websocket.ClosePolicyViolation, "client is not reading acknowledgments")
+	}
+}
+
+// shutdown stops the session. Unless the connection already failed, the
+// client is sent a close frame with code and reason first. Closing the
+// connection unblocks the read loop.
+func (s *session) shutdown(code int, reason string) {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		if code != websocket.CloseAbnormalClosure {
+			msg := websocket.FormatCloseMessage(code, reason)
+			_ = s.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(s.config.WriteWait))
+		}
+		_ = s.conn.Close()
+	})
+}
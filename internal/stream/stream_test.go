@@ -0,0 +1,267 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type savedLocation struct {
+	driverID string
+	lat, lon float64
+}
+
+type fakeSaver struct {
+	mu    sync.Mutex
+	saved []savedLocation
+	err   error
+}
+
+func (f *fakeSaver) UpdateDriverLocation(ctx context.Context, driverID string, lat, lon float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.saved = append(f.saved, savedLocation{driverID: driverID, lat: lat, lon: lon})
+	return nil
+}
+
+func (f *fakeSaver) locations() []savedLocation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]savedLocation(nil), f.saved...)
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.FlushInterval = 100 * time.Millisecond
+	return cfg
+}
+
+// dial starts a server running a session per connection and connects to it
+func dial(t *testing.T, saver Saver, cfg Config) *websocket.Conn {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		Serve(context.Background(), conn, "driver-1", saver, cfg, testLogger())
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) Message {
+	t.Helper()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var msg Message
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func TestParseFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Frame
+		wantErr error
+	}{
+		{name: "valid", data: `[7,41.0431,29.0099]`, want: Frame{Seq: 7, Lat: 41.0431, Lon: 29.0099}},
+		{name: "not an array", data: `{"seq":1}`, wantErr: ErrInvalidFrame},
+		{name: "too short", data: `[1,41]`, wantErr: ErrInvalidFrame},
+		{name: "too long", data: `[1,41,29,0]`, wantErr: ErrInvalidFrame},
+		{name: "zero seq", data: `[0,41,29]`, wantErr: ErrInvalidSeq},
+		{name: "fractional seq", data: `[1.5,41,29]`, wantErr: ErrInvalidSeq},
+		{name: "latitude out of range", data: `[1,91,29]`, wantErr: ErrInvalidLatitude},
+		{name: "longitude out of range", data: `[1,41,-181]`, wantErr: ErrInvalidLongitude},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := ParseFrame([]byte(tt.data))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, frame)
+		})
+	}
+}
+
+func TestServe_CoalescesFrames(t *testing.T) {
+	saver := &fakeSaver{}
+	conn := dial(t, saver, testConfig())
+
+	for seq := 1; seq <= 20; seq++ {
+		frame := []float64{float64(seq), 41 + float64(seq)/1000, 29}
+		require.NoError(t, conn.WriteJSON(frame))
+	}
+
+	// Acks are cumulative, so the last one is for the last frame
+	for {
+		msg := readMessage(t, conn)
+		require.Equal(t, MessageAck, msg.Type)
+		if msg.Seq == 20 {
+			break
+		}
+	}
+
+	saved := saver.locations()
+	assert.Less(t, len(saved), 20, "frames within a flush interval should be coalesced")
+	assert.Equal(t, savedLocation{driverID: "driver-1", lat: 41.02, lon: 29}, saved[len(saved)-1])
+}
+
+func TestServe_RejectsInvalidFrames(t *testing.T) {
+	saver := &fakeSaver{}
+	conn := dial(t, saver, testConfig())
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`not json`)))
+	msg := readMessage(t, conn)
+	assert.Equal(t, Message{Type: MessageError, Error: ErrInvalidFrame.Error()}, msg)
+
+	require.NoError(t, conn.WriteJSON([]float64{5, 41, 29}))
+	assert.Equal(t, Message{Type: MessageAck, Seq: 5}, readMessage(t, conn))
+
+	require.NoError(t, conn.WriteJSON([]float64{5, 42, 29}))
+	msg = readMessage(t, conn)
+	assert.Equal(t, Message{Type: MessageError, Seq: 5, Error: ErrStaleSeq.Error()}, msg)
+
+	assert.Len(t, saver.locations(), 1)
+}
+
+func TestServe_ReportsSaveFailures(t *testing.T) {
+	saver := &fakeSaver{err: errors.New("database is down")}
+	conn := dial(t, saver, testConfig())
+
+	require.NoError(t, conn.WriteJSON([]float64{1, 41, 29}))
+	msg := readMessage(t, conn)
+	assert.Equal(t, Message{Type: MessageError, Seq: 1, Error: ErrSaveFailed.Error()}, msg)
+}
+
+func TestServe_SavesPendingFrameOnClose(t *testing.T) {
+	saver := &fakeSaver{}
+	cfg := testConfig()
+	cfg.FlushInterval = time.Hour
+	conn := dial(t, saver, cfg)
+
+	require.NoError(t, conn.WriteJSON([]float64{1, 41, 29}))
+	assert.Equal(t, Message{Type: MessageAck, Seq: 1}, readMessage(t, conn))
+
+	// Held back by the flush interval until the client goes away
+	require.NoError(t, conn.WriteJSON([]float64{2, 42, 30}))
+	require.NoError(t, conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")))
+
+	require.Eventually(t, func() bool {
+		return len(saver.locations()) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, savedLocation{driverID: "driver-1", lat: 42, lon: 30}, saver.locations()[1])
+}
+
+func TestServe_Heartbeat(t *testing.T) {
+	cfg := testConfig()
+	cfg.PingInterval = 20 * time.Millisecond
+	cfg.PongWait = 200 * time.Millisecond
+
+	t.Run("answered pings keep the connection open", func(t *testing.T) {
+		conn := dial(t, &fakeSaver{}, cfg)
+
+		pings := make(chan struct{}, 100)
+		defaultHandler := conn.PingHandler()
+		conn.SetPingHandler(func(data string) error {
+			pings <- struct{}{}
+			return defaultHandler(data)
+		})
+
+		// Read past several pong waits; only control frames arrive
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(3*cfg.PongWait)))
+		_, _, err := conn.ReadMessage()
+		var netErr interface{ Timeout() bool }
+		require.ErrorAs(t, err, &netErr)
+		assert.True(t, netErr.Timeout(), "connection should still be open")
+		assert.GreaterOrEqual(t, len(pings), 5)
+	})
+
+	t.Run("unanswered pings drop the client", func(t *testing.T) {
+		conn := dial(t, &fakeSaver{}, cfg)
+		conn.SetPingHandler(func(string) error { return nil })
+
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(3*cfg.PongWait)))
+		_, _, err := conn.ReadMessage()
+		var netErr interface{ Timeout() bool }
+		if errors.As(err, &netErr) {
+			assert.False(t, netErr.Timeout(), "server should have closed the connection")
+		}
+		require.Error(t, err)
+	})
+}
+
+func TestSession_DisconnectsSlowClient(t *testing.T) {
+	sessions := make(chan *session, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		// No write loop, so nothing drains the queue
+		cfg := testConfig()
+		sessions <- &session{
+			conn:   conn,
+			config: cfg,
+			logger: testLogger(),
+			send:   make(chan Message, 1),
+			done:   make(chan struct{}),
+		}
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	s := <-sessions
+	s.enqueue(context.Background(), Message{Type: MessageAck, Seq: 1})
+	select {
+	case <-s.done:
+		t.Fatal("session closed before its queue was full")
+	default:
+	}
+
+	s.enqueue(context.Background(), Message{Type: MessageAck, Seq: 2})
+	select {
+	case <-s.done:
+	default:
+		t.Fatal("session should close when its queue is full")
+	}
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.ClosePolicyViolation, closeErr.Code)
+}
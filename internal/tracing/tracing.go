@@ -0,0 +1,189 @@
+// Package tracing sets up OpenTelemetry tracing and provides the helpers the
+// services use to start spans. Spans are started with the global tracer
+// provider, so tests can install a provider that records them.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/requestctx"
+)
+
+// instrumentationName names the tracer of the services
+const instrumentationName = "github.com/yusufatac/bitaksi-case-study"
+
+// Exporters
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// Custom errors
+var (
+	ErrUnknownExporter = errors.New("unknown tracing exporter")
+)
+
+type Config struct {
+	// ServiceName is reported as the service.name resource attribute
+	ServiceName string
+	// Exporter is none, otlp, stdout or file. Defaults to none.
+	Exporter string
+	// FilePath is the file spans are appended to by the file exporter
+	FilePath string
+	// SampleRatio is the fraction of new traces that are sampled. Traces
+	// started by a caller follow the caller's sampling decision.
+	SampleRatio float64
+}
+
+// Setup installs the global tracer provider and the W3C trace context
+// propagator. The OTLP exporter is configured with the standard
+// OTEL_EXPORTER_OTLP_* environment variables. The returned function flushes
+// and stops the exporter.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, closer, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if closeErr := closer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+func newExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, io.Closer, error) {
+	switch strings.ToLower(cfg.Exporter) {
+	case "", ExporterNone:
+		return nil, nil, nil
+	case ExporterOTLP:
+		exporter, err := otlptracehttp.New(ctx)
+		return exporter, nil, err
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exporter, nil, err
+	case ExporterFile:
+		file, err := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return exporter, file, nil
+	default:
+		return nil, nil, ErrUnknownExporter
+	}
+}
+
+// Start starts a span under ctx
+func Start(ctx context.Context, name string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, options...)
+}
+
+// StartMongo starts a client span for a MongoDB operation on collection
+func StartMongo(ctx context.Context, collection, operation string) (context.Context, trace.Span) {
+	return Start(ctx, "mongodb."+collection+"."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemMongoDB,
+			semconv.DBCollectionName(collection),
+			semconv.DBOperationName(operation),
+		),
+	)
+}
+
+// End records err, if any, on span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// SetAttributes sets attributes on the span of ctx
+func SetAttributes(ctx context.Context, attributes ...attribute.KeyValue) {
+	trace.SpanFromContext(ctx).SetAttributes(attributes...)
+}
+
+// TraceParent returns the traceparent of the span of ctx when the span was
+// started by this service. Spans of callers and no-op spans are ignored, so
+// with tracing disabled the traceparent set up by requestctx is kept.
+func TraceParent(ctx context.Context) (requestctx.TraceParent, bool) {
+	sc := trace.SpanFromContext(ctx).SpanContext()
+	if !sc.IsValid() || sc.IsRemote() {
+		return requestctx.TraceParent{}, false
+	}
+
+	flags := "00"
+	if sc.IsSampled() {
+		flags = "01"
+	}
+	return requestctx.TraceParent{
+		TraceID:  sc.TraceID().String(),
+		ParentID: sc.SpanID().String(),
+		Flags:    flags,
+	}, true
+}
+
+// Inject sets the traceparent and tracestate of an outbound request to the
+// span of ctx when the span was started by this service
+func Inject(ctx context.Context, header http.Header) {
+	if _, ok := TraceParent(ctx); ok {
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+	}
+}
+
+// Extract returns ctx with the span context propagated in header as the
+// remote parent
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func useRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+	return recorder
+}
+
+func TestTraceParent(t *testing.T) {
+	useRecorder(t)
+
+	// No span
+	_, ok := TraceParent(context.Background())
+	assert.False(t, ok)
+
+	// The caller's span is not ours
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, err := Setup(context.Background(), Config{})
+	require.NoError(t, err)
+	ctx := Extract(context.Background(), header)
+	_, ok = TraceParent(ctx)
+	assert.False(t, ok)
+
+	// A span started here continues the caller's trace
+	ctx, span := Start(ctx, "handler")
+	defer span.End()
+	tp, ok := TraceParent(ctx)
+	require.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tp.TraceID)
+	assert.Equal(t, span.SpanContext().SpanID().String(), tp.ParentID)
+	assert.Equal(t, "01", tp.Flags)
+
+	outbound := http.Header{}
+	Inject(ctx, outbound)
+	assert.Equal(t, tp.String(), outbound.Get("traceparent"))
+}
+
+func TestEnd(t *testing.T) {
+	recorder := useRecorder(t)
+
+	_, span := StartMongo(context.Background(), "users", "GetUserByID")
+	End(span, errors.New("connection reset"))
+	_, span = Start(context.Background(), "ok")
+	End(span, nil)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "mongodb.users.GetUserByID", spans[0].Name())
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Len(t, spans[0].Events(), 1)
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+}
+
+func TestSetupFileExporter(t *testing.T) {
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	path := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := Setup(context.Background(), Config{
+		ServiceName: "test",
+		Exporter:    ExporterFile,
+		FilePath:    path,
+		SampleRatio: 1,
+	})
+	require.NoError(t, err)
+
+	_, span := Start(context.Background(), "exported")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Name":"exported"`)
+	assert.Contains(t, string(data), `"test"`)
+}
+
+func TestSetupUnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), Config{Exporter: "zipkin"})
+	assert.Equal(t, ErrUnknownExporter, err)
+}
@@ -0,0 +1,170 @@
+// Package tracking fans driver locations out to the riders watching them.
+//
+// The hub is fed by the location write path and is in-process: a rider only
+// sees the locations written by the instance serving their stream.
+// Subscriptions keep just the latest location, so a slow subscriber never
+// holds up publishers or other subscribers; it skips intermediate locations
+// instead.
+package tracking
+
+import (
+	"sync"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+// Hub routes driver locations and ride endings to subscriptions
+type Hub struct {
+	mu       sync.Mutex
+	byDriver map[string]map[*Subscription]struct{}
+	byRide   map[string]map[*Subscription]struct{}
+}
+
+// NewHub creates an empty hub
+func NewHub() *Hub {
+	return &Hub{
+		byDriver: make(map[string]map[*Subscription]struct{}),
+		byRide:   make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe watches the locations of the driver of a ride until the ride
+// ends or the subscription is closed
+func (h *Hub) Subscribe(rideID, driverID string) *Subscription {
+	sub := &Subscription{
+		hub:      h,
+		rideID:   rideID,
+		driverID: driverID,
+		ready:    make(chan struct{}, 1),
+		ended:    make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	add(h.byDriver, driverID, sub)
+	add(h.byRide, rideID, sub)
+
+	return sub
+}
+
+// PublishLocation delivers a driver location to the subscriptions watching
+// the driver
+func (h *Hub) PublishLocation(location domain.DriverLocation) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.byDriver[location.DriverID] {
+		sub.offer(location)
+	}
+}
+
+// RideEnded ends the subscriptions of a ride
+func (h *Hub) RideEnded(rideID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.byRide[rideID] {
+		sub.end()
+	}
+}
+
+// Subscribers returns the number of open subscriptions
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	count := 0
+	for _, subs := range h.byRide {
+		count += len(subs)
+	}
+	return count
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	remove(h.byDriver, sub.driverID, sub)
+	remove(h.byRide, sub.rideID, sub)
+}
+
+func add(index map[string]map[*Subscription]struct{}, key string, sub *Subscription) {
+	subs, ok := index[key]
+	if !ok {
+		subs = make(map[*Subscription]struct{})
+		index[key] = subs
+	}
+	subs[sub] = struct{}{}
+}
+
+func remove(index map[string]map[*Subscription]struct{}, key string, sub *Subscription) {
+	subs := index[key]
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(index, key)
+	}
+}
+
+// Subscription receives the locations of one driver for one ride
+type Subscription struct {
+	hub      *Hub
+	rideID   string
+	driverID string
+
+	// ready is signaled when a location is waiting
+	ready chan struct{}
+	// ended is closed when the ride ends
+	ended     chan struct{}
+	endOnce   sync.Once
+	closeOnce sync.Once
+
+	mu     sync.Mutex
+	latest *domain.DriverLocation
+}
+
+// Ready is signaled when a location is waiting to be taken with Next
+func (s *Subscription) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Ended is closed when the ride ends
+func (s *Subscription) Ended() <-chan struct{} {
+	return s.ended
+}
+
+// Next takes the latest location published since the previous call.
+// Locations published in between are skipped.
+func (s *Subscription) Next() (domain.DriverLocation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.latest == nil {
+		return domain.DriverLocation{}, false
+	}
+	location := *s.latest
+	s.latest = nil
+	return location, true
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.closeOnce.Do(func() {
+		s.hub.remove(s)
+	})
+}
+
+func (s *Subscription) offer(location domain.DriverLocation) {
+	s.mu.Lock()
+	s.latest = &location
+	s.mu.Unlock()
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Subscription) end() {
+	s.endOnce.Do(func() {
+		close(s.ended)
+	})
+}
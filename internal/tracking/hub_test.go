@@ -0,0 +1,86 @@
+package tracking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+)
+
+func location(driverID string, lat, lon float64) domain.DriverLocation {
+	return domain.DriverLocation{DriverID: driverID, Location: domain.NewPoint(lat, lon)}
+}
+
+func isSignaled(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestHub_DeliversLatestLocationOfDriver(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe("ride-1", "driver-1")
+	defer sub.Close()
+
+	hub.PublishLocation(location("driver-2", 1, 1))
+	assert.False(t, isSignaled(sub.Ready()), "locations of other drivers are not delivered")
+
+	hub.PublishLocation(location("driver-1", 41.01, 29))
+	hub.PublishLocation(location("driver-1", 41.02, 29))
+	require.True(t, isSignaled(sub.Ready()))
+
+	got, ok := sub.Next()
+	require.True(t, ok)
+	assert.Equal(t, location("driver-1", 41.02, 29), got, "intermediate locations are skipped")
+
+	_, ok = sub.Next()
+	assert.False(t, ok)
+}
+
+func TestHub_FansOutToEverySubscriber(t *testing.T) {
+	hub := NewHub()
+	first := hub.Subscribe("ride-1", "driver-1")
+	second := hub.Subscribe("ride-2", "driver-1")
+	defer first.Close()
+	defer second.Close()
+
+	hub.PublishLocation(location("driver-1", 41, 29))
+
+	for _, sub := range []*Subscription{first, second} {
+		got, ok := sub.Next()
+		require.True(t, ok)
+		assert.Equal(t, location("driver-1", 41, 29), got)
+	}
+}
+
+func TestHub_RideEnded(t *testing.T) {
+	hub := NewHub()
+	ended := hub.Subscribe("ride-1", "driver-1")
+	other := hub.Subscribe("ride-2", "driver-1")
+	defer ended.Close()
+	defer other.Close()
+
+	hub.RideEnded("ride-1")
+	hub.RideEnded("ride-1")
+
+	assert.True(t, isSignaled(ended.Ended()))
+	assert.False(t, isSignaled(other.Ended()))
+}
+
+func TestSubscription_Close(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe("ride-1", "driver-1")
+	assert.Equal(t, 1, hub.Subscribers())
+
+	sub.Close()
+	sub.Close()
+	assert.Equal(t, 0, hub.Subscribers())
+
+	hub.PublishLocation(location("driver-1", 41, 29))
+	assert.False(t, isSignaled(sub.Ready()), "closed subscriptions get no locations")
+}
@@ -0,0 +1,90 @@
+package udpingest
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// minKeyLength is the shortest device key accepted, in bytes
+const minKeyLength = 16
+
+// Device is a tracker allowed to report locations for a driver
+type Device struct {
+	ID       string
+	DriverID string
+	// Key signs the datagrams of the device
+	Key []byte
+}
+
+// DeviceStore looks up devices by ID
+type DeviceStore interface {
+	Device(id string) (*Device, bool)
+}
+
+type staticDeviceStore map[string]*Device
+
+// NewDeviceStore creates a store holding a fixed set of devices
+func NewDeviceStore(devices []Device) DeviceStore {
+	store := make(staticDeviceStore, len(devices))
+	for i := range devices {
+		store[devices[i].ID] = &devices[i]
+	}
+	return store
+}
+
+func (s staticDeviceStore) Device(id string) (*Device, bool) {
+	device, ok := s[id]
+	return device, ok
+}
+
+// LoadDevices reads devices, one per line as
+//
+//	device_id,driver_id,hex_key
+//
+// Blank lines and lines starting with # are skipped. Keys must be at least
+// 16 bytes long.
+func LoadDevices(r io.Reader) ([]Device, error) {
+	var devices []Device
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, ",")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: want device_id,driver_id,hex_key", line)
+		}
+		id, driverID := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1])
+		if id == "" || len(id) > MaxDeviceIDLength {
+			return nil, fmt.Errorf("line %d: %w", line, ErrInvalidDeviceID)
+		}
+		if driverID == "" {
+			return nil, fmt.Errorf("line %d: missing driver id", line)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("line %d: duplicate device %q", line, id)
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(fields[2]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid key: %w", line, err)
+		}
+		if len(key) < minKeyLength {
+			return nil, fmt.Errorf("line %d: key must be at least %d bytes", line, minKeyLength)
+		}
+
+		seen[id] = true
+		devices = append(devices, Device{ID: id, DriverID: driverID, Key: key})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
@@ -0,0 +1,135 @@
+package udpingest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+// PacketVersion is the datagram format version
+const PacketVersion = 1
+
+// MaxDeviceIDLength is the longest device ID accepted, in bytes
+const MaxDeviceIDLength = 64
+
+// MACSize is the size of the HMAC-SHA256 closing a datagram
+const MACSize = sha256.Size
+
+// MaxPacketSize is the size of the largest valid datagram
+const MaxPacketSize = 1 + binary.MaxVarintLen64 + MaxDeviceIDLength + 3*binary.MaxVarintLen64 + MACSize
+
+// scale converts degrees to fixed-point units, the same 1e-7 degrees as
+// internal/batchcodec
+const scale = 1e7
+
+// Custom errors
+var (
+	ErrMalformedPacket    = errors.New("udpingest: malformed packet")
+	ErrUnsupportedVersion = errors.New("udpingest: unsupported packet version")
+	ErrInvalidDeviceID    = errors.New("udpingest: invalid device id")
+	ErrInvalidLatitude    = errors.New("udpingest: latitude out of range")
+	ErrInvalidLongitude   = errors.New("udpingest: longitude out of range")
+)
+
+// Packet is a location reported by a device
+type Packet struct {
+	DeviceID  string
+	Timestamp time.Time
+	Latitude  float64
+	Longitude float64
+}
+
+// EncodePacket encodes p as a datagram signed with key
+func EncodePacket(p Packet, key []byte) ([]byte, error) {
+	if p.DeviceID == "" || len(p.DeviceID) > MaxDeviceIDLength {
+		return nil, ErrInvalidDeviceID
+	}
+	if p.Latitude < -90 || p.Latitude > 90 {
+		return nil, ErrInvalidLatitude
+	}
+	if p.Longitude < -180 || p.Longitude > 180 {
+		return nil, ErrInvalidLongitude
+	}
+
+	data := make([]byte, 0, MaxPacketSize)
+	data = append(data, PacketVersion)
+	data = binary.AppendUvarint(data, uint64(len(p.DeviceID)))
+	data = append(data, p.DeviceID...)
+	data = binary.AppendUvarint(data, uint64(p.Timestamp.UnixMilli()))
+	data = binary.AppendVarint(data, int64(math.Round(p.Latitude*scale)))
+	data = binary.AppendVarint(data, int64(math.Round(p.Longitude*scale)))
+
+	return append(data, sign(key, data)...), nil
+}
+
+// signedPacket is a parsed datagram whose MAC is not verified yet
+type signedPacket struct {
+	Packet
+	signed []byte
+	mac    []byte
+}
+
+// verify reports whether the packet was signed with key
+func (p *signedPacket) verify(key []byte) bool {
+	return hmac.Equal(p.mac, sign(key, p.signed))
+}
+
+func parsePacket(data []byte) (*signedPacket, error) {
+	if len(data) < 1+MACSize {
+		return nil, ErrMalformedPacket
+	}
+	if data[0] != PacketVersion {
+		return nil, ErrUnsupportedVersion
+	}
+	signed, mac := data[:len(data)-MACSize], data[len(data)-MACSize:]
+	rest := signed[1:]
+
+	length, n := binary.Uvarint(rest)
+	if n <= 0 || length == 0 || length > MaxDeviceIDLength || length > uint64(len(rest)-n) {
+		return nil, ErrMalformedPacket
+	}
+	deviceID := string(rest[n : n+int(length)])
+	rest = rest[n+int(length):]
+
+	millis, n := binary.Uvarint(rest)
+	if n <= 0 || millis > math.MaxInt64 {
+		return nil, ErrMalformedPacket
+	}
+	rest = rest[n:]
+	lat, n := binary.Varint(rest)
+	if n <= 0 {
+		return nil, ErrMalformedPacket
+	}
+	rest = rest[n:]
+	lon, n := binary.Varint(rest)
+	if n <= 0 || n != len(rest) {
+		return nil, ErrMalformedPacket
+	}
+
+	if lat < -90*scale || lat > 90*scale {
+		return nil, ErrInvalidLatitude
+	}
+	if lon < -180*scale || lon > 180*scale {
+		return nil, ErrInvalidLongitude
+	}
+
+	return &signedPacket{
+		Packet: Packet{
+			DeviceID:  deviceID,
+			Timestamp: time.UnixMilli(int64(millis)),
+			Latitude:  float64(lat) / scale,
+			Longitude: float64(lon) / scale,
+		},
+		signed: signed,
+		mac:    mac,
+	}, nil
+}
+
+func sign(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,291 @@
+// Package udpingest receives driver locations from vehicle trackers that can
+// only send UDP datagrams.
+//
+// Each datagram carries one location and is signed with the key of the
+// device that sent it, so locations cannot be spoofed without the key. A
+// datagram also carries the time it was sent; datagrams outside the allowed
+// clock skew or not newer than the last one accepted from the device are
+// rejected, which stops replays. Devices are rate limited with token buckets.
+//
+// Accepted locations are buffered, keeping the latest one per driver, and
+// saved in batches through the same write path as POST /locations/batch. UDP
+// is lossy by nature and so is the listener: when the buffer is full or a
+// batch fails to save, locations are dropped and counted rather than retried.
+//
+// A datagram is
+//
+//	version   1 byte, 0x01
+//	device_id uvarint length, then that many bytes
+//	timestamp uvarint, Unix milliseconds
+//	lat, lon  zig-zag varints, in 1e-7 degrees
+//	mac       32 bytes, HMAC-SHA256 of all preceding bytes with the device key
+package udpingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+)
+
+// Packet results recorded by Metrics
+const (
+	ResultAccepted = "accepted"
+	// ResultInvalid is a malformed datagram or one with invalid coordinates
+	ResultInvalid = "invalid"
+	// ResultUnauthorized is a datagram from an unknown device, with a wrong
+	// MAC, or with a stale or replayed timestamp
+	ResultUnauthorized = "unauthorized"
+	ResultRateLimited  = "rate_limited"
+	// ResultDropped is a location lost to a full buffer or a failed save
+	ResultDropped = "dropped"
+)
+
+// rateLimitShards is the number of shards of the device rate limit store
+const rateLimitShards = 16
+
+// BatchSaver saves batches of driver locations
+type BatchSaver interface {
+	UpdateDriverLocations(ctx context.Context, locations []domain.DriverLocation) error
+}
+
+// Metrics records what happened to received datagrams
+type Metrics interface {
+	ObserveUDPPackets(result string, count int)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveUDPPackets(string, int) {}
+
+// Config tunes the listener
+type Config struct {
+	// FlushInterval is how often buffered locations are saved
+	FlushInterval time.Duration
+	// BatchSize is the number of buffered drivers that triggers a save
+	// before the interval ends; it is also the largest batch saved at once
+	BatchSize int
+	// MaxPending is the number of buffered drivers beyond which locations
+	// of other drivers are dropped
+	MaxPending int
+	// MaxClockSkew is how far the timestamp of a datagram may be from now
+	MaxClockSkew time.Duration
+	// RateLimit is the budget of each device; a zero Rate disables it
+	RateLimit middleware.RateLimit
+	// SaveTimeout bounds each batch save
+	SaveTimeout time.Duration
+}
+
+// DefaultConfig returns the listener settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		FlushInterval: time.Second,
+		BatchSize:     500,
+		MaxPending:    10000,
+		MaxClockSkew:  time.Minute,
+		RateLimit:     middleware.RateLimit{Rate: 1, Burst: 5},
+		SaveTimeout:   5 * time.Second,
+	}
+}
+
+// Server receives signed location datagrams and saves them in batches
+type Server struct {
+	saver   BatchSaver
+	devices DeviceStore
+	config  Config
+	metrics Metrics
+	logger  *slog.Logger
+	limiter middleware.RateLimitStore
+	now     func() time.Time
+
+	mu sync.Mutex
+	// pending holds the latest location of each driver until it is saved
+	pending map[string]domain.DriverLocation
+	// lastSeen holds the timestamp of the last datagram accepted per device
+	lastSeen map[string]time.Time
+	flushNow chan struct{}
+}
+
+// Option configures the server
+type Option func(*Server)
+
+// WithMetrics sets where the server records what happened to datagrams
+func WithMetrics(metrics Metrics) Option {
+	return func(s *Server) {
+		s.metrics = metrics
+	}
+}
+
+// WithLogger sets the logger of the server
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// NewServer creates a server accepting datagrams from the given devices
+func NewServer(saver BatchSaver, devices DeviceStore, config Config, options ...Option) *Server {
+	s := &Server{
+		saver:    saver,
+		devices:  devices,
+		config:   config,
+		metrics:  noopMetrics{},
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		limiter:  middleware.NewMemoryRateLimitStore(rateLimitShards),
+		now:      time.Now,
+		pending:  make(map[string]domain.DriverLocation),
+		lastSeen: make(map[string]time.Time),
+		flushNow: make(chan struct{}, 1),
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// Serve reads datagrams from conn until ctx is done or conn fails, then
+// saves the buffered locations and returns. It closes conn.
+func (s *Server) Serve(ctx context.Context, conn net.PacketConn) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	flushed := make(chan struct{})
+	go func() {
+		s.flushLoop(ctx)
+		close(flushed)
+	}()
+
+	// One byte more than the largest datagram, so oversized ones are seen
+	buf := make([]byte, MaxPacketSize+1)
+	var err error
+	for {
+		n, addr, readErr := conn.ReadFrom(buf)
+		if readErr != nil {
+			if ctx.Err() == nil && !errors.Is(readErr, net.ErrClosed) {
+				err = readErr
+			}
+			break
+		}
+		s.handlePacket(buf[:n], addr)
+	}
+
+	cancel()
+	<-flushed
+	return err
+}
+
+// handlePacket buffers the location of a datagram and records the result
+func (s *Server) handlePacket(data []byte, addr net.Addr) string {
+	result, deviceID := s.accept(data)
+	s.metrics.ObserveUDPPackets(result, 1)
+	if result != ResultAccepted {
+		s.logger.Debug("udp packet rejected", "result", result, "device_id", deviceID, "addr", addr)
+	}
+	return result
+}
+
+func (s *Server) accept(data []byte) (result string, deviceID string) {
+	packet, err := parsePacket(data)
+	if err != nil {
+		return ResultInvalid, ""
+	}
+	device, ok := s.devices.Device(packet.DeviceID)
+	if !ok || !packet.verify(device.Key) {
+		return ResultUnauthorized, packet.DeviceID
+	}
+
+	now := s.now()
+	if skew := now.Sub(packet.Timestamp); skew > s.config.MaxClockSkew || skew < -s.config.MaxClockSkew {
+		return ResultUnauthorized, device.ID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if last, ok := s.lastSeen[device.ID]; ok && !packet.Timestamp.After(last) {
+		return ResultUnauthorized, device.ID
+	}
+	if limit := s.config.RateLimit; limit.Rate > 0 && limit.Burst > 0 {
+		if !s.limiter.Take(device.ID, limit, now).Allowed {
+			return ResultRateLimited, device.ID
+		}
+	}
+	if _, ok := s.pending[device.DriverID]; !ok && len(s.pending) >= s.config.MaxPending {
+		return ResultDropped, device.ID
+	}
+
+	s.lastSeen[device.ID] = packet.Timestamp
+	s.pending[device.DriverID] = domain.DriverLocation{
+		DriverID: device.DriverID,
+		Location: domain.NewPoint(packet.Latitude, packet.Longitude),
+		Status:   "active",
+	}
+	if len(s.pending) >= s.config.BatchSize {
+		select {
+		case s.flushNow <- struct{}{}:
+		default:
+		}
+	}
+
+	return ResultAccepted, device.ID
+}
+
+// flushLoop saves the buffered locations every FlushInterval, whenever a
+// batch fills up, and once more when ctx is done
+func (s *Server) flushLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.flush()
+			return
+		case <-ticker.C:
+		case <-s.flushNow:
+		}
+		s.flush()
+	}
+}
+
+func (s *Server) flush() {
+	s.mu.Lock()
+	if len(s.pending) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	locations := make([]domain.DriverLocation, 0, len(s.pending))
+	for _, location := range s.pending {
+		locations = append(locations, location)
+	}
+	s.pending = make(map[string]domain.DriverLocation)
+	s.mu.Unlock()
+
+	for start := 0; start < len(locations); start += s.config.BatchSize {
+		end := min(start+s.config.BatchSize, len(locations))
+		s.save(locations[start:end])
+	}
+}
+
+func (s *Server) save(locations []domain.DriverLocation) {
+	// Saves outlive the listener so the last batch is not lost on shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.SaveTimeout)
+	defer cancel()
+
+	if err := s.saver.UpdateDriverLocations(ctx, locations); err != nil {
+		s.logger.Error("failed to save udp locations", "count", len(locations), "error", err)
+		s.metrics.ObserveUDPPackets(ResultDropped, len(locations))
+	}
+}
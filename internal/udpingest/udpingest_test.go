@@ -0,0 +1,396 @@
+package udpingest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/middleware"
+)
+
+var (
+	testNow = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	keyA    = []byte("0123456789abcdef-device-a")
+	keyB    = []byte("0123456789abcdef-device-b")
+)
+
+type fakeSaver struct {
+	mu      sync.Mutex
+	batches [][]domain.DriverLocation
+	err     error
+	saved   chan struct{}
+}
+
+func newFakeSaver() *fakeSaver {
+	return &fakeSaver{saved: make(chan struct{}, 100)}
+}
+
+func (f *fakeSaver) UpdateDriverLocations(ctx context.Context, locations []domain.DriverLocation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, append([]domain.DriverLocation(nil), locations...))
+	f.saved <- struct{}{}
+	return f.err
+}
+
+func (f *fakeSaver) locations() []domain.DriverLocation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var all []domain.DriverLocation
+	for _, batch := range f.batches {
+		all = append(all, batch...)
+	}
+	return all
+}
+
+type fakeMetrics struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (f *fakeMetrics) ObserveUDPPackets(result string, count int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.counts == nil {
+		f.counts = make(map[string]int)
+	}
+	f.counts[result] += count
+}
+
+func (f *fakeMetrics) count(result string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counts[result]
+}
+
+func testDevices() DeviceStore {
+	return NewDeviceStore([]Device{
+		{ID: "tracker-a", DriverID: "driver-1", Key: keyA},
+		{ID: "tracker-b", DriverID: "driver-2", Key: keyB},
+	})
+}
+
+func newTestServer(saver BatchSaver, config Config, metrics Metrics) *Server {
+	s := NewServer(saver, testDevices(), config, WithMetrics(metrics))
+	s.now = func() time.Time { return testNow }
+	return s
+}
+
+func packet(t *testing.T, deviceID string, key []byte, at time.Time, lat, lon float64) []byte {
+	t.Helper()
+	data, err := EncodePacket(Packet{DeviceID: deviceID, Timestamp: at, Latitude: lat, Longitude: lon}, key)
+	require.NoError(t, err)
+	return data
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	data := packet(t, "tracker-a", keyA, testNow, 41.0431234, -29.0099876)
+	assert.LessOrEqual(t, len(data), MaxPacketSize)
+
+	parsed, err := parsePacket(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, "tracker-a", parsed.DeviceID)
+	assert.True(t, testNow.Equal(parsed.Timestamp))
+	assert.InDelta(t, 41.0431234, parsed.Latitude, 1e-9)
+	assert.InDelta(t, -29.0099876, parsed.Longitude, 1e-9)
+	assert.True(t, parsed.verify(keyA))
+	assert.False(t, parsed.verify(keyB))
+}
+
+func TestParsePacketErrors(t *testing.T) {
+	valid := packet(t, "tracker-a", keyA, testNow, 41, 29)
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{name: "empty", data: nil, err: ErrMalformedPacket},
+		{name: "version", data: append([]byte{2}, valid[1:]...), err: ErrUnsupportedVersion},
+		{name: "truncated", data: append(append([]byte{}, valid[:len(valid)-MACSize-1]...), valid[len(valid)-MACSize:]...), err: ErrMalformedPacket},
+		{name: "trailing byte", data: append(append(append([]byte{}, valid[:len(valid)-MACSize]...), 0), valid[len(valid)-MACSize:]...), err: ErrMalformedPacket},
+		{name: "no device", data: append([]byte{PacketVersion, 0}, make([]byte, MACSize)...), err: ErrMalformedPacket},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePacket(tt.data)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestEncodePacketErrors(t *testing.T) {
+	_, err := EncodePacket(Packet{Latitude: 41, Longitude: 29}, keyA)
+	assert.ErrorIs(t, err, ErrInvalidDeviceID)
+	_, err = EncodePacket(Packet{DeviceID: "tracker-a", Latitude: 91}, keyA)
+	assert.ErrorIs(t, err, ErrInvalidLatitude)
+	_, err = EncodePacket(Packet{DeviceID: "tracker-a", Longitude: 181}, keyA)
+	assert.ErrorIs(t, err, ErrInvalidLongitude)
+}
+
+func TestHandlePacket(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   func(t *testing.T) []byte
+		result string
+	}{
+		{
+			name:   "accepted",
+			data:   func(t *testing.T) []byte { return packet(t, "tracker-a", keyA, testNow, 41, 29) },
+			result: ResultAccepted,
+		},
+		{
+			name:   "garbage",
+			data:   func(t *testing.T) []byte { return []byte("hello") },
+			result: ResultInvalid,
+		},
+		{
+			name:   "unknown device",
+			data:   func(t *testing.T) []byte { return packet(t, "tracker-x", keyA, testNow, 41, 29) },
+			result: ResultUnauthorized,
+		},
+		{
+			name:   "spoofed device",
+			data:   func(t *testing.T) []byte { return packet(t, "tracker-a", keyB, testNow, 41, 29) },
+			result: ResultUnauthorized,
+		},
+		{
+			name: "tampered location",
+			data: func(t *testing.T) []byte {
+				data := packet(t, "tracker-a", keyA, testNow, 41, 29)
+				data[len(data)-MACSize-1] ^= 0x02
+				return data
+			},
+			result: ResultUnauthorized,
+		},
+		{
+			name:   "stale",
+			data:   func(t *testing.T) []byte { return packet(t, "tracker-a", keyA, testNow.Add(-2*time.Minute), 41, 29) },
+			result: ResultUnauthorized,
+		},
+		{
+			name:   "from the future",
+			data:   func(t *testing.T) []byte { return packet(t, "tracker-a", keyA, testNow.Add(2*time.Minute), 41, 29) },
+			result: ResultUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := &fakeMetrics{}
+			s := newTestServer(newFakeSaver(), DefaultConfig(), metrics)
+
+			assert.Equal(t, tt.result, s.handlePacket(tt.data(t), nil))
+			assert.Equal(t, 1, metrics.count(tt.result))
+		})
+	}
+}
+
+func TestReplayRejected(t *testing.T) {
+	s := newTestServer(newFakeSaver(), DefaultConfig(), &fakeMetrics{})
+	data := packet(t, "tracker-a", keyA, testNow, 41, 29)
+
+	assert.Equal(t, ResultAccepted, s.handlePacket(data, nil))
+	assert.Equal(t, ResultUnauthorized, s.handlePacket(data, nil))
+	assert.Equal(t, ResultUnauthorized, s.handlePacket(packet(t, "tracker-a", keyA, testNow.Add(-time.Second), 41, 29), nil))
+	assert.Equal(t, ResultAccepted, s.handlePacket(packet(t, "tracker-a", keyA, testNow.Add(time.Second), 41, 29), nil))
+}
+
+func TestRateLimitPerDevice(t *testing.T) {
+	config := DefaultConfig()
+	config.RateLimit = middleware.RateLimit{Rate: 1, Burst: 2}
+	s := newTestServer(newFakeSaver(), config, &fakeMetrics{})
+
+	send := func(key []byte, deviceID string, i int) string {
+		return s.handlePacket(packet(t, deviceID, key, testNow.Add(time.Duration(i)*time.Millisecond), 41, 29), nil)
+	}
+	assert.Equal(t, ResultAccepted, send(keyA, "tracker-a", 1))
+	assert.Equal(t, ResultAccepted, send(keyA, "tracker-a", 2))
+	assert.Equal(t, ResultRateLimited, send(keyA, "tracker-a", 3))
+	assert.Equal(t, ResultAccepted, send(keyB, "tracker-b", 1))
+
+	// A rate limited datagram does not count as seen
+	s.now = func() time.Time { return testNow.Add(time.Second) }
+	assert.Equal(t, ResultAccepted, send(keyA, "tracker-a", 3))
+}
+
+func TestFlushCoalescesPerDriver(t *testing.T) {
+	saver := newFakeSaver()
+	s := newTestServer(saver, DefaultConfig(), &fakeMetrics{})
+
+	s.handlePacket(packet(t, "tracker-a", keyA, testNow, 41, 29), nil)
+	s.handlePacket(packet(t, "tracker-a", keyA, testNow.Add(time.Millisecond), 41.5, 29.5), nil)
+	s.handlePacket(packet(t, "tracker-b", keyB, testNow, 40, 28), nil)
+	s.flush()
+
+	require.Len(t, saver.batches, 1)
+	byDriver := make(map[string]domain.DriverLocation)
+	for _, location := range saver.batches[0] {
+		byDriver[location.DriverID] = location
+	}
+	require.Len(t, byDriver, 2)
+	assert.Equal(t, domain.NewPoint(41.5, 29.5), byDriver["driver-1"].Location)
+	assert.Equal(t, "active", byDriver["driver-1"].Status)
+	assert.Equal(t, domain.NewPoint(40, 28), byDriver["driver-2"].Location)
+
+	// Nothing is saved when nothing was received
+	s.flush()
+	assert.Len(t, saver.batches, 1)
+}
+
+func TestFlushSplitsBatches(t *testing.T) {
+	devices := make([]Device, 5)
+	for i := range devices {
+		devices[i] = Device{ID: string(rune('a' + i)), DriverID: string(rune('a' + i)), Key: keyA}
+	}
+	saver := newFakeSaver()
+	config := DefaultConfig()
+	config.BatchSize = 2
+	s := NewServer(saver, NewDeviceStore(devices), config)
+	s.now = func() time.Time { return testNow }
+
+	for _, device := range devices {
+		require.Equal(t, ResultAccepted, s.handlePacket(packet(t, device.ID, keyA, testNow, 41, 29), nil))
+	}
+	s.flush()
+
+	require.Len(t, saver.batches, 3)
+	assert.Len(t, saver.batches[0], 2)
+	assert.Len(t, saver.batches[2], 1)
+}
+
+func TestDroppedWhenBufferFull(t *testing.T) {
+	metrics := &fakeMetrics{}
+	config := DefaultConfig()
+	config.MaxPending = 1
+	s := newTestServer(newFakeSaver(), config, metrics)
+
+	assert.Equal(t, ResultAccepted, s.handlePacket(packet(t, "tracker-a", keyA, testNow, 41, 29), nil))
+	assert.Equal(t, ResultDropped, s.handlePacket(packet(t, "tracker-b", keyB, testNow, 41, 29), nil))
+	// A buffered driver can still be updated
+	assert.Equal(t, ResultAccepted, s.handlePacket(packet(t, "tracker-a", keyA, testNow.Add(time.Millisecond), 42, 29), nil))
+	assert.Equal(t, 1, metrics.count(ResultDropped))
+}
+
+func TestDroppedWhenSaveFails(t *testing.T) {
+	metrics := &fakeMetrics{}
+	saver := newFakeSaver()
+	saver.err = errors.New("mongo down")
+	s := newTestServer(saver, DefaultConfig(), metrics)
+
+	s.handlePacket(packet(t, "tracker-a", keyA, testNow, 41, 29), nil)
+	s.handlePacket(packet(t, "tracker-b", keyB, testNow, 41, 29), nil)
+	s.flush()
+
+	assert.Equal(t, 2, metrics.count(ResultDropped))
+}
+
+func TestServe(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	saver := newFakeSaver()
+	metrics := &fakeMetrics{}
+	config := DefaultConfig()
+	config.FlushInterval = time.Hour
+	config.BatchSize = 2
+	s := NewServer(saver, testDevices(), config, WithMetrics(metrics))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx, conn) }()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	now := time.Now()
+	for _, data := range [][]byte{
+		packet(t, "tracker-a", keyA, now, 41, 29),
+		[]byte(strings.Repeat("x", MaxPacketSize+10)),
+		packet(t, "tracker-b", keyB, now, 40, 28),
+	} {
+		_, err := client.Write(data)
+		require.NoError(t, err)
+	}
+
+	// A full batch is saved without waiting for the interval
+	select {
+	case <-saver.saved:
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch not saved")
+	}
+	assert.Len(t, saver.locations(), 2)
+	assert.Equal(t, 2, metrics.count(ResultAccepted))
+	assert.Equal(t, 1, metrics.count(ResultInvalid))
+
+	// Locations still buffered are saved on shutdown
+	_, err = client.Write(packet(t, "tracker-a", keyA, now.Add(time.Millisecond), 42, 29))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return metrics.count(ResultAccepted) == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-served:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return")
+	}
+	assert.Len(t, saver.locations(), 3)
+}
+
+func TestLoadDevices(t *testing.T) {
+	devices, err := LoadDevices(strings.NewReader(`
+# device_id,driver_id,hex_key
+tracker-a, driver-1, 000102030405060708090a0b0c0d0e0f
+
+tracker-b,driver-2,101112131415161718191a1b1c1d1e1f
+`))
+	require.NoError(t, err)
+
+	require.Len(t, devices, 2)
+	assert.Equal(t, Device{
+		ID:       "tracker-a",
+		DriverID: "driver-1",
+		Key:      []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	}, devices[0])
+
+	device, ok := NewDeviceStore(devices).Device("tracker-b")
+	require.True(t, ok)
+	assert.Equal(t, "driver-2", device.DriverID)
+}
+
+func TestLoadDevicesErrors(t *testing.T) {
+	key := "000102030405060708090a0b0c0d0e0f"
+
+	tests := []struct {
+		name    string
+		input   string
+		message string
+	}{
+		{name: "fields", input: "tracker-a,driver-1", message: "line 1: want device_id,driver_id,hex_key"},
+		{name: "device id", input: "," + "driver-1," + key, message: "line 1: udpingest: invalid device id"},
+		{name: "driver id", input: "tracker-a,," + key, message: "line 1: missing driver id"},
+		{name: "hex", input: "tracker-a,driver-1,zz", message: "line 1: invalid key"},
+		{name: "short key", input: "tracker-a,driver-1,0001", message: "line 1: key must be at least 16 bytes"},
+		{name: "duplicate", input: "tracker-a,driver-1," + key + "\ntracker-a,driver-2," + key, message: `line 2: duplicate device "tracker-a"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadDevices(strings.NewReader(tt.input))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+		})
+	}
+}
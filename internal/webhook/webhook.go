@@ -0,0 +1,598 @@
+// Package webhook calls partner endpoints subscribed to ride events.
+//
+// Publish hands the event to the dispatcher, which records a delivery for
+// every active subscription to it and queues them. Workers POST the JSON
+// payload to the subscription URL with these headers:
+//
+//	X-Webhook-Event: ride.completed
+//	X-Webhook-Delivery: <delivery ID>
+//	X-Webhook-Timestamp: <unix seconds>
+//	X-Webhook-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">
+//
+// Any 2xx answer is a success; redirects are not followed. Unless the
+// configuration allows it, connections to loopback, private, link-local
+// and other non-public addresses are refused, whatever the URL resolves
+// to. Failed attempts are retried with exponential backoff. Deliveries that
+// fail every attempt, cannot be queued or whose subscription was
+// deactivated are kept as dead letters. Every attempt is recorded in the
+// delivery log.
+//
+// Deliveries still queued or waiting for a retry when the dispatcher stops
+// are left pending in the log, and Start queues them again when their
+// attempt is due. A delivery may therefore be sent more than once, e.g. when
+// an instance stops mid-attempt; receivers can drop repeated delivery IDs.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+)
+
+// Delivery results recorded by Metrics
+const (
+	ResultDelivered = "delivered"
+	// ResultRetried is a failed attempt that will be retried
+	ResultRetried      = "retried"
+	ResultDeadLettered = "dead_lettered"
+)
+
+// ErrPrivateAddress is returned when connecting to an address that is not
+// public
+var ErrPrivateAddress = errors.New("webhook: connection to non-public address refused")
+
+// maxResponseBytes is how much of a response body is read before the
+// connection is released
+const maxResponseBytes = 64 << 10
+
+// Metrics records what happened to deliveries
+type Metrics interface {
+	ObserveWebhookDelivery(event, result string)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveWebhookDelivery(string, string) {}
+
+// Payload is the body sent to endpoints
+type Payload struct {
+	// ID identifies the event. Deliveries of one event to several
+	// subscriptions share it.
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Config tunes the dispatcher
+type Config struct {
+	// Workers is how many deliveries are sent at once
+	Workers int
+	// QueueSize is how many deliveries wait for a worker before new ones are
+	// dead-lettered. As many published events can wait to be recorded
+	// before new ones are dropped.
+	QueueSize int
+	// MaxAttempts is how many times a delivery is tried
+	MaxAttempts int
+	// BaseBackoff is the delay before the first retry. It doubles with every
+	// further retry, up to MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Timeout bounds each attempt
+	Timeout time.Duration
+	// AllowPrivateAddresses lets deliveries reach loopback, private and
+	// link-local addresses, e.g. for local development
+	AllowPrivateAddresses bool
+}
+
+// DefaultConfig returns the dispatcher settings used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		Workers:     4,
+		QueueSize:   1000,
+		MaxAttempts: 6,
+		BaseBackoff: 5 * time.Second,
+		MaxBackoff:  10 * time.Minute,
+		Timeout:     10 * time.Second,
+	}
+}
+
+// Dispatcher sends events to the endpoints subscribed to them
+type Dispatcher struct {
+	repo    repository.WebhookRepository
+	config  Config
+	client  *http.Client
+	metrics Metrics
+	logger  *slog.Logger
+
+	events chan publishedEvent
+	queue  chan *domain.WebhookDelivery
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// publishedEvent is an event waiting for its deliveries to be recorded
+type publishedEvent struct {
+	// ctx carries the values of the publishing request, for logging
+	ctx     context.Context
+	event   string
+	payload []byte
+}
+
+// Option configures the dispatcher
+type Option func(*Dispatcher)
+
+// WithHTTPClient sets the client deliveries are sent with. Its redirect
+// policy is replaced so that redirects are not followed. Unless private
+// addresses are allowed, an *http.Transport is copied to refuse them and to
+// connect without a proxy; other transports must check addresses themselves.
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithMetrics sets where the dispatcher records what happened to deliveries
+func WithMetrics(metrics Metrics) Option {
+	return func(d *Dispatcher) {
+		d.metrics = metrics
+	}
+}
+
+// WithLogger sets the logger of the dispatcher
+func WithLogger(logger *slog.Logger) Option {
+	return func(d *Dispatcher) {
+		d.logger = logger
+	}
+}
+
+// NewDispatcher creates a dispatcher delivering to the subscriptions stored
+// in repo. Deliveries are only sent once Start is called.
+func NewDispatcher(repo repository.WebhookRepository, config Config, options ...Option) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		repo:    repo,
+		config:  config,
+		client:  &http.Client{},
+		metrics: noopMetrics{},
+		logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		events:  make(chan publishedEvent, config.QueueSize),
+		queue:   make(chan *domain.WebhookDelivery, config.QueueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	for _, option := range options {
+		option(d)
+	}
+
+	client := *d.client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	if !config.AllowPrivateAddresses {
+		client.Transport = publicTransport(client.Transport)
+	}
+	d.client = &client
+
+	return d
+}
+
+// publicTransport returns a copy of transport that only connects to public
+// addresses. The address is checked when connecting, after the host name
+// was resolved, so names resolving to private addresses are refused too.
+func publicTransport(transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	base, ok := transport.(*http.Transport)
+	if !ok {
+		return transport
+	}
+
+	public := base.Clone()
+	// Through a proxy only the address of the proxy could be checked
+	public.Proxy = nil
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   controlPublic,
+	}
+	public.DialContext = dialer.DialContext
+	return public
+}
+
+// controlPublic refuses connections to addresses that are not public
+func controlPublic(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+// specialPurposeNets are the reserved ranges that net.IP has no predicate
+// for: shared address space (carrier-grade NAT), "this network", benchmarking
+// and the NAT64 prefix, which embeds any IPv4 address
+var specialPurposeNets = parseCIDRs("100.64.0.0/10", "0.0.0.0/8", "198.18.0.0/15", "64:ff9b::/96")
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+// IsPublicIP reports whether ip is a public unicast address, i.e. not a
+// loopback, private, link-local, multicast, unspecified or other
+// special-purpose one
+func IsPublicIP(ip net.IP) bool {
+	for _, n := range specialPurposeNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// Start queues the deliveries left pending by an earlier run and starts
+// recording published events and sending deliveries
+func (d *Dispatcher) Start() {
+	// Loaded before any event is recorded, so that deliveries of this run
+	// are not queued twice
+	ctx, cancel := context.WithTimeout(d.ctx, d.config.Timeout)
+	pending, err := d.repo.ListPendingDeliveries(ctx)
+	cancel()
+	if err != nil {
+		d.logger.Error("failed to load pending webhook deliveries", "error", err)
+	}
+
+	for i := 0; i < d.config.Workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+
+	d.wg.Add(2)
+	go d.recordEvents()
+	go d.resume(pending)
+}
+
+// Stop stops the workers and waits for the attempts in flight
+func (d *Dispatcher) Stop() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// Publish hands event to the dispatcher, which records and queues a
+// delivery of it for every active subscription. data is sent as the data of
+// the payload. Publish does not wait for the deliveries to be recorded, and
+// failures are logged rather than returned, so publishing never fails or
+// slows down the caller.
+func (d *Dispatcher) Publish(ctx context.Context, event string, data any) {
+	if d.ctx.Err() != nil {
+		d.logger.WarnContext(ctx, "webhook dispatcher stopped, dropping event", "event", event)
+		return
+	}
+
+	// Encoded right away, as data may change once Publish returns
+	payload, err := json.Marshal(Payload{
+		ID:        uuid.New().String(),
+		Event:     event,
+		CreatedAt: time.Now(),
+		Data:      data,
+	})
+	if err != nil {
+		d.logger.ErrorContext(ctx, "failed to encode webhook payload", "event", event, "error", err)
+		return
+	}
+
+	select {
+	case d.events <- publishedEvent{ctx: context.WithoutCancel(ctx), event: event, payload: payload}:
+	default:
+		d.logger.ErrorContext(ctx, "webhook event queue is full, dropping event", "event", event)
+	}
+}
+
+// recordEvents records the deliveries of published events. Events still
+// waiting when the dispatcher stops are recorded as pending, to be sent
+// after the next start.
+func (d *Dispatcher) recordEvents() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			for {
+				select {
+				case published := <-d.events:
+					d.record(published)
+				default:
+					return
+				}
+			}
+		case published := <-d.events:
+			d.record(published)
+		}
+	}
+}
+
+// record records and queues a delivery of a published event for every
+// active subscription to it
+func (d *Dispatcher) record(published publishedEvent) {
+	ctx, cancel := context.WithTimeout(published.ctx, d.config.Timeout)
+	defer cancel()
+
+	subscriptions, err := d.repo.ListSubscriptionsForEvent(ctx, published.event)
+	if err != nil {
+		d.logger.ErrorContext(ctx, "failed to list webhook subscriptions", "event", published.event, "error", err)
+		return
+	}
+
+	now := time.Now()
+	for _, subscription := range subscriptions {
+		delivery := &domain.WebhookDelivery{
+			ID:             uuid.New().String(),
+			SubscriptionID: subscription.ID,
+			Event:          published.event,
+			Payload:        published.payload,
+			Status:         domain.WebhookDeliveryPending,
+			Attempts:       []domain.WebhookAttempt{},
+			CreatedAt:      now,
+		}
+		if err := d.repo.CreateDelivery(ctx, delivery); err != nil {
+			d.logger.ErrorContext(ctx, "failed to record webhook delivery",
+				"event", published.event, "subscription_id", subscription.ID, "error", err)
+			continue
+		}
+
+		if d.ctx.Err() != nil {
+			// Stopped; the delivery stays pending
+			continue
+		}
+		select {
+		case d.queue <- delivery:
+		default:
+			d.deadLetter(delivery, "delivery queue is full")
+			d.save(delivery)
+		}
+	}
+}
+
+// resume queues pending deliveries in the order their attempts are due,
+// waiting for each to be due and for room in the queue
+func (d *Dispatcher) resume(pending []*domain.WebhookDelivery) {
+	defer d.wg.Done()
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		return dueAt(pending[i]).Before(dueAt(pending[j]))
+	})
+
+	for _, delivery := range pending {
+		timer := time.NewTimer(time.Until(dueAt(delivery)))
+		select {
+		case <-d.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case d.queue <- delivery:
+		}
+	}
+}
+
+// dueAt is when the next attempt of a pending delivery is due
+func dueAt(delivery *domain.WebhookDelivery) time.Time {
+	if delivery.NextAttemptAt != nil {
+		return *delivery.NextAttemptAt
+	}
+	return delivery.CreatedAt
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case delivery := <-d.queue:
+			d.deliver(delivery)
+		}
+	}
+}
+
+// deliver makes one attempt and schedules a retry when it failed and
+// attempts are left
+func (d *Dispatcher) deliver(delivery *domain.WebhookDelivery) {
+	subscription, err := d.subscription(delivery.SubscriptionID)
+	switch {
+	case err != nil:
+		delivery.Attempts = append(delivery.Attempts, domain.WebhookAttempt{
+			At:    time.Now(),
+			Error: "failed to load subscription",
+		})
+		d.logger.Error("failed to load webhook subscription", "subscription_id", delivery.SubscriptionID, "error", err)
+	case subscription == nil:
+		// Deleted while the delivery was queued or waiting for a retry
+		d.deadLetter(delivery, "subscription was deleted")
+		d.save(delivery)
+		return
+	case !subscription.Active:
+		d.deadLetter(delivery, "subscription is inactive")
+		d.save(delivery)
+		return
+	default:
+		attempt, sent := d.send(subscription, delivery)
+		if !sent {
+			// Stopped while sending; the delivery stays pending
+			return
+		}
+		delivery.Attempts = append(delivery.Attempts, attempt)
+	}
+
+	last := delivery.Attempts[len(delivery.Attempts)-1]
+	switch {
+	case last.Error == "" && last.StatusCode >= 200 && last.StatusCode < 300:
+		completedAt := time.Now()
+		delivery.Status = domain.WebhookDeliverySucceeded
+		delivery.NextAttemptAt = nil
+		delivery.CompletedAt = &completedAt
+		d.metrics.ObserveWebhookDelivery(delivery.Event, ResultDelivered)
+	case len(delivery.Attempts) >= d.config.MaxAttempts:
+		d.deadLetter(delivery, fmt.Sprintf("gave up after %d attempts", len(delivery.Attempts)))
+	default:
+		delay := d.backoff(len(delivery.Attempts))
+		nextAttemptAt := time.Now().Add(delay)
+		delivery.NextAttemptAt = &nextAttemptAt
+		d.metrics.ObserveWebhookDelivery(delivery.Event, ResultRetried)
+		// Saved before it is queued again, when another worker may take it
+		d.save(delivery)
+		d.retry(delivery, delay)
+		return
+	}
+
+	d.save(delivery)
+}
+
+// send POSTs the payload of delivery to the subscription URL. It reports
+// false when the dispatcher stopped before the endpoint answered.
+func (d *Dispatcher) send(subscription *domain.WebhookSubscription, delivery *domain.WebhookDelivery) (domain.WebhookAttempt, bool) {
+	ctx, cancel := context.WithTimeout(d.ctx, d.config.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	attempt := domain.WebhookAttempt{At: start}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt, true
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(start.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(subscription.Secret, start.Unix(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	attempt.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		if d.ctx.Err() != nil {
+			return attempt, false
+		}
+		attempt.Error = err.Error()
+		return attempt, true
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		attempt.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return attempt, true
+}
+
+// retry queues delivery again after delay, unless the dispatcher stops first
+func (d *Dispatcher) retry(delivery *domain.WebhookDelivery, delay time.Duration) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		select {
+		case <-d.ctx.Done():
+		case d.queue <- delivery:
+		}
+	}()
+}
+
+// backoff returns the delay after the given number of failed attempts:
+// BaseBackoff doubled for every attempt after the first, up to MaxBackoff,
+// of which up to a quarter is random so that retries spread out
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.config.BaseBackoff << uint(attempts-1)
+	if delay <= 0 || delay > d.config.MaxBackoff {
+		delay = d.config.MaxBackoff
+	}
+	if jitter := int64(delay / 4); jitter > 0 {
+		delay -= time.Duration(rand.Int63n(jitter))
+	}
+	return delay
+}
+
+func (d *Dispatcher) deadLetter(delivery *domain.WebhookDelivery, reason string) {
+	completedAt := time.Now()
+	delivery.Status = domain.WebhookDeliveryDeadLetter
+	delivery.Error = reason
+	delivery.NextAttemptAt = nil
+	delivery.CompletedAt = &completedAt
+	d.metrics.ObserveWebhookDelivery(delivery.Event, ResultDeadLettered)
+	d.logger.Warn("webhook delivery dead-lettered",
+		"delivery_id", delivery.ID,
+		"subscription_id", delivery.SubscriptionID,
+		"event", delivery.Event,
+		"reason", reason,
+	)
+}
+
+func (d *Dispatcher) subscription(id string) (*domain.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.config.Timeout)
+	defer cancel()
+
+	return d.repo.GetSubscription(ctx, id)
+}
+
+// save records the state of delivery. It is not bound to the dispatcher
+// context, so the outcome of the last attempts is kept when stopping.
+func (d *Dispatcher) save(delivery *domain.WebhookDelivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.config.Timeout)
+	defer cancel()
+
+	if err := d.repo.UpdateDelivery(ctx, delivery); err != nil {
+		d.logger.Error("failed to save webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
@@ -0,0 +1,430 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yusufatac/bitaksi-case-study/internal/domain"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository"
+	"github.com/yusufatac/bitaksi-case-study/internal/repository/memory"
+)
+
+const testSecret = "whsec_test"
+
+// receivedRequest is a delivery as seen by a receiver
+type receivedRequest struct {
+	at     time.Time
+	header http.Header
+	body   []byte
+}
+
+// receiver is an endpoint answering deliveries with the given statuses in
+// turn, repeating the last one
+type receiver struct {
+	server   *httptest.Server
+	statuses []int
+
+	mu       sync.Mutex
+	requests []receivedRequest
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		r.mu.Lock()
+		r.requests = append(r.requests, receivedRequest{at: time.Now(), header: req.Header.Clone(), body: body})
+		status := r.statuses[min(len(r.requests), len(r.statuses))-1]
+		r.mu.Unlock()
+
+		if status == http.StatusFound {
+			http.Redirect(w, req, "/elsewhere", status)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *receiver) received() []receivedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedRequest(nil), r.requests...)
+}
+
+type recordingMetrics struct {
+	mu      sync.Mutex
+	results []string
+}
+
+func (m *recordingMetrics) ObserveWebhookDelivery(_, result string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results = append(m.results, result)
+}
+
+func (m *recordingMetrics) observed() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.results...)
+}
+
+func testConfig() Config {
+	return Config{
+		Workers:     2,
+		QueueSize:   10,
+		MaxAttempts: 3,
+		BaseBackoff: 20 * time.Millisecond,
+		MaxBackoff:  time.Second,
+		Timeout:     time.Second,
+		// The receivers listen on loopback
+		AllowPrivateAddresses: true,
+	}
+}
+
+func subscribe(t *testing.T, repo repository.WebhookRepository, url string, active bool, events ...string) *domain.WebhookSubscription {
+	subscription := &domain.WebhookSubscription{
+		ID:        uuid.New().String(),
+		URL:       url,
+		Events:    events,
+		Secret:    testSecret,
+		Active:    active,
+		CreatedAt: time.Now(),
+	}
+	require.NoError(t, repo.CreateSubscription(context.Background(), subscription))
+	return subscription
+}
+
+func startDispatcher(t *testing.T, repo repository.WebhookRepository, config Config, options ...Option) *Dispatcher {
+	d := NewDispatcher(repo, config, options...)
+	d.Start()
+	t.Cleanup(d.Stop)
+	return d
+}
+
+// waitForDelivery waits until the only delivery of subscription has status
+func waitForDelivery(t *testing.T, repo repository.WebhookRepository, subscriptionID, status string) *domain.WebhookDelivery {
+	t.Helper()
+
+	var delivery *domain.WebhookDelivery
+	require.Eventually(t, func() bool {
+		deliveries, err := repo.ListDeliveries(context.Background(), domain.WebhookDeliveryFilter{SubscriptionID: subscriptionID})
+		require.NoError(t, err)
+		if len(deliveries) != 1 || deliveries[0].Status != status {
+			return false
+		}
+		delivery = deliveries[0]
+		return true
+	}, 2*time.Second, 5*time.Millisecond)
+	return delivery
+}
+
+func TestDispatcherDeliversSignedPayload(t *testing.T) {
+	repo := memory.NewWebhookRepository()
+	target := newReceiver(t, http.StatusNoContent)
+	other := newReceiver(t, http.StatusNoContent)
+	subscription := subscribe(t, repo, target.server.URL, true, domain.EventRideMatched, domain.EventRideCompleted)
+	subscribe(t, repo, other.server.URL, true, domain.EventRideMatched)
+	subscribe(t, repo, other.server.URL, false, domain.EventRideCompleted)
+	metrics := &recordingMetrics{}
+	d := startDispatcher(t, repo, testConfig(), WithMetrics(metrics))
+
+	ride := &domain.Ride{ID: "ride-1", RiderID: "rider-1", DriverID: "driver-1", Status: domain.RideStatusCompleted}
+	d.Publish(context.Background(), domain.EventRideCompleted, ride)
+
+	delivery := waitForDelivery(t, repo, subscription.ID, domain.WebhookDeliverySucceeded)
+	require.Len(t, delivery.Attempts, 1)
+	assert.Equal(t, http.StatusNoContent, delivery.Attempts[0].StatusCode)
+	assert.Empty(t, delivery.Attempts[0].Error)
+	assert.NotNil(t, delivery.CompletedAt)
+	assert.Equal(t, []string{ResultDelivered}, metrics.observed())
+
+	requests := target.received()
+	require.Len(t, requests, 1)
+	request := requests[0]
+	assert.Equal(t, "application/json", request.header.Get("Content-Type"))
+	assert.Equal(t, domain.EventRideCompleted, request.header.Get(HeaderEvent))
+	assert.Equal(t, delivery.ID, request.header.Get(HeaderDelivery))
+
+	timestamp, err := strconv.ParseInt(request.header.Get(HeaderTimestamp), 10, 64)
+	require.NoError(t, err)
+	assert.True(t, Verify(testSecret, timestamp, request.body, request.header.Get(HeaderSignature), time.Minute))
+
+	var payload struct {
+		ID    string      `json:"id"`
+		Event string      `json:"event"`
+		Data  domain.Ride `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(request.body, &payload))
+	assert.NotEmpty(t, payload.ID)
+	assert.Equal(t, domain.EventRideCompleted, payload.Event)
+	assert.Equal(t, "ride-1", payload.Data.ID)
+	assert.JSONEq(t, string(request.body), string(delivery.Payload))
+
+	// Neither the subscription to another event nor the inactive one is called
+	assert.Empty(t, other.received())
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	repo := memory.NewWebhookRepository()
+	target := newReceiver(t, http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusOK)
+	subscription := subscribe(t, repo, target.server.URL, true, domain.EventRideMatched)
+	metrics := &recordingMetrics{}
+	d := startDispatcher(t, repo, testConfig(), WithMetrics(metrics))
+
+	d.Publish(context.Background(), domain.EventRideMatched, map[string]string{"ride_id": "ride-1"})
+
+	delivery := waitForDelivery(t, repo, subscription.ID, domain.WebhookDeliverySucceeded)
+	require.Len(t, delivery.Attempts, 3)
+	assert.Equal(t, http.StatusInternalServerError, delivery.Attempts[0].StatusCode)
+	assert.Equal(t, "unexpected status 500", delivery.Attempts[0].Error)
+	assert.Equal(t, http.StatusServiceUnavailable, delivery.Attempts[1].StatusCode)
+	assert.Equal(t, http.StatusOK, delivery.Attempts[2].StatusCode)
+	assert.Nil(t, delivery.NextAttemptAt)
+	assert.Equal(t, []string{ResultRetried, ResultRetried, ResultDelivered}, metrics.observed())
+
+	// The delay doubles after every failed attempt, less up to a quarter of jitter
+	requests := target.received()
+	require.Len(t, requests, 3)
+	assert.GreaterOrEqual(t, requests[1].at.Sub(requests[0].at), 15*time.Millisecond)
+	assert.GreaterOrEqual(t, requests[2].at.Sub(requests[1].at), 30*time.Millisecond)
+
+	// Every attempt is signed with its own timestamp
+	for _, request := range requests {
+		timestamp, err := strconv.ParseInt(request.header.Get(HeaderTimestamp), 10, 64)
+		require.NoError(t, err)
+		assert.True(t, Verify(testSecret, timestamp, request.body, request.header.Get(HeaderSignature), time.Minute))
+	}
+}
+
+func TestDispatcherDeadLetters(t *testing.T) {
+	repo := memory.NewWebhookRepository()
+	target := newReceiver(t, http.StatusBadGateway)
+	subscription := subscribe(t, repo, target.server.URL, true, domain.EventRideCompleted)
+	metrics := &recordingMetrics{}
+	d := startDispatcher(t, repo, testConfig(), WithMetrics(metrics))
+
+	d.Publish(context.Background(), domain.EventRideCompleted, map[string]string{"ride_id": "ride-1"})
+
+	delivery := waitForDelivery(t, repo, subscription.ID, domain.WebhookDeliveryDeadLetter)
+	assert.Len(t, delivery.Attempts, 3)
+	assert.Equal(t, "gave up after 3 attempts", delivery.Error)
+	assert.NotNil(t, delivery.CompletedAt)
+	assert.Len(t, target.received(), 3)
+	assert.Equal(t, []string{ResultRetried, ResultRetried, ResultDeadLettered}, metrics.observed())
+
+	deadLetters, err := repo.ListDeliveries(context.Background(), domain.WebhookDeliveryFilter{
+		SubscriptionID: subscription.ID,
+		Status:         domain.WebhookDeliveryDeadLetter,
+	})
+	require.NoError(t, err)
+	assert.Len(t, deadLetters, 1)
+}
+
+func TestDispatcherDoesNotFollowRedirects(t *testing.T) {
+	repo := memory.NewWebhookRepository()
+	target := newReceiver(t, http.StatusFound)
+	subscription := subscribe(t, repo, target.server.URL, true, domain.EventRideMatched)
+	config := testConfig()
+	config.MaxAttempts = 1
+	d := startDispatcher(t, repo, config)
+
+	d.Publish(context.Background(), domain.EventRideMatched, nil)
+
+	delivery := waitForDelivery(t, repo, subscription.ID, domain.WebhookDeliveryDeadLetter)
+	require.Len(t, delivery.Attempts, 1)
+	assert.Equal(t, http.StatusFound, delivery.Attempts[0].StatusCode)
+	assert.Len(t, target.received(), 1)
+}
+
+func TestDispatcherDeadLettersWhenQueueIsFull(t *testing.T) {
+	repo := memory.NewWebhookRepository()
+	target := newReceiver(t, http.StatusOK)
+	subscription := subscribe(t, repo, target.server.URL, true, domain.EventRideMatched)
+	config := testConfig()
+	config.QueueSize = 1
+	// No workers take deliveries off the queue
+	config.Workers = 0
+	d := startDispatcher(t, repo, config)
+
+	d.Publish(context.Background(), domain.EventRideMatched, nil)
+	waitForDelivery(t, repo, subscription.ID, domain.WebhookDeliveryPending)
+	d.Publish(context.Background(), domain.EventRideMatched, nil)
+
+	var deadLetters []*domain.WebhookDelivery
+	require.Eventually(t, func() bool {
+		var err error
+		deadLetters, err = repo.ListDeliveries(context.Background(), domain.WebhookDeliveryFilter{
+			SubscriptionID: subscription.ID,
+			Status:         domain.WebhookDeliveryDeadLetter,
+		})
+		require.NoError(t, err)
+		return len(deadLetters) == 1
+	}, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, "delivery queue is full", deadLetters[0].Error)
+	assert.Empty(t, deadLetters[0].Attempts)
+	assert.Empty(t, target.received())
+}
+
+// slowRepository delays listing subscriptions until released
+type slowRepository struct {
+	repository.WebhookRepository
+	release chan struct{}
+}
+
+func (r *slowRepository) ListSubscriptionsForEvent(ctx context.Context, event string) ([]*domain.WebhookSubscription, error) {
+	<-r.release
+	return r.WebhookRepository.ListSubscriptionsForEvent(ctx, event)
+}
+
+func TestDispatcherPublishDoesNotWait(t *testing.T) {
+	repo := &slowRepository{WebhookRepository: memory.NewWebhookRepository(), release: make(chan struct{})}
+	target := newReceiver(t, http.StatusOK)
+	subscription := subscribe(t, repo, target.server.URL, true, domain.EventRideMatched)
+	d := startDispatcher(t, repo, testConfig())
+
+	published := make(chan struct{})
+	go func() {
+		d.Publish(context.Background(), domain.EventRideMatched, nil)
+		close(published)
+	}()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish waited for the subscriptions to be listed")
+	}
+
+	close(repo.release)
+	waitForDelivery(t, repo, subscription.ID, domain.WebhookDeliverySucceeded)
+}
+
+func TestDispatcherResumesPendingDeliveries(t *testing.T) {
+	repo := memory.NewWebhookRepository()
+	target := newReceiver(t, http.StatusOK)
+	subscription := subscribe(t, repo, target.server.URL, true, domain.EventRideMatched)
+
+	// Left pending by an earlier run, the first attempt due shortly
+	nextAttemptAt := time.Now().Add(50 * time.Millisecond)
+	require.NoError(t, repo.CreateDelivery(context.Background(), &domain.WebhookDelivery{
+		ID:             uuid.New().String(),
+		SubscriptionID: subscription.ID,
+		Event:          domain.EventRideMatched,
+		Payload:        []byte(`{}`),
+		Status:         domain.WebhookDeliveryPending,
+		Attempts:       []domain.WebhookAttempt{{At: time.Now(), StatusCode: http.StatusBadGateway, Error: "unexpected status 502"}},
+		NextAttemptAt:  &nextAttemptAt,
+		CreatedAt:      time.Now(),
+	}))
+
+	startDispatcher(t, repo, testConfig())
+
+	delivery := waitForDelivery(t, repo, subscription.ID, domain.WebhookDeliverySucceeded)
+	assert.Len(t, delivery.Attempts, 2)
+	requests := target.received()
+	require.Len(t, requests, 1)
+	assert.False(t, requests[0].at.Before(nextAttemptAt))
+}
+
+func TestDispatcherRefusesPrivateAddresses(t *testing.T) {
+	repo := memory.NewWebhookRepository()
+	target := newReceiver(t, http.StatusOK)
+	subscription := subscribe(t, repo, target.server.URL, true, domain.EventRideMatched)
+	config := testConfig()
+	config.AllowPrivateAddresses = false
+	config.MaxAttempts = 1
+	d := startDispatcher(t, repo, config)
+
+	d.Publish(context.Background(), domain.EventRideMatched, nil)
+
+	delivery := waitForDelivery(t, repo, subscription.ID, domain.WebhookDeliveryDeadLetter)
+	require.Len(t, delivery.Attempts, 1)
+	assert.Contains(t, delivery.Attempts[0].Error, ErrPrivateAddress.Error())
+	assert.Empty(t, target.received())
+}
+
+func TestIsPublicIP(t *testing.T) {
+	for _, address := range []string{"8.8.8.8", "2606:4700:4700::1111"} {
+		assert.True(t, IsPublicIP(net.ParseIP(address)), address)
+	}
+	for _, address := range []string{
+		"127.0.0.1", "::1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"fe80::1", "fd00::1", "0.0.0.0", "::", "224.0.0.1", "::ffff:127.0.0.1",
+		"100.64.0.1", "100.127.255.254", "0.1.2.3", "198.18.0.1", "198.19.255.254", "64:ff9b::808:808",
+	} {
+		assert.False(t, IsPublicIP(net.ParseIP(address)), address)
+	}
+}
+
+func TestDispatcherDeadLettersForInactiveSubscription(t *testing.T) {
+	repo := memory.NewWebhookRepository()
+	target := newReceiver(t, http.StatusInternalServerError)
+	subscription := subscribe(t, repo, target.server.URL, true, domain.EventRideMatched)
+	config := testConfig()
+	config.BaseBackoff = 100 * time.Millisecond
+	d := startDispatcher(t, repo, config)
+
+	d.Publish(context.Background(), domain.EventRideMatched, nil)
+
+	// Deactivated while waiting for the retry
+	require.Eventually(t, func() bool { return len(target.received()) == 1 }, time.Second, 5*time.Millisecond)
+	subscription.Active = false
+	require.NoError(t, repo.UpdateSubscription(context.Background(), subscription))
+
+	delivery := waitForDelivery(t, repo, subscription.ID, domain.WebhookDeliveryDeadLetter)
+	assert.Equal(t, "subscription is inactive", delivery.Error)
+	assert.Len(t, delivery.Attempts, 1)
+	assert.Len(t, target.received(), 1)
+}
+
+func TestDispatcherDeadLettersForDeletedSubscription(t *testing.T) {
+	repo := memory.NewWebhookRepository()
+
+	// Left pending by an earlier run, after its subscription was deleted
+	subscriptionID := uuid.New().String()
+	nextAttemptAt := time.Now()
+	require.NoError(t, repo.CreateDelivery(context.Background(), &domain.WebhookDelivery{
+		ID:             uuid.New().String(),
+		SubscriptionID: subscriptionID,
+		Event:          domain.EventRideMatched,
+		Payload:        []byte(`{}`),
+		Status:         domain.WebhookDeliveryPending,
+		NextAttemptAt:  &nextAttemptAt,
+		CreatedAt:      time.Now(),
+	}))
+
+	startDispatcher(t, repo, testConfig())
+
+	delivery := waitForDelivery(t, repo, subscriptionID, domain.WebhookDeliveryDeadLetter)
+	assert.Equal(t, "subscription was deleted", delivery.Error)
+	assert.Empty(t, delivery.Attempts)
+}
+
+func TestVerify(t *testing.T) {
+	payload := []byte(`{"event":"ride.matched"}`)
+	now := time.Now().Unix()
+	signature := Sign(testSecret, now, payload)
+
+	assert.True(t, Verify(testSecret, now, payload, signature, time.Minute))
+	assert.False(t, Verify("other-secret", now, payload, signature, time.Minute))
+	assert.False(t, Verify(testSecret, now, []byte(`{"event":"ride.completed"}`), signature, time.Minute))
+	assert.False(t, Verify(testSecret, now+1, payload, signature, time.Minute))
+
+	old := time.Now().Add(-time.Hour).Unix()
+	assert.False(t, Verify(testSecret, old, payload, Sign(testSecret, old, payload), time.Minute))
+	assert.True(t, Verify(testSecret, old, payload, Sign(testSecret, old, payload), 0))
+}